@@ -0,0 +1,156 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/harness/ff-proxy/v2/domain"
+	"github.com/harness/ff-proxy/v2/log"
+)
+
+type contextKey string
+
+const (
+	sourceKey contextKey = "auditSource"
+	eventKey  contextKey = "auditEvent"
+)
+
+// WithSource returns a context that records the source of any config changes
+// applied with it. The event is the SSE event that triggered the change and
+// can be empty.
+func WithSource(ctx context.Context, source domain.AuditSource, event string) context.Context {
+	ctx = context.WithValue(ctx, sourceKey, source)
+	return context.WithValue(ctx, eventKey, event)
+}
+
+// SourceFromContext returns the source and event set by WithSource. If the
+// source hasn't been set it defaults to a poll.
+func SourceFromContext(ctx context.Context) (domain.AuditSource, string) {
+	source, ok := ctx.Value(sourceKey).(domain.AuditSource)
+	if !ok {
+		source = domain.AuditSourcePoll
+	}
+
+	event, _ := ctx.Value(eventKey).(string)
+	return source, event
+}
+
+// Sink is where AuditRecords are written to
+type Sink interface {
+	Write(r domain.AuditRecord) error
+}
+
+// Recorder records the config changes that the Proxy applies
+type Recorder interface {
+	Record(ctx context.Context, records ...domain.AuditRecord)
+}
+
+// Auditor is a Recorder that writes AuditRecords to one or more Sinks
+type Auditor struct {
+	log   log.Logger
+	sinks []Sink
+	now   func() time.Time
+}
+
+// NewAuditor creates an Auditor that writes to the sinks
+func NewAuditor(l log.Logger, sinks ...Sink) Auditor {
+	l = l.With("component", "Auditor")
+	return Auditor{log: l, sinks: sinks, now: time.Now}
+}
+
+// Record sets the time and source of the records and writes them to each sink.
+// Failing to write a record is logged rather than returned so that auditing
+// never stops a config change from being applied.
+func (a Auditor) Record(ctx context.Context, records ...domain.AuditRecord) {
+	source, event := SourceFromContext(ctx)
+	t := a.now().UTC()
+
+	for _, r := range records {
+		r.Time = t
+		if r.Source == "" {
+			r.Source = source
+			r.Event = event
+		}
+
+		for _, s := range a.sinks {
+			if err := s.Write(r); err != nil {
+				a.log.Error("failed to write audit record", "kind", r.Kind, "environment", r.Environment, "identifier", r.Identifier, "err", err)
+			}
+		}
+	}
+}
+
+// LogSink writes AuditRecords to a dedicated log stream. Each record is
+// logged with a 'stream=audit' field so they can be routed separately from
+// the Proxy's other logs.
+type LogSink struct {
+	log log.Logger
+}
+
+// NewLogSink creates a LogSink
+func NewLogSink(l log.Logger) LogSink {
+	return LogSink{log: l.With("stream", "audit")}
+}
+
+// Write logs the record
+func (l LogSink) Write(r domain.AuditRecord) error {
+	keyvals := []interface{}{
+		"time", r.Time.Format(time.RFC3339Nano),
+		"source", r.Source,
+		"action", r.Action,
+		"kind", r.Kind,
+		"environment", r.Environment,
+		"identifier", r.Identifier,
+	}
+	if r.Event != "" {
+		keyvals = append(keyvals, "event", r.Event)
+	}
+	if r.OldVersion != nil {
+		keyvals = append(keyvals, "oldVersion", *r.OldVersion)
+	}
+	if r.NewVersion != nil {
+		keyvals = append(keyvals, "newVersion", *r.NewVersion)
+	}
+	if r.Diff != "" {
+		keyvals = append(keyvals, "diff", r.Diff)
+	}
+
+	l.log.Info("config change applied", keyvals...)
+	return nil
+}
+
+// FileSink appends AuditRecords to a file as JSON lines
+type FileSink struct {
+	mtx  *sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// NewFileSink opens the file at path for appending, creating it if it doesn't exist
+func NewFileSink(path string) (FileSink, error) {
+	// #nosec G304
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return FileSink{}, fmt.Errorf("failed to open audit log file: %s", err)
+	}
+
+	return FileSink{mtx: &sync.Mutex{}, file: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Write appends the record to the file
+func (f FileSink) Write(r domain.AuditRecord) error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return f.enc.Encode(r)
+}
+
+// Close closes the file
+func (f FileSink) Close() error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return f.file.Close()
+}
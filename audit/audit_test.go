@@ -0,0 +1,148 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/harness/ff-proxy/v2/domain"
+	"github.com/harness/ff-proxy/v2/log"
+)
+
+func int64Ptr(i int64) *int64 { return &i }
+
+func TestAuditor_Record(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := map[string]struct {
+		ctx      context.Context
+		expected domain.AuditRecord
+	}{
+		"Given I record a change with no source in the context": {
+			ctx: context.Background(),
+			expected: domain.AuditRecord{
+				Time:        now,
+				Source:      domain.AuditSourcePoll,
+				Action:      domain.AuditActionCreate,
+				Kind:        domain.AuditKindFlag,
+				Environment: "env-123",
+				Identifier:  "flag1",
+			},
+		},
+		"Given I record a change with an SSE source in the context": {
+			ctx: WithSource(context.Background(), domain.AuditSourceSSE, "patch"),
+			expected: domain.AuditRecord{
+				Time:        now,
+				Source:      domain.AuditSourceSSE,
+				Event:       "patch",
+				Action:      domain.AuditActionCreate,
+				Kind:        domain.AuditKindFlag,
+				Environment: "env-123",
+				Identifier:  "flag1",
+			},
+		},
+	}
+
+	for desc, tc := range testCases {
+		tc := tc
+		t.Run(desc, func(t *testing.T) {
+			history := NewHistory(10)
+			a := NewAuditor(log.NewNoOpLogger(), history)
+			a.now = func() time.Time { return now }
+
+			a.Record(tc.ctx, domain.AuditRecord{
+				Action:      domain.AuditActionCreate,
+				Kind:        domain.AuditKindFlag,
+				Environment: "env-123",
+				Identifier:  "flag1",
+			})
+
+			assert.Equal(t, []domain.AuditRecord{tc.expected}, history.Recent(domain.AuditHistoryRequest{}))
+		})
+	}
+}
+
+func TestHistory_Recent(t *testing.T) {
+	history := NewHistory(3)
+	for _, id := range []string{"flag1", "flag2", "flag3", "flag4"} {
+		env := "env-123"
+		if id == "flag3" {
+			env = "env-456"
+		}
+		assert.Nil(t, history.Write(domain.AuditRecord{Environment: env, Identifier: id}))
+	}
+
+	testCases := map[string]struct {
+		req      domain.AuditHistoryRequest
+		expected []string
+	}{
+		"Given I have no filters": {
+			req:      domain.AuditHistoryRequest{},
+			expected: []string{"flag4", "flag3", "flag2"},
+		},
+		"Given I filter by environment": {
+			req:      domain.AuditHistoryRequest{Environment: "env-123"},
+			expected: []string{"flag4", "flag2"},
+		},
+		"Given I filter by identifier": {
+			req:      domain.AuditHistoryRequest{Identifier: "flag3"},
+			expected: []string{"flag3"},
+		},
+		"Given I set a limit": {
+			req:      domain.AuditHistoryRequest{Limit: 1},
+			expected: []string{"flag4"},
+		},
+		"Given I filter by a record that has been replaced": {
+			req:      domain.AuditHistoryRequest{Identifier: "flag1"},
+			expected: []string{},
+		},
+	}
+
+	for desc, tc := range testCases {
+		tc := tc
+		t.Run(desc, func(t *testing.T) {
+			actual := []string{}
+			for _, r := range history.Recent(tc.req) {
+				actual = append(actual, r.Identifier)
+			}
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestFileSink_Write(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	sink, err := NewFileSink(path)
+	require.NoError(t, err)
+
+	records := []domain.AuditRecord{
+		{Source: domain.AuditSourceStartup, Action: domain.AuditActionCreate, Kind: domain.AuditKindFlag, Identifier: "flag1", NewVersion: int64Ptr(1)},
+		{Source: domain.AuditSourceSSE, Action: domain.AuditActionDelete, Kind: domain.AuditKindFlag, Identifier: "flag1", OldVersion: int64Ptr(1)},
+	}
+	for _, r := range records {
+		require.NoError(t, sink.Write(r))
+	}
+	require.NoError(t, sink.Close())
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	actual := []domain.AuditRecord{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		r := domain.AuditRecord{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &r))
+		actual = append(actual, r)
+	}
+
+	assert.Equal(t, records, actual)
+}
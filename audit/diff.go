@@ -0,0 +1,187 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/harness/ff-proxy/v2/domain"
+)
+
+// ignoredFields are the fields that change every time an asset is modified
+// so they don't tell us anything in a diff summary
+var ignoredFields = map[string]struct{}{
+	"version":    {},
+	"modifiedAt": {},
+}
+
+// FlagChanges compares the flags in an environment before and after a config
+// change and returns a record for each flag that was created, patched or deleted
+func FlagChanges(env string, old []domain.FeatureFlag, new []domain.FeatureFlag) []domain.AuditRecord {
+	oldFlags := make(map[string]interface{}, len(old))
+	oldVersions := make(map[string]*int64, len(old))
+	for _, f := range old {
+		oldFlags[f.Feature] = f
+		oldVersions[f.Feature] = f.Version
+	}
+
+	newFlags := make(map[string]interface{}, len(new))
+	newVersions := make(map[string]*int64, len(new))
+	for _, f := range new {
+		newFlags[f.Feature] = f
+		newVersions[f.Feature] = f.Version
+	}
+
+	return changes(domain.AuditKindFlag, env, oldFlags, oldVersions, newFlags, newVersions)
+}
+
+// SegmentChanges compares the segments in an environment before and after a
+// config change and returns a record for each segment that was created, patched
+// or deleted
+func SegmentChanges(env string, old []domain.Segment, new []domain.Segment) []domain.AuditRecord {
+	oldSegments := make(map[string]interface{}, len(old))
+	oldVersions := make(map[string]*int64, len(old))
+	for _, s := range old {
+		oldSegments[s.Identifier] = s
+		oldVersions[s.Identifier] = s.Version
+	}
+
+	newSegments := make(map[string]interface{}, len(new))
+	newVersions := make(map[string]*int64, len(new))
+	for _, s := range new {
+		newSegments[s.Identifier] = s
+		newVersions[s.Identifier] = s.Version
+	}
+
+	return changes(domain.AuditKindSegment, env, oldSegments, oldVersions, newSegments, newVersions)
+}
+
+func changes(kind string, env string, old map[string]interface{}, oldVersions map[string]*int64, new map[string]interface{}, newVersions map[string]*int64) []domain.AuditRecord {
+	records := []domain.AuditRecord{}
+
+	for id, n := range new {
+		o, ok := old[id]
+		if !ok {
+			records = append(records, domain.AuditRecord{
+				Action:      domain.AuditActionCreate,
+				Kind:        kind,
+				Environment: env,
+				Identifier:  id,
+				NewVersion:  newVersions[id],
+			})
+			continue
+		}
+
+		diff := Diff(o, n)
+		if diff == "" && reflect.DeepEqual(oldVersions[id], newVersions[id]) {
+			continue
+		}
+
+		records = append(records, domain.AuditRecord{
+			Action:      domain.AuditActionPatch,
+			Kind:        kind,
+			Environment: env,
+			Identifier:  id,
+			OldVersion:  oldVersions[id],
+			NewVersion:  newVersions[id],
+			Diff:        diff,
+		})
+	}
+
+	for id := range old {
+		if _, ok := new[id]; ok {
+			continue
+		}
+
+		records = append(records, domain.AuditRecord{
+			Action:      domain.AuditActionDelete,
+			Kind:        kind,
+			Environment: env,
+			Identifier:  id,
+			OldVersion:  oldVersions[id],
+		})
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Identifier < records[j].Identifier
+	})
+	return records
+}
+
+// Diff returns a summary of the top level fields that differ between two
+// assets, e.g. 'state changed from off to on; rules changed'. Fields with
+// simple values include the old and new value while fields with nested values
+// just say they changed.
+func Diff(old interface{}, new interface{}) string {
+	oldFields, err := fields(old)
+	if err != nil {
+		return ""
+	}
+
+	newFields, err := fields(new)
+	if err != nil {
+		return ""
+	}
+
+	names := make([]string, 0, len(newFields))
+	for name := range oldFields {
+		names = append(names, name)
+	}
+	for name := range newFields {
+		if _, ok := oldFields[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	summary := []string{}
+	for _, name := range names {
+		if _, ok := ignoredFields[name]; ok {
+			continue
+		}
+
+		o, n := oldFields[name], newFields[name]
+		if reflect.DeepEqual(o, n) {
+			continue
+		}
+
+		if isScalar(o) && isScalar(n) {
+			summary = append(summary, fmt.Sprintf("%s changed from %s to %s", name, format(o), format(n)))
+			continue
+		}
+		summary = append(summary, fmt.Sprintf("%s changed", name))
+	}
+
+	return strings.Join(summary, "; ")
+}
+
+func fields(v interface{}) (map[string]interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func isScalar(v interface{}) bool {
+	switch v.(type) {
+	case nil, string, float64, bool:
+		return true
+	default:
+		return false
+	}
+}
+
+func format(v interface{}) string {
+	if v == nil {
+		return "<none>"
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,65 @@
+package audit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/harness/ff-proxy/v2/domain"
+	clientgen "github.com/harness/ff-proxy/v2/gen/client"
+)
+
+func TestFlagChanges(t *testing.T) {
+	flagOn := domain.FeatureFlag{Feature: "flag1", State: "on", Version: int64Ptr(2)}
+	flagOff := domain.FeatureFlag{Feature: "flag1", State: "off", Version: int64Ptr(1)}
+	flagWithRules := domain.FeatureFlag{Feature: "flag1", State: "off", Version: int64Ptr(2), Rules: &[]clientgen.ServingRule{{Priority: 1}}}
+	flag2 := domain.FeatureFlag{Feature: "flag2", State: "on", Version: int64Ptr(1)}
+
+	testCases := map[string]struct {
+		old      []domain.FeatureFlag
+		new      []domain.FeatureFlag
+		expected []domain.AuditRecord
+	}{
+		"Given I have no changes": {
+			old:      []domain.FeatureFlag{flagOff, flag2},
+			new:      []domain.FeatureFlag{flagOff, flag2},
+			expected: []domain.AuditRecord{},
+		},
+		"Given a flag has been created": {
+			old: []domain.FeatureFlag{flagOff},
+			new: []domain.FeatureFlag{flagOff, flag2},
+			expected: []domain.AuditRecord{
+				{Action: domain.AuditActionCreate, Kind: domain.AuditKindFlag, Environment: "env-123", Identifier: "flag2", NewVersion: int64Ptr(1)},
+			},
+		},
+		"Given a flag has been deleted": {
+			old: []domain.FeatureFlag{flagOff, flag2},
+			new: []domain.FeatureFlag{flagOff},
+			expected: []domain.AuditRecord{
+				{Action: domain.AuditActionDelete, Kind: domain.AuditKindFlag, Environment: "env-123", Identifier: "flag2", OldVersion: int64Ptr(1)},
+			},
+		},
+		"Given a flag has been turned on": {
+			old: []domain.FeatureFlag{flagOff},
+			new: []domain.FeatureFlag{flagOn},
+			expected: []domain.AuditRecord{
+				{Action: domain.AuditActionPatch, Kind: domain.AuditKindFlag, Environment: "env-123", Identifier: "flag1", OldVersion: int64Ptr(1), NewVersion: int64Ptr(2), Diff: "state changed from off to on"},
+			},
+		},
+		"Given a flag's rules have changed": {
+			old: []domain.FeatureFlag{flagOff},
+			new: []domain.FeatureFlag{flagWithRules},
+			expected: []domain.AuditRecord{
+				{Action: domain.AuditActionPatch, Kind: domain.AuditKindFlag, Environment: "env-123", Identifier: "flag1", OldVersion: int64Ptr(1), NewVersion: int64Ptr(2), Diff: "rules changed"},
+			},
+		},
+	}
+
+	for desc, tc := range testCases {
+		tc := tc
+		t.Run(desc, func(t *testing.T) {
+			actual := FlagChanges("env-123", tc.old, tc.new)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package audit
+
+import (
+	"sync"
+
+	"github.com/harness/ff-proxy/v2/domain"
+)
+
+// History is a Sink that keeps the most recent AuditRecords in memory so they
+// can be queried
+type History struct {
+	mtx     *sync.RWMutex
+	records []domain.AuditRecord
+	next    int
+	full    bool
+}
+
+// NewHistory creates a History that keeps up to size records
+func NewHistory(size int) *History {
+	if size < 1 {
+		size = 1
+	}
+
+	return &History{
+		mtx:     &sync.RWMutex{},
+		records: make([]domain.AuditRecord, size),
+	}
+}
+
+// Write adds the record to the History, replacing the oldest record if it's full
+func (h *History) Write(r domain.AuditRecord) error {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	h.records[h.next] = r
+	h.next = (h.next + 1) % len(h.records)
+	if h.next == 0 {
+		h.full = true
+	}
+	return nil
+}
+
+// Recent returns up to req.Limit records that match the request's environment
+// and identifier, newest first. A limit of zero or less returns every match.
+func (h *History) Recent(req domain.AuditHistoryRequest) []domain.AuditRecord {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+
+	count := h.next
+	if h.full {
+		count = len(h.records)
+	}
+
+	results := []domain.AuditRecord{}
+	for i := 1; i <= count; i++ {
+		r := h.records[(h.next-i+len(h.records))%len(h.records)]
+
+		if req.Environment != "" && r.Environment != req.Environment {
+			continue
+		}
+		if req.Identifier != "" && r.Identifier != req.Identifier {
+			continue
+		}
+
+		results = append(results, r)
+		if req.Limit > 0 && len(results) == req.Limit {
+			break
+		}
+	}
+	return results
+}
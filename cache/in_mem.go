@@ -3,6 +3,7 @@ package cache
 import (
 	"context"
 	"fmt"
+	"path"
 	"reflect"
 	"strings"
 	"sync"
@@ -19,10 +20,19 @@ type MemCache struct {
 	data map[string][]byte
 }
 
-// Scan all the keys for given key
-func (m MemCache) Scan(_ context.Context, _ string) (map[string]string, error) {
-	//TODO implement equivalent.
-	return map[string]string{}, nil
+// Scan returns a map of keys that match the pattern, like the KeyValCache the
+// pattern can appear anywhere in the key
+func (m MemCache) Scan(ctx context.Context, key string) (map[string]string, error) {
+	keys, err := m.Keys(ctx, "*"+key+"*")
+	if err != nil {
+		return map[string]string{}, err
+	}
+
+	scan := make(map[string]string, len(keys))
+	for _, k := range keys {
+		scan[k] = ""
+	}
+	return scan, nil
 }
 
 // NewMemCache creates an initialised MemCache
@@ -69,7 +79,8 @@ func (m MemCache) Delete(_ context.Context, key string) error {
 	return nil
 }
 
-// Keys returns a list of keys that match the pattern
+// Keys returns a list of keys that match the pattern. Patterns can either be a
+// prefix or use the same '*' wildcards as the redis KEYS command
 func (m MemCache) Keys(_ context.Context, key string) ([]string, error) {
 	m.Lock()
 	defer m.Unlock()
@@ -81,6 +92,11 @@ func (m MemCache) Keys(_ context.Context, key string) ([]string, error) {
 		s := k.String()
 		if strings.HasPrefix(s, key) {
 			results = append(results, s)
+			continue
+		}
+
+		if matched, _ := path.Match(key, s); matched {
+			results = append(results, s)
 		}
 	}
 
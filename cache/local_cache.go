@@ -0,0 +1,398 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/harness/ff-proxy/v2/domain"
+	"github.com/harness/ff-proxy/v2/log"
+)
+
+const (
+	invalidatedByEvent      = "event"
+	invalidatedByChecksum   = "checksum"
+	invalidatedByDisconnect = "disconnect"
+	invalidatedByWrite      = "write"
+)
+
+// LocalCache is a Cache decorator for read replicas that keeps a local, size
+// bounded copy of the flag, segment and generation keys it reads so requests
+// can be served without a round trip to redis. An environment's entries are
+// dropped when an SSE event for it is forwarded from the Primary, and a
+// checksum of each environment is periodically compared with redis to catch
+// any events that were missed.
+type LocalCache struct {
+	Cache
+	log        log.Logger
+	maxEntries int
+	metrics    localCacheMetrics
+
+	mx           *sync.Mutex
+	entries      *list.List
+	keys         map[string]*list.Element
+	envs         map[string]map[string]*list.Element
+	fingerprints map[string]string
+	onInvalidate func(envID string)
+
+	// epoch is incremented on every invalidation so that values read from
+	// redis while an environment was being invalidated don't get cached
+	epoch uint64
+}
+
+type localEntry struct {
+	env   string
+	key   string
+	value interface{}
+}
+
+// NewLocalCache creates a LocalCache that holds at most maxEntries keys
+func NewLocalCache(next Cache, l log.Logger, reg prometheus.Registerer, maxEntries int) *LocalCache {
+	return &LocalCache{
+		Cache:        next,
+		log:          l.With("component", "LocalCache"),
+		maxEntries:   maxEntries,
+		metrics:      newLocalCacheMetrics(reg),
+		mx:           &sync.Mutex{},
+		entries:      list.New(),
+		keys:         map[string]*list.Element{},
+		envs:         map[string]map[string]*list.Element{},
+		fingerprints: map[string]string{},
+	}
+}
+
+// OnInvalidate sets a func that's called with every environment that's dropped
+// from the local cache, whether it's invalidated, purged or evicted. It lets
+// anything built from the local cache be dropped at the same time.
+func (lc *LocalCache) OnInvalidate(fn func(envID string)) {
+	lc.mx.Lock()
+	defer lc.mx.Unlock()
+	lc.onInvalidate = fn
+}
+
+// Get returns the value from the local cache if it's there, otherwise it reads
+// it from the underlying cache and keeps a copy of it
+func (lc *LocalCache) Get(ctx context.Context, key string, value interface{}) error {
+	env, ok := domain.EnvIDFromConfigKey(key)
+	if !ok {
+		return lc.Cache.Get(ctx, key, value)
+	}
+
+	if lc.load(key, value) {
+		lc.metrics.hits.Inc()
+		return nil
+	}
+	lc.metrics.misses.Inc()
+
+	lc.mx.Lock()
+	epoch := lc.epoch
+	_, fingerprinted := lc.fingerprints[env]
+	lc.mx.Unlock()
+
+	// The first time we cache something for an environment we take its
+	// fingerprint before reading the value. That way what we cache is never
+	// older than the fingerprint we compare against redis later on.
+	fingerprint := ""
+	if !fingerprinted {
+		fp, err := lc.fingerprint(ctx, env)
+		if err != nil {
+			lc.log.Warn("failed to fingerprint environment, reading from cache without storing locally", "environment", env, "err", err)
+			return lc.Cache.Get(ctx, key, value)
+		}
+		fingerprint = fp
+	}
+
+	if err := lc.Cache.Get(ctx, key, value); err != nil {
+		return err
+	}
+
+	lc.store(env, key, value, epoch, fingerprint)
+	return nil
+}
+
+// Set sets the value in the underlying cache and drops the environment it
+// belongs to from the local cache
+func (lc *LocalCache) Set(ctx context.Context, key string, value interface{}) error {
+	if err := lc.Cache.Set(ctx, key, value); err != nil {
+		return err
+	}
+
+	if env, ok := domain.EnvIDFromConfigKey(key); ok {
+		lc.Invalidate(env, invalidatedByWrite)
+	}
+	return nil
+}
+
+// Delete removes the key from the underlying cache and drops the environment
+// it belongs to from the local cache
+func (lc *LocalCache) Delete(ctx context.Context, key string) error {
+	if env, ok := domain.EnvIDFromConfigKey(key); ok {
+		defer lc.Invalidate(env, invalidatedByWrite)
+	}
+	return lc.Cache.Delete(ctx, key)
+}
+
+// HandleMessage makes LocalCache implement the MessageHandler interface. It
+// drops any environments the message is for so that the next request for them
+// reads the latest config from redis.
+func (lc *LocalCache) HandleMessage(_ context.Context, msg domain.SSEMessage) error {
+	if msg.Environment != "" {
+		lc.Invalidate(msg.Environment, invalidatedByEvent)
+	}
+
+	for _, env := range msg.Environments {
+		lc.Invalidate(env, invalidatedByEvent)
+	}
+	return nil
+}
+
+// Invalidate drops everything in the local cache for an environment
+func (lc *LocalCache) Invalidate(env string, reason string) {
+	lc.mx.Lock()
+	onInvalidate := lc.onInvalidate
+	defer func() {
+		lc.mx.Unlock()
+		notifyInvalidated(onInvalidate, env)
+	}()
+
+	lc.epoch++
+	for key, e := range lc.envs[env] {
+		lc.entries.Remove(e)
+		delete(lc.keys, key)
+	}
+	delete(lc.envs, env)
+	delete(lc.fingerprints, env)
+
+	lc.metrics.invalidations.WithLabelValues(reason).Inc()
+	lc.metrics.entries.Set(float64(lc.entries.Len()))
+}
+
+// Purge drops everything from the local cache. It's used when the replica
+// disconnects from the stream the Primary forwards events on because we can't
+// know which events we missed.
+func (lc *LocalCache) Purge() {
+	lc.mx.Lock()
+	onInvalidate := lc.onInvalidate
+	envs := make([]string, 0, len(lc.envs))
+	for env := range lc.envs {
+		envs = append(envs, env)
+	}
+	defer func() {
+		lc.mx.Unlock()
+		notifyInvalidated(onInvalidate, envs...)
+	}()
+
+	lc.epoch++
+	lc.entries.Init()
+	lc.keys = map[string]*list.Element{}
+	lc.envs = map[string]map[string]*list.Element{}
+	lc.fingerprints = map[string]string{}
+
+	lc.metrics.invalidations.WithLabelValues(invalidatedByDisconnect).Inc()
+	lc.metrics.entries.Set(0)
+}
+
+// Start compares the fingerprint of each environment in the local cache with
+// redis on every interval and drops any environments that have changed. It
+// blocks until the context is cancelled.
+func (lc *LocalCache) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			lc.verify(ctx)
+		}
+	}
+}
+
+func (lc *LocalCache) verify(ctx context.Context) {
+	lc.mx.Lock()
+	fingerprints := make(map[string]string, len(lc.fingerprints))
+	for env, fp := range lc.fingerprints {
+		fingerprints[env] = fp
+	}
+	lc.mx.Unlock()
+
+	for env, fp := range fingerprints {
+		latest, err := lc.fingerprint(ctx, env)
+		if err != nil {
+			lc.log.Warn("failed to fingerprint environment", "environment", env, "err", err)
+			continue
+		}
+
+		if latest == fp {
+			continue
+		}
+
+		lc.log.Info("local cache was out of date with redis, dropping environment", "environment", env)
+		lc.Invalidate(env, invalidatedByChecksum)
+	}
+}
+
+// fingerprint returns a value that changes whenever an environment's config in
+// redis changes. It's made up of the generation pointer and the hashes that the
+// HashCache stores for the unversioned flag and segment lists, which covers
+// environments whether they're using config generations or not.
+func (lc *LocalCache) fingerprint(ctx context.Context, env string) (string, error) {
+	var gen domain.ConfigGeneration
+	if err := lc.Cache.Get(ctx, string(domain.NewGenerationKey(env)), &gen); err != nil && !errors.Is(err, domain.ErrCacheNotFound) {
+		return "", err
+	}
+
+	hashes := make([]string, 0, 2)
+	for _, key := range []string{string(domain.NewFeatureConfigsKey(env)), string(domain.NewSegmentsKey(env))} {
+		var hash string
+		if err := lc.Cache.Get(ctx, fmt.Sprintf("%s-latest", key), &hash); err != nil && !errors.Is(err, domain.ErrCacheNotFound) {
+			return "", err
+		}
+		hashes = append(hashes, hash)
+	}
+
+	return fmt.Sprintf("%d:%d:%s:%s", gen.Current, gen.UpdatedAt, hashes[0], hashes[1]), nil
+}
+
+// load copies the locally cached value for the key into value. It returns false
+// if the key isn't cached or was cached as a different type
+func (lc *LocalCache) load(key string, value interface{}) bool {
+	lc.mx.Lock()
+	defer lc.mx.Unlock()
+
+	e, ok := lc.keys[key]
+	if !ok {
+		return false
+	}
+
+	val := reflect.ValueOf(value)
+	cached := reflect.ValueOf(e.Value.(*localEntry).value)
+	if val.Kind() != reflect.Ptr || !cached.IsValid() || !cached.Type().AssignableTo(val.Elem().Type()) {
+		return false
+	}
+
+	val.Elem().Set(cached)
+	lc.entries.MoveToFront(e)
+	return true
+}
+
+func (lc *LocalCache) store(env string, key string, value interface{}, epoch uint64, fingerprint string) {
+	val := reflect.ValueOf(value)
+	if val.Kind() != reflect.Ptr {
+		return
+	}
+
+	lc.mx.Lock()
+	onInvalidate := lc.onInvalidate
+	var evicted []string
+	defer func() {
+		lc.mx.Unlock()
+		notifyInvalidated(onInvalidate, evicted...)
+	}()
+
+	// Don't cache the value if the environment was invalidated while we were
+	// reading it or we don't have a fingerprint to check it against
+	if lc.epoch != epoch {
+		return
+	}
+	if _, ok := lc.fingerprints[env]; !ok {
+		if fingerprint == "" {
+			return
+		}
+		lc.fingerprints[env] = fingerprint
+	}
+
+	if e, ok := lc.keys[key]; ok {
+		e.Value.(*localEntry).value = val.Elem().Interface()
+		lc.entries.MoveToFront(e)
+		return
+	}
+
+	e := lc.entries.PushFront(&localEntry{env: env, key: key, value: val.Elem().Interface()})
+	lc.keys[key] = e
+	if _, ok := lc.envs[env]; !ok {
+		lc.envs[env] = map[string]*list.Element{}
+	}
+	lc.envs[env][key] = e
+
+	for lc.entries.Len() > lc.maxEntries {
+		if env, dropped := lc.evict(lc.entries.Back()); dropped {
+			evicted = append(evicted, env)
+		}
+	}
+	lc.metrics.entries.Set(float64(lc.entries.Len()))
+}
+
+// evict removes an entry from the local cache. It returns the entry's
+// environment and true if it was the last entry for that environment.
+func (lc *LocalCache) evict(e *list.Element) (string, bool) {
+	entry := e.Value.(*localEntry)
+	lc.entries.Remove(e)
+	delete(lc.keys, entry.key)
+	lc.metrics.evictions.Inc()
+
+	delete(lc.envs[entry.env], entry.key)
+	if len(lc.envs[entry.env]) > 0 {
+		return entry.env, false
+	}
+
+	// Once an environment has no entries left we stop checking its
+	// fingerprint, so anything built from it has to be dropped too
+	delete(lc.envs, entry.env)
+	delete(lc.fingerprints, entry.env)
+	return entry.env, true
+}
+
+func notifyInvalidated(fn func(envID string), envs ...string) {
+	if fn == nil {
+		return
+	}
+	for _, env := range envs {
+		fn(env)
+	}
+}
+
+type localCacheMetrics struct {
+	hits          prometheus.Counter
+	misses        prometheus.Counter
+	evictions     prometheus.Counter
+	invalidations *prometheus.CounterVec
+	entries       prometheus.Gauge
+}
+
+func newLocalCacheMetrics(reg prometheus.Registerer) localCacheMetrics {
+	m := localCacheMetrics{
+		hits: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "ff_proxy_replica_local_cache_hit",
+			Help: "Tracks the number of reads the read replica served from its local cache without going to redis",
+		}),
+		misses: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "ff_proxy_replica_local_cache_miss",
+			Help: "Tracks the number of reads the read replica couldn't serve from its local cache and had to go to redis for",
+		}),
+		evictions: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "ff_proxy_replica_local_cache_evictions",
+			Help: "Tracks the number of keys evicted from the read replica's local cache because it was full",
+		}),
+		invalidations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ff_proxy_replica_local_cache_invalidations",
+			Help: "Tracks the number of times the read replica dropped an environment from its local cache and why",
+		},
+			[]string{"reason"},
+		),
+		entries: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "ff_proxy_replica_local_cache_entries",
+			Help: "The number of keys in the read replica's local cache",
+		}),
+	}
+
+	reg.MustRegister(m.hits, m.misses, m.evictions, m.invalidations, m.entries)
+	return m
+}
@@ -0,0 +1,144 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/harness/ff-proxy/v2/domain"
+	"github.com/harness/ff-proxy/v2/log"
+)
+
+// countingCache counts the reads that make it through to the underlying cache
+type countingCache struct {
+	Cache
+	gets map[string]int
+}
+
+func (c *countingCache) Get(ctx context.Context, key string, value interface{}) error {
+	c.gets[key]++
+	return c.Cache.Get(ctx, key, value)
+}
+
+func newTestLocalCache(maxEntries int) (*LocalCache, *countingCache) {
+	redis := &countingCache{Cache: NewHashCache(NewMemCache(), 1*time.Minute, 1*time.Minute), gets: map[string]int{}}
+	return NewLocalCache(redis, log.NoOpLogger{}, prometheus.NewRegistry(), maxEntries), redis
+}
+
+func getFlags(t *testing.T, c Cache, env string) []domain.FeatureFlag {
+	var flags []domain.FeatureFlag
+	assert.Nil(t, c.Get(context.Background(), string(domain.NewFeatureConfigsKey(env)), &flags))
+	return flags
+}
+
+func TestLocalCache_Get(t *testing.T) {
+	ctx := context.Background()
+	lc, redis := newTestLocalCache(10)
+	key := string(domain.NewFeatureConfigsKey("123"))
+
+	t.Log("Given I have flags in redis")
+	assert.Nil(t, redis.Set(ctx, key, []domain.FeatureFlag{{Feature: "foo"}}))
+
+	t.Log("When I read them twice")
+	assert.Equal(t, []domain.FeatureFlag{{Feature: "foo"}}, getFlags(t, lc, "123"))
+	assert.Equal(t, []domain.FeatureFlag{{Feature: "foo"}}, getFlags(t, lc, "123"))
+
+	t.Log("Then redis is only read from once")
+	assert.Equal(t, 1, redis.gets[key])
+
+	t.Log("And keys that don't belong to an environment aren't cached")
+	assert.Nil(t, redis.Set(ctx, "pinned-generations", map[string]domain.ConfigGeneration{}))
+	var pinned map[string]domain.ConfigGeneration
+	assert.Nil(t, lc.Get(ctx, "pinned-generations", &pinned))
+	assert.Nil(t, lc.Get(ctx, "pinned-generations", &pinned))
+	assert.Equal(t, 2, redis.gets["pinned-generations"])
+}
+
+func TestLocalCache_HandleMessage(t *testing.T) {
+	ctx := context.Background()
+	lc, redis := newTestLocalCache(10)
+
+	t.Log("Given I have flags for two environments in the local cache")
+	assert.Nil(t, redis.Set(ctx, string(domain.NewFeatureConfigsKey("123")), []domain.FeatureFlag{{Feature: "foo"}}))
+	assert.Nil(t, redis.Set(ctx, string(domain.NewFeatureConfigsKey("456")), []domain.FeatureFlag{{Feature: "foo"}}))
+	getFlags(t, lc, "123")
+	getFlags(t, lc, "456")
+
+	t.Log("When the flags change in redis and an event for one of the environments arrives")
+	assert.Nil(t, redis.Set(ctx, string(domain.NewFeatureConfigsKey("123")), []domain.FeatureFlag{{Feature: "bar"}}))
+	assert.Nil(t, redis.Set(ctx, string(domain.NewFeatureConfigsKey("456")), []domain.FeatureFlag{{Feature: "bar"}}))
+	assert.Nil(t, lc.HandleMessage(ctx, domain.SSEMessage{Event: domain.EventPatch, Domain: domain.MsgDomainFeature, Environment: "123"}))
+
+	t.Log("Then the environment the event was for is read from redis")
+	assert.Equal(t, []domain.FeatureFlag{{Feature: "bar"}}, getFlags(t, lc, "123"))
+
+	t.Log("And the other environment is still served from the local cache")
+	assert.Equal(t, []domain.FeatureFlag{{Feature: "foo"}}, getFlags(t, lc, "456"))
+
+	t.Log("When an event for several environments arrives")
+	assert.Nil(t, lc.HandleMessage(ctx, domain.SSEMessage{Environments: []string{"456"}}))
+
+	t.Log("Then they're read from redis")
+	assert.Equal(t, []domain.FeatureFlag{{Feature: "bar"}}, getFlags(t, lc, "456"))
+}
+
+func TestLocalCache_Verify(t *testing.T) {
+	ctx := context.Background()
+	lc, redis := newTestLocalCache(10)
+
+	t.Log("Given I have flags for two environments in the local cache")
+	assert.Nil(t, redis.Set(ctx, string(domain.NewFeatureConfigsKey("123")), []domain.FeatureFlag{{Feature: "foo"}}))
+	assert.Nil(t, redis.Set(ctx, string(domain.NewGenerationKey("456")), domain.ConfigGeneration{Current: 1}))
+	assert.Nil(t, redis.Set(ctx, string(domain.NewFeatureConfigsKey("456-gen-1")), []domain.FeatureFlag{{Feature: "foo"}}))
+	getFlags(t, lc, "123")
+	getFlags(t, lc, "456-gen-1")
+
+	t.Log("When they change in redis without an event arriving")
+	assert.Nil(t, redis.Set(ctx, string(domain.NewFeatureConfigsKey("123")), []domain.FeatureFlag{{Feature: "bar"}}))
+	assert.Nil(t, redis.Set(ctx, string(domain.NewGenerationKey("456")), domain.ConfigGeneration{Current: 2}))
+	assert.Nil(t, redis.Set(ctx, string(domain.NewFeatureConfigsKey("456-gen-1")), []domain.FeatureFlag{{Feature: "bar"}}))
+
+	t.Log("Then the local cache still serves the old flags")
+	assert.Equal(t, []domain.FeatureFlag{{Feature: "foo"}}, getFlags(t, lc, "123"))
+
+	t.Log("When the local cache is verified against redis")
+	lc.verify(ctx)
+
+	t.Log("Then both environments are read from redis")
+	assert.Equal(t, []domain.FeatureFlag{{Feature: "bar"}}, getFlags(t, lc, "123"))
+	assert.Equal(t, []domain.FeatureFlag{{Feature: "bar"}}, getFlags(t, lc, "456-gen-1"))
+}
+
+func TestLocalCache_Evict(t *testing.T) {
+	ctx := context.Background()
+	lc, redis := newTestLocalCache(2)
+
+	dropped := []string{}
+	lc.OnInvalidate(func(env string) { dropped = append(dropped, env) })
+
+	for _, env := range []string{"1", "2", "3"} {
+		assert.Nil(t, redis.Set(ctx, string(domain.NewFeatureConfigsKey(env)), []domain.FeatureFlag{{Feature: env}}))
+	}
+
+	t.Log("Given I read more keys than the local cache can hold")
+	getFlags(t, lc, "1")
+	getFlags(t, lc, "2")
+	getFlags(t, lc, "1")
+	getFlags(t, lc, "3")
+
+	t.Log("Then the least recently used key is evicted")
+	assert.Equal(t, 2, lc.entries.Len())
+	_, ok := lc.keys[string(domain.NewFeatureConfigsKey("2"))]
+	assert.False(t, ok)
+	_, ok = lc.fingerprints["2"]
+	assert.False(t, ok)
+
+	t.Log("And the environment it belonged to is reported as dropped")
+	assert.Equal(t, []string{"2"}, dropped)
+
+	getFlags(t, lc, "1")
+	assert.Equal(t, 1, redis.gets[string(domain.NewFeatureConfigsKey("1"))])
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/harness/ff-proxy/v2/audit"
 	"github.com/harness/ff-proxy/v2/domain"
 	"github.com/harness/ff-proxy/v2/log"
 )
@@ -15,8 +16,21 @@ var (
 
 	// ErrUnexpectedEventType is the error returned when an SSE message has an event type we aren't expecting
 	ErrUnexpectedEventType = errors.New("unexpected event type")
+
+	// ErrGenerationsDisabled is the error returned when trying to pin an
+	// environment when config generations aren't enabled
+	ErrGenerationsDisabled = errors.New("config generations aren't enabled")
 )
 
+// generations is the interface for pinning an environment to one of the
+// generations of its config that are being kept
+type generations interface {
+	Get(ctx context.Context, envID string) (domain.ConfigGeneration, error)
+	Latest(ctx context.Context, envID string) context.Context
+	Pin(ctx context.Context, envID string, generation int64) (domain.ConfigGeneration, error)
+	Release(ctx context.Context, envID string) (domain.ConfigGeneration, error)
+}
+
 type config interface {
 	// FetchAndPopulate authenticates, fetches and populates the config.
 	FetchAndPopulate(ctx context.Context, inventoryRepo domain.InventoryRepo, authRepo domain.AuthRepo, flagRepo domain.FlagRepo, segmentRepo domain.SegmentRepo) error
@@ -53,16 +67,59 @@ type Refresher struct {
 	authRepo          domain.AuthRepo
 	flagRepo          domain.FlagRepo
 	segmentRepo       domain.SegmentRepo
+	audit             audit.Recorder
+	generations       generations
+	notifier          domain.MessageHandler
+}
+
+// WithAuditRecorder sets the Recorder that the Refresher records the config
+// changes it applies with
+func WithAuditRecorder(r audit.Recorder) func(s *Refresher) {
+	return func(s *Refresher) {
+		s.audit = r
+	}
+}
+
+// WithGenerations lets the Refresher pin environments to a previous generation
+// of their config. Flag and segment events for pinned environments are still
+// applied but they're written as new generations that aren't served until the
+// environment is released.
+func WithGenerations(g generations) func(s *Refresher) {
+	return func(s *Refresher) {
+		s.generations = g
+	}
+}
+
+// WithNotifier sets the MessageHandler the Refresher uses to tell SDKs and read
+// replicas about the flags and segments that change when an environment is
+// pinned or released
+func WithNotifier(n domain.MessageHandler) func(s *Refresher) {
+	return func(s *Refresher) {
+		s.notifier = n
+	}
 }
 
 // NewRefresher creates a Refresher
-func NewRefresher(l log.Logger, config config, client domain.ClientService, inventory domain.InventoryRepo, authRepo domain.AuthRepo, flagRepo domain.FlagRepo, segmentRepo domain.SegmentRepo) Refresher {
+func NewRefresher(l log.Logger, config config, client domain.ClientService, inventory domain.InventoryRepo, authRepo domain.AuthRepo, flagRepo domain.FlagRepo, segmentRepo domain.SegmentRepo, opts ...func(s *Refresher)) Refresher {
 	l = l.With("component", "Refresher")
-	return Refresher{log: l, config: config, clientService: client, inventory: inventory, authRepo: authRepo, flagRepo: flagRepo, segmentRepo: segmentRepo}
+	r := Refresher{log: l, config: config, clientService: client, inventory: inventory, authRepo: authRepo, flagRepo: flagRepo, segmentRepo: segmentRepo}
+
+	for _, opt := range opts {
+		opt(&r)
+	}
+	return r
 }
 
 // HandleMessage makes Refresher implement the MessageHandler interface
 func (s Refresher) HandleMessage(ctx context.Context, msg domain.SSEMessage) error {
+	ctx = audit.WithSource(ctx, domain.AuditSourceSSE, msg.Event)
+
+	// Changes for pinned environments need to be made on top of the latest
+	// generation rather than the one that's being served
+	if s.generations != nil && msg.Environment != "" {
+		ctx = s.generations.Latest(ctx, msg.Environment)
+	}
+
 	switch msg.Domain {
 	case domain.MsgDomainFeature:
 		return s.handleFeatureMessage(ctx, msg)
@@ -183,10 +240,24 @@ func (s Refresher) handleAddEnvironmentEvent(ctx context.Context, environments [
 			return err
 		}
 
+		oldFlags := s.currentFlags(ctx, env)
+		oldSegments := s.currentSegments(ctx, env)
+
 		s.config.SetProxyConfig(proxyConfig)
 		if err := s.config.Populate(ctx, s.authRepo, s.flagRepo, s.segmentRepo); err != nil {
 			return err
 		}
+
+		s.record(ctx, domain.AuditRecord{Action: domain.AuditActionCreate, Kind: domain.AuditKindEnvironment, Environment: env})
+		for _, cfg := range proxyConfig {
+			for _, e := range cfg.Environments {
+				if e.ID.String() != env {
+					continue
+				}
+				s.record(ctx, audit.FlagChanges(env, oldFlags, e.FeatureConfigs)...)
+				s.record(ctx, audit.SegmentChanges(env, oldSegments, e.Segments)...)
+			}
+		}
 		// update key inventory for environment.
 		if err := s.inventory.Patch(ctx, s.config.Key(), func(assets map[string]string) (map[string]string, error) {
 			newAssets, err := s.inventory.BuildAssetListFromConfig(proxyConfig)
@@ -212,6 +283,16 @@ func (s Refresher) handleRemoveEnvironmentEvent(ctx context.Context, environment
 	for _, env := range environments {
 		s.log.Debug("removing entries for env", "environment", env)
 
+		oldFlags := s.currentFlags(ctx, env)
+		oldSegments := s.currentSegments(ctx, env)
+
+		// Grab the keys we are about to delete before they're removed from
+		// the repos, otherwise we can't find the environment's api keys
+		assetsToDelete, err := s.getAssetsToBeDeletedForEnvironment(ctx, env)
+		if err != nil && !errors.Is(err, domain.ErrCacheNotFound) {
+			return err
+		}
+
 		if err := s.authRepo.RemoveAllKeysForEnvironment(ctx, env); err != nil {
 			if !errors.Is(err, domain.ErrCacheNotFound) {
 				return fmt.Errorf("failed to remove apikey configs from cache for environment %s with error %s", env, err)
@@ -230,20 +311,18 @@ func (s Refresher) handleRemoveEnvironmentEvent(ctx context.Context, environment
 			}
 		}
 
-		if err := s.removeAssets(ctx, env); err != nil {
+		if err := s.removeAssets(ctx, assetsToDelete); err != nil {
 			return err
 		}
+
+		s.record(ctx, audit.FlagChanges(env, oldFlags, nil)...)
+		s.record(ctx, audit.SegmentChanges(env, oldSegments, nil)...)
+		s.record(ctx, domain.AuditRecord{Action: domain.AuditActionDelete, Kind: domain.AuditKindEnvironment, Environment: env})
 	}
 	return nil
 }
 
-func (s Refresher) removeAssets(ctx context.Context, env string) error {
-	// lest grab the keys we are about to delete from cache.
-	assetsToDelete, err := s.getAssetsToBeDeletedForEnvironment(ctx, env)
-	if err != nil && !errors.Is(err, domain.ErrCacheNotFound) {
-		return err
-	}
-
+func (s Refresher) removeAssets(ctx context.Context, assetsToDelete map[string]string) error {
 	if err := s.inventory.Patch(ctx, s.config.Key(), func(assets map[string]string) (map[string]string, error) {
 		// remove deleted keys from the assets
 		for k := range assetsToDelete {
@@ -272,6 +351,10 @@ func (s Refresher) getAssetsToBeDeletedForEnvironment(ctx context.Context, env s
 			return map[string]string{}, err
 		}
 	}
+	if assetsToDelete == nil {
+		assetsToDelete = map[string]string{}
+	}
+
 	// insert all APIKeys to the map.
 	for _, key := range apiKeys {
 		assetsToDelete[key] = ""
@@ -297,10 +380,12 @@ func (s Refresher) handleAddAPIKeyEvent(ctx context.Context, env, apiKey string)
 	if err := s.authRepo.PatchAPIConfigForEnvironment(ctx, env, apiKey, domain.EventAPIKeyAdded); err != nil {
 		return err
 	}
+	s.record(ctx, domain.AuditRecord{Action: domain.AuditActionCreate, Kind: domain.AuditKindAPIKey, Environment: env, Identifier: apiKey})
 
-	// add key to the invetnory if does not exits
+	// add key to the invetnory if does not exits. It has to be the key the
+	// AuthRepo stored it under so that it's removed along with the environment.
 	return s.inventory.Patch(ctx, s.config.Key(), func(assets map[string]string) (map[string]string, error) {
-		apiKeyEntry := string(domain.NewAuthAPIKey(apiKey))
+		apiKeyEntry := s.authRepo.StorageKey(domain.NewAuthAPIKey(apiKey))
 		apiConfigsEntry := string(domain.NewAPIConfigsKey(env))
 		return s.addItems(assets, apiKeyEntry, apiConfigsEntry)
 	})
@@ -310,7 +395,7 @@ func (s Refresher) handleAddAPIKeyEvent(ctx context.Context, env, apiKey string)
 // handleRemoveApiKeyEvent removes apiKeys from cache as well as removes the key from the list of keys for given environment.
 func (s Refresher) handleRemoveAPIKeyEvent(ctx context.Context, env, apiKey string) error {
 	s.log.Debug("removing apikey entry for env", "environment", env)
-	apiKeyEntry := string(domain.NewAuthAPIKey(apiKey))
+	apiKeyEntry := s.authRepo.StorageKey(domain.NewAuthAPIKey(apiKey))
 	apiConfigsEntry := string(domain.NewAPIConfigsKey(env))
 	k := fmt.Sprintf("auth-key-%s", apiKey)
 
@@ -320,12 +405,12 @@ func (s Refresher) handleRemoveAPIKeyEvent(ctx context.Context, env, apiKey stri
 	if err := s.authRepo.PatchAPIConfigForEnvironment(ctx, env, apiKey, domain.EventAPIKeyRemoved); err != nil {
 		return err
 	}
+	s.record(ctx, domain.AuditRecord{Action: domain.AuditActionDelete, Kind: domain.AuditKindAPIKey, Environment: env, Identifier: apiKey})
 
 	return s.inventory.Patch(ctx, s.config.Key(), func(assets map[string]string) (map[string]string, error) {
-		_, ok := assets[apiKeyEntry]
-		if ok {
-			delete(assets, apiKeyEntry)
-		}
+		// Inventories written before keys were upgraded have the original hash
+		delete(assets, apiKeyEntry)
+		delete(assets, k)
 		if !s.inventory.KeyExists(ctx, apiConfigsEntry) {
 			delete(assets, apiConfigsEntry)
 		}
@@ -345,6 +430,8 @@ func (s Refresher) handleFetchFeatureEvent(ctx context.Context, env, id string)
 		features = append(features, domain.FeatureFlag(v))
 	}
 
+	oldFeatures := s.currentFlags(ctx, env)
+
 	// set the config
 	if err := s.flagRepo.Add(ctx, domain.FlagConfig{
 		EnvironmentID:  env,
@@ -352,6 +439,7 @@ func (s Refresher) handleFetchFeatureEvent(ctx context.Context, env, id string)
 	}); err != nil {
 		return err
 	}
+	s.record(ctx, audit.FlagChanges(env, oldFeatures, features)...)
 	// patch the inventory
 	return s.inventory.Patch(ctx, s.config.Key(), func(assets map[string]string) (map[string]string, error) {
 		featureConfigEntry := string(domain.NewFeatureConfigKey(env, id))
@@ -367,7 +455,9 @@ func (s Refresher) handleDeleteFeatureEvent(ctx context.Context, env, identifier
 	// fetch and reset config map and delete the entry.
 	features, _ := s.flagRepo.GetFeatureConfigForEnvironment(ctx, env)
 
-	if len(features) > 1 {
+	// We always re-add the remaining flags, even if there are none left, so
+	// that when generations are enabled a new one is published without it
+	if len(features) > 0 {
 		//delete the identifier
 		err := s.updateFeatureConfigsEntry(ctx, env, identifier, features)
 		if err != nil {
@@ -386,6 +476,7 @@ func (s Refresher) handleDeleteFeatureEvent(ctx context.Context, env, identifier
 			return err
 		}
 	}
+	s.record(ctx, deleteRecord(domain.AuditKindFlag, env, identifier, flagVersion(features, identifier)))
 
 	return s.inventory.Patch(ctx, s.config.Key(), func(assets map[string]string) (map[string]string, error) {
 		_, ok := assets[featureConfigEntry]
@@ -426,12 +517,15 @@ func (s Refresher) handleFetchSegmentEvent(ctx context.Context, env, id string)
 		segments = append(segments, domain.Segment(v))
 	}
 
+	oldSegments := s.currentSegments(ctx, env)
+
 	if err := s.segmentRepo.Add(ctx, domain.SegmentConfig{
 		EnvironmentID: env,
 		Segments:      segments,
 	}); err != nil {
 		return err
 	}
+	s.record(ctx, audit.SegmentChanges(env, oldSegments, segments)...)
 	// patch the inventory
 	return s.inventory.Patch(ctx, s.config.Key(), func(assets map[string]string) (map[string]string, error) {
 		segmentConfigEntry := string(domain.NewSegmentKey(env, id))
@@ -446,7 +540,8 @@ func (s Refresher) handleDeleteSegmentEvent(ctx context.Context, env, identifier
 	segmentConfigs := string(domain.NewSegmentsKey(env))
 	// get the segment entry for the environment and update it.
 	segments, _ := s.segmentRepo.GetSegmentsForEnvironment(ctx, env)
-	if len(segments) > 1 {
+	// As with flags we re-add the remaining segments even if there are none left
+	if len(segments) > 0 {
 		//delete the identifier
 		err := s.updateSegmentConfigsEntry(ctx, env, identifier, segments)
 		if err != nil {
@@ -464,6 +559,7 @@ func (s Refresher) handleDeleteSegmentEvent(ctx context.Context, env, identifier
 			return err
 		}
 	}
+	s.record(ctx, deleteRecord(domain.AuditKindSegment, env, identifier, segmentVersion(segments, identifier)))
 
 	return s.inventory.Patch(ctx, s.config.Key(), func(assets map[string]string) (map[string]string, error) {
 		_, ok := assets[segmentConfig]
@@ -522,6 +618,163 @@ func (s Refresher) handleDeleteProxyKeyEvent(ctx context.Context) error {
 		}
 	}
 	keyInventoryEntry := string(domain.NewKeyInventory(proxyKey))
-	return s.inventory.Remove(ctx, keyInventoryEntry)
+	if err := s.inventory.Remove(ctx, keyInventoryEntry); err != nil {
+		return err
+	}
+
+	s.record(ctx, domain.AuditRecord{Action: domain.AuditActionDelete, Kind: domain.AuditKindProxyKey})
+	return nil
+}
+
+// record writes the records to the audit Recorder if one has been configured
+func (s Refresher) record(ctx context.Context, records ...domain.AuditRecord) {
+	if s.audit == nil || len(records) == 0 {
+		return
+	}
+	s.audit.Record(ctx, records...)
+}
+
+// currentFlags gets the flags in an environment before a change is applied so
+// they can be diffed for the audit log. We only need them if we're auditing so
+// we avoid the extra read from the cache otherwise.
+func (s Refresher) currentFlags(ctx context.Context, env string) []domain.FeatureFlag {
+	if s.audit == nil {
+		return nil
+	}
+	flags, _ := s.flagRepo.GetFeatureConfigForEnvironment(ctx, env)
+	return flags
+}
+
+// currentSegments gets the segments in an environment before a change is applied
+func (s Refresher) currentSegments(ctx context.Context, env string) []domain.Segment {
+	if s.audit == nil {
+		return nil
+	}
+	segments, _ := s.segmentRepo.GetSegmentsForEnvironment(ctx, env)
+	return segments
+}
+
+func deleteRecord(kind string, env string, identifier string, version *int64) domain.AuditRecord {
+	return domain.AuditRecord{
+		Action:      domain.AuditActionDelete,
+		Kind:        kind,
+		Environment: env,
+		Identifier:  identifier,
+		OldVersion:  version,
+	}
+}
+
+func flagVersion(features []domain.FeatureFlag, identifier string) *int64 {
+	for _, f := range features {
+		if f.Feature == identifier {
+			return f.Version
+		}
+	}
+	return nil
+}
+
+func segmentVersion(segments []domain.Segment, identifier string) *int64 {
+	for _, s := range segments {
+		if s.Identifier == identifier {
+			return s.Version
+		}
+	}
+	return nil
+}
+
+// ConfigGeneration gets the generation of an environment's config that's
+// being served
+func (s Refresher) ConfigGeneration(ctx context.Context, envID string) (domain.ConfigGeneration, error) {
+	if s.generations == nil {
+		return domain.ConfigGeneration{}, ErrGenerationsDisabled
+	}
+	return s.generations.Get(ctx, envID)
+}
+
+// Pin pins an environment to one of the generations of its config that are
+// being kept and notifies SDKs of the flags and segments that changed. Events
+// for the environment are written as new generations that aren't served until
+// it's released.
+func (s Refresher) Pin(ctx context.Context, envID string, generation int64) (domain.ConfigGeneration, error) {
+	if s.generations == nil {
+		return domain.ConfigGeneration{}, ErrGenerationsDisabled
+	}
+
+	before := s.envAssets(ctx, envID)
+	gen, err := s.generations.Pin(ctx, envID, generation)
+	if err != nil {
+		return domain.ConfigGeneration{}, err
+	}
+
+	s.notify(ctx, envID, before, s.envAssets(ctx, envID))
+	return gen, nil
+}
+
+// Release unpins an environment so its latest generation, which includes any
+// events that were applied while it was pinned, is served and notifies SDKs of
+// the flags and segments that changed
+func (s Refresher) Release(ctx context.Context, envID string) (domain.ConfigGeneration, error) {
+	if s.generations == nil {
+		return domain.ConfigGeneration{}, ErrGenerationsDisabled
+	}
 
+	before := s.envAssets(ctx, envID)
+	gen, err := s.generations.Release(ctx, envID)
+	if err != nil {
+		return domain.ConfigGeneration{}, err
+	}
+
+	s.notify(ctx, envID, before, s.envAssets(ctx, envID))
+	return gen, nil
+}
+
+// envAssets returns SSE messages keyed by the domain and identifier of every
+// flag and segment that's being served for an environment
+func (s Refresher) envAssets(ctx context.Context, envID string) map[string]domain.SSEMessage {
+	assets := map[string]domain.SSEMessage{}
+
+	flags, _ := s.flagRepo.GetFeatureConfigForEnvironment(ctx, envID)
+	for _, f := range flags {
+		assets[domain.MsgDomainFeature+"/"+f.Feature] = domain.SSEMessage{Domain: domain.MsgDomainFeature, Identifier: f.Feature, Environment: envID, Version: messageVersion(f.Version)}
+	}
+
+	segments, _ := s.segmentRepo.GetSegmentsForEnvironment(ctx, envID)
+	for _, seg := range segments {
+		assets[domain.MsgDomainSegment+"/"+seg.Identifier] = domain.SSEMessage{Domain: domain.MsgDomainSegment, Identifier: seg.Identifier, Environment: envID, Version: messageVersion(seg.Version)}
+	}
+	return assets
+}
+
+func messageVersion(v *int64) int {
+	if v == nil {
+		return 0
+	}
+	return int(*v)
+}
+
+// notify sends a patch event for every flag and segment that's being served
+// and a delete event for the ones that were being served before but aren't now
+func (s Refresher) notify(ctx context.Context, envID string, before map[string]domain.SSEMessage, after map[string]domain.SSEMessage) {
+	if s.notifier == nil {
+		return
+	}
+
+	msgs := make([]domain.SSEMessage, 0, len(after)+len(before))
+	for _, msg := range after {
+		msg.Event = domain.EventPatch
+		msgs = append(msgs, msg)
+	}
+	for k, msg := range before {
+		if _, ok := after[k]; ok {
+			continue
+		}
+		msg.Event = domain.EventDelete
+		msgs = append(msgs, msg)
+	}
+
+	for _, msg := range msgs {
+		if err := s.notifier.HandleMessage(ctx, msg); err != nil {
+			s.log.Error("failed to notify SDKs of pinned config change", "environment", envID, "domain", msg.Domain, "identifier", msg.Identifier, "err", err)
+		}
+	}
 }
@@ -3,12 +3,14 @@ package cache
 import (
 	"context"
 	"errors"
+	"strings"
 	"testing"
 
 	"github.com/google/uuid"
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/harness/ff-proxy/v2/audit"
 	"github.com/harness/ff-proxy/v2/domain"
 	clientgen "github.com/harness/ff-proxy/v2/gen/client"
 	"github.com/harness/ff-proxy/v2/log"
@@ -561,6 +563,291 @@ func TestRefresher_handleRemoveEnvironmentEvent(t *testing.T) {
 	}
 }
 
+// mockRecorder collects the AuditRecords it's given
+func TestRefresher_RemoveEnvironmentAfterAPIKeyAdded(t *testing.T) {
+	ctx := context.Background()
+	const env = "env-123"
+
+	// The AuthRepo stores api keys under an upgraded hash rather than the
+	// one we get from Harness SaaS
+	storageKey := func(key domain.AuthAPIKey) string {
+		return strings.Replace(string(key), "auth-key-", "auth-key-v2:", 1)
+	}
+
+	inventory := map[string]string{}
+	authKeys := map[string]string{}
+
+	authRepo := mockAuthRepo{
+		storageKeyFn: storageKey,
+		addFn: func(ctx context.Context, values ...domain.AuthConfig) error {
+			for _, v := range values {
+				authKeys[storageKey(v.APIKey)] = string(v.EnvironmentID)
+			}
+			return nil
+		},
+		patchAPIConfigForEnvironmentFn: func(ctx context.Context, envID, apikey, action string) error {
+			return nil
+		},
+		removeAllKeysForEnvironmentFn: func(ctx context.Context, envID string) error {
+			for k, e := range authKeys {
+				if e == envID {
+					delete(authKeys, k)
+				}
+			}
+			return nil
+		},
+		getKeysForEnvironmentFn: func(ctx context.Context, envID string) ([]string, error) {
+			keys := []string{}
+			for k, e := range authKeys {
+				if e == envID {
+					keys = append(keys, k)
+				}
+			}
+			return keys, nil
+		},
+	}
+
+	inventoryRepo := mockInventoryRepo{
+		patchFn: func(ctx context.Context, key string, patch func(assets map[string]string) (map[string]string, error)) error {
+			var err error
+			inventory, err = patch(inventory)
+			return err
+		},
+		getKeysForEnvironmentFn: func(ctx context.Context, env string) (map[string]string, error) {
+			keys := map[string]string{}
+			for k, v := range inventory {
+				if strings.Contains(k, env) {
+					keys[k] = v
+				}
+			}
+			return keys, nil
+		},
+		keyExistsFn: func(ctx context.Context, key string) bool {
+			return false
+		},
+	}
+
+	flagRepo := mockFlagRepo{
+		removeAllFeaturesForEnvironmentFn: func(ctx context.Context, id string) error { return nil },
+		getFeatureConfigForEnvironmentFn: func(ctx context.Context, envID string) ([]domain.FeatureFlag, bool) {
+			return nil, false
+		},
+	}
+	segmentRepo := mockSegmentRepo{
+		removeAllSegmentsForEnvironmentFn: func(ctx context.Context, id string) error { return nil },
+		getSegmentsForEnvironmentFn: func(ctx context.Context, envID string) ([]domain.Segment, bool) {
+			return nil, false
+		},
+	}
+
+	r := NewRefresher(log.NoOpLogger{}, mockConfig{}, mockClientService{}, inventoryRepo, authRepo, flagRepo, segmentRepo)
+
+	t.Log("Given an api key has been added to an environment by an SSE event")
+	err := r.HandleMessage(ctx, domain.SSEMessage{Domain: domain.MsgDomainProxy, Event: domain.EventAPIKeyAdded, Environments: []string{env}, APIKey: "apikey"})
+	assert.Nil(t, err)
+
+	t.Log("Then the inventory has the key the AuthRepo stored the api key under")
+	assert.Contains(t, inventory, "auth-key-v2:apikey")
+	assert.NotContains(t, inventory, "auth-key-apikey")
+
+	t.Log("When the environment is removed")
+	err = r.HandleMessage(ctx, domain.SSEMessage{Domain: domain.MsgDomainProxy, Event: domain.EventEnvironmentRemoved, Environments: []string{env}})
+	assert.Nil(t, err)
+
+	t.Log("Then the api key is removed from the AuthRepo and the inventory")
+	assert.Empty(t, authKeys)
+	assert.Empty(t, inventory)
+}
+
+type mockRecorder struct {
+	records *[]domain.AuditRecord
+}
+
+func (m mockRecorder) Record(ctx context.Context, records ...domain.AuditRecord) {
+	source, event := audit.SourceFromContext(ctx)
+	for _, r := range records {
+		r.Source = source
+		r.Event = event
+		*m.records = append(*m.records, r)
+	}
+}
+
+func TestRefresher_AuditRecords(t *testing.T) {
+	authRepo := mockAuthRepo{
+		addFn: func(ctx context.Context, values ...domain.AuthConfig) error {
+			return nil
+		},
+		patchAPIConfigForEnvironmentFn: func(ctx context.Context, envID, apikey, action string) error {
+			return nil
+		},
+		removeFn: func(ctx context.Context, id []string) error {
+			return nil
+		},
+	}
+	config := mockConfig{
+		key: func() string {
+			return "key"
+		},
+	}
+	inventoryRepo := mockInventoryRepo{
+		patchFn: func(ctx context.Context, key string, patch func(assets map[string]string) (map[string]string, error)) error {
+			return nil
+		},
+	}
+
+	testCases := map[string]struct {
+		message  domain.SSEMessage
+		expected []domain.AuditRecord
+	}{
+		"Given I receive an apiKeyAdded event": {
+			message: domain.SSEMessage{
+				Domain:       domain.MsgDomainProxy,
+				Event:        domain.EventAPIKeyAdded,
+				Environments: []string{"env-123"},
+				APIKey:       "apikey",
+			},
+			expected: []domain.AuditRecord{
+				{Source: domain.AuditSourceSSE, Event: domain.EventAPIKeyAdded, Action: domain.AuditActionCreate, Kind: domain.AuditKindAPIKey, Environment: "env-123", Identifier: "apikey"},
+			},
+		},
+		"Given I receive an apiKeyRemoved event": {
+			message: domain.SSEMessage{
+				Domain:       domain.MsgDomainProxy,
+				Event:        domain.EventAPIKeyRemoved,
+				Environments: []string{"env-123"},
+				APIKey:       "apikey",
+			},
+			expected: []domain.AuditRecord{
+				{Source: domain.AuditSourceSSE, Event: domain.EventAPIKeyRemoved, Action: domain.AuditActionDelete, Kind: domain.AuditKindAPIKey, Environment: "env-123", Identifier: "apikey"},
+			},
+		},
+	}
+
+	for desc, tc := range testCases {
+		desc := desc
+		tc := tc
+
+		t.Run(desc, func(t *testing.T) {
+			records := []domain.AuditRecord{}
+			r := NewRefresher(log.NewNoOpLogger(), config, mockClientService{}, inventoryRepo, authRepo, mockFlagRepo{}, mockSegmentRepo{}, WithAuditRecorder(mockRecorder{records: &records}))
+
+			assert.Nil(t, r.HandleMessage(context.Background(), tc.message))
+			assert.Equal(t, tc.expected, records)
+		})
+	}
+}
+
+// mockGenerations pins a single environment between generation 1 and 2
+type mockGenerations struct {
+	gen *domain.ConfigGeneration
+}
+
+func (m mockGenerations) Get(_ context.Context, _ string) (domain.ConfigGeneration, error) {
+	return *m.gen, nil
+}
+
+func (m mockGenerations) Latest(ctx context.Context, _ string) context.Context {
+	return ctx
+}
+
+func (m mockGenerations) Pin(_ context.Context, _ string, generation int64) (domain.ConfigGeneration, error) {
+	m.gen.Current = generation
+	m.gen.Pinned = true
+	return *m.gen, nil
+}
+
+func (m mockGenerations) Release(_ context.Context, _ string) (domain.ConfigGeneration, error) {
+	m.gen.Current = m.gen.Latest()
+	m.gen.Pinned = false
+	return *m.gen, nil
+}
+
+// mockNotifier collects the messages it's given
+type mockNotifier struct {
+	msgs *[]domain.SSEMessage
+}
+
+func (m mockNotifier) HandleMessage(_ context.Context, msg domain.SSEMessage) error {
+	*m.msgs = append(*m.msgs, msg)
+	return nil
+}
+
+func TestRefresher_PinAndRelease(t *testing.T) {
+	ctx := context.Background()
+
+	gen := &domain.ConfigGeneration{Current: 2, History: []int64{2, 1}}
+	served := map[int64][]domain.FeatureFlag{
+		1: {{Feature: "foo", Environment: "env-123"}},
+		2: {{Feature: "bar", Environment: "env-123"}},
+	}
+
+	fetches := 0
+	clientService := mockClientService{
+		FetchFeatureConfigForEnvironmentFn: func(ctx context.Context, authToken, envId string) ([]clientgen.FeatureConfig, error) {
+			fetches++
+			return []clientgen.FeatureConfig{}, nil
+		},
+	}
+	flagRepo := mockFlagRepo{
+		addFn: func(ctx context.Context, values ...domain.FlagConfig) error {
+			return nil
+		},
+		getFeatureConfigForEnvironmentFn: func(ctx context.Context, envID string) ([]domain.FeatureFlag, bool) {
+			return served[gen.Current], true
+		},
+	}
+	segmentRepo := mockSegmentRepo{
+		getSegmentsForEnvironmentFn: func(ctx context.Context, envID string) ([]domain.Segment, bool) {
+			return nil, false
+		},
+	}
+	inventoryRepo := mockInventoryRepo{
+		patchFn: func(ctx context.Context, key string, patch func(assets map[string]string) (map[string]string, error)) error {
+			return nil
+		},
+	}
+
+	msgs := []domain.SSEMessage{}
+	r := NewRefresher(log.NewNoOpLogger(), mockConfig{}, clientService, inventoryRepo, mockAuthRepo{}, flagRepo, segmentRepo,
+		WithGenerations(mockGenerations{gen: gen}),
+		WithNotifier(mockNotifier{msgs: &msgs}),
+	)
+
+	t.Log("Given I pin an environment to its previous generation")
+	pinned, err := r.Pin(ctx, "env-123", 1)
+	assert.Nil(t, err)
+	assert.True(t, pinned.Pinned)
+
+	t.Log("Then SDKs are told about the flags that changed")
+	assert.ElementsMatch(t, []domain.SSEMessage{
+		{Event: domain.EventPatch, Domain: domain.MsgDomainFeature, Identifier: "foo", Environment: "env-123"},
+		{Event: domain.EventDelete, Domain: domain.MsgDomainFeature, Identifier: "bar", Environment: "env-123"},
+	}, msgs)
+
+	t.Log("When I get a flag event for the pinned environment")
+	assert.Nil(t, r.HandleMessage(ctx, domain.SSEMessage{Event: domain.EventPatch, Domain: domain.MsgDomainFeature, Identifier: "bar", Environment: "env-123"}))
+
+	t.Log("Then it's applied straight away rather than being held until the environment is released")
+	assert.Equal(t, 1, fetches)
+
+	t.Log("When I release the environment")
+	msgs = msgs[:0]
+	released, err := r.Release(ctx, "env-123")
+	assert.Nil(t, err)
+	assert.False(t, released.Pinned)
+
+	t.Log("Then SDKs are told about the flags that changed")
+	assert.Equal(t, 1, fetches)
+	assert.ElementsMatch(t, []domain.SSEMessage{
+		{Event: domain.EventPatch, Domain: domain.MsgDomainFeature, Identifier: "bar", Environment: "env-123"},
+		{Event: domain.EventDelete, Domain: domain.MsgDomainFeature, Identifier: "foo", Environment: "env-123"},
+	}, msgs)
+
+	t.Log("And I can't pin environments if generations aren't enabled")
+	_, err = NewRefresher(log.NewNoOpLogger(), mockConfig{}, clientService, inventoryRepo, mockAuthRepo{}, flagRepo, segmentRepo).Pin(ctx, "env-123", 1)
+	assert.True(t, errors.Is(err, ErrGenerationsDisabled))
+}
+
 type mockConfig struct {
 	fetchAndPopulate func(ctx context.Context, inventoryRepo domain.InventoryRepo, authRepo domain.AuthRepo, flagRepo domain.FlagRepo, segmentRepo domain.SegmentRepo) error
 
@@ -659,6 +946,14 @@ type mockAuthRepo struct {
 	removeAllKeysForEnvironmentFn  func(ctx context.Context, envID string) error
 	addAPIConfigsForEnvironmentFn  func(ctx context.Context, envID string, apiKeys []string) error
 	getKeysForEnvironmentFn        func(ctx context.Context, envID string) ([]string, error)
+	storageKeyFn                   func(key domain.AuthAPIKey) string
+}
+
+func (m mockAuthRepo) StorageKey(key domain.AuthAPIKey) string {
+	if m.storageKeyFn == nil {
+		return string(key)
+	}
+	return m.storageKeyFn(key)
 }
 
 func (m mockAuthRepo) GetKeysForEnvironment(ctx context.Context, envID string) ([]string, error) {
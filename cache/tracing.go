@@ -0,0 +1,138 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/harness/ff-proxy/v2/tracing"
+)
+
+// TracingCache is a decorator for a Cache that creates a span for each
+// operation so we can see how long reads and writes take within a request
+type TracingCache struct {
+	name string
+	next Cache
+}
+
+// NewTracingCache creates a TracingCache. The name is used to tell spans from
+// different caches apart e.g. redis & in_mem.
+func NewTracingCache(name string, next Cache) TracingCache {
+	return TracingCache{name: name, next: next}
+}
+
+func (t TracingCache) start(ctx context.Context, op string, key string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, fmt.Sprintf("cache.%s", op),
+		attribute.String("cache.name", t.name),
+		attribute.String("cache.key", key),
+	)
+}
+
+// Set creates a span and calls Set on the decorated cache
+func (t TracingCache) Set(ctx context.Context, key string, value interface{}) (err error) {
+	ctx, span := t.start(ctx, "Set", key)
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.Set(ctx, key, value)
+}
+
+// Get creates a span and calls Get on the decorated cache
+func (t TracingCache) Get(ctx context.Context, key string, value interface{}) (err error) {
+	ctx, span := t.start(ctx, "Get", key)
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.Get(ctx, key, value)
+}
+
+// Delete creates a span and calls Delete on the decorated cache
+func (t TracingCache) Delete(ctx context.Context, key string) (err error) {
+	ctx, span := t.start(ctx, "Delete", key)
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.Delete(ctx, key)
+}
+
+// Keys creates a span and calls Keys on the decorated cache
+func (t TracingCache) Keys(ctx context.Context, key string) (keys []string, err error) {
+	ctx, span := t.start(ctx, "Keys", key)
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.Keys(ctx, key)
+}
+
+// HealthCheck calls HealthCheck on the decorated cache. Health checks happen
+// on an interval rather than as part of requests so they aren't traced.
+func (t TracingCache) HealthCheck(ctx context.Context) error {
+	return t.next.HealthCheck(ctx)
+}
+
+// Scan creates a span and calls Scan on the decorated cache
+func (t TracingCache) Scan(ctx context.Context, key string) (m map[string]string, err error) {
+	ctx, span := t.start(ctx, "Scan", key)
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.Scan(ctx, key)
+}
+
+// RedisTracingHook is a redis hook that creates a span for each command sent
+// to redis. Used alongside a TracingCache it shows how much of a cache read
+// is spent in redis and how much is spent unmarshaling the result. Commands
+// that aren't part of a trace, e.g. blocking stream reads, aren't traced.
+type RedisTracingHook struct{}
+
+// DialHook makes RedisTracingHook implement the redis.Hook interface
+func (RedisTracingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+// ProcessHook makes RedisTracingHook implement the redis.Hook interface
+func (RedisTracingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if !trace.SpanContextFromContext(ctx).IsValid() {
+			return next(ctx, cmd)
+		}
+
+		ctx, span := startRedisSpan(ctx, cmd.Name())
+		err := next(ctx, cmd)
+		endRedisSpan(span, err)
+		return err
+	}
+}
+
+// ProcessPipelineHook makes RedisTracingHook implement the redis.Hook interface
+func (RedisTracingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if !trace.SpanContextFromContext(ctx).IsValid() {
+			return next(ctx, cmds)
+		}
+
+		ctx, span := startRedisSpan(ctx, "pipeline")
+		span.SetAttributes(attribute.Int("db.redis.pipeline_length", len(cmds)))
+		err := next(ctx, cmds)
+		endRedisSpan(span, err)
+		return err
+	}
+}
+
+func startRedisSpan(ctx context.Context, op string) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, fmt.Sprintf("redis.%s", op),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemRedis, semconv.DBOperationName(op)),
+	)
+}
+
+// endRedisSpan ends the span, keys that don't exist aren't recorded as errors
+func endRedisSpan(span trace.Span, err error) {
+	if errors.Is(err, redis.Nil) {
+		err = nil
+	}
+	tracing.End(span, err)
+}
@@ -49,20 +49,35 @@ type ffClientService interface {
 
 // Client is a type for interacting with the Feature Flag Client Service
 type Client struct {
-	log    log.Logger
-	client ffClientService
+	log        log.Logger
+	client     ffClientService
+	httpClient *http.Client
+	resilience resilienceConfig
+}
+
+// WithHTTPClient sets the http.Client used to make requests to the client service
+func WithHTTPClient(h *http.Client) func(c *Client) {
+	return func(c *Client) {
+		c.httpClient = h
+	}
 }
 
 // NewClient creates a Client
-func NewClient(l log.Logger, addr string, reg *prometheus.Registry) (Client, error) {
+func NewClient(l log.Logger, addr string, reg *prometheus.Registry, opts ...func(c *Client)) (Client, error) {
 	l = l.With("component", "ClientServiceClient")
 
-	client, err := clientgen.NewClientWithResponses(addr)
+	c := Client{log: l, httpClient: http.DefaultClient}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	client, err := clientgen.NewClientWithResponses(addr, clientgen.WithHTTPClient(c.httpClient))
 	if err != nil {
 		return Client{}, err
 	}
 
-	return Client{log: l, client: newPrometheusClient(client, reg)}, nil
+	c.client = newResilientClient(l, newPrometheusClient(client, reg), reg, c.resilience)
+	return c, nil
 }
 
 // Authenticate makes an authentication request to the client service
@@ -214,7 +229,7 @@ func (c Client) getProxyConfig(ctx context.Context, input domain.GetProxyConfigI
 		addAuthToken(input.AuthToken),
 	)
 	if err != nil {
-		return clientgen.ProxyConfig{}, fmt.Errorf("%w: %s", ErrInternal, err)
+		return clientgen.ProxyConfig{}, fmt.Errorf("%w: %w", ErrInternal, err)
 	}
 
 	if resp.JSON200 == nil {
@@ -237,7 +252,7 @@ func (c Client) FetchFeatureConfigForEnvironment(ctx context.Context, authToken,
 		domain.AddHarnessXHeaders(envID),
 	)
 	if err != nil {
-		return []clientgen.FeatureConfig{}, fmt.Errorf("%w: %s", ErrInternal, err)
+		return []clientgen.FeatureConfig{}, fmt.Errorf("%w: %w", ErrInternal, err)
 	}
 
 	if resp.JSON200 == nil {
@@ -261,7 +276,7 @@ func (c Client) FetchSegmentConfigForEnvironment(ctx context.Context, authToken,
 		domain.AddHarnessXHeaders(envID),
 	)
 	if err != nil {
-		return []clientgen.Segment{}, fmt.Errorf("%w: %s", ErrInternal, err)
+		return []clientgen.Segment{}, fmt.Errorf("%w: %w", ErrInternal, err)
 	}
 
 	if resp.JSON200 == nil {
@@ -0,0 +1,332 @@
+package clientservice
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/cenkalti/backoff.v1"
+
+	clientgen "github.com/harness/ff-proxy/v2/gen/client"
+	"github.com/harness/ff-proxy/v2/log"
+	"github.com/harness/ff-proxy/v2/stream"
+)
+
+// ErrCircuitOpen is the error returned when requests to the client service
+// aren't being made because too many recent requests have failed
+var ErrCircuitOpen = errors.New("client service circuit breaker is open")
+
+// The states of the circuit breaker, these are the values of the
+// ff_proxy_to_client_service_circuit_breaker_state metric
+const (
+	circuitClosed   = 0
+	circuitHalfOpen = 1
+	circuitOpen     = 2
+)
+
+// WithRetries sets how many times idempotent requests to the client service
+// are attempted before giving up. Attempts are separated by an exponential
+// backoff from initial up to max that's jittered by +/- 50%.
+func WithRetries(maxAttempts int, initial time.Duration, max time.Duration) func(c *Client) {
+	return func(c *Client) {
+		c.resilience.maxAttempts = maxAttempts
+		c.resilience.backoffInitial = initial
+		c.resilience.backoffMax = max
+	}
+}
+
+// WithCircuitBreaker opens the circuit breaker after threshold requests in a
+// row have failed. While it's open requests fail fast with ErrCircuitOpen
+// and after the cooldown a single request is let through to test whether the
+// client service has recovered.
+func WithCircuitBreaker(threshold int, cooldown time.Duration) func(c *Client) {
+	return func(c *Client) {
+		c.resilience.breakerThreshold = threshold
+		c.resilience.breakerCooldown = cooldown
+	}
+}
+
+// WithRequestTimeout sets how long each attempt at a request can take
+func WithRequestTimeout(d time.Duration) func(c *Client) {
+	return func(c *Client) {
+		c.resilience.requestTimeout = d
+	}
+}
+
+type resilienceConfig struct {
+	maxAttempts      int
+	backoffInitial   time.Duration
+	backoffMax       time.Duration
+	breakerThreshold int
+	breakerCooldown  time.Duration
+	requestTimeout   time.Duration
+}
+
+// statusCoder is implemented by all of the generated client's responses
+type statusCoder interface {
+	StatusCode() int
+}
+
+// resilientClient decorates an ffClientService with timeouts, retries for
+// idempotent requests and a circuit breaker
+type resilientClient struct {
+	log     log.Logger
+	config  resilienceConfig
+	breaker *circuitBreaker
+
+	retries    *prometheus.CounterVec
+	rejections *prometheus.CounterVec
+	state      prometheus.Gauge
+
+	next ffClientService
+}
+
+func newResilientClient(l log.Logger, next ffClientService, reg *prometheus.Registry, config resilienceConfig) resilientClient {
+	r := resilientClient{
+		log:    l,
+		config: config,
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ff_proxy_to_client_service_retries_total",
+			Help: "Tracks the number of requests to the ff-client-service that the Proxy has retried",
+		},
+			[]string{"url"},
+		),
+		rejections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ff_proxy_to_client_service_circuit_breaker_rejections_total",
+			Help: "Tracks the number of requests to the ff-client-service that weren't made because the circuit breaker was open",
+		},
+			[]string{"url"},
+		),
+		state: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "ff_proxy_to_client_service_circuit_breaker_state",
+			Help: "The state of the circuit breaker for requests to the ff-client-service. 0 is closed, 1 is half open and 2 is open",
+		}),
+		next: next,
+	}
+
+	r.breaker = newCircuitBreaker(config.breakerThreshold, config.breakerCooldown, func(state int) {
+		r.state.Set(float64(state))
+		switch state {
+		case circuitOpen:
+			l.Warn("circuit breaker opened, requests to the client service will fail fast and cached config will be served", "cooldown", config.breakerCooldown)
+		case circuitHalfOpen:
+			l.Info("circuit breaker half open, testing whether the client service has recovered")
+		case circuitClosed:
+			l.Info("circuit breaker closed, the client service has recovered")
+		}
+	})
+
+	reg.MustRegister(r.retries, r.rejections, r.state)
+	return r
+}
+
+func (r resilientClient) AuthenticateWithResponse(ctx context.Context, body clientgen.AuthenticateJSONRequestBody, reqEditors ...clientgen.RequestEditorFn) (*clientgen.AuthenticateResponse, error) {
+	// We don't retry SDK auth requests, the SDK that made the request
+	// will retry them itself if they fail
+	return call(ctx, r, "/client/auth", false, func(ctx context.Context) (*clientgen.AuthenticateResponse, error) {
+		return r.next.AuthenticateWithResponse(ctx, body, reqEditors...)
+	})
+}
+
+func (r resilientClient) AuthenticateProxyKeyWithResponse(ctx context.Context, body clientgen.AuthenticateProxyKeyJSONRequestBody, reqEditors ...clientgen.RequestEditorFn) (*clientgen.AuthenticateProxyKeyResponse, error) {
+	// This is a POST but it doesn't change anything in SaaS so it's safe to retry
+	return call(ctx, r, "/proxy/auth", true, func(ctx context.Context) (*clientgen.AuthenticateProxyKeyResponse, error) {
+		return r.next.AuthenticateProxyKeyWithResponse(ctx, body, reqEditors...)
+	})
+}
+
+func (r resilientClient) GetProxyConfigWithResponse(ctx context.Context, params *clientgen.GetProxyConfigParams, reqEditors ...clientgen.RequestEditorFn) (*clientgen.GetProxyConfigResponse, error) {
+	return call(ctx, r, "/proxy/config", true, func(ctx context.Context) (*clientgen.GetProxyConfigResponse, error) {
+		return r.next.GetProxyConfigWithResponse(ctx, params, reqEditors...)
+	})
+}
+
+func (r resilientClient) GetAllSegmentsWithResponse(ctx context.Context, environmentUUID string, params *clientgen.GetAllSegmentsParams, reqEditors ...clientgen.RequestEditorFn) (*clientgen.GetAllSegmentsResponse, error) {
+	return call(ctx, r, "/client/env/:env/target-segments", true, func(ctx context.Context) (*clientgen.GetAllSegmentsResponse, error) {
+		return r.next.GetAllSegmentsWithResponse(ctx, environmentUUID, params, reqEditors...)
+	})
+}
+
+func (r resilientClient) GetFeatureConfigWithResponse(ctx context.Context, environmentUUID string, params *clientgen.GetFeatureConfigParams, reqEditors ...clientgen.RequestEditorFn) (*clientgen.GetFeatureConfigResponse, error) {
+	return call(ctx, r, "/client/env/:env/feature-configs", true, func(ctx context.Context) (*clientgen.GetFeatureConfigResponse, error) {
+		return r.next.GetFeatureConfigWithResponse(ctx, environmentUUID, params, reqEditors...)
+	})
+}
+
+// call makes a request to the client service, retrying it if it's idempotent
+// and fails with an error or a status code that's worth retrying
+func call[T statusCoder](ctx context.Context, r resilientClient, url string, idempotent bool, fn func(ctx context.Context) (T, error)) (T, error) {
+	maxAttempts := 1
+	if idempotent && r.config.maxAttempts > 1 {
+		maxAttempts = r.config.maxAttempts
+	}
+
+	var b backoff.BackOff
+	for n := 1; ; n++ {
+		if err := r.breaker.allow(); err != nil {
+			r.rejections.WithLabelValues(url).Inc()
+			var resp T
+			return resp, err
+		}
+
+		resp, err := attempt(ctx, r.config.requestTimeout, fn)
+
+		statusCode := 0
+		if err == nil {
+			statusCode = resp.StatusCode()
+		}
+
+		// If our context was cancelled it doesn't tell us anything about
+		// the health of the client service
+		if ctx.Err() != nil {
+			r.breaker.release()
+			return resp, err
+		}
+		// Anything we'd retry counts as a failure, including being rate
+		// limited, so that if the client service keeps throttling us the
+		// breaker opens and we back off instead of adding to its load
+		r.breaker.record(!shouldRetry(err, statusCode))
+
+		if !shouldRetry(err, statusCode) || n >= maxAttempts {
+			return resp, err
+		}
+
+		if b == nil {
+			b = stream.NewJitteredBackoff(r.config.backoffInitial, r.config.backoffMax)
+		}
+		wait := b.NextBackOff()
+
+		r.retries.WithLabelValues(url).Inc()
+		r.log.Warn("request to client service failed, backing off and retrying", "url", url, "attempt", n, "status_code", statusCode, "backoff_duration", wait, "err", err)
+
+		select {
+		case <-ctx.Done():
+			return resp, err
+		case <-time.After(wait):
+		}
+	}
+}
+
+// attempt makes a single request, applying the request timeout if there is one.
+// The generated client reads the whole response body before returning so it's
+// safe to cancel the context once fn has returned.
+func attempt[T statusCoder](ctx context.Context, timeout time.Duration, fn func(ctx context.Context) (T, error)) (T, error) {
+	if timeout <= 0 {
+		return fn(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return fn(ctx)
+}
+
+// shouldRetry returns true for transport errors, server errors and rate limiting
+func shouldRetry(err error, statusCode int) bool {
+	if err != nil {
+		return true
+	}
+	return statusCode >= http.StatusInternalServerError || statusCode == http.StatusTooManyRequests
+}
+
+// circuitBreaker tracks failed requests to the client service. A threshold of
+// zero or less disables it.
+type circuitBreaker struct {
+	threshold     int
+	cooldown      time.Duration
+	onStateChange func(state int)
+	now           func() time.Time
+
+	mtx      *sync.Mutex
+	state    int
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration, onStateChange func(state int)) *circuitBreaker {
+	return &circuitBreaker{
+		threshold:     threshold,
+		cooldown:      cooldown,
+		onStateChange: onStateChange,
+		now:           time.Now,
+		mtx:           &sync.Mutex{},
+	}
+}
+
+// allow returns ErrCircuitOpen if a request shouldn't be made. When the
+// breaker is half open only one trial request is allowed at a time.
+func (c *circuitBreaker) allow() error {
+	if c.threshold <= 0 {
+		return nil
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	switch c.state {
+	case circuitOpen:
+		if c.now().Sub(c.openedAt) < c.cooldown {
+			return ErrCircuitOpen
+		}
+		c.setState(circuitHalfOpen)
+		c.trial = true
+		return nil
+	case circuitHalfOpen:
+		if c.trial {
+			return ErrCircuitOpen
+		}
+		c.trial = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// record records the outcome of a request that allow let through
+func (c *circuitBreaker) record(success bool) {
+	if c.threshold <= 0 {
+		return
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.trial = false
+	if success {
+		c.failures = 0
+		if c.state != circuitClosed {
+			c.setState(circuitClosed)
+		}
+		return
+	}
+
+	c.failures++
+	if c.state == circuitHalfOpen || c.failures >= c.threshold {
+		c.openedAt = c.now()
+		if c.state != circuitOpen {
+			c.setState(circuitOpen)
+		}
+	}
+}
+
+// release is called instead of record when a request was abandoned so
+// another trial request can be made
+func (c *circuitBreaker) release() {
+	if c.threshold <= 0 {
+		return
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.trial = false
+}
+
+func (c *circuitBreaker) setState(state int) {
+	c.state = state
+	if c.onStateChange != nil {
+		c.onStateChange(state)
+	}
+}
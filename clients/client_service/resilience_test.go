@@ -0,0 +1,216 @@
+package clientservice
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	clientgen "github.com/harness/ff-proxy/v2/gen/client"
+	"github.com/harness/ff-proxy/v2/log"
+)
+
+func proxyConfigResponse(statusCode int) *clientgen.GetProxyConfigResponse {
+	return &clientgen.GetProxyConfigResponse{HTTPResponse: &http.Response{StatusCode: statusCode}}
+}
+
+// sequence returns a getProxyConfig func that returns the given status codes
+// in order, a status code of zero returns an error
+func sequence(statusCodes ...int) func(req *clientgen.GetProxyConfigParams) (*clientgen.GetProxyConfigResponse, error) {
+	i := 0
+	return func(req *clientgen.GetProxyConfigParams) (*clientgen.GetProxyConfigResponse, error) {
+		code := statusCodes[len(statusCodes)-1]
+		if i < len(statusCodes) {
+			code = statusCodes[i]
+		}
+		i++
+
+		if code == 0 {
+			return nil, errors.New("connection reset by peer")
+		}
+		return proxyConfigResponse(code), nil
+	}
+}
+
+func TestResilientClient_Retries(t *testing.T) {
+	testCases := map[string]struct {
+		statusCodes     []int
+		maxAttempts     int
+		shouldErr       bool
+		expectedStatus  int
+		expectedCalls   int
+		expectedRetries float64
+	}{
+		"Given retries are disabled and the request fails": {
+			statusCodes:     []int{500},
+			maxAttempts:     1,
+			expectedStatus:  500,
+			expectedCalls:   1,
+			expectedRetries: 0,
+		},
+		"Given the request fails twice and then succeeds": {
+			statusCodes:     []int{500, 503, 200},
+			maxAttempts:     3,
+			expectedStatus:  200,
+			expectedCalls:   3,
+			expectedRetries: 2,
+		},
+		"Given the request keeps failing": {
+			statusCodes:     []int{500},
+			maxAttempts:     3,
+			expectedStatus:  500,
+			expectedCalls:   3,
+			expectedRetries: 2,
+		},
+		"Given the request errors and then succeeds": {
+			statusCodes:     []int{0, 200},
+			maxAttempts:     3,
+			expectedStatus:  200,
+			expectedCalls:   2,
+			expectedRetries: 1,
+		},
+		"Given the request is rate limited and then succeeds": {
+			statusCodes:     []int{429, 200},
+			maxAttempts:     3,
+			expectedStatus:  200,
+			expectedCalls:   2,
+			expectedRetries: 1,
+		},
+		"Given the request gets a not found response": {
+			statusCodes:     []int{404},
+			maxAttempts:     3,
+			expectedStatus:  404,
+			expectedCalls:   1,
+			expectedRetries: 0,
+		},
+	}
+
+	for desc, tc := range testCases {
+		desc := desc
+		tc := tc
+
+		t.Run(desc, func(t *testing.T) {
+			mock := &mockService{Mutex: &sync.Mutex{}, getProxyConfig: sequence(tc.statusCodes...)}
+			r := newResilientClient(log.NoOpLogger{}, mock, prometheus.NewRegistry(), resilienceConfig{
+				maxAttempts:    tc.maxAttempts,
+				backoffInitial: time.Millisecond,
+				backoffMax:     time.Millisecond,
+			})
+
+			resp, err := r.GetProxyConfigWithResponse(context.Background(), &clientgen.GetProxyConfigParams{})
+			if (err != nil) != tc.shouldErr {
+				t.Errorf("(%s): error = %v, shouldErr = %v", desc, err, tc.shouldErr)
+			}
+
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode())
+			assert.Equal(t, tc.expectedCalls, mock.GetProxyConfigCalls())
+			assert.Equal(t, tc.expectedRetries, testutil.ToFloat64(r.retries.WithLabelValues("/proxy/config")))
+		})
+	}
+}
+
+func TestResilientClient_CircuitBreaker(t *testing.T) {
+	mock := &mockService{Mutex: &sync.Mutex{}, getProxyConfig: sequence(500, 500, 200)}
+	r := newResilientClient(log.NoOpLogger{}, mock, prometheus.NewRegistry(), resilienceConfig{
+		maxAttempts:      1,
+		breakerThreshold: 2,
+		breakerCooldown:  time.Minute,
+	})
+
+	now := time.Now()
+	r.breaker.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	params := &clientgen.GetProxyConfigParams{}
+
+	t.Log("When I make two requests that fail")
+	for i := 0; i < 2; i++ {
+		_, err := r.GetProxyConfigWithResponse(ctx, params)
+		assert.Nil(t, err)
+	}
+
+	t.Log("Then the circuit breaker opens")
+	assert.Equal(t, float64(circuitOpen), testutil.ToFloat64(r.state))
+
+	t.Log("And the next request fails fast without calling the client service")
+	_, err := r.GetProxyConfigWithResponse(ctx, params)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, 2, mock.GetProxyConfigCalls())
+	assert.Equal(t, float64(1), testutil.ToFloat64(r.rejections.WithLabelValues("/proxy/config")))
+
+	t.Log("When the cooldown has passed and the client service has recovered")
+	now = now.Add(2 * time.Minute)
+	resp, err := r.GetProxyConfigWithResponse(ctx, params)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+
+	t.Log("Then the circuit breaker closes")
+	assert.Equal(t, float64(circuitClosed), testutil.ToFloat64(r.state))
+	assert.Equal(t, 3, mock.GetProxyConfigCalls())
+}
+
+func TestResilientClient_CircuitBreakerStatusCodes(t *testing.T) {
+	testCases := map[string]struct {
+		statusCode    int
+		expectedState int
+	}{
+		"Given the client service keeps rate limiting us": {
+			statusCode:    http.StatusTooManyRequests,
+			expectedState: circuitOpen,
+		},
+		"Given the client service keeps returning not found": {
+			statusCode:    http.StatusNotFound,
+			expectedState: circuitClosed,
+		},
+	}
+
+	for desc, tc := range testCases {
+		tc := tc
+		t.Run(desc, func(t *testing.T) {
+			mock := &mockService{Mutex: &sync.Mutex{}, getProxyConfig: sequence(tc.statusCode)}
+			r := newResilientClient(log.NoOpLogger{}, mock, prometheus.NewRegistry(), resilienceConfig{
+				maxAttempts:      1,
+				breakerThreshold: 2,
+				breakerCooldown:  time.Minute,
+			})
+
+			t.Log("When I make two requests")
+			for i := 0; i < 2; i++ {
+				_, err := r.GetProxyConfigWithResponse(context.Background(), &clientgen.GetProxyConfigParams{})
+				assert.Nil(t, err)
+			}
+
+			t.Log("Then the circuit breaker is in the expected state")
+			assert.Equal(t, float64(tc.expectedState), testutil.ToFloat64(r.state))
+		})
+	}
+}
+
+func TestCircuitBreaker_HalfOpen(t *testing.T) {
+	now := time.Now()
+	b := newCircuitBreaker(1, time.Minute, nil)
+	b.now = func() time.Time { return now }
+
+	t.Log("Given I have a circuit breaker that's open")
+	assert.Nil(t, b.allow())
+	b.record(false)
+	assert.ErrorIs(t, b.allow(), ErrCircuitOpen)
+
+	t.Log("When the cooldown has passed")
+	now = now.Add(2 * time.Minute)
+
+	t.Log("Then only one trial request is allowed")
+	assert.Nil(t, b.allow())
+	assert.ErrorIs(t, b.allow(), ErrCircuitOpen)
+
+	t.Log("And if the trial request fails the circuit breaker opens again")
+	b.record(false)
+	assert.Equal(t, circuitOpen, b.state)
+	assert.ErrorIs(t, b.allow(), ErrCircuitOpen)
+}
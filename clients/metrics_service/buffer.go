@@ -0,0 +1,65 @@
+package metricsservice
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/harness/ff-proxy/v2/domain"
+)
+
+var (
+	// ErrBufferEmpty is returned by a Buffer when there are no entries waiting to be retried
+	ErrBufferEmpty = errors.New("metrics buffer is empty")
+)
+
+// BufferEntry is a metrics request that failed to send to Harness SaaS and is
+// waiting to be retried
+type BufferEntry struct {
+	ID            string                `json:"id"`
+	EnvironmentID string                `json:"environment_id"`
+	Request       domain.MetricsRequest `json:"request"`
+}
+
+// Buffer durably stores metrics that failed to send to Harness SaaS so they
+// can be retried once SaaS is reachable again
+type Buffer interface {
+	// Push adds an entry to the buffer. Entries that are already in the buffer
+	// are ignored and if the buffer is full the oldest entries are evicted to
+	// make room. It returns the number of entries that were evicted.
+	Push(ctx context.Context, entry BufferEntry) (int, error)
+
+	// Peek returns the oldest entry in the buffer without removing it. It
+	// returns ErrBufferEmpty if there's nothing in the buffer.
+	Peek(ctx context.Context) (BufferEntry, error)
+
+	// Remove removes the entry with the given id from the buffer
+	Remove(ctx context.Context, id string) error
+
+	// Len returns the number of entries in the buffer
+	Len(ctx context.Context) (int, error)
+}
+
+// NewBufferEntry creates a BufferEntry. The ID is derived from the contents of
+// the request so that pushing the same metrics more than once doesn't result
+// in them being sent to SaaS multiple times.
+func NewBufferEntry(envID string, r domain.MetricsRequest) (BufferEntry, error) {
+	b, err := jsoniter.Marshal(&r)
+	if err != nil {
+		return BufferEntry{}, fmt.Errorf("failed to marshal metrics request: %s", err)
+	}
+
+	h := sha256.New()
+	h.Write([]byte(envID))
+	h.Write(b)
+
+	return BufferEntry{
+		ID:            hex.EncodeToString(h.Sum(nil)),
+		EnvironmentID: envID,
+		Request:       r,
+	}, nil
+}
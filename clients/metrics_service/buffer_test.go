@@ -0,0 +1,229 @@
+package metricsservice
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/harness/ff-proxy/v2/domain"
+	clientgen "github.com/harness/ff-proxy/v2/gen/client"
+)
+
+func mustNewBufferEntry(envID string, count int) BufferEntry {
+	entry, err := NewBufferEntry(envID, domain.MetricsRequest{
+		EnvironmentID: envID,
+		Metrics: clientgen.Metrics{
+			MetricsData: &[]clientgen.MetricsData{
+				{
+					Count:       count,
+					MetricsType: "Server",
+					Timestamp:   111,
+				},
+			},
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return entry
+}
+
+func newTestBuffers(t *testing.T, maxEntries int) map[string]Buffer {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(mr.Close)
+
+	diskBuffer, err := NewDiskBuffer(t.TempDir(), maxEntries)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return map[string]Buffer{
+		"RedisBuffer": NewRedisBuffer(redis.NewClient(&redis.Options{Addr: mr.Addr()}), int64(maxEntries)),
+		"DiskBuffer":  diskBuffer,
+	}
+}
+
+func TestNewBufferEntry(t *testing.T) {
+	a := mustNewBufferEntry("123", 1)
+	b := mustNewBufferEntry("123", 1)
+	c := mustNewBufferEntry("123", 2)
+	d := mustNewBufferEntry("456", 1)
+
+	assert.Equal(t, a.ID, b.ID)
+	assert.NotEqual(t, a.ID, c.ID)
+	assert.NotEqual(t, a.ID, d.ID)
+}
+
+func TestBuffer(t *testing.T) {
+	entry1 := mustNewBufferEntry("123", 1)
+	entry2 := mustNewBufferEntry("123", 2)
+	entry3 := mustNewBufferEntry("456", 3)
+
+	type expected struct {
+		evicted int
+		length  int
+		oldest  BufferEntry
+		err     error
+	}
+
+	testCases := map[string]struct {
+		pushes   []BufferEntry
+		removes  []string
+		expected expected
+	}{
+		"Given I have an empty buffer": {
+			pushes: []BufferEntry{},
+			expected: expected{
+				length: 0,
+				err:    ErrBufferEmpty,
+			},
+		},
+		"Given I push two entries": {
+			pushes: []BufferEntry{entry1, entry2},
+			expected: expected{
+				length: 2,
+				oldest: entry1,
+			},
+		},
+		"Given I push the same entry twice": {
+			pushes: []BufferEntry{entry1, entry1},
+			expected: expected{
+				length: 1,
+				oldest: entry1,
+			},
+		},
+		"Given I push more entries than the buffer can hold": {
+			pushes: []BufferEntry{entry1, entry2, entry3},
+			expected: expected{
+				evicted: 1,
+				length:  2,
+				oldest:  entry2,
+			},
+		},
+		"Given I push two entries and remove the oldest": {
+			pushes:  []BufferEntry{entry1, entry2},
+			removes: []string{entry1.ID},
+			expected: expected{
+				length: 1,
+				oldest: entry2,
+			},
+		},
+		"Given I remove an entry that doesn't exist": {
+			pushes:  []BufferEntry{entry1},
+			removes: []string{"foo"},
+			expected: expected{
+				length: 1,
+				oldest: entry1,
+			},
+		},
+	}
+
+	for desc, tc := range testCases {
+		desc := desc
+		tc := tc
+
+		for name, buffer := range newTestBuffers(t, 2) {
+			buffer := buffer
+
+			t.Run(name+"/"+desc, func(t *testing.T) {
+				ctx := context.Background()
+
+				evicted := 0
+				for _, e := range tc.pushes {
+					n, err := buffer.Push(ctx, e)
+					assert.Nil(t, err)
+					evicted += n
+				}
+
+				for _, id := range tc.removes {
+					assert.Nil(t, buffer.Remove(ctx, id))
+				}
+
+				length, err := buffer.Len(ctx)
+				assert.Nil(t, err)
+
+				oldest, err := buffer.Peek(ctx)
+
+				assert.Equal(t, tc.expected.evicted, evicted)
+				assert.Equal(t, tc.expected.length, length)
+				assert.Equal(t, tc.expected.err, err)
+				assert.Equal(t, tc.expected.oldest, oldest)
+			})
+		}
+	}
+}
+
+func TestDiskBuffer_Reload(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	entry1 := mustNewBufferEntry("123", 1)
+	entry2 := mustNewBufferEntry("123", 2)
+
+	buffer, err := NewDiskBuffer(dir, 10)
+	assert.Nil(t, err)
+
+	_, err = buffer.Push(ctx, entry1)
+	assert.Nil(t, err)
+	_, err = buffer.Push(ctx, entry2)
+	assert.Nil(t, err)
+
+	// Create a new buffer in the same directory to simulate a restart
+	reloaded, err := NewDiskBuffer(dir, 10)
+	assert.Nil(t, err)
+
+	length, err := reloaded.Len(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, length)
+
+	oldest, err := reloaded.Peek(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, entry1, oldest)
+
+	// Entries loaded from disk should still be de-duplicated
+	evicted, err := reloaded.Push(ctx, entry1)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, evicted)
+
+	length, err = reloaded.Len(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, length)
+}
+
+func TestRedisBuffer_PeekCorrupt(t *testing.T) {
+	ctx := context.Background()
+
+	mr, err := miniredis.Run()
+	assert.Nil(t, err)
+	defer mr.Close()
+
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	buffer := NewRedisBuffer(client, 10)
+
+	t.Log("Given the oldest entry in the buffer can't be decoded")
+	assert.Nil(t, client.HSet(ctx, redisBufferEntriesKey, "corrupt", "not json").Err())
+	assert.Nil(t, client.ZAdd(ctx, redisBufferOrderKey, redis.Z{Score: 1, Member: "corrupt"}).Err())
+
+	entry := mustNewBufferEntry("123", 1)
+	_, err = buffer.Push(ctx, entry)
+	assert.Nil(t, err)
+
+	t.Log("When I peek at the buffer")
+	_, err = buffer.Peek(ctx)
+
+	t.Log("Then I get an error and the corrupt entry is removed")
+	assert.NotNil(t, err)
+	assert.False(t, client.HExists(ctx, redisBufferEntriesKey, "corrupt").Val())
+	assert.Equal(t, redis.Nil, client.ZScore(ctx, redisBufferOrderKey, "corrupt").Err())
+
+	t.Log("And the next peek gets the entry behind it")
+	oldest, err := buffer.Peek(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, entry, oldest)
+}
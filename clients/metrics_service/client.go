@@ -2,6 +2,7 @@ package metricsservice
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -11,6 +12,10 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+// ErrMetricsRejected is returned when feature flags rejects metrics with a
+// status that won't change if they're sent again
+var ErrMetricsRejected = errors.New("metrics rejected by feature flags")
+
 // doer is a simple http client that gets passed to the generated admin client
 // and injects the service token into the header before any requests are made
 type doer struct {
@@ -31,29 +36,30 @@ type counter interface {
 
 // Client is a type for interacting with the Feature Flag Metric Service
 type Client struct {
-	log    log.Logger
-	client clientgen.ClientWithResponsesInterface
-	token  func() string
+	log        log.Logger
+	client     clientgen.ClientWithResponsesInterface
+	token      func() string
+	httpClient *http.Client
 
 	sdkUsage         counter
 	metricsForwarded counter
 }
 
+// WithHTTPClient sets the http.Client used to make requests to the metrics service
+func WithHTTPClient(h *http.Client) func(c *Client) {
+	return func(c *Client) {
+		c.httpClient = h
+	}
+}
+
 // NewClient creates a MetricStore
-func NewClient(l log.Logger, addr string, token func() string, reg *prometheus.Registry) (Client, error) {
+func NewClient(l log.Logger, addr string, token func() string, reg *prometheus.Registry, opts ...func(c *Client)) (Client, error) {
 	l = l.With("component", "MetricServiceClient")
-	client, err := clientgen.NewClientWithResponses(
-		addr,
-		clientgen.WithHTTPClient(doer{c: http.DefaultClient}),
-	)
-	if err != nil {
-		return Client{}, err
-	}
 
 	m := Client{
-		log:    l,
-		client: client,
-		token:  token,
+		log:        l,
+		token:      token,
+		httpClient: http.DefaultClient,
 
 		sdkUsage: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
@@ -71,6 +77,19 @@ func NewClient(l log.Logger, addr string, token func() string, reg *prometheus.R
 		),
 	}
 
+	for _, opt := range opts {
+		opt(&m)
+	}
+
+	client, err := clientgen.NewClientWithResponses(
+		addr,
+		clientgen.WithHTTPClient(doer{c: m.httpClient}),
+	)
+	if err != nil {
+		return Client{}, err
+	}
+	m.client = client
+
 	reg.MustRegister(m.sdkUsage, m.metricsForwarded)
 	return m, nil
 }
@@ -102,12 +121,26 @@ func (c Client) PostMetrics(ctx context.Context, envID string, metric domain.Met
 	}
 
 	if res != nil && res.StatusCode() != 200 {
+		if !retryableStatus(res.StatusCode()) {
+			return fmt.Errorf("%w: status_code=%d, body: %s", ErrMetricsRejected, res.StatusCode(), res.Body)
+		}
 		return fmt.Errorf("got non 200 status code from feature flags: status_code=%d, body: %s", res.StatusCode(), res.Body)
 	}
 
 	return nil
 }
 
+// retryableStatus returns whether a request that failed with the given status
+// code might succeed if it's sent again. Auth failures are retryable because
+// the token gets refreshed, everything else in the 4xx range isn't.
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	}
+	return code < 400 || code >= 500
+}
+
 func createAttributeMap(data []clientgen.KeyValue) map[string]string {
 	result := map[string]string{}
 	for _, kv := range data {
@@ -0,0 +1,189 @@
+package metricsservice
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const diskBufferFileExt = ".json"
+
+// DiskBuffer is a Buffer that stores each entry as a file in a directory on
+// local disk. File names are prefixed with the time the entry was added so
+// sorting them by name gives us the entries in oldest-first order.
+type DiskBuffer struct {
+	*sync.Mutex
+	dir        string
+	maxEntries int
+
+	// files holds the names of the files in the buffer sorted oldest first and
+	// ids maps an entry id to its file name
+	files []string
+	ids   map[string]string
+}
+
+// NewDiskBuffer creates a DiskBuffer that stores at most maxEntries entries in
+// dir. Any entries already in dir, e.g. from before the Proxy restarted, are
+// loaded into the buffer.
+func NewDiskBuffer(dir string, maxEntries int) (*DiskBuffer, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create metrics buffer directory: %s", err)
+	}
+
+	des, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metrics buffer directory: %s", err)
+	}
+
+	d := &DiskBuffer{
+		Mutex:      &sync.Mutex{},
+		dir:        dir,
+		maxEntries: maxEntries,
+		files:      []string{},
+		ids:        map[string]string{},
+	}
+
+	for _, de := range des {
+		name := de.Name()
+		if de.IsDir() || !strings.HasSuffix(name, diskBufferFileExt) {
+			continue
+		}
+
+		id, ok := idFromFileName(name)
+		if !ok {
+			continue
+		}
+
+		d.files = append(d.files, name)
+		d.ids[id] = name
+	}
+	sort.Strings(d.files)
+
+	return d, nil
+}
+
+// Push writes an entry to disk and evicts the oldest entries if the buffer is full
+func (d *DiskBuffer) Push(_ context.Context, entry BufferEntry) (int, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	// We've already got this entry so there's nothing to do
+	if _, ok := d.ids[entry.ID]; ok {
+		return 0, nil
+	}
+
+	b, err := jsoniter.Marshal(entry)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal buffer entry: %s", err)
+	}
+
+	name := fmt.Sprintf("%020d_%s%s", time.Now().UnixNano(), entry.ID, diskBufferFileExt)
+
+	// Write to a temp file and rename it so we never leave a partially written
+	// entry in the buffer if the Proxy is killed mid-write
+	tmp := filepath.Join(d.dir, fmt.Sprintf(".%s.tmp", name))
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return 0, fmt.Errorf("failed to write buffer entry: %s", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(d.dir, name)); err != nil {
+		_ = os.Remove(tmp)
+		return 0, fmt.Errorf("failed to write buffer entry: %s", err)
+	}
+
+	d.files = append(d.files, name)
+	d.ids[entry.ID] = name
+
+	evicted := 0
+	for len(d.files) > d.maxEntries {
+		oldest := d.files[0]
+		if err := d.remove(oldest); err != nil {
+			return evicted, err
+		}
+		evicted++
+	}
+
+	return evicted, nil
+}
+
+// Peek returns the oldest entry in the buffer
+func (d *DiskBuffer) Peek(_ context.Context) (BufferEntry, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	if len(d.files) == 0 {
+		return BufferEntry{}, ErrBufferEmpty
+	}
+
+	oldest := d.files[0]
+
+	b, err := os.ReadFile(filepath.Join(d.dir, oldest))
+	if err != nil {
+		return BufferEntry{}, fmt.Errorf("failed to read buffer entry: %s", err)
+	}
+
+	entry := BufferEntry{}
+	if err := jsoniter.Unmarshal(b, &entry); err != nil {
+		// If the file's corrupt there's no point keeping it around
+		_ = d.remove(oldest)
+		return BufferEntry{}, fmt.Errorf("failed to unmarshal buffer entry: %s", err)
+	}
+
+	return entry, nil
+}
+
+// Remove deletes an entry from disk
+func (d *DiskBuffer) Remove(_ context.Context, id string) error {
+	d.Lock()
+	defer d.Unlock()
+
+	name, ok := d.ids[id]
+	if !ok {
+		return nil
+	}
+
+	return d.remove(name)
+}
+
+// Len returns the number of entries in the buffer
+func (d *DiskBuffer) Len(_ context.Context) (int, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	return len(d.files), nil
+}
+
+// remove deletes a file from disk and the index, it must be called while
+// holding the lock
+func (d *DiskBuffer) remove(name string) error {
+	if err := os.Remove(filepath.Join(d.dir, name)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove buffer entry: %s", err)
+	}
+
+	for i, f := range d.files {
+		if f == name {
+			d.files = append(d.files[:i], d.files[i+1:]...)
+			break
+		}
+	}
+
+	if id, ok := idFromFileName(name); ok {
+		delete(d.ids, id)
+	}
+	return nil
+}
+
+// idFromFileName extracts the entry id from a file name in the format <timestamp>_<id>.json
+func idFromFileName(name string) (string, bool) {
+	_, id, ok := strings.Cut(strings.TrimSuffix(name, diskBufferFileExt), "_")
+	if !ok || id == "" {
+		return "", false
+	}
+	return id, true
+}
@@ -0,0 +1,255 @@
+package metricsservice
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/harness/ff-proxy/v2/build"
+	"github.com/harness/ff-proxy/v2/domain"
+	clientgen "github.com/harness/ff-proxy/v2/gen/client"
+	"github.com/harness/ff-proxy/v2/log"
+)
+
+const (
+	// unknownAppID is the value the request ID middleware uses when a request
+	// doesn't include the Harness-SDK-ApplicationID header
+	unknownAppID = "unknown"
+
+	evaluationSDKType     = "server"
+	evaluationSDKName     = "ff-proxy"
+	evaluationSDKLanguage = "go"
+)
+
+type evaluationStore interface {
+	StoreMetrics(ctx context.Context, req domain.MetricsRequest) error
+}
+
+// evaluationKey identifies a count of evaluations the Proxy has served
+type evaluationKey struct {
+	envID     string
+	appID     string
+	flag      string
+	variation string
+	target    string
+}
+
+// EvaluationCounter counts the evaluations the Proxy serves so that analytics
+// still show up for clients that don't post metrics. Evaluations are counted
+// per application and are dropped when they're flushed if that application
+// has posted its own metrics recently so they aren't counted twice. An
+// application's evaluations aren't stored until it's gone a full window
+// without posting metrics after we first saw it, so the evaluations an SDK
+// makes before its first post aren't counted twice either.
+type EvaluationCounter struct {
+	log   log.Logger
+	store evaluationStore
+
+	mx     *sync.Mutex
+	counts map[evaluationKey]int
+
+	// seen is when we first served evaluations to each application that
+	// has evaluations waiting to be flushed
+	seen map[string]time.Time
+
+	// posted is the applications that have posted metrics recently
+	posted PostedApps
+
+	// postedWindow is how long after an application has posted metrics we
+	// treat it as posting its own metrics
+	postedWindow time.Duration
+}
+
+// WithPostedMetricsWindow sets how long after an application posts metrics
+// the evaluations the Proxy serves it are dropped rather than counted
+func WithPostedMetricsWindow(d time.Duration) func(e *EvaluationCounter) {
+	return func(e *EvaluationCounter) {
+		e.postedWindow = d
+	}
+}
+
+// WithPostedApps sets where the EvaluationCounter records the applications that
+// have posted metrics. Proxies sharing a redis should share this so that
+// applications that post their metrics to another Proxy aren't counted.
+func WithPostedApps(p PostedApps) func(e *EvaluationCounter) {
+	return func(e *EvaluationCounter) {
+		e.posted = p
+	}
+}
+
+// NewEvaluationCounter creates an EvaluationCounter that stores the counts in
+// the given store when it's flushed
+func NewEvaluationCounter(l log.Logger, store evaluationStore, opts ...func(e *EvaluationCounter)) *EvaluationCounter {
+	l = l.With("component", "EvaluationCounter")
+	e := &EvaluationCounter{
+		log:          l,
+		store:        store,
+		mx:           &sync.Mutex{},
+		counts:       map[evaluationKey]int{},
+		seen:         map[string]time.Time{},
+		posted:       newMemoryPostedApps(),
+		postedWindow: 2 * time.Minute,
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// CountEvaluations records that the evaluations have been served to a target
+// by an application
+func (e *EvaluationCounter) CountEvaluations(envID string, appID string, target string, evaluations ...clientgen.Evaluation) {
+	if len(evaluations) == 0 {
+		return
+	}
+
+	e.mx.Lock()
+	defer e.mx.Unlock()
+
+	if _, ok := e.seen[appID]; !ok {
+		e.seen[appID] = time.Now()
+	}
+
+	for _, eval := range evaluations {
+		key := evaluationKey{
+			envID:     envID,
+			appID:     appID,
+			flag:      eval.Flag,
+			variation: domain.SafePtrDereference(eval.Identifier),
+			target:    target,
+		}
+		e.counts[key]++
+	}
+}
+
+// SDKPostedMetrics records that an application has posted its own metrics.
+// Requests without an application ID can't be matched to evaluations so
+// they're ignored.
+func (e *EvaluationCounter) SDKPostedMetrics(ctx context.Context, appID string) {
+	if appID == "" || appID == unknownAppID {
+		return
+	}
+
+	if err := e.posted.SetPosted(ctx, appID, e.postedWindow); err != nil {
+		e.log.Error("failed to record that app posted metrics", "app", appID, "err", err)
+	}
+}
+
+// Start flushes the counts to the store every interval until the context is
+// cancelled. It doesn't flush when it stops, callers that are shutting down
+// should call Flush themselves before flushing the store so that the last of
+// the counts make it in.
+func (e *EvaluationCounter) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.Flush(ctx)
+		}
+	}
+}
+
+// Flush aggregates the evaluations counted since the last flush into a
+// metrics request per environment and stores them. Evaluations for apps that
+// we haven't seen for a full window yet are kept until the next flush.
+func (e *EvaluationCounter) Flush(ctx context.Context) {
+	now := time.Now()
+
+	e.mx.Lock()
+	counts := e.counts
+	seen := e.seen
+	e.counts = map[evaluationKey]int{}
+	e.seen = map[string]time.Time{}
+	e.mx.Unlock()
+
+	appIDs := make([]string, 0, len(seen))
+	for appID := range seen {
+		appIDs = append(appIDs, appID)
+	}
+
+	posted, err := e.posted.Posted(ctx, appIDs)
+	if err != nil {
+		// We don't know which apps are posting their own metrics so hold
+		// on to everything until the next flush
+		e.log.Error("failed to get the apps that have posted metrics", "err", err)
+		e.keep(counts, seen)
+		return
+	}
+
+	pending := map[evaluationKey]int{}
+	pendingSeen := map[string]time.Time{}
+
+	metrics := map[string][]clientgen.MetricsData{}
+	for key, count := range counts {
+		if posted[key.appID] {
+			continue
+		}
+
+		if now.Sub(seen[key.appID]) < e.postedWindow {
+			pending[key] = count
+			pendingSeen[key.appID] = seen[key.appID]
+			continue
+		}
+
+		metrics[key.envID] = append(metrics[key.envID], clientgen.MetricsData{
+			Attributes:  evaluationAttributes(key),
+			Count:       count,
+			MetricsType: clientgen.FFMETRICS,
+			Timestamp:   now.UnixMilli(),
+		})
+	}
+
+	for envID, md := range metrics {
+		req := domain.MetricsRequest{
+			EnvironmentID: envID,
+			Metrics: clientgen.Metrics{
+				MetricsData: domain.ToPtr(md),
+			},
+		}
+
+		b, err := json.Marshal(req.Metrics)
+		if err == nil {
+			req.Size = len(b)
+		}
+
+		if err := e.store.StoreMetrics(ctx, req); err != nil {
+			e.log.Error("failed to store evaluation metrics", "environment", envID, "err", err)
+		}
+	}
+
+	e.keep(pending, pendingSeen)
+}
+
+// keep adds counts that weren't flushed back to the counter
+func (e *EvaluationCounter) keep(counts map[evaluationKey]int, seen map[string]time.Time) {
+	e.mx.Lock()
+	defer e.mx.Unlock()
+
+	for key, count := range counts {
+		e.counts[key] += count
+	}
+	for appID, t := range seen {
+		if existing, ok := e.seen[appID]; !ok || t.Before(existing) {
+			e.seen[appID] = t
+		}
+	}
+}
+
+func evaluationAttributes(key evaluationKey) []clientgen.KeyValue {
+	return []clientgen.KeyValue{
+		{Key: "featureIdentifier", Value: key.flag},
+		{Key: "featureName", Value: key.flag},
+		{Key: "variationIdentifier", Value: key.variation},
+		{Key: "target", Value: key.target},
+		{Key: "SDK_TYPE", Value: evaluationSDKType},
+		{Key: "SDK_NAME", Value: evaluationSDKName},
+		{Key: "SDK_LANGUAGE", Value: evaluationSDKLanguage},
+		{Key: "SDK_VERSION", Value: build.Version},
+	}
+}
@@ -0,0 +1,150 @@
+package metricsservice
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/harness/ff-proxy/v2/domain"
+	clientgen "github.com/harness/ff-proxy/v2/gen/client"
+	"github.com/harness/ff-proxy/v2/log"
+)
+
+type mockEvaluationStore struct {
+	requests []domain.MetricsRequest
+}
+
+func (m *mockEvaluationStore) StoreMetrics(_ context.Context, req domain.MetricsRequest) error {
+	m.requests = append(m.requests, req)
+	return nil
+}
+
+func getAttribute(attributes []clientgen.KeyValue, key string) string {
+	for _, kv := range attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return ""
+}
+
+func TestEvaluationCounter_Flush(t *testing.T) {
+	ctx := context.Background()
+	on := clientgen.Evaluation{Flag: "foo", Identifier: domain.ToPtr("true")}
+	off := clientgen.Evaluation{Flag: "foo", Identifier: domain.ToPtr("false")}
+
+	window := 20 * time.Millisecond
+	store := &mockEvaluationStore{}
+	counter := NewEvaluationCounter(log.NoOpLogger{}, store, WithPostedMetricsWindow(window))
+
+	t.Log("Given I've served evaluations to an app that posts metrics and one that doesn't")
+	counter.CountEvaluations("123", "app-1", "target-1", on, on)
+	counter.CountEvaluations("123", "app-1", "target-1", on, off)
+	counter.CountEvaluations("123", "app-2", "target-1", on)
+	counter.SDKPostedMetrics(ctx, "app-2")
+
+	t.Log("When I flush the counter before a full window has passed")
+	counter.Flush(ctx)
+
+	t.Log("Then nothing is stored yet")
+	assert.Len(t, store.requests, 0)
+
+	t.Log("When a full window has passed and I flush the counter")
+	time.Sleep(2 * window)
+	counter.SDKPostedMetrics(ctx, "app-2")
+	counter.Flush(ctx)
+
+	t.Log("Then only the evaluations for the app that doesn't post metrics are stored")
+	assert.Len(t, store.requests, 1)
+	assert.Equal(t, "123", store.requests[0].EnvironmentID)
+
+	counts := map[string]int{}
+	for _, md := range domain.SafePtrDereference(store.requests[0].MetricsData) {
+		assert.Equal(t, "target-1", getAttribute(md.Attributes, "target"))
+		assert.Equal(t, "server", getAttribute(md.Attributes, "SDK_TYPE"))
+		counts[getAttribute(md.Attributes, "variationIdentifier")] += md.Count
+	}
+	assert.Equal(t, map[string]int{"true": 3, "false": 1}, counts)
+
+	t.Log("And flushing again doesn't store anything")
+	counter.Flush(ctx)
+	assert.Len(t, store.requests, 1)
+
+	t.Log("When an app stops posting metrics")
+	time.Sleep(2 * window)
+	counter.CountEvaluations("123", "app-2", "target-1", on)
+	counter.Flush(ctx)
+	assert.Len(t, store.requests, 1)
+
+	t.Log("Then its evaluations are stored once it's gone a full window without posting")
+	time.Sleep(2 * window)
+	counter.Flush(ctx)
+	assert.Len(t, store.requests, 2)
+}
+
+func TestEvaluationCounter_FirstWindow(t *testing.T) {
+	ctx := context.Background()
+	on := clientgen.Evaluation{Flag: "foo", Identifier: domain.ToPtr("true")}
+
+	window := 100 * time.Millisecond
+	store := &mockEvaluationStore{}
+	counter := NewEvaluationCounter(log.NoOpLogger{}, store, WithPostedMetricsWindow(window))
+
+	t.Log("Given I've served evaluations to an app that hasn't posted its first metrics yet")
+	counter.CountEvaluations("123", "app-1", "target-1", on)
+
+	t.Log("When the counter is flushed before the app posts its metrics")
+	counter.Flush(ctx)
+
+	t.Log("And the app posts its metrics part way through its first window")
+	time.Sleep(window / 2)
+	counter.SDKPostedMetrics(ctx, "app-1")
+
+	time.Sleep(window/2 + 10*time.Millisecond)
+	counter.Flush(ctx)
+
+	t.Log("Then its evaluations are never stored")
+	assert.Len(t, store.requests, 0)
+}
+
+func TestEvaluationCounter_SharedPostedApps(t *testing.T) {
+	ctx := context.Background()
+	on := clientgen.Evaluation{Flag: "foo", Identifier: domain.ToPtr("true")}
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+
+	posted := NewRedisPostedApps(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	window := 20 * time.Millisecond
+
+	store := &mockEvaluationStore{}
+	replica1 := NewEvaluationCounter(log.NoOpLogger{}, store, WithPostedMetricsWindow(window), WithPostedApps(posted))
+	replica2 := NewEvaluationCounter(log.NoOpLogger{}, store, WithPostedMetricsWindow(window), WithPostedApps(posted))
+
+	t.Log("Given one Proxy serves an app evaluations and it posts its metrics to another")
+	replica1.CountEvaluations("123", "app-1", "target-1", on)
+	replica2.SDKPostedMetrics(ctx, "app-1")
+
+	t.Log("When the first Proxy flushes after a full window")
+	time.Sleep(2 * window)
+	replica1.Flush(ctx)
+
+	t.Log("Then the evaluations aren't stored")
+	assert.Len(t, store.requests, 0)
+
+	t.Log("When the app's posted metrics have expired")
+	mr.FastForward(window)
+	replica1.CountEvaluations("123", "app-1", "target-1", on)
+	time.Sleep(2 * window)
+	replica1.Flush(ctx)
+
+	t.Log("Then its evaluations are stored")
+	assert.Len(t, store.requests, 1)
+}
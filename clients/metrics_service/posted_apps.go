@@ -0,0 +1,109 @@
+package metricsservice
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const redisPostedAppsKeyPrefix = "ffproxy:metrics:posted_app:"
+
+// PostedApps records which applications have posted their own metrics recently
+type PostedApps interface {
+	// SetPosted records that an application has posted metrics. It's treated
+	// as posting its own metrics until the window has passed.
+	SetPosted(ctx context.Context, appID string, window time.Duration) error
+
+	// Posted returns which of the applications have posted metrics within
+	// their window
+	Posted(ctx context.Context, appIDs []string) (map[string]bool, error)
+}
+
+// memoryPostedApps is a PostedApps that's local to a single Proxy
+type memoryPostedApps struct {
+	mx      *sync.Mutex
+	expires map[string]time.Time
+}
+
+func newMemoryPostedApps() memoryPostedApps {
+	return memoryPostedApps{
+		mx:      &sync.Mutex{},
+		expires: map[string]time.Time{},
+	}
+}
+
+// SetPosted makes memoryPostedApps implement the PostedApps interface
+func (m memoryPostedApps) SetPosted(_ context.Context, appID string, window time.Duration) error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	m.expires[appID] = time.Now().Add(window)
+	return nil
+}
+
+// Posted makes memoryPostedApps implement the PostedApps interface
+func (m memoryPostedApps) Posted(_ context.Context, appIDs []string) (map[string]bool, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	now := time.Now()
+	for appID, expires := range m.expires {
+		if now.After(expires) {
+			delete(m.expires, appID)
+		}
+	}
+
+	posted := make(map[string]bool, len(appIDs))
+	for _, appID := range appIDs {
+		_, ok := m.expires[appID]
+		posted[appID] = ok
+	}
+	return posted, nil
+}
+
+// RedisPostedApps is a PostedApps that's shared by every Proxy using the same
+// redis, so an application that posts its metrics to one Proxy isn't counted
+// by the others. Each application has a key that expires once its window has
+// passed.
+type RedisPostedApps struct {
+	client redis.UniversalClient
+}
+
+// NewRedisPostedApps creates a RedisPostedApps
+func NewRedisPostedApps(client redis.UniversalClient) RedisPostedApps {
+	return RedisPostedApps{client: client}
+}
+
+// SetPosted makes RedisPostedApps implement the PostedApps interface
+func (r RedisPostedApps) SetPosted(ctx context.Context, appID string, window time.Duration) error {
+	return r.client.Set(ctx, redisPostedAppsKeyPrefix+appID, 1, window).Err()
+}
+
+// Posted makes RedisPostedApps implement the PostedApps interface. The keys are
+// checked in a pipeline rather than with a single MGET so that it works on a
+// Redis Cluster where they can be in different slots.
+func (r RedisPostedApps) Posted(ctx context.Context, appIDs []string) (map[string]bool, error) {
+	posted := make(map[string]bool, len(appIDs))
+	if len(appIDs) == 0 {
+		return posted, nil
+	}
+
+	cmds := make([]*redis.IntCmd, len(appIDs))
+	_, err := r.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for i, appID := range appIDs {
+			cmds[i] = p.Exists(ctx, redisPostedAppsKeyPrefix+appID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to check which apps have posted metrics: %w", err)
+	}
+
+	for i, appID := range appIDs {
+		posted[appID] = cmds[i].Val() > 0
+	}
+	return posted, nil
+}
@@ -0,0 +1,80 @@
+package metricsservice
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PrometheusBuffer is a Buffer decorator that records the depth of the buffer
+// and the number of entries that have been dropped from it
+type PrometheusBuffer struct {
+	next    Buffer
+	depth   prometheus.Gauge
+	dropped *prometheus.CounterVec
+}
+
+// NewPrometheusBuffer creates a PrometheusBuffer
+func NewPrometheusBuffer(next Buffer, reg prometheus.Registerer) PrometheusBuffer {
+	p := PrometheusBuffer{
+		next: next,
+		depth: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "ff_proxy_metrics_buffer_depth",
+			Help: "Records the number of metrics requests waiting in the buffer to be retried",
+		}),
+		dropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ff_proxy_metrics_buffer_dropped",
+			Help: "Records the number of metrics requests dropped from the buffer, either because it was full, because they couldn't be written to it or because feature flags rejected them",
+		},
+			[]string{"reason"},
+		),
+	}
+
+	reg.MustRegister(p.depth, p.dropped)
+	return p
+}
+
+// Push calls the decorated Buffer's Push method and records any dropped entries
+func (p PrometheusBuffer) Push(ctx context.Context, entry BufferEntry) (int, error) {
+	evicted, err := p.next.Push(ctx, entry)
+	if evicted > 0 {
+		p.dropped.WithLabelValues("evicted").Add(float64(evicted))
+	}
+	if err != nil {
+		p.dropped.WithLabelValues("error").Inc()
+	}
+
+	p.recordDepth(ctx)
+	return evicted, err
+}
+
+// Peek calls the decorated Buffer's Peek method
+func (p PrometheusBuffer) Peek(ctx context.Context) (BufferEntry, error) {
+	return p.next.Peek(ctx)
+}
+
+// Remove calls the decorated Buffer's Remove method and records the new depth
+func (p PrometheusBuffer) Remove(ctx context.Context, id string) error {
+	err := p.next.Remove(ctx, id)
+	p.recordDepth(ctx)
+	return err
+}
+
+// RecordRejected records metrics that were dropped rather than buffered or
+// retried because feature flags rejected them
+func (p PrometheusBuffer) RecordRejected() {
+	p.dropped.WithLabelValues("rejected").Inc()
+}
+
+// Len calls the decorated Buffer's Len method
+func (p PrometheusBuffer) Len(ctx context.Context) (int, error) {
+	return p.next.Len(ctx)
+}
+
+func (p PrometheusBuffer) recordDepth(ctx context.Context) {
+	n, err := p.next.Len(ctx)
+	if err != nil {
+		return
+	}
+	p.depth.Set(float64(n))
+}
@@ -174,6 +174,25 @@ func (q Queue) handleTargetData(ctx context.Context, m domain.MetricsRequest) er
 	return nil
 }
 
+// Drain removes and returns any metrics that are currently held in the queue
+// without waiting for the ticker to expire. It's used during shutdown so that
+// metrics that haven't been flushed yet aren't lost.
+func (q Queue) Drain() []map[string]domain.MetricsRequest {
+	drained := []map[string]domain.MetricsRequest{}
+
+	if metrics := q.metricsData.get(); len(metrics) > 0 {
+		drained = append(drained, metrics)
+		q.metricsData.flush()
+	}
+
+	if targets := q.targetData.get(); len(targets) > 0 {
+		drained = append(drained, targets)
+		q.targetData.flush()
+	}
+
+	return drained
+}
+
 // Listen returns a channel that the queue flushes metrics requests to
 func (q Queue) Listen(ctx context.Context) <-chan map[string]domain.MetricsRequest {
 	out := make(chan map[string]domain.MetricsRequest)
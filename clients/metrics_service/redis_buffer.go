@@ -0,0 +1,130 @@
+package metricsservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/redis/go-redis/v9"
+)
+
+// The keys share a hash tag so they're in the same slot on a Redis Cluster,
+// which they need to be to update them both in a script or transaction
+const (
+	redisBufferOrderKey   = "{ffproxy:metrics_buffer}:order"
+	redisBufferEntriesKey = "{ffproxy:metrics_buffer}:entries"
+)
+
+// pushScript adds an entry to the buffer and evicts the oldest entries if the
+// buffer is full. Doing this in a script means an entry can't end up in the
+// hash without being in the sorted set, or vice versa, if we fail part way
+// through. It returns the number of entries that were evicted.
+//
+// KEYS[1] - the entries hash
+// KEYS[2] - the order sorted set
+// ARGV[1] - the id of the entry
+// ARGV[2] - the entry
+// ARGV[3] - the entry's score
+// ARGV[4] - the max number of entries
+var pushScript = redis.NewScript(`
+if redis.call("HSETNX", KEYS[1], ARGV[1], ARGV[2]) == 0 then
+	return 0
+end
+redis.call("ZADD", KEYS[2], ARGV[3], ARGV[1])
+
+local size = redis.call("ZCARD", KEYS[2])
+local maxEntries = tonumber(ARGV[4])
+if size <= maxEntries then
+	return 0
+end
+
+-- ZPOPMIN returns the members and their scores
+local evicted = redis.call("ZPOPMIN", KEYS[2], size - maxEntries)
+local n = 0
+for i = 1, #evicted, 2 do
+	redis.call("HDEL", KEYS[1], evicted[i])
+	n = n + 1
+end
+return n
+`)
+
+// RedisBuffer is a Buffer that stores entries in redis. Entry ids are kept in a
+// sorted set scored by the time they were added so the oldest entry can always
+// be retried or evicted first, and the entries themselves are kept in a hash.
+type RedisBuffer struct {
+	client     redis.UniversalClient
+	maxEntries int64
+}
+
+// NewRedisBuffer creates a RedisBuffer that holds at most maxEntries entries
+func NewRedisBuffer(client redis.UniversalClient, maxEntries int64) RedisBuffer {
+	return RedisBuffer{
+		client:     client,
+		maxEntries: maxEntries,
+	}
+}
+
+// Push adds an entry to the buffer and evicts the oldest entries if the buffer is full
+func (r RedisBuffer) Push(ctx context.Context, entry BufferEntry) (int, error) {
+	b, err := jsoniter.Marshal(entry)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal buffer entry: %s", err)
+	}
+
+	keys := []string{redisBufferEntriesKey, redisBufferOrderKey}
+	evicted, err := pushScript.Run(ctx, r.client, keys, entry.ID, b, time.Now().UnixNano(), r.maxEntries).Int()
+	if err != nil {
+		return 0, fmt.Errorf("failed to push buffer entry: %w", err)
+	}
+	return evicted, nil
+}
+
+// Peek returns the oldest entry in the buffer
+func (r RedisBuffer) Peek(ctx context.Context) (BufferEntry, error) {
+	ids, err := r.client.ZRange(ctx, redisBufferOrderKey, 0, 0).Result()
+	if err != nil {
+		return BufferEntry{}, err
+	}
+
+	if len(ids) == 0 {
+		return BufferEntry{}, ErrBufferEmpty
+	}
+
+	b, err := r.client.HGet(ctx, redisBufferEntriesKey, ids[0]).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			// The entry has gone missing so tidy up the id to make sure
+			// we don't keep trying to read it
+			_ = r.client.ZRem(ctx, redisBufferOrderKey, ids[0]).Err()
+		}
+		return BufferEntry{}, err
+	}
+
+	entry := BufferEntry{}
+	if err := jsoniter.Unmarshal(b, &entry); err != nil {
+		// If the entry's corrupt there's no point keeping it around, it'd
+		// stop us getting to any of the entries behind it
+		_ = r.Remove(ctx, ids[0])
+		return BufferEntry{}, fmt.Errorf("failed to unmarshal buffer entry: %s", err)
+	}
+
+	return entry, nil
+}
+
+// Remove removes an entry from the buffer
+func (r RedisBuffer) Remove(ctx context.Context, id string) error {
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, redisBufferOrderKey, id)
+		pipe.HDel(ctx, redisBufferEntriesKey, id)
+		return nil
+	})
+	return err
+}
+
+// Len returns the number of entries in the buffer
+func (r RedisBuffer) Len(ctx context.Context) (int, error) {
+	n, err := r.client.ZCard(ctx, redisBufferOrderKey).Result()
+	return int(n), err
+}
@@ -0,0 +1,108 @@
+package metricsservice
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/harness/ff-proxy/v2/domain"
+)
+
+const (
+	// otherLabelValue is the value used for every label once a sink has
+	// reached its cardinality limit
+	otherLabelValue = "other"
+)
+
+// Sink receives a copy of the aggregated metrics that the Worker sends to Harness
+// SaaS so they can be exported to local observability tools
+type Sink interface {
+	Write(ctx context.Context, envID string, r domain.MetricsRequest) error
+}
+
+// evaluation is the number of times a variation of a flag was evaluated by a
+// type of SDK in an environment
+type evaluation struct {
+	EnvironmentID string `json:"environment"`
+	Feature       string `json:"feature"`
+	Variation     string `json:"variation"`
+	SDKType       string `json:"sdk_type"`
+	Count         int    `json:"count"`
+}
+
+func (e evaluation) labels() []string {
+	return []string{e.EnvironmentID, e.Feature, e.Variation, e.SDKType}
+}
+
+func (e *evaluation) setLabels(labels []string) {
+	e.EnvironmentID, e.Feature, e.Variation, e.SDKType = labels[0], labels[1], labels[2], labels[3]
+}
+
+// evaluationsFromMetrics extracts the evaluation counts from a metrics request
+func evaluationsFromMetrics(envID string, r domain.MetricsRequest) []evaluation {
+	if r.MetricsData == nil {
+		return nil
+	}
+
+	evaluations := make([]evaluation, 0, len(*r.MetricsData))
+	for _, md := range *r.MetricsData {
+		attrs := createAttributeMap(md.Attributes)
+
+		// Some SDKs only send the featureName but use the identifier as its value
+		feature := attrs["featureIdentifier"]
+		if feature == "" {
+			feature = attrs["featureName"]
+		}
+
+		evaluations = append(evaluations, evaluation{
+			EnvironmentID: envID,
+			Feature:       feature,
+			Variation:     attrs["variationIdentifier"],
+			SDKType:       getSDKType(attrs),
+			Count:         md.Count,
+		})
+	}
+
+	return evaluations
+}
+
+// cardinalityLimiter caps the number of unique label sets a sink exports. Once
+// the limit's been reached any label sets that haven't been seen before have
+// all of their values replaced with "other".
+type cardinalityLimiter struct {
+	*sync.Mutex
+	max  int
+	seen map[string]struct{}
+}
+
+func newCardinalityLimiter(max int) cardinalityLimiter {
+	return cardinalityLimiter{
+		Mutex: &sync.Mutex{},
+		max:   max,
+		seen:  map[string]struct{}{},
+	}
+}
+
+// limit returns the labels unchanged if they've been seen before or there's
+// still room for them, otherwise it returns a set of "other" labels
+func (c cardinalityLimiter) limit(labels []string) []string {
+	key := strings.Join(labels, "\x00")
+
+	c.Lock()
+	defer c.Unlock()
+
+	if _, ok := c.seen[key]; ok {
+		return labels
+	}
+
+	if len(c.seen) < c.max {
+		c.seen[key] = struct{}{}
+		return labels
+	}
+
+	other := make([]string, len(labels))
+	for i := range other {
+		other[i] = otherLabelValue
+	}
+	return other
+}
@@ -0,0 +1,73 @@
+package metricsservice
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/harness/ff-proxy/v2/domain"
+)
+
+// fileSinkRecord is a single line written by the FileSink
+type fileSinkRecord struct {
+	Timestamp int64 `json:"timestamp"`
+	evaluation
+}
+
+// FileSink is a Sink that appends evaluation counts to a file as JSON lines
+type FileSink struct {
+	*sync.Mutex
+	w       io.Writer
+	limiter cardinalityLimiter
+	now     func() time.Time
+}
+
+// NewFileSink creates a FileSink that appends to the file at path, creating it
+// if it doesn't exist. At most maxSeries label combinations are written, any
+// others are written with "other" labels.
+func NewFileSink(path string, maxSeries int) (FileSink, io.Closer, error) {
+	// #nosec G304
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return FileSink{}, nil, fmt.Errorf("failed to open metrics sink file: %s", err)
+	}
+
+	return newFileSink(f, maxSeries), f, nil
+}
+
+func newFileSink(w io.Writer, maxSeries int) FileSink {
+	return FileSink{
+		Mutex:   &sync.Mutex{},
+		w:       w,
+		limiter: newCardinalityLimiter(maxSeries),
+		now:     time.Now,
+	}
+}
+
+// Write writes a line for each evaluation in the metrics request
+func (f FileSink) Write(_ context.Context, envID string, r domain.MetricsRequest) error {
+	evaluations := evaluationsFromMetrics(envID, r)
+	if len(evaluations) == 0 {
+		return nil
+	}
+
+	ts := f.now().UnixMilli()
+
+	f.Lock()
+	defer f.Unlock()
+
+	enc := jsoniter.NewEncoder(f.w)
+	for _, e := range evaluations {
+		e.setLabels(f.limiter.limit(e.labels()))
+
+		if err := enc.Encode(fileSinkRecord{Timestamp: ts, evaluation: e}); err != nil {
+			return fmt.Errorf("failed to write to metrics sink file: %s", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package metricsservice
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/harness/ff-proxy/v2/domain"
+)
+
+// OTLPSink is a Sink that records evaluation counts on an OpenTelemetry counter
+// so they can be exported to an OTLP collector
+type OTLPSink struct {
+	evaluations metric.Int64Counter
+	limiter     cardinalityLimiter
+}
+
+// NewOTLPSink creates an OTLPSink that records at most maxSeries attribute
+// combinations using the given Meter
+func NewOTLPSink(meter metric.Meter, maxSeries int) (OTLPSink, error) {
+	evaluations, err := meter.Int64Counter(
+		"ff_proxy.sdk.evaluations",
+		metric.WithDescription("The number of flag evaluations reported by SDKs"),
+	)
+	if err != nil {
+		return OTLPSink{}, fmt.Errorf("failed to create evaluations counter: %s", err)
+	}
+
+	return OTLPSink{
+		evaluations: evaluations,
+		limiter:     newCardinalityLimiter(maxSeries),
+	}, nil
+}
+
+// Write adds each evaluation in the metrics request to the counter
+func (o OTLPSink) Write(ctx context.Context, envID string, r domain.MetricsRequest) error {
+	for _, e := range evaluationsFromMetrics(envID, r) {
+		e.setLabels(o.limiter.limit(e.labels()))
+
+		o.evaluations.Add(ctx, int64(e.Count), metric.WithAttributes(
+			attribute.String("environment", e.EnvironmentID),
+			attribute.String("feature", e.Feature),
+			attribute.String("variation", e.Variation),
+			attribute.String("sdk_type", e.SDKType),
+		))
+	}
+	return nil
+}
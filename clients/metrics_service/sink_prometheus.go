@@ -0,0 +1,39 @@
+package metricsservice
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/harness/ff-proxy/v2/domain"
+)
+
+// PrometheusSink is a Sink that exports evaluation counts as a prometheus counter
+type PrometheusSink struct {
+	evaluations *prometheus.CounterVec
+	limiter     cardinalityLimiter
+}
+
+// NewPrometheusSink creates a PrometheusSink that exports at most maxSeries label combinations
+func NewPrometheusSink(reg prometheus.Registerer, maxSeries int) PrometheusSink {
+	p := PrometheusSink{
+		evaluations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ff_proxy_sdk_evaluations_total",
+			Help: "Records the number of flag evaluations reported by SDKs",
+		},
+			[]string{"envID", "feature", "variation", "sdk_type"},
+		),
+		limiter: newCardinalityLimiter(maxSeries),
+	}
+
+	reg.MustRegister(p.evaluations)
+	return p
+}
+
+// Write increments the evaluation counter for each evaluation in the metrics request
+func (p PrometheusSink) Write(_ context.Context, envID string, r domain.MetricsRequest) error {
+	for _, e := range evaluationsFromMetrics(envID, r) {
+		p.evaluations.WithLabelValues(p.limiter.limit(e.labels())...).Add(float64(e.Count))
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package metricsservice
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/harness/ff-proxy/v2/domain"
+	clientgen "github.com/harness/ff-proxy/v2/gen/client"
+)
+
+func newEvaluationMetrics(envID string, evaluations ...evaluation) domain.MetricsRequest {
+	data := []clientgen.MetricsData{}
+	for _, e := range evaluations {
+		data = append(data, clientgen.MetricsData{
+			Attributes: []clientgen.KeyValue{
+				{Key: "featureIdentifier", Value: e.Feature},
+				{Key: "variationIdentifier", Value: e.Variation},
+				{Key: "SDK_TYPE", Value: e.SDKType},
+			},
+			Count:       e.Count,
+			MetricsType: "FFMETRICS",
+			Timestamp:   111,
+		})
+	}
+
+	return domain.MetricsRequest{
+		EnvironmentID: envID,
+		Metrics:       clientgen.Metrics{MetricsData: &data},
+	}
+}
+
+func TestEvaluationsFromMetrics(t *testing.T) {
+	testCases := map[string]struct {
+		metrics  domain.MetricsRequest
+		expected []evaluation
+	}{
+		"Given I have a metrics request with no MetricsData": {
+			metrics:  domain.MetricsRequest{EnvironmentID: "123"},
+			expected: nil,
+		},
+		"Given I have a metrics request with a featureIdentifier": {
+			metrics: newEvaluationMetrics("123", evaluation{Feature: "flag1", Variation: "true", SDKType: "server", Count: 3}),
+			expected: []evaluation{
+				{EnvironmentID: "123", Feature: "flag1", Variation: "true", SDKType: "server", Count: 3},
+			},
+		},
+		"Given I have a metrics request that only has a featureName": {
+			metrics: domain.MetricsRequest{
+				EnvironmentID: "123",
+				Metrics: clientgen.Metrics{MetricsData: &[]clientgen.MetricsData{
+					{
+						Attributes: []clientgen.KeyValue{
+							{Key: "featureName", Value: "flag1"},
+							{Key: "variationIdentifier", Value: "false"},
+							{Key: "SDK_TYPE", Value: "client"},
+						},
+						Count: 1,
+					},
+				}},
+			},
+			expected: []evaluation{
+				{EnvironmentID: "123", Feature: "flag1", Variation: "false", SDKType: "client", Count: 1},
+			},
+		},
+	}
+
+	for desc, tc := range testCases {
+		desc := desc
+		tc := tc
+
+		t.Run(desc, func(t *testing.T) {
+			assert.Equal(t, tc.expected, evaluationsFromMetrics("123", tc.metrics))
+		})
+	}
+}
+
+func TestCardinalityLimiter(t *testing.T) {
+	c := newCardinalityLimiter(2)
+
+	assert.Equal(t, []string{"a", "b"}, c.limit([]string{"a", "b"}))
+	assert.Equal(t, []string{"c", "d"}, c.limit([]string{"c", "d"}))
+
+	// We've hit the limit so new label sets get collapsed
+	assert.Equal(t, []string{"other", "other"}, c.limit([]string{"e", "f"}))
+
+	// But label sets we've already seen are still allowed through
+	assert.Equal(t, []string{"a", "b"}, c.limit([]string{"a", "b"}))
+}
+
+func TestPrometheusSink_Write(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	sink := NewPrometheusSink(reg, 2)
+
+	metrics := newEvaluationMetrics("123",
+		evaluation{Feature: "flag1", Variation: "true", SDKType: "server", Count: 3},
+		evaluation{Feature: "flag1", Variation: "false", SDKType: "server", Count: 2},
+		evaluation{Feature: "flag2", Variation: "true", SDKType: "client", Count: 1},
+		evaluation{Feature: "flag3", Variation: "true", SDKType: "client", Count: 4},
+	)
+
+	assert.Nil(t, sink.Write(context.Background(), "123", metrics))
+	assert.Nil(t, sink.Write(context.Background(), "123", metrics))
+
+	assert.Equal(t, float64(6), testutil.ToFloat64(sink.evaluations.WithLabelValues("123", "flag1", "true", "server")))
+	assert.Equal(t, float64(4), testutil.ToFloat64(sink.evaluations.WithLabelValues("123", "flag1", "false", "server")))
+	assert.Equal(t, float64(10), testutil.ToFloat64(sink.evaluations.WithLabelValues("other", "other", "other", "other")))
+	assert.Equal(t, 3, testutil.CollectAndCount(sink.evaluations))
+}
+
+func TestOTLPSink_Write(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	sink, err := NewOTLPSink(provider.Meter("test"), 1)
+	assert.Nil(t, err)
+
+	metrics := newEvaluationMetrics("123",
+		evaluation{Feature: "flag1", Variation: "true", SDKType: "server", Count: 3},
+		evaluation{Feature: "flag2", Variation: "true", SDKType: "client", Count: 1},
+	)
+	assert.Nil(t, sink.Write(context.Background(), "123", metrics))
+
+	rm := metricdata.ResourceMetrics{}
+	assert.Nil(t, reader.Collect(context.Background(), &rm))
+
+	assert.Len(t, rm.ScopeMetrics, 1)
+	assert.Len(t, rm.ScopeMetrics[0].Metrics, 1)
+
+	sum, ok := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Sum[int64])
+	assert.True(t, ok)
+
+	actual := map[string]int64{}
+	for _, dp := range sum.DataPoints {
+		feature, _ := dp.Attributes.Value("feature")
+		actual[feature.AsString()] = dp.Value
+	}
+
+	assert.Equal(t, map[string]int64{"flag1": 3, "other": 1}, actual)
+}
+
+func TestFileSink_Write(t *testing.T) {
+	buf := &bytes.Buffer{}
+	sink := newFileSink(buf, 1)
+	sink.now = func() time.Time { return time.UnixMilli(1700000000000) }
+
+	metrics := newEvaluationMetrics("123",
+		evaluation{Feature: "flag1", Variation: "true", SDKType: "server", Count: 3},
+		evaluation{Feature: "flag2", Variation: "true", SDKType: "client", Count: 1},
+	)
+	assert.Nil(t, sink.Write(context.Background(), "123", metrics))
+
+	expected := `{"timestamp":1700000000000,"environment":"123","feature":"flag1","variation":"true","sdk_type":"server","count":3}
+{"timestamp":1700000000000,"environment":"other","feature":"other","variation":"other","sdk_type":"other","count":1}
+`
+	assert.Equal(t, expected, buf.String())
+}
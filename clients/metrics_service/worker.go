@@ -3,17 +3,26 @@ package metricsservice
 import (
 	"context"
 	"errors"
+	"fmt"
 	"reflect"
 	"time"
 
 	"github.com/harness/ff-proxy/v2/domain"
 	"github.com/harness/ff-proxy/v2/log"
 	jsoniter "github.com/json-iterator/go"
+	"gopkg.in/cenkalti/backoff.v1"
+)
+
+const (
+	// bufferPollInterval is how often the Worker checks the Buffer for metrics
+	// to retry when it's empty
+	bufferPollInterval = 10 * time.Second
 )
 
 type metricStore interface {
 	StoreMetrics(ctx context.Context, r domain.MetricsRequest) error
 	Listen(ctx context.Context) <-chan map[string]domain.MetricsRequest
+	Drain() []map[string]domain.MetricsRequest
 }
 
 // metricService defines the interface for interacting with the Harness Saas metrics service
@@ -30,11 +39,35 @@ type Worker struct {
 	metricsService    metricService
 	readConcurrency   int
 	clusterIdentifier string
+	buffer            Buffer
+	sinks             []Sink
+}
+
+// rejectedRecorder is implemented by Buffers that keep track of the metrics
+// that were dropped because feature flags rejected them, e.g. the PrometheusBuffer
+type rejectedRecorder interface {
+	RecordRejected()
+}
+
+// WithBuffer configures the Worker to store metrics that fail to send to SaaS
+// in the Buffer and retry them with exponential backoff
+func WithBuffer(b Buffer) func(w *Worker) {
+	return func(w *Worker) {
+		w.buffer = b
+	}
+}
+
+// WithSinks configures the Worker to write a copy of the metrics it sends to
+// SaaS to each of the Sinks
+func WithSinks(sinks ...Sink) func(w *Worker) {
+	return func(w *Worker) {
+		w.sinks = append(w.sinks, sinks...)
+	}
 }
 
 // NewWorker creates a Worker
-func NewWorker(l log.Logger, store metricStore, metricSvc metricService, sub domain.Subscriber, readConn int, clusterIdentifer string) Worker {
-	return Worker{
+func NewWorker(l log.Logger, store metricStore, metricSvc metricService, sub domain.Subscriber, readConn int, clusterIdentifer string, opts ...func(w *Worker)) Worker {
+	w := Worker{
 		log:               l,
 		subscriber:        sub,
 		metricsStore:      store,
@@ -42,6 +75,12 @@ func NewWorker(l log.Logger, store metricStore, metricSvc metricService, sub dom
 		readConcurrency:   readConn,
 		clusterIdentifier: clusterIdentifer,
 	}
+
+	for _, opt := range opts {
+		opt(&w)
+	}
+
+	return w
 }
 
 // Start starts the process whereby the Worker consumes metrics from read replicas and forwards them on to Harness Saas.
@@ -56,6 +95,11 @@ func (w Worker) Start(ctx context.Context) {
 
 	// Start a single thread that sends metrics to Saas
 	go w.postMetrics(ctx)
+
+	// Start a single thread that retries any metrics that failed to send
+	if w.buffer != nil {
+		go w.retryBuffered(ctx)
+	}
 }
 
 // subscribe starts a single thread that subcribes to a redis stream and writes
@@ -137,11 +181,129 @@ func (w Worker) handleMetrics(ctx context.Context, metrics <-chan []byte) {
 
 func (w Worker) postMetrics(ctx context.Context) {
 	for metrics := range w.metricsStore.Listen(ctx) {
+		w.post(ctx, metrics)
+	}
+}
+
+// Flush sends any metrics still held in the Worker's store on to Harness Saas.
+// It blocks until they've been sent or the context expires and should be called
+// during shutdown before the context passed to Start is cancelled.
+func (w Worker) Flush(ctx context.Context) {
+	for _, metrics := range w.metricsStore.Drain() {
+		w.post(ctx, metrics)
+	}
+}
+
+func (w Worker) post(ctx context.Context, metrics map[string]domain.MetricsRequest) {
+	for envID, metric := range metrics {
+		w.writeToSinks(ctx, envID, metric)
 
-		for envID, metric := range metrics {
-			if err := w.metricsService.PostMetrics(ctx, envID, metric, w.clusterIdentifier); err != nil {
-				w.log.Error("sending metrics failed", "environment", envID, "cluster_identifier", w.clusterIdentifier, "error", err)
+		if err := w.metricsService.PostMetrics(ctx, envID, metric, w.clusterIdentifier); err != nil {
+			w.log.Error("sending metrics failed", "environment", envID, "cluster_identifier", w.clusterIdentifier, "error", err)
+
+			// There's no point buffering metrics that'll be rejected again
+			if errors.Is(err, ErrMetricsRejected) {
+				w.recordRejected()
+				continue
 			}
+			w.bufferMetrics(ctx, envID, metric)
+		}
+	}
+}
+
+// writeToSinks writes metrics to any local sinks. Failing to write to a sink
+// shouldn't stop the metrics being sent to SaaS so errors are only logged.
+func (w Worker) writeToSinks(ctx context.Context, envID string, metric domain.MetricsRequest) {
+	for _, sink := range w.sinks {
+		if err := sink.Write(ctx, envID, metric); err != nil {
+			w.log.Warn("failed to write metrics to sink", "environment", envID, "sink", fmt.Sprintf("%T", sink), "err", err)
+		}
+	}
+}
+
+// bufferMetrics adds metrics that failed to send to the Buffer so they can be retried
+func (w Worker) bufferMetrics(ctx context.Context, envID string, metric domain.MetricsRequest) {
+	if w.buffer == nil {
+		return
+	}
+
+	entry, err := NewBufferEntry(envID, metric)
+	if err != nil {
+		w.log.Error("failed to create metrics buffer entry", "environment", envID, "err", err)
+		return
+	}
+
+	evicted, err := w.buffer.Push(ctx, entry)
+	if err != nil {
+		w.log.Error("failed to add metrics to buffer", "environment", envID, "err", err)
+		return
+	}
+
+	if evicted > 0 {
+		w.log.Warn("metrics buffer is full, dropped oldest metrics", "dropped", evicted)
+	}
+}
+
+// recordRejected records that metrics were dropped because they were rejected,
+// if the Buffer keeps track of them
+func (w Worker) recordRejected() {
+	if r, ok := w.buffer.(rejectedRecorder); ok {
+		r.RecordRejected()
+	}
+}
+
+// retryBuffered sends metrics from the Buffer to SaaS, oldest first. If sending
+// fails it backs off exponentially before trying again, unless SaaS rejected
+// the metrics in which case they're dropped.
+func (w Worker) retryBuffered(ctx context.Context) {
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = 5 * time.Second
+	b.MaxInterval = 5 * time.Minute
+	b.MaxElapsedTime = 0
+
+	for {
+		wait := time.Duration(0)
+
+		entry, err := w.buffer.Peek(ctx)
+		switch {
+		case errors.Is(err, ErrBufferEmpty):
+			b.Reset()
+			wait = bufferPollInterval
+
+		case err != nil:
+			w.log.Warn("failed to read metrics from buffer", "err", err)
+			wait = b.NextBackOff()
+
+		default:
+			err := w.metricsService.PostMetrics(ctx, entry.EnvironmentID, entry.Request, w.clusterIdentifier)
+			if errors.Is(err, ErrMetricsRejected) {
+				// Retrying these will never succeed and they'd stop us getting
+				// to the entries behind them, so drop them
+				w.log.Warn("buffered metrics were rejected, dropping them", "environment", entry.EnvironmentID, "err", err)
+				w.recordRejected()
+				if err := w.buffer.Remove(ctx, entry.ID); err != nil {
+					w.log.Error("failed to remove rejected metrics from buffer", "environment", entry.EnvironmentID, "err", err)
+					wait = b.NextBackOff()
+				}
+				break
+			}
+			if err != nil {
+				wait = b.NextBackOff()
+				w.log.Warn("failed to resend buffered metrics, backing off", "environment", entry.EnvironmentID, "backoff", wait.String(), "err", err)
+				break
+			}
+
+			b.Reset()
+			if err := w.buffer.Remove(ctx, entry.ID); err != nil {
+				w.log.Error("failed to remove metrics from buffer after sending", "environment", entry.EnvironmentID, "err", err)
+				wait = b.NextBackOff()
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
 		}
 	}
 }
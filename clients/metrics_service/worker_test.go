@@ -3,6 +3,7 @@ package metricsservice
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sort"
 	"sync"
@@ -13,6 +14,8 @@ import (
 	clientgen "github.com/harness/ff-proxy/v2/gen/client"
 	"github.com/harness/ff-proxy/v2/log"
 	jsoniter "github.com/json-iterator/go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -98,6 +101,10 @@ func (m *mockMetricStore) Listen(ctx context.Context) <-chan map[string]domain.M
 	return m.metrics
 }
 
+func (m *mockMetricStore) Drain() []map[string]domain.MetricsRequest {
+	return nil
+}
+
 func TestWorker_Start(t *testing.T) {
 	mr123 := domain.MetricsRequest{
 		Size:          176,
@@ -586,3 +593,225 @@ func generateData(size int) []domain.MetricsRequest {
 	}
 	return data
 }
+
+func TestWorker_Flush(t *testing.T) {
+	mr123 := domain.MetricsRequest{
+		EnvironmentID: "123",
+		Metrics: clientgen.Metrics{
+			MetricsData: &[]clientgen.MetricsData{
+				{
+					Count:       1,
+					MetricsType: "Server",
+					Timestamp:   111,
+				},
+			},
+		},
+	}
+
+	mr456 := domain.MetricsRequest{
+		EnvironmentID: "456",
+		Metrics: clientgen.Metrics{
+			TargetData: &[]clientgen.TargetData{
+				{
+					Identifier: "Foo",
+					Name:       "Bar",
+				},
+			},
+		},
+	}
+
+	testCases := map[string]struct {
+		metrics  []domain.MetricsRequest
+		expected []domain.MetricsRequest
+	}{
+		"Given I have an empty queue": {
+			metrics:  []domain.MetricsRequest{},
+			expected: []domain.MetricsRequest{},
+		},
+		"Given I have a queue with evaluation and target metrics that haven't been flushed": {
+			metrics:  []domain.MetricsRequest{mr123, mr456},
+			expected: []domain.MetricsRequest{mr123, mr456},
+		},
+	}
+
+	for desc, tc := range testCases {
+		desc := desc
+		tc := tc
+
+		t.Run(desc, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			// Use a long flush duration so that the only way metrics get sent is via Flush
+			q := NewQueue(ctx, log.NoOpLogger{}, 1*time.Hour)
+			for _, mr := range tc.metrics {
+				assert.Nil(t, q.StoreMetrics(ctx, mr))
+			}
+
+			metricService := &mockMetricsService{Mutex: &sync.Mutex{}, metrics: []domain.MetricsRequest{}}
+			w := NewWorker(log.NoOpLogger{}, q, metricService, newMockRedisStream(), 1, "1")
+
+			w.Flush(ctx)
+			assert.ElementsMatch(t, tc.expected, metricService.getMetrics())
+
+			// Flushing again shouldn't resend anything
+			w.Flush(ctx)
+			assert.ElementsMatch(t, tc.expected, metricService.getMetrics())
+		})
+	}
+}
+
+type mockFailingMetricsService struct {
+	*sync.Mutex
+	fail     bool
+	rejectID string
+	metrics  []domain.MetricsRequest
+}
+
+func (m *mockFailingMetricsService) PostMetrics(ctx context.Context, envID string, r domain.MetricsRequest, clusterIdentifier string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.fail {
+		return errors.New("metrics service unavailable")
+	}
+
+	if envID == m.rejectID {
+		return fmt.Errorf("%w: status_code=400", ErrMetricsRejected)
+	}
+
+	m.metrics = append(m.metrics, r)
+	return nil
+}
+
+func (m *mockFailingMetricsService) setFail(fail bool) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.fail = fail
+}
+
+func (m *mockFailingMetricsService) getMetrics() []domain.MetricsRequest {
+	m.Lock()
+	defer m.Unlock()
+
+	return m.metrics
+}
+
+func TestWorker_RetryBuffered(t *testing.T) {
+	mr123 := domain.MetricsRequest{
+		EnvironmentID: "123",
+		Metrics: clientgen.Metrics{
+			MetricsData: &[]clientgen.MetricsData{
+				{
+					Count:       1,
+					MetricsType: "Server",
+					Timestamp:   111,
+				},
+			},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	buffer, err := NewDiskBuffer(t.TempDir(), 10)
+	assert.Nil(t, err)
+
+	metricService := &mockFailingMetricsService{Mutex: &sync.Mutex{}, fail: true, metrics: []domain.MetricsRequest{}}
+
+	q := NewQueue(ctx, log.NoOpLogger{}, 1*time.Hour)
+	assert.Nil(t, q.StoreMetrics(ctx, mr123))
+
+	w := NewWorker(log.NoOpLogger{}, q, metricService, newMockRedisStream(), 1, "1", WithBuffer(buffer))
+
+	// SaaS is down so the metrics should end up in the buffer
+	w.Flush(ctx)
+	assert.Empty(t, metricService.getMetrics())
+
+	length, err := buffer.Len(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, length)
+
+	// Once SaaS comes back the buffered metrics should be sent and removed from the buffer
+	metricService.setFail(false)
+	go w.retryBuffered(ctx)
+
+	assert.Eventually(t, func() bool {
+		n, _ := buffer.Len(ctx)
+		return n == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, []domain.MetricsRequest{mr123}, metricService.getMetrics())
+}
+
+func TestWorker_RetryBufferedRejected(t *testing.T) {
+	mr123 := domain.MetricsRequest{
+		EnvironmentID: "123",
+		Metrics: clientgen.Metrics{
+			MetricsData: &[]clientgen.MetricsData{
+				{
+					Count:       1,
+					MetricsType: "Server",
+					Timestamp:   111,
+				},
+			},
+		},
+	}
+	mr456 := domain.MetricsRequest{
+		EnvironmentID: "456",
+		Metrics: clientgen.Metrics{
+			MetricsData: &[]clientgen.MetricsData{
+				{
+					Count:       2,
+					MetricsType: "Server",
+					Timestamp:   111,
+				},
+			},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	diskBuffer, err := NewDiskBuffer(t.TempDir(), 10)
+	assert.Nil(t, err)
+	buffer := NewPrometheusBuffer(diskBuffer, prometheus.NewRegistry())
+
+	entry123, err := NewBufferEntry("123", mr123)
+	assert.Nil(t, err)
+	entry456, err := NewBufferEntry("456", mr456)
+	assert.Nil(t, err)
+
+	t.Log("Given the oldest entry in the buffer will be rejected by SaaS")
+	_, err = buffer.Push(ctx, entry123)
+	assert.Nil(t, err)
+	_, err = buffer.Push(ctx, entry456)
+	assert.Nil(t, err)
+
+	metricService := &mockFailingMetricsService{Mutex: &sync.Mutex{}, rejectID: "123", metrics: []domain.MetricsRequest{}}
+	q := NewQueue(ctx, log.NoOpLogger{}, 1*time.Hour)
+	w := NewWorker(log.NoOpLogger{}, q, metricService, newMockRedisStream(), 1, "1", WithBuffer(buffer))
+
+	t.Log("When the buffered metrics are retried")
+	go w.retryBuffered(ctx)
+
+	t.Log("Then the rejected entry is dropped and the entry behind it is sent")
+	assert.Eventually(t, func() bool {
+		n, _ := buffer.Len(ctx)
+		return n == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, []domain.MetricsRequest{mr456}, metricService.getMetrics())
+	assert.Equal(t, float64(1), testutil.ToFloat64(buffer.dropped.WithLabelValues("rejected")))
+
+	t.Log("When queued metrics are rejected")
+	assert.Nil(t, q.StoreMetrics(ctx, mr123))
+	w.Flush(ctx)
+
+	t.Log("Then they're dropped rather than buffered")
+	length, err := buffer.Len(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, length)
+	assert.Equal(t, float64(2), testutil.ToFloat64(buffer.dropped.WithLabelValues("rejected")))
+}
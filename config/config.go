@@ -38,9 +38,9 @@ type Config interface {
 }
 
 // NewConfig creates either a local or remote config type that implements the Config interface
-func NewConfig(offline bool, configDir string, proxyKey string, clientService domain.ClientService, stream stream.Stream) (Config, error) {
+func NewConfig(offline bool, configDir string, proxyKey string, clientService domain.ClientService, stream stream.Stream, remoteOpts ...func(c *remote.Config)) (Config, error) {
 	if !offline {
-		return remote.NewConfig(proxyKey, clientService, stream), nil
+		return remote.NewConfig(proxyKey, clientService, stream, remoteOpts...), nil
 	}
 
 	conf, err := local.NewConfig(os.DirFS(configDir))
@@ -39,6 +39,10 @@ func (m mockAuthRepo) AddAPIConfigsForEnvironment(ctx context.Context, envID str
 	return m.addAPIConfigsForEnvironmentFn(ctx, envID, apiKeys)
 }
 
+func (m *mockAuthRepo) StorageKey(key domain.AuthAPIKey) string {
+	return string(key)
+}
+
 func (m *mockAuthRepo) PatchAPIConfigForEnvironment(ctx context.Context, envID, apikey, action string) error {
 	//TODO implement me
 	panic("implement me")
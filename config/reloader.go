@@ -0,0 +1,70 @@
+package config
+
+import "sync"
+
+// reload is a single run of a Reloader's reload func that callers can wait on
+type reload struct {
+	done chan struct{}
+	err  error
+}
+
+// Reloader makes sure only one reload of the config runs at a time. Reloads
+// are triggered by the poller, the SaaS stream's connect and disconnect
+// handlers and secret rotations, and running them concurrently can leave the
+// cache with a mix of two configs. Any calls made while a reload is running
+// wait for it to finish and then share a single follow up reload, so they see
+// a reload that started after they asked for one without each running their own.
+type Reloader struct {
+	fn func() error
+
+	mx      *sync.Mutex
+	running bool
+	pending *reload
+}
+
+// NewReloader creates a Reloader that reloads the config using fn
+func NewReloader(fn func() error) *Reloader {
+	return &Reloader{
+		fn: fn,
+		mx: &sync.Mutex{},
+	}
+}
+
+// Reload reloads the config, or waits for the next reload if one is already running
+func (r *Reloader) Reload() error {
+	r.mx.Lock()
+	if r.running {
+		if r.pending == nil {
+			r.pending = &reload{done: make(chan struct{})}
+		}
+		next := r.pending
+		r.mx.Unlock()
+
+		<-next.done
+		return next.err
+	}
+
+	r.running = true
+	r.mx.Unlock()
+
+	current := &reload{done: make(chan struct{})}
+	r.run(current)
+	return current.err
+}
+
+// run performs the reload and then any reload that was requested while it
+// was running, until there are none left
+func (r *Reloader) run(current *reload) {
+	for current != nil {
+		current.err = r.fn()
+		close(current.done)
+
+		r.mx.Lock()
+		current = r.pending
+		r.pending = nil
+		if current == nil {
+			r.running = false
+		}
+		r.mx.Unlock()
+	}
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReloader_Reload(t *testing.T) {
+	var (
+		running  int32
+		overlaps int32
+		reloads  int32
+	)
+
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+
+	r := NewReloader(func() error {
+		if atomic.AddInt32(&running, 1) > 1 {
+			atomic.AddInt32(&overlaps, 1)
+		}
+		defer atomic.AddInt32(&running, -1)
+
+		if atomic.AddInt32(&reloads, 1) == 1 {
+			started <- struct{}{}
+			<-release
+		}
+		return nil
+	})
+
+	t.Log("Given a reload is running")
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.Nil(t, r.Reload())
+	}()
+	<-started
+
+	t.Log("When several more reloads are triggered while it's running")
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Nil(t, r.Reload())
+		}()
+	}
+
+	// Give the callers time to queue up behind the running reload
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	t.Log("Then the reloads never overlap")
+	assert.Equal(t, int32(0), overlaps)
+
+	t.Log("And the triggers that came in while it was running share one follow up reload")
+	assert.Equal(t, int32(2), reloads)
+
+	t.Log("And a reload after they've finished runs straight away")
+	assert.Nil(t, r.Reload())
+	assert.Equal(t, int32(3), reloads)
+}
@@ -2,12 +2,15 @@ package remote
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt"
+	"github.com/harness/ff-proxy/v2/audit"
 	"github.com/harness/ff-proxy/v2/domain"
 	"github.com/harness/ff-proxy/v2/stream"
 	jsoniter "github.com/json-iterator/go"
@@ -32,22 +35,50 @@ func (s *safeString) Get() string {
 
 // Config is the type that fetches config from Harness SaaS
 type Config struct {
-	key               string
+	key               *safeString
 	token             *safeString
-	clusterIdentifier string
+	clusterIdentifier *safeString
+	proxyConfigMx     *sync.RWMutex
 	proxyConfig       []domain.ProxyConfig
 	ClientService     domain.ClientService
 	stream            stream.Stream
-	accountID         string
+	accountID         *safeString
+	audit             audit.Recorder
+	checksum          *safeString
+	generations       domain.GenerationRepo
+}
+
+// WithAuditRecorder sets the Recorder that the Config records the changes it
+// applies when it fetches and populates the config with
+func WithAuditRecorder(r audit.Recorder) func(c *Config) {
+	return func(c *Config) {
+		c.audit = r
+	}
+}
+
+// WithGenerationRepo makes the Config publish each environment's flags and
+// segments together as a single generation rather than writing them separately
+func WithGenerationRepo(g domain.GenerationRepo) func(c *Config) {
+	return func(c *Config) {
+		c.generations = g
+	}
 }
 
 // NewConfig creates a new Config
-func NewConfig(key string, cs domain.ClientService, s stream.Stream) *Config {
+func NewConfig(key string, cs domain.ClientService, s stream.Stream, opts ...func(c *Config)) *Config {
 	c := &Config{
-		token:         &safeString{RWMutex: &sync.RWMutex{}, value: ""},
-		key:           key,
-		ClientService: cs,
-		stream:        s,
+		token:             &safeString{RWMutex: &sync.RWMutex{}, value: ""},
+		key:               &safeString{RWMutex: &sync.RWMutex{}, value: key},
+		checksum:          &safeString{RWMutex: &sync.RWMutex{}, value: ""},
+		clusterIdentifier: &safeString{RWMutex: &sync.RWMutex{}, value: ""},
+		accountID:         &safeString{RWMutex: &sync.RWMutex{}, value: ""},
+		proxyConfigMx:     &sync.RWMutex{},
+		ClientService:     cs,
+		stream:            s,
+	}
+
+	for _, opt := range opts {
+		opt(c)
 	}
 	return c
 }
@@ -59,11 +90,11 @@ func (c *Config) Token() string {
 
 // AccountID returns the accountID for the account the Proxy is configured to work with
 func (c *Config) AccountID() string {
-	return c.accountID
+	return c.accountID.Get()
 }
 
 func (c *Config) RefreshToken() (string, error) {
-	authResp, err := authenticate(c.key, c.ClientService)
+	authResp, err := authenticate(c.key.Get(), c.ClientService)
 	if err != nil {
 		return "", err
 	}
@@ -74,44 +105,71 @@ func (c *Config) RefreshToken() (string, error) {
 
 // ClusterIdentifier returns the identifier of the cluster that the Config authenticated against
 func (c *Config) ClusterIdentifier() string {
-	if c.clusterIdentifier == "" {
+	clusterIdentifier := c.clusterIdentifier.Get()
+	if clusterIdentifier == "" {
 		return "1"
 	}
-	return c.clusterIdentifier
+	return clusterIdentifier
 }
 
 // Key returns proxyKey
 func (c *Config) Key() string {
-	return c.key
+	return c.key.Get()
+}
+
+// Checksum returns a checksum of the config that was last fetched from Harness
+// SaaS and populated. It changes whenever the config does.
+func (c *Config) Checksum() string {
+	return c.checksum.Get()
+}
+
+// SetKey replaces the proxyKey, the new key is used the next time the Config
+// authenticates with Harness SaaS
+func (c *Config) SetKey(key string) {
+	c.key.Set(key)
 }
 
 // SetProxyConfig sets the proxy config member
 func (c *Config) SetProxyConfig(proxyConfig []domain.ProxyConfig) {
+	c.proxyConfigMx.Lock()
+	defer c.proxyConfigMx.Unlock()
 	c.proxyConfig = proxyConfig
 }
 
+func (c *Config) getProxyConfig() []domain.ProxyConfig {
+	c.proxyConfigMx.RLock()
+	defer c.proxyConfigMx.RUnlock()
+	return c.proxyConfig
+}
+
 // FetchAndPopulate Fetches and populates repositories with the config
 func (c *Config) FetchAndPopulate(ctx context.Context, inventory domain.InventoryRepo, authRepo domain.AuthRepo, flagRepo domain.FlagRepo, segmentRepo domain.SegmentRepo) error {
+	key := c.key.Get()
 
-	authResp, err := authenticate(c.key, c.ClientService)
+	authResp, err := authenticate(key, c.ClientService)
 	if err != nil {
 		return err
 	}
 	c.token.Set(authResp.Token)
-	c.clusterIdentifier = authResp.ClusterIdentifier
+	c.clusterIdentifier.Set(authResp.ClusterIdentifier)
 
-	proxyConfig, err := retrieveConfig(c.key, authResp.Token, authResp.ClusterIdentifier, c.ClientService)
+	proxyConfig, err := retrieveConfig(key, authResp.Token, authResp.ClusterIdentifier, c.ClientService)
 	if err != nil {
 		return err
 	}
 
 	// It's not the end of the world if we fail to
 	// get the accountID from the auth token
-	c.accountID, _ = parseAuthToken(authResp.Token)
+	accountID, _ := parseAuthToken(authResp.Token)
+	c.accountID.Set(accountID)
+
+	// Take a snapshot of what's in the cache before it's changed so we can
+	// record what the new config changes
+	before := c.snapshot(ctx, flagRepo, segmentRepo, proxyConfig)
 
 	// TODO we probably should defer that
 	// compare new and old config assets and delete difference.
-	notificationsToSend, err := inventory.Cleanup(ctx, c.key, proxyConfig)
+	notificationsToSend, err := inventory.Cleanup(ctx, key, proxyConfig)
 	if err != nil {
 		return err
 	}
@@ -121,8 +179,88 @@ func (c *Config) FetchAndPopulate(ctx context.Context, inventory domain.Inventor
 		return err
 	}
 
-	c.proxyConfig = proxyConfig
-	return c.Populate(ctx, authRepo, flagRepo, segmentRepo)
+	c.SetProxyConfig(proxyConfig)
+	if err := c.Populate(ctx, authRepo, flagRepo, segmentRepo); err != nil {
+		return err
+	}
+
+	c.recordChanges(ctx, before, proxyConfig, notificationsToSend)
+	c.checksum.Set(checksum(proxyConfig))
+	return nil
+}
+
+// checksum returns a sha256 of the config. If it fails to marshal we return
+// an empty string which will be treated as a change.
+func checksum(proxyConfig []domain.ProxyConfig) string {
+	b, err := jsoniter.Marshal(proxyConfig)
+	if err != nil {
+		return ""
+	}
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// envSnapshot is the flags and segments that were in the cache for an
+// environment before a config change was applied
+type envSnapshot struct {
+	flags    []domain.FeatureFlag
+	segments []domain.Segment
+}
+
+// snapshot gets the current flags and segments for each environment in the
+// config. It's only needed if we're auditing so it avoids reading from the
+// cache otherwise.
+func (c *Config) snapshot(ctx context.Context, flagRepo domain.FlagRepo, segmentRepo domain.SegmentRepo, proxyConfig []domain.ProxyConfig) map[string]envSnapshot {
+	if c.audit == nil {
+		return nil
+	}
+
+	snapshot := map[string]envSnapshot{}
+	for _, cfg := range proxyConfig {
+		for _, env := range cfg.Environments {
+			id := env.ID.String()
+			flags, _ := flagRepo.GetFeatureConfigForEnvironment(ctx, id)
+			segments, _ := segmentRepo.GetSegmentsForEnvironment(ctx, id)
+			snapshot[id] = envSnapshot{flags: flags, segments: segments}
+		}
+	}
+	return snapshot
+}
+
+// recordChanges records the differences between the snapshot and the config
+// that's been applied. Flags and segments in environments that have been
+// removed from the config are recorded from the delete notifications.
+func (c *Config) recordChanges(ctx context.Context, before map[string]envSnapshot, proxyConfig []domain.ProxyConfig, notifications []domain.SSEMessage) {
+	if c.audit == nil {
+		return
+	}
+
+	for _, cfg := range proxyConfig {
+		for _, env := range cfg.Environments {
+			id := env.ID.String()
+			c.audit.Record(ctx, audit.FlagChanges(id, before[id].flags, env.FeatureConfigs)...)
+			c.audit.Record(ctx, audit.SegmentChanges(id, before[id].segments, env.Segments)...)
+		}
+	}
+
+	for _, n := range notifications {
+		if _, ok := before[n.Environment]; ok || n.Event != domain.EventDelete {
+			continue
+		}
+
+		kind := domain.AuditKindFlag
+		if n.Domain == domain.MsgDomainSegment {
+			kind = domain.AuditKindSegment
+		}
+
+		c.audit.Record(ctx, domain.AuditRecord{
+			Action:      domain.AuditActionDelete,
+			Kind:        kind,
+			Environment: n.Environment,
+			Identifier:  n.Identifier,
+		})
+	}
 }
 
 func (c *Config) notifySDKs(ctx context.Context, notificationsToSend []domain.SSEMessage) error {
@@ -142,7 +280,7 @@ func (c *Config) Populate(ctx context.Context, authRepo domain.AuthRepo, flagRep
 	errchan := make(chan error)
 	semaphore := make(chan struct{}, 1000)
 
-	for _, cfg := range c.proxyConfig {
+	for _, cfg := range c.getProxyConfig() {
 		for _, targetEnv := range cfg.Environments {
 			wg.Add(1)
 			go func(env domain.Environments) {
@@ -162,7 +300,7 @@ func (c *Config) Populate(ctx context.Context, authRepo domain.AuthRepo, flagRep
 						EnvironmentID: domain.EnvironmentID(env.ID.String()),
 					})
 				}
-				err := populate(ctx, authRepo, flagRepo, segmentRepo, apiKeys, authConfig, env)
+				err := populate(ctx, authRepo, flagRepo, segmentRepo, c.generations, apiKeys, authConfig, env)
 				errchan <- err
 			}(targetEnv)
 		}
@@ -183,7 +321,7 @@ func (c *Config) Populate(ctx context.Context, authRepo domain.AuthRepo, flagRep
 }
 
 // func extracted to satisfy lint complexity metrics.
-func populate(ctx context.Context, authRepo domain.AuthRepo, flagRepo domain.FlagRepo, segmentRepo domain.SegmentRepo, apiKeys []string, authConfig []domain.AuthConfig, env domain.Environments) error {
+func populate(ctx context.Context, authRepo domain.AuthRepo, flagRepo domain.FlagRepo, segmentRepo domain.SegmentRepo, generations domain.GenerationRepo, apiKeys []string, authConfig []domain.AuthConfig, env domain.Environments) error {
 
 	// check for len is important to ensure we do not insert empty keys.
 	// add apiKeys to cache.
@@ -200,6 +338,15 @@ func populate(ctx context.Context, authRepo domain.AuthRepo, flagRepo domain.Fla
 		}
 	}
 
+	// Publishing flags and segments together means readers never see the new
+	// flags with the old segments or vice versa
+	if generations != nil {
+		if _, err := generations.Publish(ctx, env.ID.String(), env.FeatureConfigs, env.Segments); err != nil {
+			return fmt.Errorf("failed to publish config to cache: %s", err)
+		}
+		return nil
+	}
+
 	if len(env.FeatureConfigs) > 0 {
 		if err := flagRepo.Add(ctx, domain.FlagConfig{
 			EnvironmentID:  env.ID.String(),
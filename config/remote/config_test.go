@@ -107,6 +107,10 @@ func (m mockAuthRepo) Cleanup(ctx context.Context, key string, config []domain.P
 func (m mockAuthRepo) AddAPIConfigsForEnvironment(ctx context.Context, envID string, apiKeys []string) error {
 	return m.addAPIConfigsForEnvironmentFn(ctx, envID, apiKeys)
 }
+func (m *mockAuthRepo) StorageKey(key domain.AuthAPIKey) string {
+	return string(key)
+}
+
 func (m *mockAuthRepo) PatchAPIConfigForEnvironment(ctx context.Context, envID, apikey, action string) error {
 	//TODO implement me
 	panic("implement me")
@@ -585,9 +589,8 @@ func Benchmark_ConfigPopulate100Env30Flags(b *testing.B) {
 	authRepo := repository.NewAuthRepo(r)
 	flagRepo := repository.NewFeatureFlagRepo(r)
 	segmentRepo := repository.NewSegmentRepo(r)
-	c := Config{
-		proxyConfig: []domain.ProxyConfig{proxyConfig},
-	}
+	c := NewConfig("", nil, stream.Stream{})
+	c.SetProxyConfig([]domain.ProxyConfig{proxyConfig})
 
 	// Limit to 1 CPU core
 	runtime.GOMAXPROCS(1)
@@ -613,9 +616,8 @@ func Benchmark_ConfigPopulate200Env30Flags(b *testing.B) {
 	authRepo := repository.NewAuthRepo(r)
 	flagRepo := repository.NewFeatureFlagRepo(r)
 	segmentRepo := repository.NewSegmentRepo(r)
-	c := Config{
-		proxyConfig: []domain.ProxyConfig{proxyConfig},
-	}
+	c := NewConfig("", nil, stream.Stream{})
+	c.SetProxyConfig([]domain.ProxyConfig{proxyConfig})
 
 	// Limit to 1 CPU core
 	runtime.GOMAXPROCS(1)
@@ -641,9 +643,8 @@ func Benchmark_ConfigPopulate300Env30Flags(b *testing.B) {
 	authRepo := repository.NewAuthRepo(r)
 	flagRepo := repository.NewFeatureFlagRepo(r)
 	segmentRepo := repository.NewSegmentRepo(r)
-	c := Config{
-		proxyConfig: proxyConfigs,
-	}
+	c := NewConfig("", nil, stream.Stream{})
+	c.SetProxyConfig(proxyConfigs)
 
 	// Limit to 1 CPU core
 	runtime.GOMAXPROCS(1)
@@ -670,9 +671,8 @@ func Benchmark_ConfigPopulate500Env30Flags(b *testing.B) {
 	authRepo := repository.NewAuthRepo(r)
 	flagRepo := repository.NewFeatureFlagRepo(r)
 	segmentRepo := repository.NewSegmentRepo(r)
-	c := Config{
-		proxyConfig: proxyConfigs,
-	}
+	c := NewConfig("", nil, stream.Stream{})
+	c.SetProxyConfig(proxyConfigs)
 
 	// Limit to 1 CPU core
 	runtime.GOMAXPROCS(1)
@@ -699,9 +699,8 @@ func Benchmark_ConfigPopulate1000Env30Flags(b *testing.B) {
 	authRepo := repository.NewAuthRepo(r)
 	flagRepo := repository.NewFeatureFlagRepo(r)
 	segmentRepo := repository.NewSegmentRepo(r)
-	c := Config{
-		proxyConfig: proxyConfigs,
-	}
+	c := NewConfig("", nil, stream.Stream{})
+	c.SetProxyConfig(proxyConfigs)
 
 	// Limit to 1 CPU core
 	runtime.GOMAXPROCS(1)
@@ -734,9 +733,8 @@ func Benchmark_ConfigCleanup100Env30Flags(b *testing.B) {
 		os.Exit(1)
 	}
 	inventoryRepo := repository.NewInventoryRepo(r, l)
-	c := Config{
-		proxyConfig: []domain.ProxyConfig{proxyConfig},
-	}
+	c := NewConfig("", nil, stream.Stream{})
+	c.SetProxyConfig([]domain.ProxyConfig{proxyConfig})
 
 	newAssets, _ := inventoryRepo.BuildAssetListFromConfig(c.proxyConfig)
 	inventoryRepo.Add(context.Background(), "test", newAssets)
@@ -779,9 +777,8 @@ func Benchmark_ConfigCleanup1000Env30Flags(b *testing.B) {
 		os.Exit(1)
 	}
 	inventoryRepo := repository.NewInventoryRepo(r, l)
-	c := Config{
-		proxyConfig: proxyConfigs,
-	}
+	c := NewConfig("", nil, stream.Stream{})
+	c.SetProxyConfig(proxyConfigs)
 
 	newAssets, _ := inventoryRepo.BuildAssetListFromConfig(c.proxyConfig)
 	inventoryRepo.Add(context.Background(), "test", newAssets)
@@ -0,0 +1,74 @@
+package domain
+
+import "time"
+
+// AuditSource is what caused the Proxy to apply a config change
+type AuditSource string
+
+const (
+	// AuditSourceStartup is a change applied when the Proxy fetched its config on startup
+	AuditSourceStartup AuditSource = "startup"
+
+	// AuditSourcePoll is a change applied when the Proxy polled Harness SaaS for its config
+	AuditSourcePoll AuditSource = "poll"
+
+	// AuditSourceSSE is a change applied after the Proxy received an SSE event from Harness SaaS
+	AuditSourceSSE AuditSource = "sse"
+)
+
+// AuditAction is the type of change that was applied to an asset
+type AuditAction string
+
+const (
+	// AuditActionCreate is used when an asset is added to the cache
+	AuditActionCreate AuditAction = "create"
+
+	// AuditActionPatch is used when an asset that was already in the cache is changed
+	AuditActionPatch AuditAction = "patch"
+
+	// AuditActionDelete is used when an asset is removed from the cache
+	AuditActionDelete AuditAction = "delete"
+)
+
+const (
+	// AuditKindFlag is the kind of AuditRecord for feature flags
+	AuditKindFlag = "flag"
+
+	// AuditKindSegment is the kind of AuditRecord for target segments
+	AuditKindSegment = "segment"
+
+	// AuditKindAPIKey is the kind of AuditRecord for hashed SDK keys
+	AuditKindAPIKey = "apiKey"
+
+	// AuditKindEnvironment is the kind of AuditRecord for environments
+	AuditKindEnvironment = "environment"
+
+	// AuditKindProxyKey is the kind of AuditRecord for the Proxy Key
+	AuditKindProxyKey = "proxyKey"
+)
+
+// AuditRecord describes a config change that the Proxy applied to its cache
+type AuditRecord struct {
+	Time        time.Time   `json:"time"`
+	Source      AuditSource `json:"source"`
+	Event       string      `json:"event,omitempty"`
+	Action      AuditAction `json:"action"`
+	Kind        string      `json:"kind"`
+	Environment string      `json:"environment,omitempty"`
+	Identifier  string      `json:"identifier,omitempty"`
+	OldVersion  *int64      `json:"oldVersion,omitempty"`
+	NewVersion  *int64      `json:"newVersion,omitempty"`
+	Diff        string      `json:"diff,omitempty"`
+}
+
+// AuditHistoryRequest contains the fields sent in a GET /admin/audit request
+type AuditHistoryRequest struct {
+	Environment string
+	Identifier  string
+	Limit       int
+}
+
+// AuditHistoryResponse contains the most recent AuditRecords, newest first
+type AuditHistoryResponse struct {
+	Records []AuditRecord `json:"records"`
+}
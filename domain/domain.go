@@ -25,6 +25,8 @@ const (
 	ConfigStateFailedToSync ConfigState = "FAILED_TO_SYNC"
 	// ConfigStateReadReplica is the status for read replica
 	ConfigStateReadReplica ConfigState = "READ_REPLICA"
+	// ConfigStateInitializing is the status for when the proxy hasn't finished its initial sync
+	ConfigStateInitializing ConfigState = "INITIALIZING"
 	// StreamStateConnected is the status for when a stream is connected
 	StreamStateConnected StreamState = "CONNECTED"
 	// StreamStateDisconnected is the status for when a stream is disconnected
@@ -96,3 +96,51 @@ func Test_ToPtr(t *testing.T) {
 	actual := ToPtr(s)
 	assert.True(t, reflect.ValueOf(actual).Kind() == reflect.Ptr)
 }
+
+func TestEnvIDFromConfigKey(t *testing.T) {
+	uuid := "0c3b8e3a-4c2f-4b5e-9a55-17f3f7b7c0f1"
+
+	testCases := map[string]struct {
+		key      string
+		expected string
+		ok       bool
+	}{
+		"Given I have a feature configs key": {
+			key:      string(NewFeatureConfigsKey(uuid)),
+			expected: uuid,
+			ok:       true,
+		},
+		"Given I have a segment key": {
+			key:      string(NewSegmentKey(uuid, "foo-segment-bar")),
+			expected: uuid,
+			ok:       true,
+		},
+		"Given I have a generation key": {
+			key:      string(NewGenerationKey(uuid)),
+			expected: uuid,
+			ok:       true,
+		},
+		"Given I have the feature config key for a generation": {
+			key:      string(NewFeatureConfigKey(NewGenerationEnvID(uuid, 3), "foo")),
+			expected: uuid,
+			ok:       true,
+		},
+		"Given I have an api configs key": {
+			key: string(NewAPIConfigsKey(uuid)),
+			ok:  false,
+		},
+		"Given I have a key that isn't for an environment": {
+			key: "pinned-generations",
+			ok:  false,
+		},
+	}
+
+	for desc, tc := range testCases {
+		tc := tc
+		t.Run(desc, func(t *testing.T) {
+			actual, ok := EnvIDFromConfigKey(tc.key)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
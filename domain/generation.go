@@ -0,0 +1,114 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrNoPreviousGeneration is the error returned when trying to pin an
+	// environment to its previous generation when it doesn't have one
+	ErrNoPreviousGeneration = errors.New("environment has no previous generation")
+
+	// ErrGenerationNotFound is the error returned when trying to pin an
+	// environment to a generation that isn't being kept
+	ErrGenerationNotFound = errors.New("generation not found")
+
+	// ErrEnvironmentNotPinned is the error returned when trying to release an
+	// environment that isn't pinned
+	ErrEnvironmentNotPinned = errors.New("environment isn't pinned")
+)
+
+// GenerationKey is the key that points at the generation of an environment's
+// config that's currently being served
+type GenerationKey string
+
+// NewGenerationKey creates a GenerationKey from an environment
+func NewGenerationKey(envID string) GenerationKey {
+	return GenerationKey(fmt.Sprintf("env-%s-generation", envID))
+}
+
+// EnvIDFromGenerationKey returns the environment a GenerationKey belongs to
+func EnvIDFromGenerationKey(key string) (string, bool) {
+	if !strings.HasPrefix(key, "env-") || !strings.HasSuffix(key, "-generation") {
+		return "", false
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(key, "env-"), "-generation"), true
+}
+
+// configKeyMarkers are the parts of a flag, segment or generation key that
+// follow the environment ID
+var configKeyMarkers = []string{"-feature-config", "-segment", "-generation", "-gen-"}
+
+// EnvIDFromConfigKey returns the environment that a flag, segment or generation
+// key belongs to. It works for unversioned keys e.g. env-<envID>-feature-configs
+// and the keys for a generation e.g. env-<envID>-gen-2-segment-<identifier>
+func EnvIDFromConfigKey(key string) (string, bool) {
+	if !strings.HasPrefix(key, "env-") {
+		return "", false
+	}
+	rest := strings.TrimPrefix(key, "env-")
+
+	end := -1
+	for _, marker := range configKeyMarkers {
+		if i := strings.Index(rest, marker); i > 0 && (end == -1 || i < end) {
+			end = i
+		}
+	}
+	if end == -1 {
+		return "", false
+	}
+	return rest[:end], true
+}
+
+// NewGenerationEnvID returns the ID an environment's config is stored under
+// for a given generation. Using it with the feature config and segment key
+// funcs gives the keys for that generation e.g. env-<envID>-gen-2-feature-configs
+func NewGenerationEnvID(envID string, generation int64) string {
+	return fmt.Sprintf("%s-gen-%d", envID, generation)
+}
+
+// ConfigGeneration points at the generation of an environment's config that's
+// being served and lists the generations that are kept, newest first, so the
+// environment can be pinned to one of them
+type ConfigGeneration struct {
+	Current   int64   `json:"current"`
+	History   []int64 `json:"history,omitempty"`
+	Pinned    bool    `json:"pinned,omitempty"`
+	PinnedAt  int64   `json:"pinnedAt,omitempty"`
+	UpdatedAt int64   `json:"updatedAt"`
+}
+
+// Latest returns the most recently published generation. It's the same as
+// Current unless the environment is pinned.
+func (c ConfigGeneration) Latest() int64 {
+	if len(c.History) == 0 {
+		return c.Current
+	}
+	return c.History[0]
+}
+
+// Kept returns true if the generation is one of the ones being kept
+func (c ConfigGeneration) Kept(generation int64) bool {
+	for _, g := range c.History {
+		if g == generation {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerationRequest contains the fields sent in requests to get or release the
+// generation an environment is pinned to
+type GenerationRequest struct {
+	EnvironmentID string
+}
+
+// PinGenerationRequest contains the fields sent in a request to pin an
+// environment to one of its generations. If Generation is zero the environment
+// is pinned to the generation before the current one.
+type PinGenerationRequest struct {
+	EnvironmentID string
+	Generation    int64
+}
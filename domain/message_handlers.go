@@ -2,6 +2,7 @@ package domain
 
 import (
 	"context"
+	"errors"
 	"io"
 
 	"github.com/harness/ff-proxy/v2/log"
@@ -21,6 +22,22 @@ func (n NoOpMessageHandler) HandleMessage(_ context.Context, _ SSEMessage) error
 	return nil
 }
 
+// MessageHandlers is a MessageHandler that passes each message to a list of
+// MessageHandlers in order
+type MessageHandlers []MessageHandler
+
+// HandleMessage makes MessageHandlers implement the MessageHandler interface.
+// Every handler gets the message even if one before it returns an error.
+func (m MessageHandlers) HandleMessage(ctx context.Context, msg SSEMessage) error {
+	var errs []error
+	for _, h := range m {
+		if err := h.HandleMessage(ctx, msg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
 type healther interface {
 	SetUnhealthy(ctx context.Context) error
 	SetHealthy(ctx context.Context) error
@@ -2,6 +2,7 @@ package domain
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"testing"
 
@@ -152,3 +153,27 @@ func TestReadReplicaMessageHandler_HandleMessage(t *testing.T) {
 		})
 	}
 }
+
+type recordingHandler struct {
+	msgs []SSEMessage
+	err  error
+}
+
+func (r *recordingHandler) HandleMessage(_ context.Context, msg SSEMessage) error {
+	r.msgs = append(r.msgs, msg)
+	return r.err
+}
+
+func TestMessageHandlers_HandleMessage(t *testing.T) {
+	first := &recordingHandler{err: errors.New("first")}
+	second := &recordingHandler{}
+	msg := SSEMessage{Event: EventPatch, Domain: MsgDomainFeature, Environment: "123"}
+
+	t.Log("Given I handle a message with a handler that errors followed by one that doesn't")
+	err := MessageHandlers{first, second}.HandleMessage(context.Background(), msg)
+
+	t.Log("Then both handlers get the message and the error is returned")
+	assert.ErrorIs(t, err, first.err)
+	assert.Equal(t, []SSEMessage{msg}, first.msgs)
+	assert.Equal(t, []SSEMessage{msg}, second.msgs)
+}
@@ -24,6 +24,7 @@ type AuthRepo interface {
 	RemoveAllKeysForEnvironment(ctx context.Context, envID string) error
 	GetKeysForEnvironment(ctx context.Context, envID string) ([]string, error)
 	PatchAPIConfigForEnvironment(ctx context.Context, envID, apikey, action string) error
+	StorageKey(key AuthAPIKey) string
 }
 
 // FlagRepo is the interface for the FlagRepository
@@ -41,3 +42,9 @@ type SegmentRepo interface {
 	RemoveAllSegmentsForEnvironment(ctx context.Context, id string) error
 	GetSegmentsForEnvironment(ctx context.Context, envID string) ([]Segment, bool)
 }
+
+// GenerationRepo is the interface for the repository that publishes an
+// environment's flags and segments together as a new generation
+type GenerationRepo interface {
+	Publish(ctx context.Context, envID string, flags []FeatureFlag, segments []Segment) (ConfigGeneration, error)
+}
@@ -22,6 +22,26 @@ type AuthResponse struct {
 	AuthToken string `json:"authToken"`
 }
 
+// BootstrapRequest contains the fields sent in a POST /client/bootstrap request
+type BootstrapRequest struct {
+	APIKey string
+	Target Target
+
+	// Origin is the Origin header of the request, it's empty if the request
+	// didn't come from a browser
+	Origin string
+}
+
+// BootstrapResponse contains everything a client SDK needs to start up. An
+// empty StreamChannel means the Proxy isn't serving streams and the SDK should
+// poll for changes.
+type BootstrapResponse struct {
+	AuthToken     string                 `json:"authToken"`
+	Evaluations   []clientgen.Evaluation `json:"evaluations"`
+	StreamChannel string                 `json:"streamChannel"`
+	ConfigVersion string                 `json:"configVersion"`
+}
+
 // FeatureConfigRequest contains the fields sent in a GET /client/env/{environmentUUID}/feature-configs
 type FeatureConfigRequest struct {
 	EnvironmentID string
@@ -75,6 +95,26 @@ type StreamResponse struct {
 	GripChannel string
 }
 
+const (
+	// TargetsExportFormatJSON exports Targets as a JSON array
+	TargetsExportFormatJSON = "json"
+	// TargetsExportFormatCSV exports Targets as CSV with a header row
+	TargetsExportFormatCSV = "csv"
+)
+
+// ExportTargetsRequest contains the fields sent in a GET /admin/environments/{environmentUUID}/targets request
+type ExportTargetsRequest struct {
+	EnvironmentID string
+	Format        string
+}
+
+// ExportTargetsResponse contains the Targets returned by an export request and
+// the format they should be written in
+type ExportTargetsResponse struct {
+	Format  string
+	Targets []Target
+}
+
 // MetricsRequest contains the fields sent in a POST /metrics request
 type MetricsRequest struct {
 	// Size is only used internally by the Proxy so we don't want to include it in any JSON requests/responses
@@ -93,6 +133,45 @@ type HealthResponse struct {
 	ConfigStatus ConfigStatus `json:"configStatus"`
 	StreamStatus StreamStatus `json:"streamStatus"`
 	CacheStatus  string       `json:"cacheStatus"`
+
+	SecurityStatus SecurityStatus `json:"securityStatus"`
+
+	// PinnedEnvironments are the environments that have been pinned to a
+	// previous generation of their config, keyed by environment ID
+	PinnedEnvironments map[string]ConfigGeneration `json:"pinnedEnvironments,omitempty"`
+}
+
+const (
+	// SecurityStatusSecure is the security status when no insecure config was found
+	SecurityStatusSecure = "secure"
+	// SecurityStatusInsecure is the security status when the Proxy is running
+	// with an insecure config in insecure dev mode
+	SecurityStatusInsecure = "insecure"
+)
+
+// SecurityStatus contains the result of the startup security checks
+type SecurityStatus struct {
+	Status          string   `json:"status"`
+	InsecureDevMode bool     `json:"insecureDevMode"`
+	Issues          []string `json:"issues,omitempty"`
+}
+
+const (
+	// ProbeStatusHealthy is the status returned by a probe when all of its checks pass
+	ProbeStatusHealthy = "healthy"
+	// ProbeStatusUnhealthy is the status returned by a probe when one or more of its checks fail
+	ProbeStatusUnhealthy = "unhealthy"
+)
+
+// ProbeResponse contains the fields returned by the liveness, readiness and startup probes
+type ProbeResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Healthy returns true if the probe passed
+func (p ProbeResponse) Healthy() bool {
+	return p.Status == ProbeStatusHealthy
 }
 
 type GetProxyConfigInput struct {
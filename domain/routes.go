@@ -2,7 +2,11 @@ package domain
 
 const (
 	AuthRoute                     = "/client/auth"
+	BootstrapRoute                = "/client/bootstrap"
 	HealthRoute                   = "/health"
+	LivenessRoute                 = "/health/live"
+	ReadinessRoute                = "/health/ready"
+	StartupRoute                  = "/health/startup"
 	FeatureConfigsRoute           = "/client/env/:environment_uuid/feature-configs"
 	FeatureConfigsIdentifierRoute = "/client/env/:environment_uuid/feature-configs/:identifier"
 	SegmentsRoute                 = "/client/env/:environment_uuid/target-segments"
@@ -11,4 +15,12 @@ const (
 	EvaluationsFlagRoute          = "/client/env/:environment_uuid/target/:target/evaluations/:feature"
 	StreamRoute                   = "/stream"
 	MetricsRoute                  = "/metrics/:environment_uuid"
+
+	// AdminRoutePrefix is the prefix for operator facing routes that are
+	// authenticated with the admin token rather than an SDK token
+	AdminRoutePrefix        = "/admin"
+	AdminTargetsRoute       = "/admin/environments/:environment_uuid/targets"
+	AdminAuditRoute         = "/admin/audit"
+	AdminGenerationsRoute   = "/admin/environments/:environment_uuid/generations"
+	AdminGenerationPinRoute = "/admin/environments/:environment_uuid/generations/pin"
 )
@@ -0,0 +1,33 @@
+package domain
+
+import "sync"
+
+// SafeConfigStatus is a ConfigStatus that's safe for concurrent use
+type SafeConfigStatus struct {
+	*sync.RWMutex
+	value ConfigStatus
+}
+
+// NewSafeConfigStatus creates a SafeConfigStatus
+func NewSafeConfigStatus(v ConfigStatus) *SafeConfigStatus {
+	return &SafeConfigStatus{
+		RWMutex: &sync.RWMutex{},
+		value:   v,
+	}
+}
+
+// Set sets the ConfigStatus
+func (s *SafeConfigStatus) Set(v ConfigStatus) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.value = v
+}
+
+// Get gets the ConfigStatus
+func (s *SafeConfigStatus) Get() ConfigStatus {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.value
+}
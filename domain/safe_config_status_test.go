@@ -0,0 +1,18 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSafeConfigStatus(t *testing.T) {
+	s := NewSafeConfigStatus(NewConfigStatus(ConfigStateInitializing))
+
+	expected := NewConfigStatus(ConfigStateSynced)
+
+	s.Set(expected)
+
+	actual := s.Get()
+	assert.Equal(t, expected, actual)
+}
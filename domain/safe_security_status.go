@@ -0,0 +1,33 @@
+package domain
+
+import "sync"
+
+// SafeSecurityStatus is a SecurityStatus that's safe for concurrent use
+type SafeSecurityStatus struct {
+	*sync.RWMutex
+	value SecurityStatus
+}
+
+// NewSafeSecurityStatus creates a SafeSecurityStatus
+func NewSafeSecurityStatus(v SecurityStatus) *SafeSecurityStatus {
+	return &SafeSecurityStatus{
+		RWMutex: &sync.RWMutex{},
+		value:   v,
+	}
+}
+
+// Set sets the SecurityStatus
+func (s *SafeSecurityStatus) Set(v SecurityStatus) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.value = v
+}
+
+// Get gets the SecurityStatus
+func (s *SafeSecurityStatus) Get() SecurityStatus {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.value
+}
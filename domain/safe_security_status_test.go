@@ -0,0 +1,18 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSafeSecurityStatus(t *testing.T) {
+	s := NewSafeSecurityStatus(SecurityStatus{Status: SecurityStatusSecure})
+
+	expected := SecurityStatus{Status: SecurityStatusInsecure, Issues: []string{"the auth secret is too weak"}}
+
+	s.Set(expected)
+
+	actual := s.Get()
+	assert.Equal(t, expected, actual)
+}
@@ -21,9 +21,20 @@ func NewTargetKey(envID string, identifier string) TargetKey {
 	return TargetKey(fmt.Sprintf("env-%s-target-config-%s", envID, identifier))
 }
 
+// NewTargetKeysPattern creates a pattern that matches the keys of every Target
+// in an environment. Passing '*' as the envID matches Targets in all environments.
+func NewTargetKeysPattern(envID string) string {
+	return fmt.Sprintf("env-%s-target-config-*", envID)
+}
+
 // Target is a admingen.Target that we can declare methods on
 type Target struct {
 	clientgen.Target
+
+	// FirstSeen and LastSeen are the times, in unix milliseconds, that the
+	// Proxy first and most recently received the Target in an auth request
+	FirstSeen int64 `json:"firstSeen,omitempty"`
+	LastSeen  int64 `json:"lastSeen,omitempty"`
 }
 
 // MarshalBinary marshals a Target to bytes. Currently it uses json marshaling
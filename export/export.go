@@ -6,10 +6,12 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/harness/ff-proxy/v2/domain"
+	"github.com/harness/ff-proxy/v2/hash"
 	"github.com/harness/ff-proxy/v2/log"
 	"github.com/harness/ff-proxy/v2/repository"
 )
@@ -25,6 +27,8 @@ Number of Targets: %d
 
 Number of Segments: %d
 
+API key hash algorithm: %s
+
 Generated at: %s
 `
 
@@ -163,7 +167,7 @@ func (s Service) Persist(ctx context.Context) error {
 			envName = config.Features[0].Environment
 		}
 
-		_, err = io.WriteString(readme, fmt.Sprintf(readmeTemplate, environment, envName, len(config.Features), len(config.Targets), len(config.Segments), time.Now().Format("2006-01-02 15:04:05")))
+		_, err = io.WriteString(readme, fmt.Sprintf(readmeTemplate, environment, envName, len(config.Features), len(config.Targets), len(config.Segments), hashVersions(config.APIKeys), time.Now().Format("2006-01-02 15:04:05")))
 		if err != nil {
 			return fmt.Errorf("failed writing to readme: %s", err)
 		}
@@ -174,6 +178,26 @@ func (s Service) Persist(ctx context.Context) error {
 	return nil
 }
 
+// hashVersions returns the versions of the algorithms used to hash the API keys.
+// The hashes in auth_config.json carry their version as a prefix, apart from v1
+// hashes which are unprefixed, so a Proxy loading them knows how to treat them.
+func hashVersions(apiKeys []string) string {
+	versions := []string{}
+	seen := map[hash.Version]struct{}{}
+
+	for _, key := range apiKeys {
+		v := hash.VersionOf(key)
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = struct{}{}
+		versions = append(versions, string(v))
+	}
+
+	sort.Strings(versions)
+	return strings.Join(versions, ", ")
+}
+
 func saveConfig(filename string, v interface{}) error {
 	// #nosec
 	f, err := os.Create(filename)
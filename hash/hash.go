@@ -1,9 +1,27 @@
 package hash
 
 import (
+	"crypto/hmac"
 	"crypto/sha256"
 	"fmt"
 	"io"
+	"strings"
+)
+
+// Version identifies the algorithm that was used to hash an API key
+type Version string
+
+const (
+	// V1 is an unsalted sha256 hash of a key. These are the hashes Harness SaaS
+	// sends us and they don't have a prefix.
+	V1 Version = "v1"
+
+	// V2 is a HMAC-SHA256, keyed with a pepper that's local to the Proxy, of the
+	// V1 hash of a key. These are prefixed with 'v2:' so they can be told apart
+	// from V1 hashes.
+	V2 Version = "v2"
+
+	v2Prefix = "v2:"
 )
 
 // Hasher an interface for generating a hash
@@ -11,6 +29,27 @@ type Hasher interface {
 	Hash(s string) string
 }
 
+// Upgrader is implemented by Hashers that replace an older hashing algorithm.
+// While keys are being migrated they may be stored using either algorithm.
+type Upgrader interface {
+	Hasher
+
+	// Previous returns the Hasher that this one replaces
+	Previous() Hasher
+
+	// Upgrade converts a hash created by the Previous Hasher into a hash
+	// created by this one. Hashes that are already upgraded are returned as is.
+	Upgrade(h string) string
+}
+
+// VersionOf returns the Version of the algorithm that was used to create a hash
+func VersionOf(h string) Version {
+	if strings.HasPrefix(h, v2Prefix) {
+		return V2
+	}
+	return V1
+}
+
 // Sha256 is a Hasher that generates a sha256 hash
 type Sha256 struct {
 }
@@ -30,3 +69,43 @@ func (s *Sha256) Hash(v string) string {
 	}
 	return fmt.Sprintf("%x", h.Sum(nil))
 }
+
+// HMACSha256 is a Hasher that generates V2 hashes. Because it hashes the V1
+// hash of a key rather than the key itself it can upgrade the V1 hashes we get
+// from Harness SaaS without needing to know the key.
+type HMACSha256 struct {
+	pepper []byte
+	sha    *Sha256
+}
+
+// NewHMACSha256 returns a pointer to a HMACSha256 that's keyed with the pepper
+func NewHMACSha256(pepper string) *HMACSha256 {
+	return &HMACSha256{
+		pepper: []byte(pepper),
+		sha:    NewSha256(),
+	}
+}
+
+// Hash generates the V2 hash of a key
+func (s *HMACSha256) Hash(v string) string {
+	return s.Upgrade(s.sha.Hash(v))
+}
+
+// Previous returns the Sha256 Hasher that HMACSha256 replaces
+func (s *HMACSha256) Previous() Hasher {
+	return s.sha
+}
+
+// Upgrade converts a V1 hash into a V2 hash
+func (s *HMACSha256) Upgrade(h string) string {
+	if VersionOf(h) == V2 {
+		return h
+	}
+
+	mac := hmac.New(sha256.New, s.pepper)
+	_, err := io.WriteString(mac, h)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%s%x", v2Prefix, mac.Sum(nil))
+}
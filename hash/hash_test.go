@@ -13,3 +13,27 @@ func TestSha256_Hash(t *testing.T) {
 		t.Errorf("Sha256.Hash() got: %q, want: %q", actual, expected)
 	}
 }
+
+func TestHMACSha256_Hash(t *testing.T) {
+	const uuid = "0BE2E2F7-A8A5-41A0-957E-59F92C3D81CB"
+	hmac := NewHMACSha256("pepper")
+
+	actual := hmac.Hash(uuid)
+
+	if VersionOf(actual) != V2 {
+		t.Errorf("HMACSha256.Hash() got version: %q, want: %q", VersionOf(actual), V2)
+	}
+
+	if other := NewHMACSha256("other-pepper").Hash(uuid); other == actual {
+		t.Errorf("HMACSha256.Hash() got the same hash for different peppers: %q", actual)
+	}
+
+	v1 := NewSha256().Hash(uuid)
+	if upgraded := hmac.Upgrade(v1); upgraded != actual {
+		t.Errorf("HMACSha256.Upgrade() got: %q, want: %q", upgraded, actual)
+	}
+
+	if upgraded := hmac.Upgrade(actual); upgraded != actual {
+		t.Errorf("HMACSha256.Upgrade() of a v2 hash got: %q, want: %q", upgraded, actual)
+	}
+}
@@ -13,7 +13,7 @@ import (
 	"github.com/harness/ff-proxy/v2/log"
 )
 
-// Heartbeat kicks off a goroutine that polls the /health endpoint at intervals
+// Heartbeat kicks off a goroutine that polls the readiness endpoint at intervals
 // determined by how frequently events are sent on the tick channel.
 func Heartbeat(ctx context.Context, heartbeatInterval int, listenAddr string, logger log.StructuredLogger) {
 	go func() {
@@ -26,7 +26,7 @@ func Heartbeat(ctx context.Context, heartbeatInterval int, listenAddr string, lo
 				logger.Info("stopping heartbeat")
 				return
 			case <-ticker.C:
-				resp, err := http.Get(fmt.Sprintf("%s/health", listenAddr))
+				resp, err := http.Get(fmt.Sprintf("%s%s", listenAddr, domain.ReadinessRoute))
 				if err != nil {
 					logger.Error(fmt.Sprintf("heartbeat request failed: %s", err))
 					continue
@@ -83,22 +83,75 @@ func StreamHealthCheck() error {
 // ProxyHealth ...
 type ProxyHealth struct {
 	logger       log.Logger
-	configHealth domain.ConfigStatus
+	configHealth *domain.SafeConfigStatus
 	streamHealth func(context.Context) (domain.StreamStatus, error)
 	cacheHealth  func(context.Context) error
+	security     *domain.SafeSecurityStatus
+	pinned       func(context.Context) (map[string]domain.ConfigGeneration, error)
 
 	cacheHealthy *domain.SafeBool
+	started      *domain.SafeBool
+	draining     *domain.SafeBool
+}
+
+// WithSecurityStatus sets the result of the startup security checks that's
+// included in the health response
+func WithSecurityStatus(status domain.SecurityStatus) func(p *ProxyHealth) {
+	return func(p *ProxyHealth) {
+		p.security.Set(status)
+	}
+}
+
+// WithPinnedGenerations sets the function used to get the environments that
+// are pinned to a previous generation of their config so they can be included
+// in the health response
+func WithPinnedGenerations(fn func(ctx context.Context) (map[string]domain.ConfigGeneration, error)) func(p *ProxyHealth) {
+	return func(p *ProxyHealth) {
+		p.pinned = fn
+	}
 }
 
 // NewProxyHealth creates a ProxyHealth
-func NewProxyHealth(l log.Logger, config domain.ConfigStatus, stream func(ctx context.Context) (domain.StreamStatus, error), cache func(ctx context.Context) error) ProxyHealth {
-	return ProxyHealth{
+func NewProxyHealth(l log.Logger, config domain.ConfigStatus, stream func(ctx context.Context) (domain.StreamStatus, error), cache func(ctx context.Context) error, opts ...func(p *ProxyHealth)) ProxyHealth {
+	p := ProxyHealth{
 		logger:       l,
-		configHealth: config,
+		configHealth: domain.NewSafeConfigStatus(config),
 		streamHealth: stream,
 		cacheHealth:  cache,
 		cacheHealthy: domain.NewSafeBool(false),
+		started:      domain.NewSafeBool(config.State != domain.ConfigStateInitializing),
+		draining:     domain.NewSafeBool(false),
+		security:     domain.NewSafeSecurityStatus(NewSecurityStatus(nil, false)),
+	}
+
+	for _, opt := range opts {
+		opt(&p)
 	}
+	return p
+}
+
+// SetConfigStatus records the outcome of the latest config sync. Once it's
+// been called the Proxy is considered to have started.
+func (p ProxyHealth) SetConfigStatus(status domain.ConfigStatus) {
+	p.configHealth.Set(status)
+	p.started.Set(true)
+}
+
+// SetSecurityStatus replaces the result of the security checks, e.g. after the
+// auth secret has been rotated
+func (p ProxyHealth) SetSecurityStatus(status domain.SecurityStatus) {
+	p.security.Set(status)
+}
+
+// SetDraining marks the Proxy as shutting down so that the readiness probe fails
+// and load balancers stop routing new requests to it
+func (p ProxyHealth) SetDraining() {
+	p.draining.Set(true)
+}
+
+// Draining returns true if the Proxy has started shutting down
+func (p ProxyHealth) Draining() bool {
+	return p.draining.Get()
 }
 
 // Health returns the status of the Proxy's Stream and Cache
@@ -114,11 +167,56 @@ func (p ProxyHealth) Health(ctx context.Context) domain.HealthResponse {
 		p.logger.Error("failed to get proxy health", "err", err)
 	}
 
+	var pinned map[string]domain.ConfigGeneration
+	if p.pinned != nil {
+		pinned, err = p.pinned(ctx)
+		if err != nil {
+			p.logger.Error("failed to get pinned environments", "err", err)
+		}
+	}
+
 	return domain.HealthResponse{
-		ConfigStatus: p.configHealth,
+		ConfigStatus: p.configHealth.Get(),
 		StreamStatus: streamStatus,
 		CacheStatus:  boolToHealthString(cacheHealthy),
+
+		SecurityStatus:     p.security.Get(),
+		PinnedEnvironments: pinned,
+	}
+}
+
+// Readiness checks that the Proxy is able to serve SDK requests. For a Primary
+// this means the cache is reachable and config has been loaded into it, for a
+// read replica it means the cache is reachable and the replica has seen the
+// Primary's stream status. It always fails once the Proxy has started draining.
+func (p ProxyHealth) Readiness(ctx context.Context) domain.ProbeResponse {
+	checks := map[string]string{
+		"cache": boolToHealthString(p.cacheHealthy.Get()),
+	}
+
+	if p.draining.Get() {
+		checks["draining"] = domain.ProbeStatusUnhealthy
+	}
+
+	configStatus := p.configHealth.Get()
+	if configStatus.State == domain.ConfigStateReadReplica {
+		streamStatus, err := p.streamHealth(ctx)
+		if err != nil {
+			p.logger.Error("failed to get stream health", "err", err)
+		}
+		checks["stream"] = boolToHealthString(err == nil && streamStatus.State != domain.StreamStateInitializing)
+	} else {
+		checks["config"] = boolToHealthString(configStatus.State == domain.ConfigStateSynced)
 	}
+
+	return newProbeResponse(checks)
+}
+
+// Startup checks that the Proxy has finished its initial config sync
+func (p ProxyHealth) Startup(_ context.Context) domain.ProbeResponse {
+	return newProbeResponse(map[string]string{
+		"config": boolToHealthString(p.started.Get()),
+	})
 }
 
 func (p ProxyHealth) PollCacheHealth(ctx context.Context, interval time.Duration) {
@@ -150,6 +248,15 @@ func (p ProxyHealth) PollCacheHealth(ctx context.Context, interval time.Duration
 	}()
 }
 
+func newProbeResponse(checks map[string]string) domain.ProbeResponse {
+	for _, v := range checks {
+		if v != domain.ProbeStatusHealthy {
+			return domain.ProbeResponse{Status: domain.ProbeStatusUnhealthy, Checks: checks}
+		}
+	}
+	return domain.ProbeResponse{Status: domain.ProbeStatusHealthy, Checks: checks}
+}
+
 func boolToHealthString(healthy bool) string {
 	if !healthy {
 		return "unhealthy"
@@ -0,0 +1,34 @@
+package health
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/harness/ff-proxy/v2/domain"
+	"github.com/harness/ff-proxy/v2/log"
+)
+
+func TestProxyHealth_Readiness(t *testing.T) {
+	streamHealth := func(ctx context.Context) (domain.StreamStatus, error) {
+		return domain.StreamStatus{}, nil
+	}
+	cacheHealth := func(ctx context.Context) error {
+		return nil
+	}
+
+	t.Log("Given the initial config sync has failed")
+	p := NewProxyHealth(log.NoOpLogger{}, domain.NewConfigStatus(domain.ConfigStateInitializing), streamHealth, cacheHealth)
+	p.SetConfigStatus(domain.NewConfigStatus(domain.ConfigStateFailedToSync))
+
+	t.Log("Then the Proxy has started but isn't ready")
+	assert.True(t, p.Startup(context.Background()).Healthy())
+	assert.Equal(t, domain.ProbeStatusUnhealthy, p.Readiness(context.Background()).Checks["config"])
+
+	t.Log("When a later config sync succeeds")
+	p.SetConfigStatus(domain.NewConfigStatus(domain.ConfigStateSynced))
+
+	t.Log("Then the config check passes")
+	assert.Equal(t, domain.ProbeStatusHealthy, p.Readiness(context.Background()).Checks["config"])
+}
@@ -0,0 +1,86 @@
+package health
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/harness/ff-proxy/v2/domain"
+)
+
+const (
+	// DefaultAuthSecret is the auth secret the Proxy uses if one isn't configured
+	DefaultAuthSecret = "secret"
+
+	// MinAuthSecretEntropyBits is the minimum estimated entropy an auth secret
+	// needs to have, a random 16 byte hex or base64 string comfortably meets it
+	MinAuthSecretEntropyBits = 96
+)
+
+// SecurityConfig is the config the startup security checks are run against
+type SecurityConfig struct {
+	AuthSecret string
+
+	// SignsWithSecret should be true if auth tokens are signed with the
+	// AuthSecret rather than an asymmetric key
+	SignsWithSecret bool
+
+	BypassAuth bool
+}
+
+// CheckSecurity returns every issue that makes the config unsafe to run in production
+func CheckSecurity(c SecurityConfig) []string {
+	issues := []string{}
+
+	if c.BypassAuth {
+		issues = append(issues, "authentication is bypassed so any client can access flag config and evaluations without an SDK key")
+	}
+
+	if !c.SignsWithSecret {
+		return issues
+	}
+
+	if c.AuthSecret == DefaultAuthSecret {
+		issues = append(issues, "the auth secret is the default value so anyone can forge auth tokens")
+	} else if bits := entropyBits(c.AuthSecret); bits < MinAuthSecretEntropyBits {
+		issues = append(issues, fmt.Sprintf("the auth secret is too weak, it has an estimated %.0f bits of entropy but needs at least %d, e.g. generate one with 'openssl rand -base64 32'", bits, MinAuthSecretEntropyBits))
+	}
+
+	return issues
+}
+
+// NewSecurityStatus creates the SecurityStatus reported by the health endpoint
+func NewSecurityStatus(issues []string, insecureDevMode bool) domain.SecurityStatus {
+	status := domain.SecurityStatusSecure
+	if len(issues) > 0 {
+		status = domain.SecurityStatusInsecure
+	}
+
+	return domain.SecurityStatus{
+		Status:          status,
+		InsecureDevMode: insecureDevMode,
+		Issues:          issues,
+	}
+}
+
+// entropyBits estimates the entropy of s from how often each of its characters
+// occur. It overestimates the strength of dictionary words but catches short
+// and repetitive secrets.
+func entropyBits(s string) float64 {
+	runes := []rune(s)
+	if len(runes) == 0 {
+		return 0
+	}
+
+	counts := map[rune]int{}
+	for _, r := range runes {
+		counts[r]++
+	}
+
+	perChar := 0.0
+	for _, n := range counts {
+		p := float64(n) / float64(len(runes))
+		perChar -= p * math.Log2(p)
+	}
+
+	return perChar * float64(len(runes))
+}
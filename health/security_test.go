@@ -0,0 +1,59 @@
+package health
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/harness/ff-proxy/v2/domain"
+)
+
+func TestCheckSecurity(t *testing.T) {
+	const strongSecret = "q8Zt1xJ0vN3kR7yWb5LmC2sFhD9gPaE4uKoTiVj6XcY="
+
+	testCases := map[string]struct {
+		config         SecurityConfig
+		expectedIssues int
+	}{
+		"Given I have a strong secret": {
+			config:         SecurityConfig{AuthSecret: strongSecret, SignsWithSecret: true},
+			expectedIssues: 0,
+		},
+		"Given I have the default secret": {
+			config:         SecurityConfig{AuthSecret: DefaultAuthSecret, SignsWithSecret: true},
+			expectedIssues: 1,
+		},
+		"Given I have a weak secret": {
+			config:         SecurityConfig{AuthSecret: "foobar", SignsWithSecret: true},
+			expectedIssues: 1,
+		},
+		"Given I have a long but repetitive secret": {
+			config:         SecurityConfig{AuthSecret: "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", SignsWithSecret: true},
+			expectedIssues: 1,
+		},
+		"Given I have the default secret and bypass auth": {
+			config:         SecurityConfig{AuthSecret: DefaultAuthSecret, SignsWithSecret: true, BypassAuth: true},
+			expectedIssues: 2,
+		},
+		"Given I have the default secret but sign tokens with an asymmetric key": {
+			config:         SecurityConfig{AuthSecret: DefaultAuthSecret, SignsWithSecret: false},
+			expectedIssues: 0,
+		},
+	}
+
+	for desc, tc := range testCases {
+		desc := desc
+		tc := tc
+
+		t.Run(desc, func(t *testing.T) {
+			assert.Len(t, CheckSecurity(tc.config), tc.expectedIssues, desc)
+		})
+	}
+}
+
+func TestNewSecurityStatus(t *testing.T) {
+	assert.Equal(t, domain.SecurityStatus{Status: domain.SecurityStatusSecure}, NewSecurityStatus(nil, false))
+
+	issues := []string{"authentication is bypassed"}
+	assert.Equal(t, domain.SecurityStatus{Status: domain.SecurityStatusInsecure, InsecureDevMode: true, Issues: issues}, NewSecurityStatus(issues, true))
+}
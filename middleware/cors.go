@@ -0,0 +1,190 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+
+	"github.com/harness/ff-proxy/v2/domain"
+)
+
+const wildcardOrigin = "*"
+
+// CorsConfig is the CORS policy the Proxy applies to browser requests
+type CorsConfig struct {
+	// AllowOrigins are the origins that can make requests to the Proxy. They
+	// can be an exact origin e.g. https://app.example.com, a wildcard subdomain
+	// e.g. https://*.example.com or '*' to allow any origin.
+	AllowOrigins []string
+
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	AllowCredentials bool
+	MaxAge           int
+
+	// EnvironmentOrigins restricts the origins that can make requests for an
+	// environment. Environments that aren't in the map can be used from any of
+	// the AllowOrigins.
+	EnvironmentOrigins map[string][]string
+}
+
+// DefaultCorsConfig returns a CorsConfig that allows requests from any origin
+func DefaultCorsConfig() CorsConfig {
+	return CorsConfig{
+		AllowOrigins: []string{wildcardOrigin},
+		AllowHeaders: []string{"*", "Authorization"},
+	}
+}
+
+// Validate checks that the CorsConfig isn't unsafe or malformed
+func (c CorsConfig) Validate() error {
+	for _, o := range c.allOrigins() {
+		if o == wildcardOrigin {
+			if c.AllowCredentials {
+				return errors.New("cors origins can't be '*' when credentials are allowed")
+			}
+			continue
+		}
+
+		if !strings.Contains(o, "://") {
+			return fmt.Errorf("invalid cors origin %q, origins must include a scheme e.g. https://app.example.com", o)
+		}
+
+		if strings.Count(o, "*") > 1 || (strings.Contains(o, "*") && !strings.Contains(o, "://*.")) {
+			return fmt.Errorf("invalid cors origin %q, wildcards are only supported for subdomains e.g. https://*.example.com", o)
+		}
+	}
+
+	return nil
+}
+
+// ParseEnvironmentOrigins parses per environment origin allowlists in the
+// format 'envID=origin|origin,envID=origin'
+func ParseEnvironmentOrigins(s string) (map[string][]string, error) {
+	envOrigins := map[string][]string{}
+	if s == "" {
+		return envOrigins, nil
+	}
+
+	for _, part := range strings.Split(s, ",") {
+		envID, origins, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || envID == "" || origins == "" {
+			return nil, fmt.Errorf("invalid environment origins %q, expected a comma separated list of envID=origin|origin", s)
+		}
+
+		envOrigins[envID] = append(envOrigins[envID], strings.Split(origins, "|")...)
+	}
+
+	return envOrigins, nil
+}
+
+// allOrigins returns the AllowOrigins and every origin in the EnvironmentOrigins
+func (c CorsConfig) allOrigins() []string {
+	origins := append([]string{}, c.AllowOrigins...)
+	for _, envOrigins := range c.EnvironmentOrigins {
+		origins = append(origins, envOrigins...)
+	}
+	return origins
+}
+
+// originAllowed returns true if the origin matches any of the patterns
+func originAllowed(origin string, patterns []string) bool {
+	for _, p := range patterns {
+		if p == wildcardOrigin || strings.EqualFold(p, origin) {
+			return true
+		}
+
+		// For wildcard subdomains the origin has to have the same scheme and
+		// end with the same domain, e.g. https://*.example.com matches
+		// https://app.example.com but not https://example.com or https://badexample.com
+		prefix, suffix, ok := strings.Cut(p, "*")
+		if !ok {
+			continue
+		}
+
+		o := strings.ToLower(origin)
+		prefix, suffix = strings.ToLower(prefix), strings.ToLower(suffix)
+		if len(o) <= len(prefix)+len(suffix) || !strings.HasPrefix(o, prefix) || !strings.HasSuffix(o, suffix) {
+			continue
+		}
+
+		subdomain := o[len(prefix) : len(o)-len(suffix)]
+		if !strings.ContainsAny(subdomain, "/:@") {
+			return true
+		}
+	}
+	return false
+}
+
+// NewCorsMiddleware returns a cors middleware. Browsers send preflight requests
+// without an auth token so we don't know which environment they're for, which
+// means preflights are allowed for any origin in the AllowOrigins or the
+// EnvironmentOrigins. The environment allowlists are then enforced on the actual
+// request by NewEnvironmentOriginMiddleware.
+func NewCorsMiddleware(c CorsConfig) echo.MiddlewareFunc {
+	origins := c.allOrigins()
+
+	conf := middleware.CORSConfig{
+		AllowMethods:     []string{http.MethodGet, http.MethodOptions, http.MethodPost},
+		AllowHeaders:     c.AllowHeaders,
+		ExposeHeaders:    c.ExposeHeaders,
+		AllowCredentials: c.AllowCredentials,
+		MaxAge:           c.MaxAge,
+	}
+
+	// If any origin is allowed we respond with '*' rather than echoing the origin back
+	if len(origins) == 1 && origins[0] == wildcardOrigin {
+		conf.AllowOrigins = origins
+		return middleware.CORSWithConfig(conf)
+	}
+
+	conf.AllowOriginFunc = func(origin string) (bool, error) {
+		return originAllowed(origin, origins), nil
+	}
+	return middleware.CORSWithConfig(conf)
+}
+
+// EnvironmentOriginAllowed returns false if the environment has an origin
+// allowlist and the origin isn't in it. Requests without an origin didn't come
+// from a browser so they're always allowed.
+func EnvironmentOriginAllowed(envOrigins map[string][]string, envID string, origin string) bool {
+	if origin == "" {
+		return true
+	}
+
+	allowed, ok := envOrigins[envID]
+	if !ok {
+		return true
+	}
+	return originAllowed(origin, allowed)
+}
+
+// NewEnvironmentOriginMiddleware returns an echo middleware that rejects browser
+// requests for an environment that has an origin allowlist if they come from
+// an origin that isn't in it. It needs to run after the auth middleware so it
+// can get the environment from the token claims.
+func NewEnvironmentOriginMiddleware(envOrigins map[string][]string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			origin := c.Request().Header.Get(echo.HeaderOrigin)
+			if origin == "" || len(envOrigins) == 0 {
+				return next(c)
+			}
+
+			envID := c.Param("environment_uuid")
+			if claims, ok := c.Get(tokenClaims.String()).(*domain.Claims); ok && claims.Environment != "" {
+				envID = claims.Environment
+			}
+
+			if !EnvironmentOriginAllowed(envOrigins, envID, origin) {
+				return echo.NewHTTPError(http.StatusForbidden, fmt.Sprintf("origin %s is not allowed for environment %s", origin, envID))
+			}
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/harness/ff-proxy/v2/domain"
+)
+
+func TestOriginAllowed(t *testing.T) {
+	patterns := []string{"https://app.example.com", "https://*.harness.io"}
+
+	testCases := map[string]struct {
+		origin   string
+		patterns []string
+		expected bool
+	}{
+		"Given the origin matches an exact origin": {
+			origin:   "https://app.example.com",
+			patterns: patterns,
+			expected: true,
+		},
+		"Given the origin matches an exact origin with a different case": {
+			origin:   "https://APP.example.com",
+			patterns: patterns,
+			expected: true,
+		},
+		"Given the origin has a different scheme": {
+			origin:   "http://app.example.com",
+			patterns: patterns,
+			expected: false,
+		},
+		"Given the origin matches a wildcard subdomain": {
+			origin:   "https://ff.harness.io",
+			patterns: patterns,
+			expected: true,
+		},
+		"Given the origin matches a nested wildcard subdomain": {
+			origin:   "https://a.b.harness.io",
+			patterns: patterns,
+			expected: true,
+		},
+		"Given the origin is the wildcard's parent domain": {
+			origin:   "https://harness.io",
+			patterns: patterns,
+			expected: false,
+		},
+		"Given the origin only ends with the wildcard's domain": {
+			origin:   "https://notharness.io",
+			patterns: patterns,
+			expected: false,
+		},
+		"Given the origin tries to smuggle the wildcard's domain": {
+			origin:   "https://evil.com/.harness.io",
+			patterns: patterns,
+			expected: false,
+		},
+		"Given any origin is allowed": {
+			origin:   "https://evil.com",
+			patterns: []string{"*"},
+			expected: true,
+		},
+		"Given there are no patterns": {
+			origin:   "https://app.example.com",
+			patterns: []string{},
+			expected: false,
+		},
+	}
+
+	for desc, tc := range testCases {
+		desc := desc
+		tc := tc
+
+		t.Run(desc, func(t *testing.T) {
+			assert.Equal(t, tc.expected, originAllowed(tc.origin, tc.patterns))
+		})
+	}
+}
+
+func TestCorsConfig_Validate(t *testing.T) {
+	testCases := map[string]struct {
+		config    CorsConfig
+		shouldErr bool
+	}{
+		"Given I have the default config": {
+			config:    DefaultCorsConfig(),
+			shouldErr: false,
+		},
+		"Given I allow any origin with credentials": {
+			config:    CorsConfig{AllowOrigins: []string{"*"}, AllowCredentials: true},
+			shouldErr: true,
+		},
+		"Given I have specific origins with credentials": {
+			config:    CorsConfig{AllowOrigins: []string{"https://app.example.com", "https://*.example.com"}, AllowCredentials: true},
+			shouldErr: false,
+		},
+		"Given I have an origin without a scheme": {
+			config:    CorsConfig{AllowOrigins: []string{"app.example.com"}},
+			shouldErr: true,
+		},
+		"Given I have a wildcard that isn't a subdomain": {
+			config:    CorsConfig{AllowOrigins: []string{"https://app.*.com"}},
+			shouldErr: true,
+		},
+		"Given I have an invalid environment origin": {
+			config:    CorsConfig{EnvironmentOrigins: map[string][]string{"123": {"example.com"}}},
+			shouldErr: true,
+		},
+	}
+
+	for desc, tc := range testCases {
+		desc := desc
+		tc := tc
+
+		t.Run(desc, func(t *testing.T) {
+			err := tc.config.Validate()
+			if (err != nil) != tc.shouldErr {
+				t.Errorf("(%s): error = %v, shouldErr = %v", desc, err, tc.shouldErr)
+			}
+		})
+	}
+}
+
+func TestParseEnvironmentOrigins(t *testing.T) {
+	testCases := map[string]struct {
+		value     string
+		expected  map[string][]string
+		shouldErr bool
+	}{
+		"Given I have an empty string": {
+			value:    "",
+			expected: map[string][]string{},
+		},
+		"Given I have origins for two environments": {
+			value: "123=https://a.example.com|https://*.b.example.com, 456=https://c.example.com",
+			expected: map[string][]string{
+				"123": {"https://a.example.com", "https://*.b.example.com"},
+				"456": {"https://c.example.com"},
+			},
+		},
+		"Given I have an environment without origins": {
+			value:     "123=",
+			shouldErr: true,
+		},
+		"Given I have origins without an environment": {
+			value:     "https://a.example.com",
+			shouldErr: true,
+		},
+	}
+
+	for desc, tc := range testCases {
+		desc := desc
+		tc := tc
+
+		t.Run(desc, func(t *testing.T) {
+			actual, err := ParseEnvironmentOrigins(tc.value)
+			if (err != nil) != tc.shouldErr {
+				t.Errorf("(%s): error = %v, shouldErr = %v", desc, err, tc.shouldErr)
+			}
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestNewCorsMiddleware(t *testing.T) {
+	config := CorsConfig{
+		AllowOrigins:     []string{"https://*.example.com"},
+		AllowHeaders:     []string{"Authorization", "Content-Type"},
+		ExposeHeaders:    []string{"Retry-After"},
+		AllowCredentials: true,
+		MaxAge:           600,
+		EnvironmentOrigins: map[string][]string{
+			"123": {"https://app.harness.io"},
+		},
+	}
+
+	testCases := map[string]struct {
+		config                   CorsConfig
+		origin                   string
+		expectedAllowOrigin      string
+		expectedAllowHeaders     string
+		expectedAllowCredentials string
+		expectedMaxAge           string
+	}{
+		"Given I make a preflight request with the default config": {
+			config:               DefaultCorsConfig(),
+			origin:               "https://anything.com",
+			expectedAllowOrigin:  "*",
+			expectedAllowHeaders: "*,Authorization",
+		},
+		"Given I make a preflight request from an allowed origin": {
+			config:                   config,
+			origin:                   "https://app.example.com",
+			expectedAllowOrigin:      "https://app.example.com",
+			expectedAllowHeaders:     "Authorization,Content-Type",
+			expectedAllowCredentials: "true",
+			expectedMaxAge:           "600",
+		},
+		"Given I make a preflight request from an environment's origin": {
+			config:                   config,
+			origin:                   "https://app.harness.io",
+			expectedAllowOrigin:      "https://app.harness.io",
+			expectedAllowHeaders:     "Authorization,Content-Type",
+			expectedAllowCredentials: "true",
+			expectedMaxAge:           "600",
+		},
+		"Given I make a preflight request from an origin that isn't allowed": {
+			config: config,
+			origin: "https://evil.com",
+		},
+	}
+
+	for desc, tc := range testCases {
+		desc := desc
+		tc := tc
+
+		t.Run(desc, func(t *testing.T) {
+			e := echo.New()
+			e.Use(NewCorsMiddleware(tc.config))
+			e.GET(domain.FeatureConfigsRoute, func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodOptions, "/client/env/123/feature-configs", nil)
+			req.Header.Set(echo.HeaderOrigin, tc.origin)
+			req.Header.Set(echo.HeaderAccessControlRequestMethod, http.MethodGet)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedAllowOrigin, rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+			assert.Equal(t, tc.expectedAllowHeaders, rec.Header().Get(echo.HeaderAccessControlAllowHeaders))
+			assert.Equal(t, tc.expectedAllowCredentials, rec.Header().Get(echo.HeaderAccessControlAllowCredentials))
+			assert.Equal(t, tc.expectedMaxAge, rec.Header().Get(echo.HeaderAccessControlMaxAge))
+		})
+	}
+}
+
+func TestNewEnvironmentOriginMiddleware(t *testing.T) {
+	envOrigins := map[string][]string{
+		"123": {"https://*.example.com"},
+	}
+
+	testCases := map[string]struct {
+		path               string
+		origin             string
+		claims             *domain.Claims
+		expectedStatusCode int
+	}{
+		"Given I make a request without an origin": {
+			path:               "/client/env/123/feature-configs",
+			origin:             "",
+			expectedStatusCode: http.StatusOK,
+		},
+		"Given I make a request from an origin in the environment's allowlist": {
+			path:               "/client/env/123/feature-configs",
+			origin:             "https://app.example.com",
+			expectedStatusCode: http.StatusOK,
+		},
+		"Given I make a request from an origin that isn't in the environment's allowlist": {
+			path:               "/client/env/123/feature-configs",
+			origin:             "https://evil.com",
+			expectedStatusCode: http.StatusForbidden,
+		},
+		"Given I make a request for an environment without an allowlist": {
+			path:               "/client/env/456/feature-configs",
+			origin:             "https://evil.com",
+			expectedStatusCode: http.StatusOK,
+		},
+		"Given I make a stream request with a token for an environment with an allowlist": {
+			path:               "/stream",
+			origin:             "https://evil.com",
+			claims:             &domain.Claims{Environment: "123"},
+			expectedStatusCode: http.StatusForbidden,
+		},
+	}
+
+	for desc, tc := range testCases {
+		desc := desc
+		tc := tc
+
+		t.Run(desc, func(t *testing.T) {
+			e := echo.New()
+			e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+				return func(c echo.Context) error {
+					if tc.claims != nil {
+						c.Set(tokenClaims.String(), tc.claims)
+					}
+					return next(c)
+				}
+			}, NewEnvironmentOriginMiddleware(envOrigins))
+
+			handler := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+			e.GET(domain.FeatureConfigsRoute, handler)
+			e.GET(domain.StreamRoute, handler)
+
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.origin != "" {
+				req.Header.Set(echo.HeaderOrigin, tc.origin)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rec.Code)
+		})
+	}
+}
@@ -2,14 +2,16 @@ package middleware
 
 import (
 	"context"
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/golang-jwt/jwt"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -52,9 +54,15 @@ func NewEchoLoggingMiddleware(l log.Logger) echo.MiddlewareFunc {
 	})
 }
 
+// tokenKeys looks up the key to verify a token with
+type tokenKeys interface {
+	Keyfunc(t *jwt.Token) (interface{}, error)
+}
+
 // NewEchoAuthMiddleware returns an echo middleware that checks if auth headers
-// are valid
-func NewEchoAuthMiddleware(logger log.Logger, authRepo keyLookUp, secret []byte, bypassAuth bool) echo.MiddlewareFunc {
+// are valid. Tokens are verified using the key identified by their kid header
+// and are rejected once they've expired.
+func NewEchoAuthMiddleware(logger log.Logger, authRepo keyLookUp, keys tokenKeys, bypassAuth bool) echo.MiddlewareFunc {
 	return middleware.JWTWithConfig(middleware.JWTConfig{
 		AuthScheme:  "Bearer",
 		TokenLookup: "header:Authorization",
@@ -63,9 +71,7 @@ func NewEchoAuthMiddleware(logger log.Logger, authRepo keyLookUp, secret []byte,
 				return nil, errors.New("token was empty")
 			}
 
-			token, err := jwt.ParseWithClaims(auth, &domain.Claims{}, func(t *jwt.Token) (interface{}, error) {
-				return secret, nil
-			})
+			token, err := jwt.ParseWithClaims(auth, &domain.Claims{}, keys.Keyfunc)
 			if err != nil {
 				return nil, err
 			}
@@ -84,7 +90,7 @@ func NewEchoAuthMiddleware(logger log.Logger, authRepo keyLookUp, secret []byte,
 			urlPath := c.Request().URL.Path
 			prometheusRequest := urlPath == metricsPath && c.Request().Method == http.MethodGet
 
-			return urlPath == "/client/auth" || urlPath == "/health" || prometheusRequest
+			return urlPath == domain.AuthRoute || urlPath == domain.BootstrapRoute || isHealthRoute(urlPath) || isAdminRoute(urlPath) || prometheusRequest
 		},
 		ErrorHandlerWithContext: func(err error, c echo.Context) error {
 			return c.JSON(http.StatusUnauthorized, err)
@@ -108,6 +114,47 @@ func isKeyInCache(ctx context.Context, logger log.Logger, repo keyLookUp, claims
 	return exists
 }
 
+// isHealthRoute returns true if the path is the health endpoint or one of the probes
+func isHealthRoute(path string) bool {
+	switch path {
+	case domain.HealthRoute, domain.LivenessRoute, domain.ReadinessRoute, domain.StartupRoute:
+		return true
+	default:
+		return false
+	}
+}
+
+// isAdminRoute returns true if the path is one of the admin endpoints
+func isAdminRoute(path string) bool {
+	return path == domain.AdminRoutePrefix || strings.HasPrefix(path, domain.AdminRoutePrefix+"/")
+}
+
+// NewEchoAdminAuthMiddleware returns an echo middleware that protects the admin
+// endpoints. Requests must include the admin token as a bearer token in their
+// Authorization header. If no admin token has been configured the admin
+// endpoints are disabled and every request to them is rejected.
+func NewEchoAdminAuthMiddleware(adminToken string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !isAdminRoute(c.Request().URL.Path) {
+				return next(c)
+			}
+
+			if adminToken == "" {
+				return echo.NewHTTPError(http.StatusForbidden, "admin endpoints are disabled, set ADMIN_TOKEN to enable them")
+			}
+
+			auth := c.Request().Header.Get(echo.HeaderAuthorization)
+			provided, ok := strings.CutPrefix(auth, "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(adminToken)) != 1 {
+				return echo.NewHTTPError(http.StatusUnauthorized, "invalid admin token")
+			}
+
+			return next(c)
+		}
+	}
+}
+
 const harnessSDKAppIDHeader = "Harness-SDK-ApplicationID"
 
 // NewEchoRequestIDMiddleware extracts X-Request_Id and Harness-SDK-ApplicationID
@@ -167,39 +214,78 @@ type prometheusMiddleware struct {
 	requestCount    *prometheus.CounterVec
 	requestDuration *prometheus.HistogramVec
 	contentLength   *prometheus.HistogramVec
+
+	envLabels *labelLimiter
+	sdkLabels *labelLimiter
+}
+
+// PrometheusOption configures the labels the prometheus middleware records
+type PrometheusOption func(p *prometheusMiddleware)
+
+// WithEnvironmentLabels configures the prometheus middleware to label request
+// durations by environment as well as request counts. The environment is taken
+// from the token claims for routes that don't have it in their path. At most
+// maxValues environments get their own label, any others are labelled "other".
+func WithEnvironmentLabels(maxValues int) PrometheusOption {
+	return func(p *prometheusMiddleware) {
+		p.envLabels = newLabelLimiter(maxValues)
+	}
+}
+
+// WithSDKLabels configures the prometheus middleware to label requests by the
+// type, language and version of the SDK that made them, which are taken from
+// the Harness-SDK-Info header. At most maxValues combinations get their own
+// labels, any others are labelled "other".
+func WithSDKLabels(maxValues int) PrometheusOption {
+	return func(p *prometheusMiddleware) {
+		p.sdkLabels = newLabelLimiter(maxValues)
+	}
 }
 
 // NewPrometheusMiddleware creates a middleware that uses prometheus to track request rate, duration & the size
 // of request bodies
-func NewPrometheusMiddleware(reg prometheus.Registerer) echo.MiddlewareFunc {
-	p := &prometheusMiddleware{
-		requestCount: prometheus.NewCounterVec(prometheus.CounterOpts{
-			Name: "ff_proxy_http_requests_total",
-			Help: "Records the number of requests to an endpoint",
-		},
-			[]string{"url", "envID", "code"},
-		),
-		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
-			Name:    "ff_proxy_http_requests_duration",
-			Help:    "Records the request duration for an endpoint",
-			Buckets: prometheus.DefBuckets,
-		},
-			[]string{"url"},
-		),
-		contentLength: prometheus.NewHistogramVec(prometheus.HistogramOpts{
-			Name:    "ff_http_requests_content_length_histogram",
-			Help:    "Records the value of the Content-Length header for an http request",
-			Buckets: prometheus.ExponentialBuckets(100, 2, 10),
-		}, []string{}),
+func NewPrometheusMiddleware(reg prometheus.Registerer, opts ...PrometheusOption) echo.MiddlewareFunc {
+	p := &prometheusMiddleware{}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	countLabels := []string{"url", "envID", "code"}
+	durationLabels := []string{"url"}
+	if p.envLabels != nil {
+		durationLabels = append(durationLabels, "envID")
+	}
+	if p.sdkLabels != nil {
+		countLabels = append(countLabels, "sdkType", "sdkLanguage", "sdkVersion")
+		durationLabels = append(durationLabels, "sdkType", "sdkLanguage", "sdkVersion")
 	}
 
+	p.requestCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ff_proxy_http_requests_total",
+		Help: "Records the number of requests to an endpoint",
+	},
+		countLabels,
+	)
+	p.requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ff_proxy_http_requests_duration",
+		Help:    "Records the request duration for an endpoint",
+		Buckets: prometheus.DefBuckets,
+	},
+		durationLabels,
+	)
+	p.contentLength = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ff_http_requests_content_length_histogram",
+		Help:    "Records the value of the Content-Length header for an http request",
+		Buckets: prometheus.ExponentialBuckets(100, 2, 10),
+	}, []string{})
+
 	reg.MustRegister(p.requestCount, p.requestDuration, p.contentLength)
 
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			// We don't care about tracking metrics for these endpoints
 			urlPath := c.Request().URL.Path
-			if urlPath == "/health" || urlPath == "/prometheus/metrics" {
+			if isHealthRoute(urlPath) || urlPath == "/prometheus/metrics" {
 				return next(c)
 			}
 
@@ -216,12 +302,12 @@ func NewPrometheusMiddleware(reg prometheus.Registerer) echo.MiddlewareFunc {
 
 			path := c.Path()
 			statusCode := strconv.Itoa(res.Status)
-			envID := c.Param("environment_uuid")
 
 			// Don't want to track request count or duration to the health or prometheus /metrics endpoints
 			if strings.Contains(path, "/health") || path != "/metrics" {
-				p.requestCount.WithLabelValues(path, envID, statusCode).Inc()
-				p.requestDuration.WithLabelValues(path).Observe(duration.Seconds())
+				countValues, durationValues := p.labelValues(c, path, statusCode)
+				p.requestCount.WithLabelValues(countValues...).Inc()
+				p.requestDuration.WithLabelValues(durationValues...).Observe(duration.Seconds())
 
 			}
 
@@ -235,13 +321,104 @@ func NewPrometheusMiddleware(reg prometheus.Registerer) echo.MiddlewareFunc {
 	}
 }
 
-// NewCorsMiddleware returns a cors middleware
-func NewCorsMiddleware() echo.MiddlewareFunc {
-	return middleware.CORSWithConfig(middleware.CORSConfig{
-		AllowOrigins: []string{"*"},
-		AllowMethods: []string{http.MethodGet, http.MethodOptions, http.MethodPost},
-		AllowHeaders: []string{"*", "Authorization"},
-	})
+// labelValues returns the values for the request count and request duration labels
+func (p *prometheusMiddleware) labelValues(c echo.Context, path string, statusCode string) ([]string, []string) {
+	envID := c.Param("environment_uuid")
+	durationValues := []string{path}
+
+	if p.envLabels != nil {
+		// The claims are only set once the auth middleware has run so we
+		// have to look them up after the request has been handled
+		claims, ok := c.Get(tokenClaims.String()).(*domain.Claims)
+		if ok && envID == "" {
+			envID = claims.Environment
+		}
+
+		// The environment in the path of a request that failed auth could be
+		// anything, so we don't let them use up the limiter's slots
+		if !ok && isAuthFailure(c.Response().Status) {
+			envID = ""
+		}
+
+		// Requests without an environment e.g. auth requests don't count towards the limit
+		if envID != "" {
+			envID = p.envLabels.limit(envID)[0]
+		}
+		durationValues = append(durationValues, envID)
+	}
+
+	countValues := []string{path, envID, statusCode}
+
+	if p.sdkLabels != nil {
+		sdkValues := p.sdkLabels.limit(parseSDKInfo(c.Request().Header.Get(harnessSDKInfoHeader))...)
+		countValues = append(countValues, sdkValues...)
+		durationValues = append(durationValues, sdkValues...)
+	}
+
+	return countValues, durationValues
+}
+
+// isAuthFailure returns true if the status code means the request was rejected
+// because it wasn't authenticated or authorised
+func isAuthFailure(status int) bool {
+	return status == http.StatusUnauthorized || status == http.StatusForbidden
+}
+
+const harnessSDKInfoHeader = "Harness-SDK-Info"
+
+// parseSDKInfo returns the type, language and version of the SDK from a
+// Harness-SDK-Info header e.g. 'Go 0.1.23 Server'. The type is lower cased so
+// it matches the SDK_TYPE that SDKs send in their metrics. If the header is
+// missing or isn't in the format we expect each value is "unknown".
+func parseSDKInfo(header string) []string {
+	fields := strings.Fields(header)
+	if len(fields) != 3 {
+		return []string{"unknown", "unknown", "unknown"}
+	}
+
+	language, version, sdkType := fields[0], fields[1], fields[2]
+	return []string{strings.ToLower(sdkType), language, version}
+}
+
+// labelLimiter caps the number of unique label values the prometheus middleware
+// records. Once the limit's been reached any values that haven't been seen before
+// are replaced with "other".
+type labelLimiter struct {
+	*sync.Mutex
+	max  int
+	seen map[string]struct{}
+}
+
+func newLabelLimiter(max int) *labelLimiter {
+	return &labelLimiter{
+		Mutex: &sync.Mutex{},
+		max:   max,
+		seen:  map[string]struct{}{},
+	}
+}
+
+// limit returns the values unchanged if they've been seen before or there's
+// still room for them, otherwise it returns the same number of "other" values
+func (l *labelLimiter) limit(values ...string) []string {
+	key := strings.Join(values, "\x00")
+
+	l.Lock()
+	defer l.Unlock()
+
+	if _, ok := l.seen[key]; ok {
+		return values
+	}
+
+	if len(l.seen) < l.max {
+		l.seen[key] = struct{}{}
+		return values
+	}
+
+	other := make([]string, len(values))
+	for i := range other {
+		other[i] = "other"
+	}
+	return other
 }
 
 func ValidateEnvironment(bypassAuth bool) echo.MiddlewareFunc {
@@ -274,13 +451,18 @@ func skipValidateEnv(c echo.Context, bypassAuth bool) bool {
 	urlPath := c.Request().URL.Path
 
 	switch urlPath {
-	case domain.AuthRoute:
+	case domain.AuthRoute, domain.BootstrapRoute:
 		return true
 	case domain.StreamRoute:
 		return true
-	case domain.HealthRoute:
+	case domain.HealthRoute, domain.LivenessRoute, domain.ReadinessRoute, domain.StartupRoute:
 		return true
 	default:
+		// Admin routes are authenticated by the admin token rather than an SDK token
+		if isAdminRoute(urlPath) {
+			return true
+		}
+
 		// Skip for prometheus requests
 		if urlPath == metricsPath && c.Request().Method == http.MethodGet {
 			return true
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/harness/ff-proxy/v2/domain"
 	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -166,6 +168,12 @@ func TestSkipper(t *testing.T) {
 			urlPath:  domain.AuthRoute,
 			expected: true,
 		},
+		{
+			name:     "Bootstrap route",
+			method:   http.MethodPost,
+			urlPath:  domain.BootstrapRoute,
+			expected: true,
+		},
 		{
 			name:     "Stream route",
 			method:   http.MethodGet,
@@ -202,6 +210,18 @@ func TestSkipper(t *testing.T) {
 			urlPath:  "/health",
 			expected: true,
 		},
+		{
+			name:     "Admin route",
+			method:   http.MethodGet,
+			urlPath:  "/admin/environments/123/targets",
+			expected: true,
+		},
+		{
+			name:     "Route that starts with admin",
+			method:   http.MethodGet,
+			urlPath:  "/administrator",
+			expected: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -216,3 +236,138 @@ func TestSkipper(t *testing.T) {
 		})
 	}
 }
+
+func TestNewPrometheusMiddleware(t *testing.T) {
+	const goSDK = "Go 0.1.23 Server"
+
+	type request struct {
+		path    string
+		sdkInfo string
+	}
+
+	testCases := map[string]struct {
+		opts     []PrometheusOption
+		requests []request
+		expected map[string]float64
+	}{
+		"Given I don't enable any extra labels": {
+			requests: []request{
+				{path: "/client/env/123/feature-configs", sdkInfo: goSDK},
+				{path: "/stream", sdkInfo: goSDK},
+			},
+			expected: map[string]float64{
+				"code=200,envID=123,url=/client/env/:environment_uuid/feature-configs": 1,
+				"code=200,envID=,url=/stream":                                          1,
+			},
+		},
+		"Given I enable environment labels": {
+			opts: []PrometheusOption{WithEnvironmentLabels(10)},
+			requests: []request{
+				{path: "/client/env/123/feature-configs"},
+				{path: "/stream"},
+			},
+			expected: map[string]float64{
+				"code=200,envID=123,url=/client/env/:environment_uuid/feature-configs": 1,
+				"code=200,envID=456,url=/stream":                                       1,
+			},
+		},
+		"Given I enable environment labels and there are more environments than the max": {
+			opts: []PrometheusOption{WithEnvironmentLabels(1)},
+			requests: []request{
+				{path: "/client/env/123/feature-configs"},
+				{path: "/client/env/789/feature-configs"},
+				{path: "/client/env/123/feature-configs"},
+			},
+			expected: map[string]float64{
+				"code=200,envID=123,url=/client/env/:environment_uuid/feature-configs":   2,
+				"code=200,envID=other,url=/client/env/:environment_uuid/feature-configs": 1,
+			},
+		},
+		"Given I enable environment labels and a request with an environment in its path fails auth": {
+			opts: []PrometheusOption{WithEnvironmentLabels(1)},
+			requests: []request{
+				{path: "/client/env/junk/target-segments"},
+				{path: "/client/env/123/feature-configs"},
+			},
+			expected: map[string]float64{
+				"code=401,envID=,url=/client/env/:environment_uuid/target-segments":    1,
+				"code=200,envID=123,url=/client/env/:environment_uuid/feature-configs": 1,
+			},
+		},
+		"Given I enable SDK labels": {
+			opts: []PrometheusOption{WithSDKLabels(1)},
+			requests: []request{
+				{path: "/stream", sdkInfo: goSDK},
+				{path: "/stream", sdkInfo: "Javascript 1.26.1 Client"},
+			},
+			expected: map[string]float64{
+				"code=200,envID=,sdkLanguage=Go,sdkType=server,sdkVersion=0.1.23,url=/stream":  1,
+				"code=200,envID=,sdkLanguage=other,sdkType=other,sdkVersion=other,url=/stream": 1,
+			},
+		},
+		"Given I enable SDK labels and a request doesn't have the SDK info header": {
+			opts: []PrometheusOption{WithSDKLabels(10)},
+			requests: []request{
+				{path: "/stream"},
+			},
+			expected: map[string]float64{
+				"code=200,envID=,sdkLanguage=unknown,sdkType=unknown,sdkVersion=unknown,url=/stream": 1,
+			},
+		},
+	}
+
+	for desc, tc := range testCases {
+		tc := tc
+		t.Run(desc, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+
+			e := echo.New()
+			e.Use(NewPrometheusMiddleware(reg, tc.opts...))
+			e.GET("/client/env/:environment_uuid/feature-configs", func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			})
+			e.GET("/client/env/:environment_uuid/target-segments", func(c echo.Context) error {
+				return c.NoContent(http.StatusUnauthorized)
+			})
+			e.GET("/stream", func(c echo.Context) error {
+				// Mimic the auth middleware setting the token claims
+				c.Set(tokenClaims.String(), &domain.Claims{Environment: "456"})
+				return c.NoContent(http.StatusOK)
+			})
+
+			for _, r := range tc.requests {
+				req := httptest.NewRequest(http.MethodGet, r.path, nil)
+				if r.sdkInfo != "" {
+					req.Header.Set(harnessSDKInfoHeader, r.sdkInfo)
+				}
+				e.ServeHTTP(httptest.NewRecorder(), req)
+			}
+
+			t.Log("Then the request count is recorded with the expected labels")
+			assert.Equal(t, tc.expected, requestCounts(t, reg))
+		})
+	}
+}
+
+// requestCounts returns the value of each ff_proxy_http_requests_total series
+// keyed by its labels
+func requestCounts(t *testing.T, reg *prometheus.Registry) map[string]float64 {
+	families, err := reg.Gather()
+	assert.Nil(t, err)
+
+	counts := map[string]float64{}
+	for _, family := range families {
+		if family.GetName() != "ff_proxy_http_requests_total" {
+			continue
+		}
+
+		for _, m := range family.GetMetric() {
+			labels := make([]string, 0, len(m.GetLabel()))
+			for _, l := range m.GetLabel() {
+				labels = append(labels, fmt.Sprintf("%s=%s", l.GetName(), l.GetValue()))
+			}
+			counts[strings.Join(labels, ",")] = m.GetCounter().GetValue()
+		}
+	}
+	return counts
+}
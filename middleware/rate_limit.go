@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/harness/ff-proxy/v2/domain"
+	"github.com/harness/ff-proxy/v2/log"
+	"github.com/harness/ff-proxy/v2/ratelimit"
+)
+
+// RouteGroup is a group of routes that share a rate limit budget
+type RouteGroup string
+
+const (
+	// RouteGroupAuth is the /client/auth and /client/bootstrap routes
+	RouteGroupAuth RouteGroup = "auth"
+	// RouteGroupConfig is the feature-configs and target-segments routes
+	RouteGroupConfig RouteGroup = "config"
+	// RouteGroupEvaluation is the evaluations routes
+	RouteGroupEvaluation RouteGroup = "evaluation"
+	// RouteGroupStream is the /stream route
+	RouteGroupStream RouteGroup = "stream"
+	// RouteGroupMetrics is the POST /metrics route
+	RouteGroupMetrics RouteGroup = "metrics"
+)
+
+// routeGroups maps the routes SDKs use to the group whose budget they use
+var routeGroups = map[string]RouteGroup{
+	domain.AuthRoute:                     RouteGroupAuth,
+	domain.BootstrapRoute:                RouteGroupAuth,
+	domain.FeatureConfigsRoute:           RouteGroupConfig,
+	domain.FeatureConfigsIdentifierRoute: RouteGroupConfig,
+	domain.SegmentsRoute:                 RouteGroupConfig,
+	domain.SegmentsIdentifierRoute:       RouteGroupConfig,
+	domain.EvaluationsRoute:              RouteGroupEvaluation,
+	domain.EvaluationsFlagRoute:          RouteGroupEvaluation,
+	domain.StreamRoute:                   RouteGroupStream,
+	domain.MetricsRoute:                  RouteGroupMetrics,
+}
+
+// rateLimiter takes a token from each of the buckets if they all have one available
+type rateLimiter interface {
+	Allow(ctx context.Context, buckets ...ratelimit.Bucket) (bool, time.Duration, error)
+}
+
+// NewRateLimitMiddleware returns an echo middleware that applies token bucket
+// rate limits to SDK requests. Each route group has its own budgets for the
+// hashed API key, the environment and the client IP that made the request.
+// Requests that exceed any of them get a 429 with a Retry-After header. If the
+// limiter errors we let the request through rather than failing SDK requests
+// because of a problem with redis.
+//
+// The middleware needs to run after the auth middleware so that the token claims
+// are available for the API key and environment.
+func NewRateLimitMiddleware(l log.Logger, limiter rateLimiter, limits map[RouteGroup]ratelimit.Limits, reg prometheus.Registerer) echo.MiddlewareFunc {
+	l = l.With("component", "RateLimitMiddleware")
+
+	limited := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ff_proxy_http_requests_rate_limited_total",
+		Help: "Records the number of requests that were rejected for exceeding their rate limit",
+	},
+		[]string{"group"},
+	)
+	reg.MustRegister(limited)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			group, ok := routeGroups[c.Path()]
+			if !ok {
+				return next(c)
+			}
+
+			groupLimits := limits[group]
+			if !groupLimits.Enabled() {
+				return next(c)
+			}
+
+			allowed, retryAfter, err := limiter.Allow(c.Request().Context(), rateLimitBuckets(c, group, groupLimits)...)
+			if err != nil {
+				l.Error("failed to check rate limit, allowing request", "group", group, "err", err)
+				return next(c)
+			}
+
+			if !allowed {
+				limited.WithLabelValues(string(group)).Inc()
+
+				// Retry-After is in whole seconds so round up to make sure the
+				// client doesn't retry before there's a token available
+				seconds := int(math.Ceil(retryAfter.Seconds()))
+				if seconds < 1 {
+					seconds = 1
+				}
+				c.Response().Header().Set("Retry-After", strconv.Itoa(seconds))
+				return echo.NewHTTPError(http.StatusTooManyRequests, "rate limit exceeded")
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// rateLimitBuckets returns a bucket for each of the things we limit a request
+// by. The auth route doesn't have any token claims so it's only limited by IP.
+func rateLimitBuckets(c echo.Context, group RouteGroup, limits ratelimit.Limits) []ratelimit.Bucket {
+	buckets := []ratelimit.Bucket{
+		{Key: bucketKey(group, "ip", c.RealIP()), Limit: limits.IP},
+	}
+
+	envID := c.Param("environment_uuid")
+
+	claims, ok := c.Get(tokenClaims.String()).(*domain.Claims)
+	if ok {
+		// The APIKey in the claims is already hashed so we never store raw keys in redis
+		if claims.APIKey != "" {
+			buckets = append(buckets, ratelimit.Bucket{Key: bucketKey(group, "key", claims.APIKey), Limit: limits.APIKey})
+		}
+		if claims.Environment != "" {
+			envID = claims.Environment
+		}
+	}
+
+	if envID != "" {
+		buckets = append(buckets, ratelimit.Bucket{Key: bucketKey(group, "env", envID), Limit: limits.Environment})
+	}
+
+	return buckets
+}
+
+// bucketKey returns the key for one of a route group's buckets. The id is used
+// as the hash tag so that buckets are spread across the slots of a Redis
+// Cluster rather than every bucket for a group landing on the same one.
+func bucketKey(group RouteGroup, kind, id string) string {
+	return fmt.Sprintf("%s:%s:{%s}", group, kind, id)
+}
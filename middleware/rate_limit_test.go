@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/harness/ff-proxy/v2/domain"
+	"github.com/harness/ff-proxy/v2/log"
+	"github.com/harness/ff-proxy/v2/ratelimit"
+)
+
+type mockRateLimiter struct {
+	allowed    bool
+	retryAfter time.Duration
+	err        error
+
+	buckets []ratelimit.Bucket
+}
+
+func (m *mockRateLimiter) Allow(_ context.Context, buckets ...ratelimit.Bucket) (bool, time.Duration, error) {
+	m.buckets = buckets
+	return m.allowed, m.retryAfter, m.err
+}
+
+func TestNewRateLimitMiddleware(t *testing.T) {
+	evalLimits := ratelimit.Limits{
+		APIKey:      ratelimit.Limit{Rate: 1, Burst: 1},
+		Environment: ratelimit.Limit{Rate: 10, Burst: 10},
+		IP:          ratelimit.Limit{Rate: 2, Burst: 2},
+	}
+
+	limits := map[RouteGroup]ratelimit.Limits{
+		RouteGroupEvaluation: evalLimits,
+	}
+
+	claims := &domain.Claims{APIKey: "auth-key-123", Environment: "env-1"}
+
+	testCases := map[string]struct {
+		limiter            *mockRateLimiter
+		route              string
+		path               string
+		claims             *domain.Claims
+		expectedStatusCode int
+		expectedRetryAfter string
+		expectedBuckets    []ratelimit.Bucket
+	}{
+		"Given I make a request to a route group with no limits": {
+			limiter:            &mockRateLimiter{allowed: false},
+			route:              domain.FeatureConfigsRoute,
+			path:               "/client/env/env-1/feature-configs",
+			claims:             claims,
+			expectedStatusCode: http.StatusOK,
+		},
+		"Given I make a request that's within the limits": {
+			limiter:            &mockRateLimiter{allowed: true},
+			route:              domain.EvaluationsRoute,
+			path:               "/client/env/env-1/target/foo/evaluations",
+			claims:             claims,
+			expectedStatusCode: http.StatusOK,
+			expectedBuckets: []ratelimit.Bucket{
+				{Key: "evaluation:ip:{192.0.2.1}", Limit: evalLimits.IP},
+				{Key: "evaluation:key:{auth-key-123}", Limit: evalLimits.APIKey},
+				{Key: "evaluation:env:{env-1}", Limit: evalLimits.Environment},
+			},
+		},
+		"Given I make a request that exceeds the limits": {
+			limiter:            &mockRateLimiter{allowed: false, retryAfter: 1500 * time.Millisecond},
+			route:              domain.EvaluationsRoute,
+			path:               "/client/env/env-1/target/foo/evaluations",
+			claims:             claims,
+			expectedStatusCode: http.StatusTooManyRequests,
+			expectedRetryAfter: "2",
+			expectedBuckets: []ratelimit.Bucket{
+				{Key: "evaluation:ip:{192.0.2.1}", Limit: evalLimits.IP},
+				{Key: "evaluation:key:{auth-key-123}", Limit: evalLimits.APIKey},
+				{Key: "evaluation:env:{env-1}", Limit: evalLimits.Environment},
+			},
+		},
+		"Given I make a request without token claims that exceeds the limits": {
+			limiter:            &mockRateLimiter{allowed: false, retryAfter: 10 * time.Millisecond},
+			route:              domain.EvaluationsRoute,
+			path:               "/client/env/env-1/target/foo/evaluations",
+			expectedStatusCode: http.StatusTooManyRequests,
+			expectedRetryAfter: "1",
+			expectedBuckets: []ratelimit.Bucket{
+				{Key: "evaluation:ip:{192.0.2.1}", Limit: evalLimits.IP},
+				{Key: "evaluation:env:{env-1}", Limit: evalLimits.Environment},
+			},
+		},
+		"Given the rate limiter errors": {
+			limiter:            &mockRateLimiter{allowed: false, err: errors.New("redis down")},
+			route:              domain.EvaluationsRoute,
+			path:               "/client/env/env-1/target/foo/evaluations",
+			claims:             claims,
+			expectedStatusCode: http.StatusOK,
+			expectedBuckets: []ratelimit.Bucket{
+				{Key: "evaluation:ip:{192.0.2.1}", Limit: evalLimits.IP},
+				{Key: "evaluation:key:{auth-key-123}", Limit: evalLimits.APIKey},
+				{Key: "evaluation:env:{env-1}", Limit: evalLimits.Environment},
+			},
+		},
+	}
+
+	for desc, tc := range testCases {
+		desc := desc
+		tc := tc
+
+		t.Run(desc, func(t *testing.T) {
+			e := echo.New()
+
+			mw := NewRateLimitMiddleware(log.NoOpLogger{}, tc.limiter, limits, prometheus.NewRegistry())
+			e.GET(tc.route, func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			}, func(next echo.HandlerFunc) echo.HandlerFunc {
+				return func(c echo.Context) error {
+					if tc.claims != nil {
+						c.Set(tokenClaims.String(), tc.claims)
+					}
+					return next(c)
+				}
+			}, mw)
+
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rec.Code, desc)
+			assert.Equal(t, tc.expectedRetryAfter, rec.Header().Get("Retry-After"))
+			assert.Equal(t, tc.expectedBuckets, tc.limiter.buckets)
+		})
+	}
+}
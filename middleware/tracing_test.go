@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNewTracingMiddleware(t *testing.T) {
+	prevProvider := otel.GetTracerProvider()
+	prevPropagator := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	}()
+
+	const (
+		traceID      = "4bf92f3577b34da6a3ce929d0e0e4736"
+		parentSpanID = "00f067aa0ba902b7"
+	)
+
+	testCases := map[string]struct {
+		path           string
+		traceparent    string
+		handlerErr     error
+		shouldTrace    bool
+		expectedStatus codes.Code
+		expectedParent string
+	}{
+		"Given I make a request without a traceparent header": {
+			path:           "/client/env/123/feature-configs",
+			shouldTrace:    true,
+			expectedStatus: codes.Unset,
+		},
+		"Given I make a request with a traceparent header": {
+			path:           "/client/env/123/feature-configs",
+			traceparent:    "00-" + traceID + "-" + parentSpanID + "-01",
+			shouldTrace:    true,
+			expectedStatus: codes.Unset,
+			expectedParent: parentSpanID,
+		},
+		"Given I make a request that errors": {
+			path:           "/client/env/123/feature-configs",
+			handlerErr:     echo.NewHTTPError(http.StatusInternalServerError),
+			shouldTrace:    true,
+			expectedStatus: codes.Error,
+		},
+		"Given I make a request to the health endpoint": {
+			path:        "/health",
+			shouldTrace: false,
+		},
+	}
+
+	for desc, tc := range testCases {
+		tc := tc
+		t.Run(desc, func(t *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.traceparent != "" {
+				req.Header.Set("traceparent", tc.traceparent)
+			}
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetPath("/client/env/:environment_uuid/feature-configs")
+			c.SetParamNames("environment_uuid")
+			c.SetParamValues("123")
+
+			var handlerSpan trace.SpanContext
+			h := NewTracingMiddleware()(func(c echo.Context) error {
+				handlerSpan = trace.SpanContextFromContext(c.Request().Context())
+				if tc.handlerErr != nil {
+					return tc.handlerErr
+				}
+				return c.NoContent(http.StatusOK)
+			})
+			_ = h(c)
+
+			spans := recorder.Ended()
+			if !tc.shouldTrace {
+				t.Log("Then no span is created")
+				assert.Len(t, spans, 0)
+				return
+			}
+
+			t.Log("Then a server span is created for the route")
+			assert.Len(t, spans, 1)
+			span := spans[0]
+			assert.Equal(t, "GET /client/env/:environment_uuid/feature-configs", span.Name())
+			assert.Equal(t, trace.SpanKindServer, span.SpanKind())
+			assert.Equal(t, tc.expectedStatus, span.Status().Code)
+
+			t.Log("And the span is in the context the handler receives")
+			assert.Equal(t, span.SpanContext().SpanID(), handlerSpan.SpanID())
+
+			if tc.expectedParent != "" {
+				t.Log("And the span is part of the caller's trace")
+				assert.Equal(t, traceID, span.SpanContext().TraceID().String())
+				assert.Equal(t, tc.expectedParent, span.Parent().SpanID().String())
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package proxyservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/harness/ff-golang-server-sdk/evaluation"
+	"github.com/harness/ff-golang-server-sdk/logger"
+	"github.com/harness/ff-golang-server-sdk/rest"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/harness/ff-proxy/v2/domain"
+)
+
+type evaluatorFlagRepo interface {
+	Get(ctx context.Context, envID string) ([]domain.FeatureFlag, error)
+	Snapshot(ctx context.Context, envID string) context.Context
+}
+
+type evaluatorSegmentRepo interface {
+	Get(ctx context.Context, envID string) ([]domain.Segment, error)
+}
+
+// environmentEvaluator is a precompiled snapshot of an environment's flags and
+// segments that have already been converted to the types the SDK evaluator
+// uses. Nothing modifies it once it's built so it's shared between requests.
+type environmentEvaluator struct {
+	flags     map[string]*rest.FeatureConfig
+	flagList  []rest.FeatureConfig
+	segments  map[string]rest.Segment
+	evaluator *evaluation.Evaluator
+}
+
+func newEnvironmentEvaluator(flags []domain.FeatureFlag, segments []domain.Segment, andRulesEnabled bool) *environmentEvaluator {
+	e := &environmentEvaluator{
+		flags:    make(map[string]*rest.FeatureConfig, len(flags)),
+		flagList: make([]rest.FeatureConfig, 0, len(flags)),
+		segments: make(map[string]rest.Segment, len(segments)),
+	}
+
+	for _, f := range flags {
+		e.flagList = append(e.flagList, f.ToSDKFeatureConfig())
+	}
+	for i := range e.flagList {
+		e.flags[e.flagList[i].Feature] = &e.flagList[i]
+	}
+
+	for _, seg := range segments {
+		sdkSegment := seg.ToSDKSegment()
+		if !andRulesEnabled {
+			sdkSegment.ServingRules = nil
+		}
+		e.segments[seg.Identifier] = sdkSegment
+	}
+
+	e.evaluator, _ = evaluation.NewEvaluator(e, nil, logger.NewNoOpLogger())
+	return e
+}
+
+// GetFlag makes environmentEvaluator implement the evaluation.Query interface
+func (e *environmentEvaluator) GetFlag(identifier string) (rest.FeatureConfig, error) {
+	f, ok := e.flags[identifier]
+	if !ok {
+		return rest.FeatureConfig{}, ErrNotFound
+	}
+	return *f, nil
+}
+
+// GetSegment makes environmentEvaluator implement the evaluation.Query interface.
+// Segments that don't exist are treated as empty so targets aren't included in them
+func (e *environmentEvaluator) GetSegment(identifier string) (rest.Segment, error) {
+	return e.segments[identifier], nil
+}
+
+// GetFlags makes environmentEvaluator implement the evaluation.Query interface
+func (e *environmentEvaluator) GetFlags() ([]rest.FeatureConfig, error) {
+	return e.flagList, nil
+}
+
+// GetFlagMap makes environmentEvaluator implement the evaluation.Query interface
+func (e *environmentEvaluator) GetFlagMap() (map[string]*rest.FeatureConfig, error) {
+	return e.flags, nil
+}
+
+// Evaluators keeps an evaluator for each environment so that evaluations don't
+// have to read flags and segments from the cache or convert them on every
+// request. An environment's evaluator is built the first time it's needed and
+// rebuilt the next time it's needed after a change to its config invalidates it.
+type Evaluators struct {
+	flagRepo        evaluatorFlagRepo
+	segmentRepo     evaluatorSegmentRepo
+	andRulesEnabled bool
+	snapshots       bool
+
+	mx    *sync.RWMutex
+	envs  map[string]*environmentEvaluator
+	group *singleflight.Group
+
+	// epoch is incremented on every invalidation so that evaluators built
+	// while an environment was being invalidated aren't kept
+	epoch uint64
+}
+
+// WithoutEvaluatorSnapshots makes the Evaluators build a new evaluator for
+// every request. It's used when nothing invalidates the Evaluators when config
+// changes.
+func WithoutEvaluatorSnapshots() func(e *Evaluators) {
+	return func(e *Evaluators) {
+		e.snapshots = false
+	}
+}
+
+// NewEvaluators creates Evaluators
+func NewEvaluators(flagRepo evaluatorFlagRepo, segmentRepo evaluatorSegmentRepo, andRulesEnabled bool, opts ...func(e *Evaluators)) *Evaluators {
+	e := &Evaluators{
+		flagRepo:        flagRepo,
+		segmentRepo:     segmentRepo,
+		andRulesEnabled: andRulesEnabled,
+		snapshots:       true,
+		mx:              &sync.RWMutex{},
+		envs:            map[string]*environmentEvaluator{},
+		group:           &singleflight.Group{},
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// get returns the evaluator for an environment, building it if it doesn't exist
+func (e *Evaluators) get(ctx context.Context, envID string) (*environmentEvaluator, error) {
+	if !e.snapshots {
+		return e.build(ctx, envID)
+	}
+
+	e.mx.RLock()
+	ev, ok := e.envs[envID]
+	epoch := e.epoch
+	e.mx.RUnlock()
+	if ok {
+		return ev, nil
+	}
+
+	v, err, _ := e.group.Do(envID, func() (interface{}, error) {
+		ev, err := e.build(ctx, envID)
+		if err != nil {
+			return nil, err
+		}
+
+		e.mx.Lock()
+		if e.epoch == epoch {
+			e.envs[envID] = ev
+		}
+		e.mx.Unlock()
+		return ev, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*environmentEvaluator), nil
+}
+
+func (e *Evaluators) build(ctx context.Context, envID string) (*environmentEvaluator, error) {
+	// Read the flags and segments from a single generation so we never build
+	// an evaluator with new flags and old segments
+	ctx = e.flagRepo.Snapshot(ctx, envID)
+
+	flags, err := e.flagRepo.Get(ctx, envID)
+	if err != nil && !errors.Is(err, domain.ErrCacheNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrInternal, err)
+	}
+
+	segments, err := e.segmentRepo.Get(ctx, envID)
+	if err != nil && !errors.Is(err, domain.ErrCacheNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrInternal, err)
+	}
+
+	return newEnvironmentEvaluator(flags, segments, e.andRulesEnabled), nil
+}
+
+// Invalidate drops the evaluator for an environment so it's rebuilt from the
+// cache the next time it's needed
+func (e *Evaluators) Invalidate(envID string) {
+	e.mx.Lock()
+	defer e.mx.Unlock()
+
+	e.epoch++
+	delete(e.envs, envID)
+}
+
+// Purge drops the evaluators for every environment
+func (e *Evaluators) Purge() {
+	e.mx.Lock()
+	defer e.mx.Unlock()
+
+	e.epoch++
+	e.envs = map[string]*environmentEvaluator{}
+}
+
+// HandleMessage makes Evaluators implement the MessageHandler interface. It
+// should be called after the change the message is for has been applied to
+// the cache.
+func (e *Evaluators) HandleMessage(_ context.Context, msg domain.SSEMessage) error {
+	if msg.Environment == "" && len(msg.Environments) == 0 {
+		// Proxy events that aren't for specific environments e.g. the proxy
+		// key being deleted could affect all of them
+		if msg.Domain == domain.MsgDomainProxy {
+			e.Purge()
+		}
+		return nil
+	}
+
+	if msg.Environment != "" {
+		e.Invalidate(msg.Environment)
+	}
+	for _, env := range msg.Environments {
+		e.Invalidate(env)
+	}
+	return nil
+}
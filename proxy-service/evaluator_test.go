@@ -0,0 +1,256 @@
+package proxyservice
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/harness/ff-golang-server-sdk/rest"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/harness/ff-proxy/v2/cache"
+	"github.com/harness/ff-proxy/v2/domain"
+	clientgen "github.com/harness/ff-proxy/v2/gen/client"
+	"github.com/harness/ff-proxy/v2/log"
+	"github.com/harness/ff-proxy/v2/repository"
+)
+
+type mockFlagRepo struct {
+	gets  int
+	getFn func() ([]domain.FeatureFlag, error)
+}
+
+func (m *mockFlagRepo) Get(_ context.Context, _ string) ([]domain.FeatureFlag, error) {
+	m.gets++
+	return m.getFn()
+}
+
+func (m *mockFlagRepo) Snapshot(ctx context.Context, _ string) context.Context {
+	return ctx
+}
+
+func TestEvaluators_Segments(t *testing.T) {
+	fooSegment := domain.Segment{Identifier: "foo", Name: "foo"}
+	fooSegment2 := domain.Segment{Identifier: "foo", Name: "foo2"}
+	barSegment := domain.Segment{Identifier: "bar", Name: "bar"}
+
+	testCases := map[string]struct {
+		segmentRepo mockSegmentRepo
+		shouldErr   bool
+		expected    map[string]rest.Segment
+	}{
+		"Given I have a segment repo that errors": {
+			segmentRepo: mockSegmentRepo{
+				getFn: func() ([]domain.Segment, error) {
+					return []domain.Segment{}, errors.New("foo")
+				},
+			},
+			shouldErr: true,
+		},
+		"Given I have a segment repo that doesn't have the environment": {
+			segmentRepo: mockSegmentRepo{
+				getFn: func() ([]domain.Segment, error) {
+					return nil, domain.ErrCacheNotFound
+				},
+			},
+			expected: map[string]rest.Segment{},
+		},
+		"Given I have a segment repo that returns one segment": {
+			segmentRepo: mockSegmentRepo{
+				getFn: func() ([]domain.Segment, error) {
+					return []domain.Segment{fooSegment}, nil
+				},
+			},
+			expected: map[string]rest.Segment{
+				"foo": fooSegment.ToSDKSegment(),
+			},
+		},
+		"Given I have a segment repo that returns two segments with the same identifier": {
+			segmentRepo: mockSegmentRepo{
+				getFn: func() ([]domain.Segment, error) {
+					return []domain.Segment{fooSegment, fooSegment2}, nil
+				},
+			},
+			expected: map[string]rest.Segment{
+				"foo": fooSegment2.ToSDKSegment(),
+			},
+		},
+		"Given I have a segment repo that returns two segments with different identifiers": {
+			segmentRepo: mockSegmentRepo{
+				getFn: func() ([]domain.Segment, error) {
+					return []domain.Segment{fooSegment, barSegment}, nil
+				},
+			},
+			expected: map[string]rest.Segment{
+				"foo": fooSegment.ToSDKSegment(),
+				"bar": barSegment.ToSDKSegment(),
+			},
+		},
+	}
+
+	for desc, tc := range testCases {
+		desc := desc
+		tc := tc
+
+		t.Run(desc, func(t *testing.T) {
+			flagRepo := &mockFlagRepo{getFn: func() ([]domain.FeatureFlag, error) { return nil, nil }}
+			e := NewEvaluators(flagRepo, tc.segmentRepo, true)
+
+			actual, err := e.get(context.Background(), "123")
+			if (err != nil) != tc.shouldErr {
+				t.Errorf("(%s): error = %v, shouldErr %v", desc, err, tc.shouldErr)
+			}
+
+			if tc.shouldErr {
+				assert.Empty(t, e.envs)
+				return
+			}
+			assert.Equal(t, tc.expected, actual.segments)
+		})
+	}
+}
+
+func TestEvaluators_Invalidate(t *testing.T) {
+	ctx := context.Background()
+	flags := []domain.FeatureFlag{{Feature: "foo"}}
+	flagRepo := &mockFlagRepo{getFn: func() ([]domain.FeatureFlag, error) { return flags, nil }}
+	segmentRepo := mockSegmentRepo{getFn: func() ([]domain.Segment, error) { return nil, nil }}
+
+	e := NewEvaluators(flagRepo, segmentRepo, false)
+
+	t.Log("Given I get the evaluator for an environment twice")
+	_, err := e.get(ctx, "123")
+	assert.Nil(t, err)
+	ev, err := e.get(ctx, "123")
+	assert.Nil(t, err)
+
+	t.Log("Then it's only built once")
+	assert.Equal(t, 1, flagRepo.gets)
+	_, err = ev.GetFlag("foo")
+	assert.Nil(t, err)
+
+	t.Log("When an event for a different environment arrives")
+	assert.Nil(t, e.HandleMessage(ctx, domain.SSEMessage{Domain: domain.MsgDomainFeature, Event: domain.EventPatch, Environment: "456"}))
+
+	t.Log("Then the evaluator isn't rebuilt")
+	_, err = e.get(ctx, "123")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, flagRepo.gets)
+
+	t.Log("When the flags change and an event for the environment arrives")
+	flags = []domain.FeatureFlag{{Feature: "bar"}}
+	assert.Nil(t, e.HandleMessage(ctx, domain.SSEMessage{Domain: domain.MsgDomainFeature, Event: domain.EventPatch, Environment: "123"}))
+
+	t.Log("Then the evaluator is rebuilt with the new flags")
+	ev, err = e.get(ctx, "123")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, flagRepo.gets)
+	_, err = ev.GetFlag("foo")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	_, err = ev.GetFlag("bar")
+	assert.Nil(t, err)
+
+	t.Log("When the proxy key is deleted")
+	assert.Nil(t, e.HandleMessage(ctx, domain.SSEMessage{Domain: domain.MsgDomainProxy, Event: domain.EventProxyKeyDeleted}))
+
+	t.Log("Then every evaluator is rebuilt")
+	_, err = e.get(ctx, "123")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, flagRepo.gets)
+
+	t.Log("And evaluators without snapshots are built for every request")
+	e = NewEvaluators(flagRepo, segmentRepo, false, WithoutEvaluatorSnapshots())
+	_, _ = e.get(ctx, "123")
+	_, _ = e.get(ctx, "123")
+	assert.Equal(t, 5, flagRepo.gets)
+}
+
+// setupEvaluationsBenchmark populates the repos with the 300 environment test
+// dataset and returns a service that evaluates using the given evaluator options
+func setupEvaluationsBenchmark(b *testing.B, opts ...func(e *Evaluators)) (Service, []string) {
+	raw, err := os.ReadFile("../config/remote/test-data/testFile_300_envs_30_flags.json")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	proxyConfigs := []domain.ProxyConfig{}
+	if err := json.Unmarshal(raw, &proxyConfigs); err != nil {
+		b.Fatalf("failed to unmarshal proxy config: %s", err)
+	}
+
+	ctx := context.Background()
+	c := cache.NewMemCache()
+	flagRepo := repository.NewFeatureFlagRepo(c)
+	segmentRepo := repository.NewSegmentRepo(c)
+
+	envIDs := []string{}
+	for _, pc := range proxyConfigs {
+		for _, env := range pc.Environments {
+			envID := env.ID.String()
+			envIDs = append(envIDs, envID)
+
+			if err := flagRepo.Add(ctx, domain.FlagConfig{EnvironmentID: envID, FeatureConfigs: env.FeatureConfigs}); err != nil {
+				b.Fatal(err)
+			}
+			if err := segmentRepo.Add(ctx, domain.SegmentConfig{EnvironmentID: envID, Segments: env.Segments}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	s := NewService(Config{
+		Logger:      log.NewNoOpContextualLogger(),
+		FeatureRepo: flagRepo,
+		SegmentRepo: segmentRepo,
+		Evaluators:  NewEvaluators(flagRepo, segmentRepo, false, opts...),
+	})
+	return s, envIDs
+}
+
+func benchmarkEvaluations(b *testing.B, opts ...func(e *Evaluators)) {
+	s, envIDs := setupEvaluationsBenchmark(b, opts...)
+	ctx := context.Background()
+	target := &domain.Target{Target: clientgen.Target{Identifier: "bench-target"}}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req := domain.EvaluationsRequest{EnvironmentID: envIDs[i%len(envIDs)], Target: target}
+		if _, err := s.Evaluations(ctx, req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkEvaluationsByFeature(b *testing.B, opts ...func(e *Evaluators)) {
+	s, envIDs := setupEvaluationsBenchmark(b, opts...)
+	ctx := context.Background()
+	target := &domain.Target{Target: clientgen.Target{Identifier: "bench-target"}}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req := domain.EvaluationsByFeatureRequest{EnvironmentID: envIDs[i%len(envIDs)], FeatureIdentifier: "flag0", Target: target}
+		if _, err := s.EvaluationsByFeature(ctx, req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEvaluations300Env30Flags_PerRequest(b *testing.B) {
+	benchmarkEvaluations(b, WithoutEvaluatorSnapshots())
+}
+
+func BenchmarkEvaluations300Env30Flags_Snapshot(b *testing.B) {
+	benchmarkEvaluations(b)
+}
+
+func BenchmarkEvaluationsByFeature300Env30Flags_PerRequest(b *testing.B) {
+	benchmarkEvaluationsByFeature(b, WithoutEvaluatorSnapshots())
+}
+
+func BenchmarkEvaluationsByFeature300Env30Flags_Snapshot(b *testing.B) {
+	benchmarkEvaluationsByFeature(b)
+}
@@ -5,18 +5,21 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/harness/ff-golang-server-sdk/evaluation"
-	"github.com/harness/ff-golang-server-sdk/logger"
 	"github.com/harness/ff-golang-server-sdk/rest"
 	jsoniter "github.com/json-iterator/go"
+	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/harness/ff-proxy/v2/domain"
 	clientgen "github.com/harness/ff-proxy/v2/gen/client"
 	"github.com/harness/ff-proxy/v2/hash"
 	"github.com/harness/ff-proxy/v2/log"
 	"github.com/harness/ff-proxy/v2/repository"
+	"github.com/harness/ff-proxy/v2/tracing"
 )
 
 // ProxyService is the interface for the ProxyService
@@ -24,6 +27,9 @@ type ProxyService interface {
 	// Authenticate performs authentication
 	Authenticate(ctx context.Context, req domain.AuthRequest) (domain.AuthResponse, error)
 
+	// Bootstrap authenticates and returns everything a client SDK needs to start up in one response
+	Bootstrap(ctx context.Context, req domain.BootstrapRequest) (domain.BootstrapResponse, error)
+
 	// FeatureConfig gets all FeatureConfig for an environment
 	FeatureConfig(ctx context.Context, req domain.FeatureConfigRequest) ([]domain.FeatureConfig, error)
 
@@ -50,6 +56,30 @@ type ProxyService interface {
 
 	// Health checks the health of the system
 	Health(ctx context.Context) (domain.HealthResponse, error)
+
+	// Liveness checks that the Proxy process is up
+	Liveness(ctx context.Context) (domain.ProbeResponse, error)
+
+	// Readiness checks that the Proxy is able to serve SDK requests
+	Readiness(ctx context.Context) (domain.ProbeResponse, error)
+
+	// Startup checks that the Proxy has finished starting up
+	Startup(ctx context.Context) (domain.ProbeResponse, error)
+
+	// ExportTargets gets every Target the Proxy has seen in an environment
+	ExportTargets(ctx context.Context, req domain.ExportTargetsRequest) (domain.ExportTargetsResponse, error)
+
+	// AuditHistory gets the most recent config changes that the Proxy has applied
+	AuditHistory(ctx context.Context, req domain.AuditHistoryRequest) (domain.AuditHistoryResponse, error)
+
+	// ConfigGeneration gets the generation of an environment's config that's being served
+	ConfigGeneration(ctx context.Context, req domain.GenerationRequest) (domain.ConfigGeneration, error)
+
+	// PinGeneration pins an environment to a previous generation of its config
+	PinGeneration(ctx context.Context, req domain.PinGenerationRequest) (domain.ConfigGeneration, error)
+
+	// ReleaseGeneration releases an environment that's pinned to a previous generation
+	ReleaseGeneration(ctx context.Context, req domain.GenerationRequest) (domain.ConfigGeneration, error)
 }
 
 var (
@@ -67,11 +97,23 @@ var (
 	// ErrUnauthorised is the error that the proxy service returns when the
 	ErrUnauthorised = errors.New("unauthorised")
 
+	// ErrForbidden is the error that the proxy service returns when a request
+	// is authenticated but isn't allowed for the environment
+	ErrForbidden = errors.New("forbidden")
+
 	// ErrStreamDisconnected is the error that the proxy service returns when
 	// its internal sdk has disconnected from the SaaS stream
 	ErrStreamDisconnected = errors.New("SaaS stream disconnected")
+
+	// ErrUnavailable is the error that the proxy service returns when one of
+	// its probes fails
+	ErrUnavailable = errors.New("service unavailable")
 )
 
+// errGenerationsNotImplemented is returned by the generation endpoints when the
+// service doesn't have a GenerationPinner
+var errGenerationsNotImplemented = fmt.Errorf("%w: config generations can only be managed by a Primary Proxy with them enabled", ErrNotImplemented)
+
 // authTokenFn is a function that can generate an auth token
 type authTokenFn func(key string) (domain.Token, error)
 
@@ -83,11 +125,26 @@ type clientService interface {
 	Authenticate(ctx context.Context, apiKey string, target domain.Target) (string, error)
 }
 
+// GenerationPinner is the interface for pinning environments to a previous
+// generation of their config
+type GenerationPinner interface {
+	ConfigGeneration(ctx context.Context, envID string) (domain.ConfigGeneration, error)
+	Pin(ctx context.Context, envID string, generation int64) (domain.ConfigGeneration, error)
+	Release(ctx context.Context, envID string) (domain.ConfigGeneration, error)
+}
+
 // MetricStore is the interface for storing metrics
 type MetricStore interface {
 	StoreMetrics(ctx context.Context, metrics domain.MetricsRequest) error
 }
 
+// EvaluationCounter is the interface for counting the evaluations the Proxy
+// serves so they show up in analytics for clients that don't post metrics
+type EvaluationCounter interface {
+	CountEvaluations(envID string, appID string, target string, evaluations ...clientgen.Evaluation)
+	SDKPostedMetrics(ctx context.Context, appID string)
+}
+
 // SDKClients is an interface that can be used to find out if internal sdks are connected to the SaaS FF stream
 type SDKClients interface {
 	StreamConnected(key string) bool
@@ -113,6 +170,38 @@ type Config struct {
 
 	Health func(ctx context.Context) domain.HealthResponse
 
+	// Readiness and Startup are the functions the service calls to
+	// check if the Proxy is ready for traffic and has finished starting up
+	Readiness func(ctx context.Context) domain.ProbeResponse
+	Startup   func(ctx context.Context) domain.ProbeResponse
+
+	// AuditHistory is the function the service calls to get the most recent
+	// config changes, the audit endpoint isn't implemented if it's nil
+	AuditHistory func(req domain.AuditHistoryRequest) []domain.AuditRecord
+
+	// Generations pins environments to a previous generation of their config,
+	// the generation endpoints aren't implemented if it's nil
+	Generations GenerationPinner
+
+	// ConfigVersion is the function the service calls to get the version of
+	// an environment's config that bootstrap responses are built from
+	ConfigVersion func(ctx context.Context, envID string) string
+
+	// OriginAllowed is the function the service calls to check that a
+	// bootstrap request's origin is allowed for its environment. Bootstrap
+	// requests don't have a token or an environment in their path so the
+	// environment origin middleware can't check them. Any origin is allowed
+	// if it's nil.
+	OriginAllowed func(envID string, origin string) bool
+
+	// Evaluators keeps the evaluators the service uses for each environment.
+	// If it's nil a new evaluator is built for every request.
+	Evaluators *Evaluators
+
+	// EvaluationCounter counts the evaluations the service serves, they
+	// aren't counted if it's nil
+	EvaluationCounter EvaluationCounter
+
 	ForwardTargets  bool
 	AndRulesEnabled bool
 }
@@ -137,7 +226,17 @@ type Service struct {
 	healthySassStream  func() bool
 	sdkStreamConnected func(envID string)
 
-	health func(ctx context.Context) domain.HealthResponse
+	health    func(ctx context.Context) domain.HealthResponse
+	readiness func(ctx context.Context) domain.ProbeResponse
+	startup   func(ctx context.Context) domain.ProbeResponse
+
+	auditHistory func(req domain.AuditHistoryRequest) []domain.AuditRecord
+	generations  GenerationPinner
+	evaluators   *Evaluators
+
+	configVersion     func(ctx context.Context, envID string) string
+	originAllowed     func(envID string, origin string) bool
+	evaluationCounter EvaluationCounter
 
 	forwardTargets  bool
 	andRulesEnabled bool
@@ -146,6 +245,12 @@ type Service struct {
 // NewService creates and returns a ProxyService
 func NewService(c Config) Service {
 	l := c.Logger.With("component", "ProxyService")
+
+	evaluators := c.Evaluators
+	if evaluators == nil {
+		evaluators = NewEvaluators(c.FeatureRepo, c.SegmentRepo, c.AndRulesEnabled, WithoutEvaluatorSnapshots())
+	}
+
 	return Service{
 		logger:             l,
 		featureRepo:        c.FeatureRepo,
@@ -160,6 +265,14 @@ func NewService(c Config) Service {
 		healthySassStream:  c.HealthySaasStream,
 		sdkStreamConnected: c.SDKStreamConnected,
 		health:             c.Health,
+		readiness:          c.Readiness,
+		startup:            c.Startup,
+		auditHistory:       c.AuditHistory,
+		generations:        c.Generations,
+		evaluators:         evaluators,
+		configVersion:      c.ConfigVersion,
+		originAllowed:      c.OriginAllowed,
+		evaluationCounter:  c.EvaluationCounter,
 		forwardTargets:     c.ForwardTargets,
 		andRulesEnabled:    c.AndRulesEnabled,
 	}
@@ -169,19 +282,29 @@ func NewService(c Config) Service {
 func (s Service) Authenticate(ctx context.Context, req domain.AuthRequest) (domain.AuthResponse, error) {
 	s.logger = s.logger.With("method", "Authenticate")
 
+	token, err := s.authenticate(ctx, req)
+	if err != nil {
+		return domain.AuthResponse{}, err
+	}
+	return domain.AuthResponse{AuthToken: token.TokenString()}, nil
+}
+
+// authenticate generates a token for the request's API key and registers its
+// Target if it has one
+func (s Service) authenticate(ctx context.Context, req domain.AuthRequest) (domain.Token, error) {
 	token, err := s.authFn(req.APIKey)
 	if err != nil {
 		if errors.Is(err, domain.ErrCacheNotFound) {
 			s.logger.Info(ctx, "unable to generate auth token because the provided sdk key doesn't exist")
-			return domain.AuthResponse{}, ErrUnauthorised
+			return domain.Token{}, ErrUnauthorised
 		}
 		s.logger.Error(ctx, "failed to generate auth token", "err", err)
-		return domain.AuthResponse{}, ErrUnauthorised
+		return domain.Token{}, ErrUnauthorised
 	}
 
 	// We don't need to bother saving the Target if it's empty
 	if reflect.DeepEqual(req.Target, domain.Target{}) {
-		return domain.AuthResponse{AuthToken: token.TokenString()}, nil
+		return token, nil
 	}
 
 	envID := token.Claims().Environment
@@ -192,7 +315,7 @@ func (s Service) Authenticate(ctx context.Context, req domain.AuthRequest) (doma
 	// if the proxy is running in offline mode we're done, we don't need to bother
 	// forwarding the request to FeatureFlags
 	if s.offline {
-		return domain.AuthResponse{AuthToken: token.TokenString()}, nil
+		return token, nil
 	}
 
 	// If we aren't forwarding targets to Saas we're done
@@ -209,7 +332,60 @@ func (s Service) Authenticate(ctx context.Context, req domain.AuthRequest) (doma
 		}()
 	}
 
-	return domain.AuthResponse{AuthToken: token.TokenString()}, nil
+	return token, nil
+}
+
+// Bootstrap authenticates the request and returns the auth token along with
+// the evaluations for its Target, the channel to stream changes on and the
+// version of the config the evaluations came from. It saves client SDKs from
+// having to make separate auth, evaluation and stream requests on startup.
+func (s Service) Bootstrap(ctx context.Context, req domain.BootstrapRequest) (domain.BootstrapResponse, error) {
+	s.logger = s.logger.With("method", "Bootstrap")
+
+	token, err := s.authenticate(ctx, domain.AuthRequest{APIKey: req.APIKey, Target: req.Target})
+	if err != nil {
+		return domain.BootstrapResponse{}, err
+	}
+	envID := token.Claims().Environment
+
+	if s.originAllowed != nil && !s.originAllowed(envID, req.Origin) {
+		return domain.BootstrapResponse{}, fmt.Errorf("%w: origin %s is not allowed for environment %s", ErrForbidden, req.Origin, envID)
+	}
+
+	// Get the version before evaluating so that if the config changes in the
+	// meantime the client sees an old version and refetches rather than
+	// holding onto evaluations from the old config with the new version
+	var version string
+	if s.configVersion != nil {
+		version = s.configVersion(ctx, envID)
+	}
+
+	target := req.Target
+	evaluations, err := s.Evaluations(ctx, domain.EvaluationsRequest{
+		EnvironmentID:    envID,
+		TargetIdentifier: target.Identifier,
+		Target:           &target,
+	})
+	if err != nil {
+		return domain.BootstrapResponse{}, err
+	}
+
+	// If the Proxy can't serve streams right now we leave the channel empty
+	// so that the SDK polls for changes instead. The SDK hasn't opened a
+	// stream yet so we don't record one as connected.
+	var streamChannel string
+	if s.healthySassStream() {
+		streamChannel = envID
+	} else {
+		s.logger.Debug(ctx, "bootstrapping without a stream channel because the SaaS stream is disconnected")
+	}
+
+	return domain.BootstrapResponse{
+		AuthToken:     token.TokenString(),
+		Evaluations:   evaluations,
+		StreamChannel: streamChannel,
+		ConfigVersion: version,
+	}, nil
 }
 
 // FeatureConfig gets all FeatureConfig for an environment
@@ -331,22 +507,16 @@ func (s Service) Evaluations(ctx context.Context, req domain.EvaluationsRequest)
 		target = domain.ConvertTarget(domain.Target{Target: clientgen.Target{Identifier: req.TargetIdentifier}})
 	}
 
-	// We fetch all the segments ahead of time and build up a map that we can
-	// pass to the QueryStore. This might be overkill for users that don't have
-	// a lot of rules but for users that do have lots of rules it saves the query
-	// store from making a ton of individual segment calls to the cache.
-	segmentMap := s.makeSegmentMap(ctx, req.EnvironmentID)
-	if !s.andRulesEnabled {
-		// loop through segment map and strip serving rules
-		for i := range segmentMap {
-			segmentMap[i].ServingRules = nil
-		}
+	envEvaluator, err := s.evaluators.get(ctx, req.EnvironmentID)
+	if err != nil {
+		s.logger.Error(ctx, "failed to get evaluator for environment", "environment", req.EnvironmentID, "err", err)
+		return nil, err
 	}
 
-	query := s.GenerateQueryStore(ctx, req.EnvironmentID, segmentMap)
-	sdkEvaluator, _ := evaluation.NewEvaluator(query, nil, logger.NewNoOpLogger())
-
-	flagVariations, err := sdkEvaluator.EvaluateAll(&target)
+	_, span := tracing.Start(ctx, "evaluate", tracing.EnvironmentKey.String(req.EnvironmentID))
+	flagVariations, err := envEvaluator.evaluator.EvaluateAll(&target)
+	span.SetAttributes(attribute.Int("ff.evaluations", len(flagVariations)))
+	tracing.End(span, err)
 	if err != nil {
 		if !errors.Is(err, context.Canceled) {
 			s.logger.Info(ctx, "unable to complete Evaluations request, client cancelled the request", "err", err)
@@ -372,6 +542,8 @@ func (s Service) Evaluations(ctx context.Context, req domain.EvaluationsRequest)
 		evaluations = append(evaluations, eval)
 	}
 
+	s.countEvaluations(ctx, req.EnvironmentID, target.Identifier, evaluations...)
+
 	return evaluations, nil
 }
 
@@ -390,21 +562,40 @@ func (s Service) EvaluationsByFeature(ctx context.Context, req domain.Evaluation
 		target = domain.ConvertTarget(domain.Target{Target: clientgen.Target{Identifier: req.TargetIdentifier}})
 	}
 
-	query := s.GenerateQueryStore(ctx, req.EnvironmentID, nil)
+	envEvaluator, err := s.evaluators.get(ctx, req.EnvironmentID)
+	if err != nil {
+		s.logger.Error(ctx, "failed to get evaluator for environment", "environment", req.EnvironmentID, "err", err)
+		return clientgen.Evaluation{}, err
+	}
 
-	sdkEvaluator, _ := evaluation.NewEvaluator(query, nil, logger.NewNoOpLogger())
-	flagVariation, err := sdkEvaluator.Evaluate(req.FeatureIdentifier, &target)
+	_, span := tracing.Start(ctx, "evaluate", tracing.EnvironmentKey.String(req.EnvironmentID), attribute.String("ff.feature", req.FeatureIdentifier))
+	flagVariation, err := envEvaluator.evaluator.Evaluate(req.FeatureIdentifier, &target)
+	tracing.End(span, err)
 	if err != nil {
 		s.logger.Error(ctx, "ClientAPI.GetEvaluationByIdentifier() failed to perform evaluation", "environment", req.EnvironmentID, "feature", req.FeatureIdentifier, "target", target.Identifier, "err", err)
 		return clientgen.Evaluation{}, err
 	}
 
-	return clientgen.Evaluation{
+	evaluation := clientgen.Evaluation{
 		Flag:       flagVariation.FlagIdentifier,
 		Value:      toString(flagVariation.Variation, string(flagVariation.Kind)),
 		Kind:       string(flagVariation.Kind),
 		Identifier: &flagVariation.Variation.Identifier,
-	}, nil
+	}
+	s.countEvaluations(ctx, req.EnvironmentID, target.Identifier, evaluation)
+
+	return evaluation, nil
+}
+
+// countEvaluations records the evaluations served to a target against the
+// application that made the request
+func (s Service) countEvaluations(ctx context.Context, envID string, target string, evaluations ...clientgen.Evaluation) {
+	if s.evaluationCounter == nil {
+		return
+	}
+
+	appID, _ := ctx.Value(log.AppIDKey).(string)
+	s.evaluationCounter.CountEvaluations(envID, appID, target, evaluations...)
 }
 
 // Stream does a lookup for the environmentID for the APIKey in the StreamRequest
@@ -441,6 +632,11 @@ func (s Service) Stream(ctx context.Context, req domain.StreamRequest) (domain.S
 func (s Service) Metrics(ctx context.Context, req domain.MetricsRequest) error {
 
 	s.logger.Debug(ctx, "got metrics request", "metrics", fmt.Sprintf("%+v", req))
+
+	if s.evaluationCounter != nil {
+		appID, _ := ctx.Value(log.AppIDKey).(string)
+		s.evaluationCounter.SDKPostedMetrics(ctx, appID)
+	}
 	return s.metricService.StoreMetrics(ctx, req)
 }
 
@@ -455,45 +651,127 @@ func (s Service) Health(ctx context.Context) (domain.HealthResponse, error) {
 	return healthResp, nil
 }
 
-func toString(variation rest.Variation, kind string) string {
-	value := fmt.Sprintf("%v", variation.Value)
-	if kind == "json" {
-		data, err := jsoniter.Marshal(variation.Value)
-		if err != nil {
-			value = "{}"
-		} else {
-			value = string(data)
-		}
+// Liveness checks that the Proxy process is up. If we're able to handle the
+// request then we're alive so there's nothing else to check, we deliberately
+// don't check any dependencies so that a Redis or SaaS outage doesn't cause
+// the Proxy to be restarted.
+func (s Service) Liveness(_ context.Context) (domain.ProbeResponse, error) {
+	return domain.ProbeResponse{Status: domain.ProbeStatusHealthy}, nil
+}
+
+// Readiness checks that the Proxy is able to serve SDK requests
+func (s Service) Readiness(ctx context.Context) (domain.ProbeResponse, error) {
+	return probeResult(s.readiness(ctx))
+}
+
+// Startup checks that the Proxy has finished its initial config sync
+func (s Service) Startup(ctx context.Context) (domain.ProbeResponse, error) {
+	return probeResult(s.startup(ctx))
+}
+
+// ExportTargets gets every Target the Proxy has seen in an environment
+func (s Service) ExportTargets(ctx context.Context, req domain.ExportTargetsRequest) (domain.ExportTargetsResponse, error) {
+	targets, err := s.targetRepo.List(ctx, req.EnvironmentID)
+	if err != nil {
+		return domain.ExportTargetsResponse{}, fmt.Errorf("%w: %s", ErrInternal, err)
 	}
-	return value
+
+	s.logger.Debug(ctx, "exporting targets", "environment", req.EnvironmentID, "count", len(targets))
+	return domain.ExportTargetsResponse{Format: req.Format, Targets: targets}, nil
 }
 
-func (s Service) makeSegmentMap(ctx context.Context, envID string) map[string]*domain.Segment {
-	var segmentMap map[string]*domain.Segment
+// AuditHistory gets the most recent config changes that the Proxy has applied
+func (s Service) AuditHistory(_ context.Context, req domain.AuditHistoryRequest) (domain.AuditHistoryResponse, error) {
+	if s.auditHistory == nil {
+		return domain.AuditHistoryResponse{}, fmt.Errorf("%w: audit history is disabled", ErrNotImplemented)
+	}
+
+	return domain.AuditHistoryResponse{Records: s.auditHistory(req)}, nil
+}
 
-	segments, err := s.segmentRepo.Get(ctx, envID)
+// ConfigGeneration gets the generation of an environment's config that's being served
+func (s Service) ConfigGeneration(ctx context.Context, req domain.GenerationRequest) (domain.ConfigGeneration, error) {
+	if s.generations == nil {
+		return domain.ConfigGeneration{}, errGenerationsNotImplemented
+	}
+
+	gen, err := s.generations.ConfigGeneration(ctx, req.EnvironmentID)
 	if err != nil {
-		if errors.Is(err, context.Canceled) {
-			s.logger.Info(ctx, "makeSegmentMap can't get segments from the cache, context was cancelled by the client", "err", err)
-			return segmentMap
-		}
+		return domain.ConfigGeneration{}, s.generationError(ctx, req.EnvironmentID, err)
+	}
+	return gen, nil
+}
 
-		// Not much else we can really do here other than log the error
-		if !errors.Is(err, domain.ErrCacheNotFound) {
-			s.logger.Error(ctx, "makeSegmentMap failed to get segments from cache: ", "err", err)
-		}
-		return segmentMap
+// PinGeneration pins an environment to a previous generation of its config
+func (s Service) PinGeneration(ctx context.Context, req domain.PinGenerationRequest) (domain.ConfigGeneration, error) {
+	if s.generations == nil {
+		return domain.ConfigGeneration{}, errGenerationsNotImplemented
+	}
+
+	gen, err := s.generations.Pin(ctx, req.EnvironmentID, req.Generation)
+	if err != nil {
+		return domain.ConfigGeneration{}, s.generationError(ctx, req.EnvironmentID, err)
+	}
+	return gen, nil
+}
+
+// ReleaseGeneration releases an environment that's pinned to a previous generation
+func (s Service) ReleaseGeneration(ctx context.Context, req domain.GenerationRequest) (domain.ConfigGeneration, error) {
+	if s.generations == nil {
+		return domain.ConfigGeneration{}, errGenerationsNotImplemented
+	}
+
+	gen, err := s.generations.Release(ctx, req.EnvironmentID)
+	if err != nil {
+		return domain.ConfigGeneration{}, s.generationError(ctx, req.EnvironmentID, err)
 	}
+	return gen, nil
+}
+
+// generationError converts errors from managing an environment's generations
+// into service errors
+func (s Service) generationError(ctx context.Context, envID string, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrCacheNotFound):
+		return fmt.Errorf("%w: environment %s has no config generations", ErrNotFound, envID)
+	case errors.Is(err, domain.ErrNoPreviousGeneration),
+		errors.Is(err, domain.ErrGenerationNotFound),
+		errors.Is(err, domain.ErrEnvironmentNotPinned):
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	default:
+		s.logger.Error(ctx, "failed to manage config generation", "environment", envID, "err", err)
+		return fmt.Errorf("%w: %w", ErrInternal, err)
+	}
+}
 
-	if len(segments) > 0 {
-		segmentMap = make(map[string]*domain.Segment, len(segments))
-		for i := 0; i < len(segments); i++ {
-			seg := segments[i]
-			segmentMap[seg.Identifier] = &seg
+// probeResult returns an ErrUnavailable that details any failing checks if the probe failed
+func probeResult(resp domain.ProbeResponse) (domain.ProbeResponse, error) {
+	if resp.Healthy() {
+		return resp, nil
+	}
+
+	failed := make([]string, 0, len(resp.Checks))
+	for check, status := range resp.Checks {
+		if status != domain.ProbeStatusHealthy {
+			failed = append(failed, fmt.Sprintf("%s=%s", check, status))
 		}
 	}
+	sort.Strings(failed)
+
+	return resp, fmt.Errorf("%w: %s", ErrUnavailable, strings.Join(failed, ", "))
+}
 
-	return segmentMap
+func toString(variation rest.Variation, kind string) string {
+	value := fmt.Sprintf("%v", variation.Value)
+	if kind == "json" {
+		data, err := jsoniter.Marshal(variation.Value)
+		if err != nil {
+			value = "{}"
+		} else {
+			value = string(data)
+		}
+	}
+	return value
 }
 
 func (s Service) findTarget(ctx context.Context, envID string, target *domain.Target, targetIdentifier string) (evaluation.Target, error) {
@@ -0,0 +1,258 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Limit is a token bucket budget. The bucket holds at most Burst tokens and is
+// refilled at Rate tokens per second, each request takes one token.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Enabled returns true if the Limit has been configured
+func (l Limit) Enabled() bool {
+	return l.Rate > 0 && l.Burst > 0
+}
+
+// String makes Limit implement the Stringer interface
+func (l Limit) String() string {
+	if !l.Enabled() {
+		return "disabled"
+	}
+	return fmt.Sprintf("%s:%d", strconv.FormatFloat(l.Rate, 'f', -1, 64), l.Burst)
+}
+
+// ParseLimit parses a Limit in the format '<rate>:<burst>' where rate is the
+// number of requests per second and burst is the max number of requests that
+// can be made at once. If the burst is omitted it defaults to the rate rounded
+// up. An empty string returns a disabled Limit.
+func ParseLimit(s string) (Limit, error) {
+	if s == "" {
+		return Limit{}, nil
+	}
+
+	rateStr, burstStr, hasBurst := strings.Cut(s, ":")
+
+	rate, err := strconv.ParseFloat(rateStr, 64)
+	if err != nil || rate <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q, rate must be a positive number", s)
+	}
+
+	if !hasBurst {
+		burst := int(rate)
+		if float64(burst) < rate {
+			burst++
+		}
+		return Limit{Rate: rate, Burst: burst}, nil
+	}
+
+	burst, err := strconv.Atoi(burstStr)
+	if err != nil || burst <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q, burst must be a positive integer", s)
+	}
+
+	return Limit{Rate: rate, Burst: burst}, nil
+}
+
+// Limits are the budgets for a group of requests. Each one is applied to its own
+// bucket so a request has to be within every Limit that's enabled.
+type Limits struct {
+	APIKey      Limit
+	Environment Limit
+	IP          Limit
+}
+
+// Enabled returns true if any of the Limits have been configured
+func (l Limits) Enabled() bool {
+	return l.APIKey.Enabled() || l.Environment.Enabled() || l.IP.Enabled()
+}
+
+// String makes Limits implement the Stringer interface
+func (l Limits) String() string {
+	return fmt.Sprintf("key=%s,env=%s,ip=%s", l.APIKey, l.Environment, l.IP)
+}
+
+// ParseLimits parses a comma separated list of Limits in the format
+// 'key=<limit>,env=<limit>,ip=<limit>' where each limit is in the format
+// accepted by ParseLimit. Any that are omitted are disabled.
+func ParseLimits(s string) (Limits, error) {
+	limits := Limits{}
+	if s == "" {
+		return limits, nil
+	}
+
+	for _, part := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return Limits{}, fmt.Errorf("invalid rate limits %q, expected a comma separated list of key=<limit>, env=<limit> or ip=<limit>", s)
+		}
+
+		limit, err := ParseLimit(value)
+		if err != nil {
+			return Limits{}, err
+		}
+
+		switch name {
+		case "key":
+			limits.APIKey = limit
+		case "env":
+			limits.Environment = limit
+		case "ip":
+			limits.IP = limit
+		default:
+			return Limits{}, fmt.Errorf("invalid rate limits %q, unknown limit %q", s, name)
+		}
+	}
+
+	return limits, nil
+}
+
+// Bucket is a key and the Limit that applies to it. Each Bucket is updated
+// on its own so on a Redis Cluster the buckets for a request can be spread
+// across slots, their keys should hash tag the full bucket identity, e.g.
+// 'evaluation:ip:{127.0.0.1}', rather than something they all share.
+type Bucket struct {
+	Key   string
+	Limit Limit
+}
+
+// tokenBucketScript takes a token from the bucket in KEYS[1] if it has one
+// available. It returns whether a token was taken and, if it wasn't, how long
+// in milliseconds until the bucket will have one. Doing this in a script means
+// the check and take is atomic across every replica sharing the same redis.
+// The time is taken from redis rather than the caller so that clock skew
+// between replicas doesn't let them refill buckets early.
+//
+// KEYS[1] - the bucket
+// ARGV[1] - the refill rate in tokens per second
+// ARGV[2] - the max number of tokens
+var tokenBucketScript = redis.NewScript(`
+-- Redis versions before 5 need this to allow writes after calling TIME
+redis.replicate_commands()
+
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+
+-- Expire buckets once they'd have refilled so idle clients don't leave keys behind
+local ttl = math.ceil(burst * 1000 / rate) + 1000
+
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local available = tonumber(bucket[1])
+local ts = tonumber(bucket[2])
+
+if available == nil or ts == nil then
+	available = burst
+	ts = now
+end
+
+local elapsed = math.max(0, now - ts)
+available = math.min(burst, available + (elapsed * rate / 1000))
+
+local allowed = 0
+local retryAfter = 0
+if available >= 1 then
+	allowed = 1
+	available = available - 1
+else
+	retryAfter = math.ceil((1 - available) * 1000 / rate)
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(available), "ts", tostring(now))
+redis.call("PEXPIRE", KEYS[1], ttl)
+
+return {allowed, retryAfter}
+`)
+
+// RedisLimiter is a token bucket rate limiter that stores its buckets in redis
+// so that limits are shared by every Proxy using the same redis
+type RedisLimiter struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisLimiter creates a RedisLimiter
+func NewRedisLimiter(client redis.UniversalClient) RedisLimiter {
+	return RedisLimiter{
+		client: client,
+		prefix: "ffproxy:ratelimit",
+	}
+}
+
+// Allow takes a token from each of the buckets that has one available, the
+// request is only allowed if they all did. The buckets are updated separately
+// so that they can be in different slots on a Redis Cluster, which means a
+// request that's over one limit still uses a token from its other buckets. If
+// the request isn't allowed it returns how long the caller should wait before
+// trying again. Buckets with a disabled Limit are ignored.
+func (r RedisLimiter) Allow(ctx context.Context, buckets ...Bucket) (bool, time.Duration, error) {
+	enabled := make([]Bucket, 0, len(buckets))
+	for _, b := range buckets {
+		if b.Limit.Enabled() {
+			enabled = append(enabled, b)
+		}
+	}
+
+	if len(enabled) == 0 {
+		return true, 0, nil
+	}
+
+	cmds, err := r.run(ctx, enabled, true)
+	if err != nil && redis.HasErrorPrefix(err, "NOSCRIPT") {
+		cmds, err = r.run(ctx, enabled, false)
+	}
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to run rate limit script: %w", err)
+	}
+
+	allowed := true
+	retryAfter := int64(0)
+	for _, cmd := range cmds {
+		result, err := cmd.Int64Slice()
+		if err != nil {
+			return false, 0, fmt.Errorf("failed to run rate limit script: %w", err)
+		}
+		if len(result) != 2 {
+			return false, 0, errors.New("unexpected response from rate limit script")
+		}
+
+		if result[0] != 1 {
+			allowed = false
+		}
+		if result[1] > retryAfter {
+			retryAfter = result[1]
+		}
+	}
+
+	return allowed, time.Duration(retryAfter) * time.Millisecond, nil
+}
+
+// run runs the token bucket script against each of the buckets in a single
+// pipeline. It uses EVALSHA unless evalSha is false, in which case it sends
+// the whole script because redis doesn't have it cached.
+func (r RedisLimiter) run(ctx context.Context, buckets []Bucket, evalSha bool) ([]*redis.Cmd, error) {
+	cmds := make([]*redis.Cmd, len(buckets))
+	_, err := r.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for i, b := range buckets {
+			keys := []string{fmt.Sprintf("%s:%s", r.prefix, b.Key)}
+			if evalSha {
+				cmds[i] = tokenBucketScript.EvalSha(ctx, p, keys, b.Limit.Rate, b.Limit.Burst)
+				continue
+			}
+			cmds[i] = tokenBucketScript.Eval(ctx, p, keys, b.Limit.Rate, b.Limit.Burst)
+		}
+		return nil
+	})
+	return cmds, err
+}
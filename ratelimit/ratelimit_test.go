@@ -0,0 +1,165 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLimit(t *testing.T) {
+	testCases := map[string]struct {
+		value     string
+		expected  Limit
+		shouldErr bool
+	}{
+		"Given I have an empty string": {
+			value:    "",
+			expected: Limit{},
+		},
+		"Given I have a rate and a burst": {
+			value:    "10:20",
+			expected: Limit{Rate: 10, Burst: 20},
+		},
+		"Given I have a rate and no burst": {
+			value:    "2.5",
+			expected: Limit{Rate: 2.5, Burst: 3},
+		},
+		"Given I have a rate that isn't a number": {
+			value:     "foo:20",
+			shouldErr: true,
+		},
+		"Given I have a negative rate": {
+			value:     "-1:20",
+			shouldErr: true,
+		},
+		"Given I have a burst of zero": {
+			value:     "10:0",
+			shouldErr: true,
+		},
+	}
+
+	for desc, tc := range testCases {
+		desc := desc
+		tc := tc
+
+		t.Run(desc, func(t *testing.T) {
+			actual, err := ParseLimit(tc.value)
+			if (err != nil) != tc.shouldErr {
+				t.Errorf("(%s): error = %v, shouldErr = %v", desc, err, tc.shouldErr)
+			}
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	testCases := map[string]struct {
+		value     string
+		expected  Limits
+		shouldErr bool
+	}{
+		"Given I have an empty string": {
+			value:    "",
+			expected: Limits{},
+		},
+		"Given I have every limit": {
+			value: "key=10:20, env=100:200, ip=5",
+			expected: Limits{
+				APIKey:      Limit{Rate: 10, Burst: 20},
+				Environment: Limit{Rate: 100, Burst: 200},
+				IP:          Limit{Rate: 5, Burst: 5},
+			},
+		},
+		"Given I only have an ip limit": {
+			value:    "ip=1:2",
+			expected: Limits{IP: Limit{Rate: 1, Burst: 2}},
+		},
+		"Given I have an unknown limit": {
+			value:     "user=1:2",
+			shouldErr: true,
+		},
+		"Given I have a limit without a name": {
+			value:     "1:2",
+			shouldErr: true,
+		},
+	}
+
+	for desc, tc := range testCases {
+		desc := desc
+		tc := tc
+
+		t.Run(desc, func(t *testing.T) {
+			actual, err := ParseLimits(tc.value)
+			if (err != nil) != tc.shouldErr {
+				t.Errorf("(%s): error = %v, shouldErr = %v", desc, err, tc.shouldErr)
+			}
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestRedisLimiter_Allow(t *testing.T) {
+	ctx := context.Background()
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+
+	now := time.UnixMilli(1700000000000)
+	mr.SetTime(now)
+
+	limiter := NewRedisLimiter(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+
+	key := Bucket{Key: "evaluation:key:{123}", Limit: Limit{Rate: 1, Burst: 2}}
+	env := Bucket{Key: "evaluation:env:{abc}", Limit: Limit{Rate: 10, Burst: 10}}
+
+	t.Log("When I make two requests the burst lets them through")
+	for i := 0; i < 2; i++ {
+		allowed, _, err := limiter.Allow(ctx, key, env)
+		assert.Nil(t, err)
+		assert.True(t, allowed)
+	}
+
+	t.Log("When I make a third request it exceeds the API key limit")
+	allowed, retryAfter, err := limiter.Allow(ctx, key, env)
+	assert.Nil(t, err)
+	assert.False(t, allowed)
+	assert.Equal(t, 1*time.Second, retryAfter)
+
+	t.Log("Then the environment bucket still had a token taken by each request")
+	for i := 0; i < 7; i++ {
+		allowed, _, err := limiter.Allow(ctx, env)
+		assert.Nil(t, err)
+		assert.True(t, allowed)
+	}
+	allowed, _, err = limiter.Allow(ctx, env)
+	assert.Nil(t, err)
+	assert.False(t, allowed)
+
+	t.Log("When half a second has passed the API key bucket still doesn't have a token")
+	now = now.Add(500 * time.Millisecond)
+	mr.SetTime(now)
+	allowed, retryAfter, err = limiter.Allow(ctx, key)
+	assert.Nil(t, err)
+	assert.False(t, allowed)
+	assert.Equal(t, 500*time.Millisecond, retryAfter)
+
+	t.Log("When a second has passed the API key bucket has refilled a token")
+	now = now.Add(500 * time.Millisecond)
+	mr.SetTime(now)
+	allowed, _, err = limiter.Allow(ctx, key)
+	assert.Nil(t, err)
+	assert.True(t, allowed)
+
+	t.Log("And buckets with a disabled limit are ignored")
+	allowed, _, err = limiter.Allow(ctx, Bucket{Key: "evaluation:ip:{127.0.0.1}"})
+	assert.Nil(t, err)
+	assert.True(t, allowed)
+	assert.False(t, mr.Exists("ffproxy:ratelimit:evaluation:ip:{127.0.0.1}"))
+}
@@ -4,25 +4,44 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 
 	"golang.org/x/exp/slices"
 
 	"github.com/harness/ff-proxy/v2/cache"
 	"github.com/harness/ff-proxy/v2/domain"
+	"github.com/harness/ff-proxy/v2/hash"
+	"github.com/harness/ff-proxy/v2/tracing"
 )
 
 // AuthRepo is a repository that stores a map of api key hashes to environmentIDs
 type AuthRepo struct {
 	cache                cache.Cache
 	approvedEnvironments map[string]struct{}
+	upgrader             hash.Upgrader
+}
+
+// WithKeyUpgrader configures the AuthRepo to store api key hashes using the
+// upgrader's algorithm. Hashes created by the previous algorithm, e.g. the ones
+// we get from Harness SaaS, are upgraded before they're stored and can still be
+// used to look keys up.
+func WithKeyUpgrader(u hash.Upgrader) func(a *AuthRepo) {
+	return func(a *AuthRepo) {
+		a.upgrader = u
+	}
 }
 
 // NewAuthRepo creates an AuthRepo from a map of api key hashes to environmentIDs
-func NewAuthRepo(c cache.Cache) AuthRepo {
-	return AuthRepo{
+func NewAuthRepo(c cache.Cache, opts ...func(a *AuthRepo)) AuthRepo {
+	a := AuthRepo{
 		cache:                c,
 		approvedEnvironments: nil,
 	}
+
+	for _, opt := range opts {
+		opt(&a)
+	}
+	return a
 }
 
 // Add adds environment api key hash pairs to the cache
@@ -31,9 +50,10 @@ func (a AuthRepo) Add(ctx context.Context, values ...domain.AuthConfig) error {
 	errs := []error{}
 	for i := 0; i < len(values); i++ {
 		value := values[i]
-		apiKeys = append(apiKeys, string(value.APIKey))
-		if err := a.cache.Set(ctx, string(value.APIKey), &value.EnvironmentID); err != nil {
-			errs = append(errs, addError{string(value.APIKey), string(value.APIKey), err})
+		key := upgradeAuthKey(a.upgrader, string(value.APIKey))
+		apiKeys = append(apiKeys, key)
+		if err := a.cache.Set(ctx, key, &value.EnvironmentID); err != nil {
+			errs = append(errs, addError{key, key, err})
 		}
 	}
 	if len(errs) > 0 {
@@ -42,18 +62,41 @@ func (a AuthRepo) Add(ctx context.Context, values ...domain.AuthConfig) error {
 	return nil
 }
 
+// StorageKey returns the key that an api key hash is stored under in the
+// cache, which is different to the hash we get from Harness SaaS if the
+// AuthRepo has been configured with an upgrader
+func (a AuthRepo) StorageKey(key domain.AuthAPIKey) string {
+	return upgradeAuthKey(a.upgrader, string(key))
+}
+
 // AddAPIConfigsForEnvironment adds/overrides the list of api keys on populate.
 func (a AuthRepo) AddAPIConfigsForEnvironment(ctx context.Context, envID string, apiKeys []string) error {
+	upgraded := make([]string, 0, len(apiKeys))
+	for _, k := range apiKeys {
+		upgraded = append(upgraded, upgradeAuthKey(a.upgrader, k))
+	}
+
 	key := domain.NewAPIConfigsKey(envID)
-	return a.cache.Set(ctx, string(key), apiKeys)
+	return a.cache.Set(ctx, string(key), upgraded)
 }
 
 // Get gets the environmentID for the passed api key hash
 // if the auth repo has been configured with approved envs only return keys that belong to those envs
-func (a AuthRepo) Get(ctx context.Context, key domain.AuthAPIKey) (string, bool, error) {
+func (a AuthRepo) Get(ctx context.Context, key domain.AuthAPIKey) (_ string, _ bool, err error) {
+	ctx, span := tracing.Start(ctx, "AuthRepo.Get")
+	defer func() { tracing.End(span, err) }()
+
 	var environment domain.EnvironmentID
 
-	if err := a.cache.Get(ctx, string(key), &environment); err != nil {
+	upgraded := upgradeAuthKey(a.upgrader, string(key))
+	err = a.cache.Get(ctx, upgraded, &environment)
+
+	// Instances that haven't been configured with the upgrader yet will still
+	// be storing keys using the previous algorithm
+	if errors.Is(err, domain.ErrCacheNotFound) && upgraded != string(key) {
+		err = a.cache.Get(ctx, string(key), &environment)
+	}
+	if err != nil {
 		return "", false, err
 	}
 
@@ -104,13 +147,19 @@ func (a AuthRepo) Remove(ctx context.Context, keys []string) error {
 		if err := a.cache.Delete(ctx, k); err != nil {
 			return err
 		}
+
+		if upgraded := upgradeAuthKey(a.upgrader, k); upgraded != k {
+			if err := a.cache.Delete(ctx, upgraded); err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
 
 // PatchAPIConfigForEnvironment Updates the list of keys for given environment
 func (a AuthRepo) PatchAPIConfigForEnvironment(ctx context.Context, envID, key, action string) error {
-	apiKey := string(domain.NewAuthAPIKey(key))
+	apiKey := upgradeAuthKey(a.upgrader, string(domain.NewAuthAPIKey(key)))
 	apiConfigsKey := domain.NewAPIConfigsKey(envID)
 	apiConfigsValue, err := a.GetKeysForEnvironment(ctx, envID)
 	if err != nil {
@@ -174,3 +223,17 @@ func (a AuthRepo) handleAdd(ctx context.Context, apiConfigsValue []string, apiKe
 	apiConfigsValue = append(apiConfigsValue, apiKey)
 	return apiConfigsValue, false, nil
 }
+
+// upgradeAuthKey upgrades the hash in an auth key using the upgrader, keys
+// that aren't auth keys are returned as is
+func upgradeAuthKey(u hash.Upgrader, key string) string {
+	if u == nil {
+		return key
+	}
+
+	h, ok := strings.CutPrefix(key, string(domain.NewAuthAPIKey("")))
+	if !ok {
+		return key
+	}
+	return string(domain.NewAuthAPIKey(u.Upgrade(h)))
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/harness/ff-proxy/v2/domain"
+	"github.com/harness/ff-proxy/v2/hash"
 )
 
 func TestAuthRepo_Get(t *testing.T) {
@@ -228,3 +229,58 @@ func TestAPIRepo_PatchAPIConfigForEnvironment(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthRepo_KeyUpgrader(t *testing.T) {
+	ctx := context.Background()
+
+	const (
+		envID = "env-123"
+		key   = "21ee6c7a-f78d-4afd-86a1-5c108aad41e8"
+	)
+
+	hmac := hash.NewHMACSha256("pepper")
+	v1 := hash.NewSha256().Hash(key)
+	v2 := hmac.Hash(key)
+
+	c := cache.NewMemCache()
+	repo := NewAuthRepo(c, WithKeyUpgrader(hmac))
+
+	t.Log("When I add a v1 key hash")
+	assert.Nil(t, repo.Add(ctx, domain.AuthConfig{APIKey: domain.NewAuthAPIKey(v1), EnvironmentID: envID}))
+	assert.Nil(t, repo.AddAPIConfigsForEnvironment(ctx, envID, []string{string(domain.NewAuthAPIKey(v1))}))
+
+	t.Log("Then it's stored as a v2 hash")
+	var env domain.EnvironmentID
+	assert.Nil(t, c.Get(ctx, string(domain.NewAuthAPIKey(v2)), &env))
+	assert.NotNil(t, c.Get(ctx, string(domain.NewAuthAPIKey(v1)), &env))
+
+	keys, err := repo.GetKeysForEnvironment(ctx, envID)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{string(domain.NewAuthAPIKey(v2))}, keys)
+
+	t.Log("And it can be looked up with either hash")
+	for _, h := range []string{v1, v2} {
+		actual, ok, err := repo.Get(ctx, domain.NewAuthAPIKey(h))
+		assert.Nil(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, envID, actual)
+	}
+
+	t.Log("When a v1 key hash is stored by a Proxy that doesn't have the upgrader")
+	other := "b8f1ba6e-7e65-4b6e-9a0b-4d1b9e9d3c4f"
+	otherV1 := hash.NewSha256().Hash(other)
+	assert.Nil(t, NewAuthRepo(c).Add(ctx, domain.AuthConfig{APIKey: domain.NewAuthAPIKey(otherV1), EnvironmentID: envID}))
+
+	t.Log("Then it can still be looked up with the v1 hash")
+	actual, ok, err := repo.Get(ctx, domain.NewAuthAPIKey(otherV1))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, envID, actual)
+
+	t.Log("When I remove the v1 key hash")
+	assert.Nil(t, repo.Remove(ctx, []string{string(domain.NewAuthAPIKey(v1))}))
+
+	t.Log("Then the v2 hash is removed")
+	_, ok, _ = repo.Get(ctx, domain.NewAuthAPIKey(v2))
+	assert.False(t, ok)
+}
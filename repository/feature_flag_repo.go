@@ -8,25 +8,53 @@ import (
 	clientgen "github.com/harness/ff-proxy/v2/gen/client"
 
 	"github.com/harness/ff-proxy/v2/domain"
+	"github.com/harness/ff-proxy/v2/tracing"
 )
 
+// WithFlagGenerations configures the FeatureFlagRepo to read flags from the
+// current generation of an environment's config and to publish a new
+// generation whenever flags are added
+func WithFlagGenerations(g *GenerationRepo) func(f *FeatureFlagRepo) {
+	return func(f *FeatureFlagRepo) {
+		f.generations = g
+	}
+}
+
 // FeatureFlagRepo is a repository that stores FeatureFlags
 type FeatureFlagRepo struct {
-	cache cache.Cache
+	cache       cache.Cache
+	generations *GenerationRepo
 }
 
 // NewFeatureFlagRepo creates a FeatureFlagRepo. It can optionally preload the repo with data
 // from the passed config
-func NewFeatureFlagRepo(c cache.Cache) FeatureFlagRepo {
-	return FeatureFlagRepo{cache: c}
+func NewFeatureFlagRepo(c cache.Cache, opts ...func(f *FeatureFlagRepo)) FeatureFlagRepo {
+	f := FeatureFlagRepo{cache: c}
+	for _, opt := range opts {
+		opt(&f)
+	}
+	return f
+}
+
+// Snapshot returns a context that pins reads for the environment to its
+// current generation, so that every read made with it sees the same config
+// even if a new generation is published part way through
+func (f FeatureFlagRepo) Snapshot(ctx context.Context, envID string) context.Context {
+	if f.generations == nil {
+		return ctx
+	}
+	return f.generations.Snapshot(ctx, envID)
 }
 
 // Get gets all the FeatureFlag for a given key
-func (f FeatureFlagRepo) Get(ctx context.Context, envID string) ([]domain.FeatureFlag, error) {
+func (f FeatureFlagRepo) Get(ctx context.Context, envID string) (_ []domain.FeatureFlag, err error) {
+	ctx, span := tracing.Start(ctx, "FeatureFlagRepo.Get", tracing.EnvironmentKey.String(envID))
+	defer func() { tracing.End(span, err) }()
+
 	var featureFlags []domain.FeatureFlag
-	key := domain.NewFeatureConfigsKey(envID)
+	key := domain.NewFeatureConfigsKey(f.envKey(ctx, envID))
 
-	err := f.cache.Get(ctx, string(key), &featureFlags)
+	err = f.cache.Get(ctx, string(key), &featureFlags)
 	if err != nil {
 		return []domain.FeatureFlag{}, err
 	}
@@ -35,11 +63,14 @@ func (f FeatureFlagRepo) Get(ctx context.Context, envID string) ([]domain.Featur
 }
 
 // GetByIdentifier gets a FeatureFlag for a given key and identifier
-func (f FeatureFlagRepo) GetByIdentifier(ctx context.Context, envID string, identifier string) (domain.FeatureFlag, error) {
+func (f FeatureFlagRepo) GetByIdentifier(ctx context.Context, envID string, identifier string) (_ domain.FeatureFlag, err error) {
+	ctx, span := tracing.Start(ctx, "FeatureFlagRepo.GetByIdentifier", tracing.EnvironmentKey.String(envID))
+	defer func() { tracing.End(span, err) }()
+
 	featureFlag := domain.FeatureFlag{}
-	key := domain.NewFeatureConfigKey(envID, identifier)
+	key := domain.NewFeatureConfigKey(f.envKey(ctx, envID), identifier)
 
-	if err := f.cache.Get(ctx, string(key), &featureFlag); err != nil {
+	if err = f.cache.Get(ctx, string(key), &featureFlag); err != nil {
 		return domain.FeatureFlag{}, err
 	}
 
@@ -53,29 +84,20 @@ func (f FeatureFlagRepo) GetByIdentifier(ctx context.Context, envID string, iden
 }
 
 // Add stores FlagConfig in the cache
-func (f FeatureFlagRepo) Add(ctx context.Context, config ...domain.FlagConfig) error {
+func (f FeatureFlagRepo) Add(ctx context.Context, config ...domain.FlagConfig) (err error) {
+	ctx, span := tracing.Start(ctx, "FeatureFlagRepo.Add")
+	defer func() { tracing.End(span, err) }()
+
 	errs := []error{}
 	for _, cfg := range config {
-		k := domain.NewFeatureConfigsKey(cfg.EnvironmentID)
-		if err := f.cache.Set(ctx, string(k), cfg.FeatureConfigs); err != nil {
-			errs = append(errs, addError{
-				key:        string(k),
-				identifier: "feature-configs",
-				err:        err,
-			})
-		}
-
-		for _, flag := range cfg.FeatureConfigs {
-			key := domain.NewFeatureConfigKey(cfg.EnvironmentID, flag.Feature)
-
-			if err := f.cache.Set(ctx, string(key), flag); err != nil {
-				errs = append(errs, addError{
-					key:        string(key),
-					identifier: flag.Feature,
-					err:        err,
-				})
+		if f.generations != nil {
+			if _, err := f.generations.PublishFlags(ctx, cfg.EnvironmentID, cfg.FeatureConfigs); err != nil {
+				errs = append(errs, err)
 			}
+			continue
 		}
+
+		errs = append(errs, addFlags(ctx, f.cache, cfg.EnvironmentID, cfg.FeatureConfigs)...)
 	}
 
 	if len(errs) > 0 {
@@ -88,7 +110,7 @@ func (f FeatureFlagRepo) Add(ctx context.Context, config ...domain.FlagConfig) e
 // GetFeatureConfigForEnvironment gets the feature config for environment from cache.
 func (f FeatureFlagRepo) GetFeatureConfigForEnvironment(ctx context.Context, envID string) ([]domain.FeatureFlag, bool) {
 	var features []domain.FeatureFlag
-	key := domain.NewFeatureConfigsKey(envID)
+	key := domain.NewFeatureConfigsKey(f.envKey(ctx, envID))
 	if err := f.cache.Get(ctx, string(key), &features); err != nil {
 		return features, false
 	}
@@ -103,22 +125,66 @@ func (f FeatureFlagRepo) Remove(ctx context.Context, identifier string) error {
 
 // RemoveAllFeaturesForEnvironment removes all feature entries for given environment id
 func (f FeatureFlagRepo) RemoveAllFeaturesForEnvironment(ctx context.Context, id string) error {
+	if f.generations != nil {
+		_, err := f.generations.PublishFlags(ctx, id, nil)
+		return err
+	}
 
 	// get all the feature for given key
 	flags, err := f.Get(ctx, id)
 	if err != nil {
 		return err
 	}
+	return removeFlags(ctx, f.cache, id, flags)
+}
+
+func (f FeatureFlagRepo) envKey(ctx context.Context, envID string) string {
+	if f.generations == nil {
+		return envID
+	}
+	return f.generations.envKey(ctx, envID)
+}
+
+// addFlags writes the flags list and an entry for each flag under the envKey,
+// which is either an environment ID or the ID of one of its generations
+func addFlags(ctx context.Context, c cache.Cache, envKey string, flags []domain.FeatureFlag) []error {
+	errs := []error{}
+
+	k := domain.NewFeatureConfigsKey(envKey)
+	if err := c.Set(ctx, string(k), flags); err != nil {
+		errs = append(errs, addError{
+			key:        string(k),
+			identifier: "feature-configs",
+			err:        err,
+		})
+	}
+
+	for _, flag := range flags {
+		key := domain.NewFeatureConfigKey(envKey, flag.Feature)
+
+		if err := c.Set(ctx, string(key), flag); err != nil {
+			errs = append(errs, addError{
+				key:        string(key),
+				identifier: flag.Feature,
+				err:        err,
+			})
+		}
+	}
+	return errs
+}
+
+// removeFlags deletes the flags list and the entry for each flag under the envKey
+func removeFlags(ctx context.Context, c cache.Cache, envKey string, flags []domain.FeatureFlag) error {
 	// remove featureConfigs entry
-	fcKey := domain.NewFeatureConfigsKey(id)
-	if err := f.cache.Delete(ctx, string(fcKey)); err != nil {
+	fcKey := domain.NewFeatureConfigsKey(envKey)
+	if err := c.Delete(ctx, string(fcKey)); err != nil {
 		return err
 	}
 	// remove all individual feature entries for environment
 	for _, flag := range flags {
 
-		key := domain.NewFeatureConfigKey(id, flag.Feature)
-		if err := f.cache.Delete(ctx, string(key)); err != nil {
+		key := domain.NewFeatureConfigKey(envKey, flag.Feature)
+		if err := c.Delete(ctx, string(key)); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,436 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/harness/ff-proxy/v2/cache"
+	"github.com/harness/ff-proxy/v2/domain"
+	"github.com/harness/ff-proxy/v2/log"
+	"github.com/harness/ff-proxy/v2/tracing"
+)
+
+// pinnedGenerationsKey is the key for the generations that environments are
+// pinned to. It's kept separately from the pointers so the pins can be listed
+// without having to scan for them.
+const pinnedGenerationsKey = "pinned-generations"
+
+// generationSnapshotKey is the context key for the generation that reads for
+// an environment are pinned to
+type generationSnapshotKey struct {
+	envID string
+}
+
+// WithGenerationHistory sets how many generations of each environment's config
+// are kept, including the current one. It can't be less than two.
+func WithGenerationHistory(n int) func(g *GenerationRepo) {
+	return func(g *GenerationRepo) {
+		g.history = max(n, 2)
+	}
+}
+
+// GenerationRepo stores each version of an environment's flags and segments
+// as an immutable generation and publishes it by updating a single pointer
+// key. Readers resolve the pointer before reading so they never see a mix of
+// old and new config.
+//
+// The last few generations are kept so that an environment can be pinned to
+// one of them. While an environment is pinned new generations are still
+// written but aren't served until the pin is released.
+//
+// Environments that haven't had a generation published yet are read from the
+// unversioned keys so that replicas can be upgraded before the Primary.
+type GenerationRepo struct {
+	log     log.Logger
+	cache   cache.Cache
+	now     func() time.Time
+	history int
+
+	// Only the Primary publishes generations so a local lock is enough to
+	// stop concurrent publishes for an environment from racing each other
+	mtx *sync.Mutex
+}
+
+// NewGenerationRepo creates a GenerationRepo
+func NewGenerationRepo(c cache.Cache, l log.Logger, opts ...func(g *GenerationRepo)) *GenerationRepo {
+	l = l.With("component", "GenerationRepo")
+	g := &GenerationRepo{
+		log:     l,
+		cache:   c,
+		now:     time.Now,
+		history: 2,
+		mtx:     &sync.Mutex{},
+	}
+
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// Get gets the generation pointer for an environment
+func (g *GenerationRepo) Get(ctx context.Context, envID string) (_ domain.ConfigGeneration, err error) {
+	ctx, span := tracing.Start(ctx, "GenerationRepo.Get", tracing.EnvironmentKey.String(envID))
+	defer func() { tracing.End(span, err) }()
+
+	var gen domain.ConfigGeneration
+	if err = g.cache.Get(ctx, string(domain.NewGenerationKey(envID)), &gen); err != nil {
+		return domain.ConfigGeneration{}, err
+	}
+
+	if len(gen.History) == 0 && gen.Current != 0 {
+		gen.History = []int64{gen.Current}
+	}
+	return gen, nil
+}
+
+// Pinned gets the generations that environments are currently pinned to
+func (g *GenerationRepo) Pinned(ctx context.Context) (map[string]domain.ConfigGeneration, error) {
+	pinned := map[string]domain.ConfigGeneration{}
+	if err := g.cache.Get(ctx, pinnedGenerationsKey, &pinned); err != nil && !errors.Is(err, domain.ErrCacheNotFound) {
+		return nil, err
+	}
+	return pinned, nil
+}
+
+// Snapshot returns a context that pins reads for the environment to its
+// current generation
+func (g *GenerationRepo) Snapshot(ctx context.Context, envID string) context.Context {
+	if _, ok := ctx.Value(generationSnapshotKey{envID: envID}).(int64); ok {
+		return ctx
+	}
+
+	gen, err := g.Get(ctx, envID)
+	if err != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, generationSnapshotKey{envID: envID}, gen.Current)
+}
+
+// Latest returns a context that pins reads for the environment to the latest
+// generation that's been written. This is the same as Snapshot unless the
+// environment is pinned, when it lets changes be made on top of the newest
+// config rather than the config that's being served.
+func (g *GenerationRepo) Latest(ctx context.Context, envID string) context.Context {
+	gen, err := g.Get(ctx, envID)
+	if err != nil || gen.Latest() == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, generationSnapshotKey{envID: envID}, gen.Latest())
+}
+
+// Publish writes the flags and segments as a new generation and then points
+// the environment at it. If there are no flags or segments the environment
+// is removed, unless it's pinned.
+func (g *GenerationRepo) Publish(ctx context.Context, envID string, flags []domain.FeatureFlag, segments []domain.Segment) (domain.ConfigGeneration, error) {
+	return g.publish(ctx, envID, func(_ string) ([]domain.FeatureFlag, []domain.Segment, error) {
+		return flags, segments, nil
+	})
+}
+
+// PublishFlags publishes a new generation with the given flags and the
+// segments from the latest generation
+func (g *GenerationRepo) PublishFlags(ctx context.Context, envID string, flags []domain.FeatureFlag) (domain.ConfigGeneration, error) {
+	return g.publish(ctx, envID, func(envKey string) ([]domain.FeatureFlag, []domain.Segment, error) {
+		var segments []domain.Segment
+		if err := g.cache.Get(ctx, string(domain.NewSegmentsKey(envKey)), &segments); err != nil && !errors.Is(err, domain.ErrCacheNotFound) {
+			return nil, nil, fmt.Errorf("failed to get current segments: %w", err)
+		}
+		return flags, segments, nil
+	})
+}
+
+// PublishSegments publishes a new generation with the given segments and the
+// flags from the latest generation
+func (g *GenerationRepo) PublishSegments(ctx context.Context, envID string, segments []domain.Segment) (domain.ConfigGeneration, error) {
+	return g.publish(ctx, envID, func(envKey string) ([]domain.FeatureFlag, []domain.Segment, error) {
+		var flags []domain.FeatureFlag
+		if err := g.cache.Get(ctx, string(domain.NewFeatureConfigsKey(envKey)), &flags); err != nil && !errors.Is(err, domain.ErrCacheNotFound) {
+			return nil, nil, fmt.Errorf("failed to get current flags: %w", err)
+		}
+		return flags, segments, nil
+	})
+}
+
+// Pin points the environment at one of the generations being kept and stops
+// new generations from being served until it's released. If generation is
+// zero the environment is pinned to the generation before the current one.
+func (g *GenerationRepo) Pin(ctx context.Context, envID string, generation int64) (domain.ConfigGeneration, error) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	current, err := g.Get(ctx, envID)
+	if err != nil {
+		return domain.ConfigGeneration{}, err
+	}
+
+	if generation == 0 {
+		generation = previousGeneration(current)
+		if generation == 0 {
+			return domain.ConfigGeneration{}, domain.ErrNoPreviousGeneration
+		}
+	}
+
+	if !current.Kept(generation) {
+		return domain.ConfigGeneration{}, fmt.Errorf("%w: %d", domain.ErrGenerationNotFound, generation)
+	}
+
+	now := g.now().Unix()
+	next := current
+	next.Current = generation
+	next.Pinned = true
+	next.PinnedAt = now
+	next.UpdatedAt = now
+
+	if err := g.cache.Set(ctx, string(domain.NewGenerationKey(envID)), next); err != nil {
+		return domain.ConfigGeneration{}, fmt.Errorf("failed to pin environment %s to generation %d: %w", envID, generation, err)
+	}
+
+	if err := g.updatePinned(ctx, func(pinned map[string]domain.ConfigGeneration) {
+		pinned[envID] = next
+	}); err != nil {
+		g.log.Warn("failed to record pinned generation", "environment", envID, "generation", generation, "err", err)
+	}
+
+	g.log.Info("pinned environment config", "environment", envID, "from", current.Current, "to", generation)
+	return next, nil
+}
+
+// Release unpins an environment and points it at the latest generation
+func (g *GenerationRepo) Release(ctx context.Context, envID string) (domain.ConfigGeneration, error) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	current, err := g.Get(ctx, envID)
+	if err != nil {
+		return domain.ConfigGeneration{}, err
+	}
+
+	if !current.Pinned {
+		return domain.ConfigGeneration{}, domain.ErrEnvironmentNotPinned
+	}
+
+	next := current
+	next.Current = current.Latest()
+	next.Pinned = false
+	next.PinnedAt = 0
+	next.UpdatedAt = g.now().Unix()
+
+	if err := g.cache.Set(ctx, string(domain.NewGenerationKey(envID)), next); err != nil {
+		return domain.ConfigGeneration{}, fmt.Errorf("failed to release environment %s: %w", envID, err)
+	}
+
+	if err := g.updatePinned(ctx, func(pinned map[string]domain.ConfigGeneration) {
+		delete(pinned, envID)
+	}); err != nil {
+		g.log.Warn("failed to remove pinned generation", "environment", envID, "err", err)
+	}
+
+	g.log.Info("released environment config", "environment", envID, "from", current.Current, "to", next.Current)
+	return next, nil
+}
+
+// Remove removes every generation of an environment's config
+func (g *GenerationRepo) Remove(ctx context.Context, envID string) error {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	current, err := g.Get(ctx, envID)
+	if err != nil && !errors.Is(err, domain.ErrCacheNotFound) {
+		return err
+	}
+	return g.remove(ctx, envID, current)
+}
+
+// publish gets the flags and segments for the new generation from fn, which
+// is passed the key the latest generation is stored under, writes them and
+// then flips the environment's pointer to the new generation
+func (g *GenerationRepo) publish(ctx context.Context, envID string, fn func(envKey string) ([]domain.FeatureFlag, []domain.Segment, error)) (domain.ConfigGeneration, error) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	current, err := g.Get(ctx, envID)
+	if err != nil && !errors.Is(err, domain.ErrCacheNotFound) {
+		return domain.ConfigGeneration{}, err
+	}
+
+	latestKey := envID
+	if latest := current.Latest(); latest != 0 {
+		latestKey = domain.NewGenerationEnvID(envID, latest)
+	}
+
+	flags, segments, err := fn(latestKey)
+	if err != nil {
+		return domain.ConfigGeneration{}, err
+	}
+
+	if len(flags) == 0 && len(segments) == 0 && !current.Pinned {
+		return domain.ConfigGeneration{}, g.remove(ctx, envID, current)
+	}
+
+	generation := current.Latest() + 1
+	history, evicted := g.keep(current, generation)
+
+	next := current
+	next.History = history
+	next.UpdatedAt = g.now().Unix()
+	if !current.Pinned {
+		next.Current = generation
+	}
+	nextKey := domain.NewGenerationEnvID(envID, generation)
+
+	// We only write the lists if they have something in them so that reads
+	// for an environment with no flags or segments get ErrCacheNotFound, the
+	// same as they would for the unversioned keys
+	errs := []error{}
+	if len(flags) > 0 {
+		errs = append(errs, addFlags(ctx, g.cache, nextKey, flags)...)
+	}
+	if len(segments) > 0 {
+		errs = append(errs, addSegments(ctx, g.cache, nextKey, segments)...)
+	}
+	if len(errs) > 0 {
+		// Clean up what we managed to write, readers are still pointed at
+		// the current generation so they won't have seen any of it
+		if err := g.removeGeneration(ctx, nextKey); err != nil {
+			g.log.Warn("failed to clean up partially written generation", "environment", envID, "generation", generation, "err", err)
+		}
+		return domain.ConfigGeneration{}, fmt.Errorf("failed to write generation %d for environment %s: %v", generation, envID, errs)
+	}
+
+	if err := g.cache.Set(ctx, string(domain.NewGenerationKey(envID)), next); err != nil {
+		return domain.ConfigGeneration{}, fmt.Errorf("failed to publish generation %d for environment %s: %w", generation, envID, err)
+	}
+
+	for _, old := range evicted {
+		if err := g.removeGeneration(ctx, domain.NewGenerationEnvID(envID, old)); err != nil {
+			g.log.Warn("failed to remove old generation", "environment", envID, "generation", old, "err", err)
+		}
+	}
+
+	if current.Pinned {
+		g.log.Info("holding new generation for pinned environment", "environment", envID, "generation", generation, "pinned", current.Current)
+	} else {
+		g.log.Debug("published environment config", "environment", envID, "generation", generation, "flags", len(flags), "segments", len(segments))
+	}
+	return next, nil
+}
+
+// keep returns the generations to keep once the new one has been added, newest
+// first, and the ones that should be removed. The generation an environment
+// is pinned to is always kept.
+func (g *GenerationRepo) keep(current domain.ConfigGeneration, generation int64) ([]int64, []int64) {
+	all := append([]int64{generation}, current.History...)
+	if len(all) <= g.history {
+		return all, nil
+	}
+
+	kept := append([]int64{}, all[:g.history]...)
+	evicted := append([]int64{}, all[g.history:]...)
+
+	if !current.Pinned {
+		return kept, evicted
+	}
+
+	for i, gen := range evicted {
+		if gen == current.Current {
+			evicted[i] = kept[len(kept)-1]
+			kept[len(kept)-1] = gen
+			break
+		}
+	}
+	return kept, evicted
+}
+
+// remove deletes the unversioned keys, the pointer and then the generations
+// so that readers never fall back to stale unversioned config
+func (g *GenerationRepo) remove(ctx context.Context, envID string, current domain.ConfigGeneration) error {
+	if err := g.removeGeneration(ctx, envID); err != nil {
+		return err
+	}
+
+	if err := g.cache.Delete(ctx, string(domain.NewGenerationKey(envID))); err != nil {
+		return err
+	}
+
+	if current.Pinned {
+		if err := g.updatePinned(ctx, func(pinned map[string]domain.ConfigGeneration) {
+			delete(pinned, envID)
+		}); err != nil {
+			g.log.Warn("failed to remove pinned generation", "environment", envID, "err", err)
+		}
+	}
+
+	for _, gen := range current.History {
+		if err := g.removeGeneration(ctx, domain.NewGenerationEnvID(envID, gen)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeGeneration deletes the flags and segments stored under the envKey
+func (g *GenerationRepo) removeGeneration(ctx context.Context, envKey string) error {
+	var flags []domain.FeatureFlag
+	if err := g.cache.Get(ctx, string(domain.NewFeatureConfigsKey(envKey)), &flags); err == nil {
+		if err := removeFlags(ctx, g.cache, envKey, flags); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, domain.ErrCacheNotFound) {
+		return err
+	}
+
+	var segments []domain.Segment
+	if err := g.cache.Get(ctx, string(domain.NewSegmentsKey(envKey)), &segments); err == nil {
+		if err := removeSegments(ctx, g.cache, envKey, segments); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, domain.ErrCacheNotFound) {
+		return err
+	}
+	return nil
+}
+
+// updatePinned applies fn to the generations that environments are pinned to
+func (g *GenerationRepo) updatePinned(ctx context.Context, fn func(pinned map[string]domain.ConfigGeneration)) error {
+	pinned, err := g.Pinned(ctx)
+	if err != nil {
+		return err
+	}
+
+	fn(pinned)
+
+	if len(pinned) == 0 {
+		return g.cache.Delete(ctx, pinnedGenerationsKey)
+	}
+	return g.cache.Set(ctx, pinnedGenerationsKey, pinned)
+}
+
+// envKey returns the key an environment's config should be read from. This is
+// the generation the context is pinned to, the current generation or the
+// unversioned keys if the environment doesn't have a generation yet.
+func (g *GenerationRepo) envKey(ctx context.Context, envID string) string {
+	if gen, ok := ctx.Value(generationSnapshotKey{envID: envID}).(int64); ok {
+		return domain.NewGenerationEnvID(envID, gen)
+	}
+
+	gen, err := g.Get(ctx, envID)
+	if err != nil || gen.Current == 0 {
+		return envID
+	}
+	return domain.NewGenerationEnvID(envID, gen.Current)
+}
+
+// previousGeneration returns the generation that was published before the
+// current one or zero if there isn't one
+func previousGeneration(gen domain.ConfigGeneration) int64 {
+	for i, g := range gen.History {
+		if g == gen.Current && i+1 < len(gen.History) {
+			return gen.History[i+1]
+		}
+	}
+	return 0
+}
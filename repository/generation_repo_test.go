@@ -0,0 +1,290 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/harness/ff-proxy/v2/cache"
+	"github.com/harness/ff-proxy/v2/domain"
+	"github.com/harness/ff-proxy/v2/log"
+)
+
+func newGenerationRepos() (*GenerationRepo, FeatureFlagRepo, SegmentRepo, cache.Cache) {
+	c := cache.NewHashCache(cache.NewMemCache(), 1*time.Minute, 1*time.Minute)
+	g := NewGenerationRepo(c, log.NoOpLogger{})
+	return g, NewFeatureFlagRepo(c, WithFlagGenerations(g)), NewSegmentRepo(c, WithSegmentGenerations(g)), c
+}
+
+func flagIdentifiers(flags []domain.FeatureFlag) []string {
+	ids := make([]string, 0, len(flags))
+	for _, f := range flags {
+		ids = append(ids, f.Feature)
+	}
+	return ids
+}
+
+func segmentIdentifiers(segments []domain.Segment) []string {
+	ids := make([]string, 0, len(segments))
+	for _, s := range segments {
+		ids = append(ids, s.Identifier)
+	}
+	return ids
+}
+
+func TestGenerationRepo_Publish(t *testing.T) {
+	ctx := context.Background()
+	g, flagRepo, segmentRepo, c := newGenerationRepos()
+
+	t.Log("Given I publish flags and segments for an environment")
+	gen, err := g.Publish(ctx, "123", []domain.FeatureFlag{featureFlagFoo}, []domain.Segment{segmentFoo})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), gen.Current)
+	assert.Equal(t, []int64{1}, gen.History)
+
+	t.Log("Then the repos read them from the first generation")
+	flags, err := flagRepo.Get(ctx, "123")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"foo"}, flagIdentifiers(flags))
+
+	segment, err := segmentRepo.GetByIdentifier(ctx, "123", "foo")
+	assert.Nil(t, err)
+	assert.Equal(t, "foo", segment.Identifier)
+
+	assert.True(t, keyExists(ctx, c, "env-123-gen-1-feature-configs"))
+	assert.False(t, keyExists(ctx, c, "env-123-feature-configs"))
+
+	t.Log("When I publish again")
+	gen, err = g.Publish(ctx, "123", []domain.FeatureFlag{featureFlagBar}, []domain.Segment{segmentBar})
+	assert.Nil(t, err)
+	assert.Equal(t, domain.ConfigGeneration{Current: 2, History: []int64{2, 1}, UpdatedAt: gen.UpdatedAt}, gen)
+
+	t.Log("Then the repos read from the new generation and the previous one is kept")
+	flags, err = flagRepo.Get(ctx, "123")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"bar"}, flagIdentifiers(flags))
+	assert.True(t, keyExists(ctx, c, "env-123-gen-1-feature-configs"))
+
+	t.Log("When I publish a third time")
+	_, err = g.Publish(ctx, "123", []domain.FeatureFlag{featureFlagFoo}, []domain.Segment{segmentFoo})
+	assert.Nil(t, err)
+
+	t.Log("Then the first generation is removed")
+	assert.False(t, keyExists(ctx, c, "env-123-gen-1-feature-configs"))
+	assert.False(t, keyExists(ctx, c, "env-123-gen-1-feature-config-foo"))
+	assert.False(t, keyExists(ctx, c, "env-123-gen-1-segments"))
+	assert.True(t, keyExists(ctx, c, "env-123-gen-2-feature-configs"))
+}
+
+func TestGenerationRepo_PublishFlagsKeepsSegments(t *testing.T) {
+	ctx := context.Background()
+	_, flagRepo, segmentRepo, _ := newGenerationRepos()
+
+	t.Log("Given I have an environment with segments")
+	assert.Nil(t, segmentRepo.Add(ctx, domain.SegmentConfig{EnvironmentID: "123", Segments: []domain.Segment{segmentFoo, segmentBar}}))
+
+	t.Log("When I add flags")
+	assert.Nil(t, flagRepo.Add(ctx, domain.FlagConfig{EnvironmentID: "123", FeatureConfigs: []domain.FeatureFlag{featureFlagFoo}}))
+
+	t.Log("Then the new generation has the flags and the existing segments")
+	flags, err := flagRepo.Get(ctx, "123")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"foo"}, flagIdentifiers(flags))
+
+	segments, err := segmentRepo.Get(ctx, "123")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"foo", "bar"}, segmentIdentifiers(segments))
+
+	t.Log("When I remove all the segments")
+	assert.Nil(t, segmentRepo.RemoveAllSegmentsForEnvironment(ctx, "123"))
+
+	t.Log("Then the flags are still there")
+	_, err = segmentRepo.Get(ctx, "123")
+	assert.True(t, errors.Is(err, domain.ErrCacheNotFound))
+
+	flags, err = flagRepo.Get(ctx, "123")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"foo"}, flagIdentifiers(flags))
+}
+
+func TestGenerationRepo_PinAndRelease(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewHashCache(cache.NewMemCache(), 1*time.Minute, 1*time.Minute)
+	g := NewGenerationRepo(c, log.NoOpLogger{}, WithGenerationHistory(3))
+	flagRepo := NewFeatureFlagRepo(c, WithFlagGenerations(g))
+
+	t.Log("Given I have an environment with a single generation")
+	_, err := g.Publish(ctx, "123", []domain.FeatureFlag{featureFlagFoo}, nil)
+	assert.Nil(t, err)
+
+	t.Log("Then I can't pin it to a previous generation")
+	_, err = g.Pin(ctx, "123", 0)
+	assert.True(t, errors.Is(err, domain.ErrNoPreviousGeneration))
+
+	t.Log("And I can't release it because it isn't pinned")
+	_, err = g.Release(ctx, "123")
+	assert.True(t, errors.Is(err, domain.ErrEnvironmentNotPinned))
+
+	t.Log("Given I publish a second generation")
+	_, err = g.Publish(ctx, "123", []domain.FeatureFlag{featureFlagBar}, nil)
+	assert.Nil(t, err)
+
+	t.Log("Then I can't pin it to a generation that isn't kept")
+	_, err = g.Pin(ctx, "123", 7)
+	assert.True(t, errors.Is(err, domain.ErrGenerationNotFound))
+
+	t.Log("When I pin it to the previous generation")
+	gen, err := g.Pin(ctx, "123", 0)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), gen.Current)
+	assert.True(t, gen.Pinned)
+
+	t.Log("Then the flags are read from the first generation")
+	flags, err := flagRepo.Get(ctx, "123")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"foo"}, flagIdentifiers(flags))
+
+	pinned, err := g.Pinned(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), pinned["123"].Current)
+
+	t.Log("When more generations are published than are kept")
+	for i := 0; i < 3; i++ {
+		_, err = g.Publish(ctx, "123", []domain.FeatureFlag{featureFlagBar}, nil)
+		assert.Nil(t, err)
+	}
+
+	t.Log("Then the environment is still pinned and the pinned generation is kept")
+	gen, err = g.Get(ctx, "123")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), gen.Current)
+	assert.Equal(t, []int64{5, 4, 1}, gen.History)
+	assert.True(t, keyExists(ctx, c, "env-123-gen-1-feature-configs"))
+	assert.False(t, keyExists(ctx, c, "env-123-gen-2-feature-configs"))
+
+	flags, err = flagRepo.Get(ctx, "123")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"foo"}, flagIdentifiers(flags))
+
+	t.Log("When I release the environment")
+	gen, err = g.Release(ctx, "123")
+	assert.Nil(t, err)
+
+	t.Log("Then it's served from the latest generation")
+	assert.Equal(t, int64(5), gen.Current)
+	assert.False(t, gen.Pinned)
+
+	flags, err = flagRepo.Get(ctx, "123")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"bar"}, flagIdentifiers(flags))
+
+	pinned, err = g.Pinned(ctx)
+	assert.Nil(t, err)
+	assert.Empty(t, pinned)
+}
+
+func TestGenerationRepo_Snapshot(t *testing.T) {
+	ctx := context.Background()
+	g, flagRepo, _, _ := newGenerationRepos()
+
+	_, err := g.Publish(ctx, "123", []domain.FeatureFlag{featureFlagFoo}, nil)
+	assert.Nil(t, err)
+
+	t.Log("Given I take a snapshot of an environment")
+	snapshot := flagRepo.Snapshot(ctx, "123")
+
+	t.Log("When a new generation is published")
+	_, err = g.Publish(ctx, "123", []domain.FeatureFlag{featureFlagBar}, nil)
+	assert.Nil(t, err)
+
+	t.Log("Then reads with the snapshot still see the old generation")
+	flags, err := flagRepo.Get(snapshot, "123")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"foo"}, flagIdentifiers(flags))
+
+	t.Log("And reads without it see the new one")
+	flags, err = flagRepo.Get(ctx, "123")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"bar"}, flagIdentifiers(flags))
+}
+
+func TestGenerationRepo_Latest(t *testing.T) {
+	ctx := context.Background()
+	g, flagRepo, _, _ := newGenerationRepos()
+
+	_, err := g.Publish(ctx, "123", []domain.FeatureFlag{featureFlagFoo}, nil)
+	assert.Nil(t, err)
+	_, err = g.Publish(ctx, "123", []domain.FeatureFlag{featureFlagBar}, nil)
+	assert.Nil(t, err)
+
+	t.Log("Given I've pinned an environment to its previous generation")
+	_, err = g.Pin(ctx, "123", 0)
+	assert.Nil(t, err)
+
+	t.Log("When a new generation is written while it's pinned")
+	_, err = g.Publish(ctx, "123", []domain.FeatureFlag{featureFlagFoo, featureFlagBar}, nil)
+	assert.Nil(t, err)
+
+	t.Log("Then reads with the latest context see the new generation")
+	flags, err := flagRepo.Get(g.Latest(ctx, "123"), "123")
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"foo", "bar"}, flagIdentifiers(flags))
+
+	t.Log("And reads without it see the pinned generation")
+	flags, err = flagRepo.Get(ctx, "123")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"foo"}, flagIdentifiers(flags))
+}
+
+func TestGenerationRepo_Remove(t *testing.T) {
+	ctx := context.Background()
+	g, flagRepo, _, c := newGenerationRepos()
+
+	t.Log("Given I have an environment with unversioned flags and two generations")
+	assert.Nil(t, NewFeatureFlagRepo(c).Add(ctx, domain.FlagConfig{EnvironmentID: "123", FeatureConfigs: []domain.FeatureFlag{featureFlagFoo}}))
+	_, err := g.Publish(ctx, "123", []domain.FeatureFlag{featureFlagFoo}, []domain.Segment{segmentFoo})
+	assert.Nil(t, err)
+	_, err = g.Publish(ctx, "123", []domain.FeatureFlag{featureFlagBar}, nil)
+	assert.Nil(t, err)
+
+	t.Log("When I publish an empty config")
+	_, err = g.Publish(ctx, "123", nil, nil)
+	assert.Nil(t, err)
+
+	t.Log("Then everything for the environment is removed")
+	_, err = flagRepo.Get(ctx, "123")
+	assert.True(t, errors.Is(err, domain.ErrCacheNotFound))
+
+	for _, key := range []string{
+		"env-123-generation",
+		"env-123-feature-configs",
+		"env-123-feature-config-foo",
+		"env-123-gen-1-feature-configs",
+		"env-123-gen-1-segments",
+		"env-123-gen-2-feature-configs",
+		"env-123-gen-2-feature-config-bar",
+	} {
+		assert.False(t, keyExists(ctx, c, key), key)
+	}
+}
+
+func TestGenerationRepo_UnversionedFallback(t *testing.T) {
+	ctx := context.Background()
+	_, flagRepo, _, c := newGenerationRepos()
+
+	t.Log("Given flags were written without generations by an older Primary")
+	assert.Nil(t, NewFeatureFlagRepo(c).Add(ctx, domain.FlagConfig{EnvironmentID: "123", FeatureConfigs: []domain.FeatureFlag{featureFlagFoo}}))
+
+	t.Log("Then a repo using generations can still read them")
+	flag, err := flagRepo.GetByIdentifier(ctx, "123", "foo")
+	assert.Nil(t, err)
+	assert.Equal(t, "foo", flag.Feature)
+}
+
+func keyExists(ctx context.Context, c cache.Cache, key string) bool {
+	var v interface{}
+	return c.Get(ctx, key, &v) == nil
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/harness/ff-proxy/v2/cache"
 	"github.com/harness/ff-proxy/v2/domain"
+	"github.com/harness/ff-proxy/v2/hash"
 	"github.com/harness/ff-proxy/v2/log"
 )
 
@@ -28,17 +29,42 @@ var (
 
 // InventoryRepo is a repository that stores all references to all assets for the key.
 type InventoryRepo struct {
-	log   log.Logger
-	cache cache.Cache
+	log      log.Logger
+	cache    cache.Cache
+	upgrader hash.Upgrader
+
+	generations *GenerationRepo
+}
+
+// WithInventoryKeyUpgrader configures the InventoryRepo to track api key
+// hashes using the upgrader's algorithm so they match the keys in the AuthRepo
+func WithInventoryKeyUpgrader(u hash.Upgrader) func(i *InventoryRepo) {
+	return func(i *InventoryRepo) {
+		i.upgrader = u
+	}
+}
+
+// WithInventoryGenerations configures the InventoryRepo to track the generation
+// pointer for each environment so that every generation of an environment's
+// config is removed when the environment is
+func WithInventoryGenerations(g *GenerationRepo) func(i *InventoryRepo) {
+	return func(i *InventoryRepo) {
+		i.generations = g
+	}
 }
 
 // NewInventoryRepo creates new instance of inventory
-func NewInventoryRepo(c cache.Cache, l log.Logger) InventoryRepo {
+func NewInventoryRepo(c cache.Cache, l log.Logger, opts ...func(i *InventoryRepo)) InventoryRepo {
 	l = l.With("component", "InventoryRepo")
-	return InventoryRepo{
+	i := InventoryRepo{
 		cache: c,
 		log:   l,
 	}
+
+	for _, opt := range opts {
+		opt(&i)
+	}
+	return i
 }
 
 // Add sets the inventory for proxy config - list of assets for the key.
@@ -109,7 +135,7 @@ func (i InventoryRepo) Cleanup(ctx context.Context, key string, config []domain.
 				<-semaphore
 			}()
 			semaphore <- struct{}{}
-			errChan <- i.cache.Delete(ctx, k)
+			errChan <- i.deleteAsset(ctx, k)
 		}(key)
 	}
 
@@ -188,7 +214,7 @@ func (i InventoryRepo) removeAssets(ctx context.Context, assets map[string]strin
 				<-semaphore
 			}()
 			semaphore <- struct{}{}
-			errChan <- i.cache.Delete(ctx, k)
+			errChan <- i.deleteAsset(ctx, k)
 		}(key)
 	}
 
@@ -207,6 +233,17 @@ func (i InventoryRepo) removeAssets(ctx context.Context, assets map[string]strin
 	return nil
 }
 
+// deleteAsset deletes an asset from the cache. Generation pointers are removed
+// along with every generation they point at.
+func (i InventoryRepo) deleteAsset(ctx context.Context, key string) error {
+	if i.generations != nil {
+		if envID, ok := domain.EnvIDFromGenerationKey(key); ok {
+			return i.generations.Remove(ctx, envID)
+		}
+	}
+	return i.cache.Delete(ctx, key)
+}
+
 func diffAssets(oldMap, newMap map[string]string) domain.Assets {
 	deleted := make(map[string]string)
 	created := make(map[string]string)
@@ -246,9 +283,12 @@ func (i InventoryRepo) BuildAssetListFromConfig(config []domain.ProxyConfig) (ma
 			if len(env.APIKeys) > 0 {
 				inventory[string(domain.NewAPIConfigsKey(environment))] = empty
 				for _, apiKey := range env.APIKeys {
-					inventory[string(domain.NewAuthAPIKey(apiKey))] = empty
+					inventory[upgradeAuthKey(i.upgrader, string(domain.NewAuthAPIKey(apiKey)))] = empty
 				}
 			}
+			if i.generations != nil && (len(env.FeatureConfigs) > 0 || len(env.Segments) > 0) {
+				inventory[string(domain.NewGenerationKey(environment))] = empty
+			}
 			if len(env.FeatureConfigs) > 0 {
 				inventory[string(domain.NewFeatureConfigsKey(environment))] = empty
 				for _, f := range env.FeatureConfigs {
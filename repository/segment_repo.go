@@ -7,25 +7,43 @@ import (
 	"github.com/harness/ff-proxy/v2/cache"
 
 	"github.com/harness/ff-proxy/v2/domain"
+	"github.com/harness/ff-proxy/v2/tracing"
 )
 
+// WithSegmentGenerations configures the SegmentRepo to read segments from the
+// current generation of an environment's config and to publish a new
+// generation whenever segments are added
+func WithSegmentGenerations(g *GenerationRepo) func(s *SegmentRepo) {
+	return func(s *SegmentRepo) {
+		s.generations = g
+	}
+}
+
 // SegmentRepo is a repository that stores Segments
 type SegmentRepo struct {
-	cache cache.Cache
+	cache       cache.Cache
+	generations *GenerationRepo
 }
 
 // NewSegmentRepo creates a SegmentRepo. It can optionally preload the repo with data
 // from the passed config
-func NewSegmentRepo(c cache.Cache) SegmentRepo {
-	return SegmentRepo{cache: c}
+func NewSegmentRepo(c cache.Cache, opts ...func(s *SegmentRepo)) SegmentRepo {
+	s := SegmentRepo{cache: c}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	return s
 }
 
 // Get gets all of the Segments for a given key
-func (s SegmentRepo) Get(ctx context.Context, envID string) ([]domain.Segment, error) {
+func (s SegmentRepo) Get(ctx context.Context, envID string) (_ []domain.Segment, err error) {
+	ctx, span := tracing.Start(ctx, "SegmentRepo.Get", tracing.EnvironmentKey.String(envID))
+	defer func() { tracing.End(span, err) }()
+
 	var segments []domain.Segment
-	key := domain.NewSegmentsKey(envID)
+	key := domain.NewSegmentsKey(s.envKey(ctx, envID))
 
-	err := s.cache.Get(ctx, string(key), &segments)
+	err = s.cache.Get(ctx, string(key), &segments)
 	if err != nil {
 		return []domain.Segment{}, err
 	}
@@ -34,51 +52,35 @@ func (s SegmentRepo) Get(ctx context.Context, envID string) ([]domain.Segment, e
 }
 
 // GetByIdentifier gets a Segment for a given key and identifer
-func (s SegmentRepo) GetByIdentifier(ctx context.Context, envID string, identifier string) (domain.Segment, error) {
+func (s SegmentRepo) GetByIdentifier(ctx context.Context, envID string, identifier string) (_ domain.Segment, err error) {
+	ctx, span := tracing.Start(ctx, "SegmentRepo.GetByIdentifier", tracing.EnvironmentKey.String(envID))
+	defer func() { tracing.End(span, err) }()
+
 	segment := domain.Segment{}
-	key := domain.NewSegmentKey(envID, identifier)
+	key := domain.NewSegmentKey(s.envKey(ctx, envID), identifier)
 
-	if err := s.cache.Get(ctx, string(key), &segment); err != nil {
+	if err = s.cache.Get(ctx, string(key), &segment); err != nil {
 		return domain.Segment{}, err
 	}
 	return segment, nil
 }
 
 // Add stores SegmentConfig in the cache
-//
-//nolint:gocognit,cyclop,maintidx,gocyclo
-func (s SegmentRepo) Add(ctx context.Context, config ...domain.SegmentConfig) error {
+func (s SegmentRepo) Add(ctx context.Context, config ...domain.SegmentConfig) (err error) {
+	ctx, span := tracing.Start(ctx, "SegmentRepo.Add")
+	defer func() { tracing.End(span, err) }()
+
 	errs := []error{}
 
 	for _, cfg := range config {
-		k := domain.NewSegmentsKey(cfg.EnvironmentID)
-
-		for i, seg := range cfg.Segments {
-			// if segment.serving rules populated and segment.rules is not then convert serving rules to rules
-			if (seg.ServingRules != nil && len(*seg.ServingRules) > 0) && (seg.Rules == nil || len(*seg.Rules) == 0) {
-				rules := domain.ConvertServingRulesToRules(*seg.ServingRules)
-				cfg.Segments[i].Rules = &rules
+		if s.generations != nil {
+			if _, err := s.generations.PublishSegments(ctx, cfg.EnvironmentID, cfg.Segments); err != nil {
+				errs = append(errs, err)
 			}
+			continue
 		}
 
-		if err := s.cache.Set(ctx, string(k), cfg.Segments); err != nil {
-			errs = append(errs, addError{
-				key:        string(k),
-				identifier: "segments",
-				err:        err,
-			})
-		}
-
-		for _, seg := range cfg.Segments {
-			key := domain.NewSegmentKey(cfg.EnvironmentID, seg.Identifier)
-			if err := s.cache.Set(ctx, string(key), seg); err != nil {
-				errs = append(errs, addError{
-					key:        string(key),
-					identifier: seg.Identifier,
-					err:        err,
-				})
-			}
-		}
+		errs = append(errs, addSegments(ctx, s.cache, cfg.EnvironmentID, cfg.Segments)...)
 	}
 
 	if len(errs) > 0 {
@@ -91,7 +93,7 @@ func (s SegmentRepo) Add(ctx context.Context, config ...domain.SegmentConfig) er
 // GetSegmentsForEnvironment gets all the segments associated with environment id
 func (s SegmentRepo) GetSegmentsForEnvironment(ctx context.Context, envID string) ([]domain.Segment, bool) {
 	var segments []domain.Segment
-	key := domain.NewSegmentsKey(envID)
+	key := domain.NewSegmentsKey(s.envKey(ctx, envID))
 	if err := s.cache.Get(ctx, string(key), &segments); err != nil {
 		return segments, false
 	}
@@ -100,28 +102,80 @@ func (s SegmentRepo) GetSegmentsForEnvironment(ctx context.Context, envID string
 
 // RemoveAllSegmentsForEnvironment removes all segments entries for given environment id
 func (s SegmentRepo) RemoveAllSegmentsForEnvironment(ctx context.Context, id string) error {
+	if s.generations != nil {
+		_, err := s.generations.PublishSegments(ctx, id, nil)
+		return err
+	}
+
 	//get all the segments for given key
 	segments, err := s.Get(ctx, id)
 	if err != nil {
 		return err
 	}
+	return removeSegments(ctx, s.cache, id, segments)
+}
+
+// Remove removes the Segment entry from the cache
+func (s SegmentRepo) Remove(ctx context.Context, identifier string) error {
+	return s.cache.Delete(ctx, identifier)
+}
+
+func (s SegmentRepo) envKey(ctx context.Context, envID string) string {
+	if s.generations == nil {
+		return envID
+	}
+	return s.generations.envKey(ctx, envID)
+}
+
+// addSegments writes the segments list and an entry for each segment under
+// the envKey, which is either an environment ID or the ID of one of its generations
+func addSegments(ctx context.Context, c cache.Cache, envKey string, segments []domain.Segment) []error {
+	errs := []error{}
+
+	for i, seg := range segments {
+		// if segment.serving rules populated and segment.rules is not then convert serving rules to rules
+		if (seg.ServingRules != nil && len(*seg.ServingRules) > 0) && (seg.Rules == nil || len(*seg.Rules) == 0) {
+			rules := domain.ConvertServingRulesToRules(*seg.ServingRules)
+			segments[i].Rules = &rules
+		}
+	}
+
+	k := domain.NewSegmentsKey(envKey)
+	if err := c.Set(ctx, string(k), segments); err != nil {
+		errs = append(errs, addError{
+			key:        string(k),
+			identifier: "segments",
+			err:        err,
+		})
+	}
+
+	for _, seg := range segments {
+		key := domain.NewSegmentKey(envKey, seg.Identifier)
+		if err := c.Set(ctx, string(key), seg); err != nil {
+			errs = append(errs, addError{
+				key:        string(key),
+				identifier: seg.Identifier,
+				err:        err,
+			})
+		}
+	}
+	return errs
+}
+
+// removeSegments deletes the segments list and the entry for each segment under the envKey
+func removeSegments(ctx context.Context, c cache.Cache, envKey string, segments []domain.Segment) error {
 	// remove segmentConfig entry
-	sKey := domain.NewSegmentsKey(id)
-	if err := s.cache.Delete(ctx, string(sKey)); err != nil {
+	sKey := domain.NewSegmentsKey(envKey)
+	if err := c.Delete(ctx, string(sKey)); err != nil {
 		return err
 	}
 	// remove all individual segment entries for environment
 	for _, segment := range segments {
 
-		key := domain.NewSegmentKey(id, segment.Identifier)
-		if err := s.cache.Delete(ctx, string(key)); err != nil {
+		key := domain.NewSegmentKey(envKey, segment.Identifier)
+		if err := c.Delete(ctx, string(key)); err != nil {
 			return err
 		}
 	}
 	return nil
 }
-
-// Remove removes the Segment entry from the cache
-func (s SegmentRepo) Remove(ctx context.Context, identifier string) error {
-	return s.cache.Delete(ctx, identifier)
-}
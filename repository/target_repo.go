@@ -4,17 +4,31 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"time"
+
+	gocache "github.com/patrickmn/go-cache"
 
 	"github.com/harness/ff-proxy/v2/cache"
 	"github.com/harness/ff-proxy/v2/log"
 
 	"github.com/harness/ff-proxy/v2/domain"
+	"github.com/harness/ff-proxy/v2/tracing"
+)
+
+const (
+	// firstSeenExpiration is how long a Target's FirstSeen is remembered in
+	// memory after it was last touched
+	firstSeenExpiration = 10 * time.Minute
+	firstSeenCleanup    = 20 * time.Minute
 )
 
 // TargetRepo is a repository that stores Targets
 type TargetRepo struct {
-	log   log.Logger
-	cache cache.Cache
+	log       log.Logger
+	cache     cache.Cache
+	now       func() time.Time
+	firstSeen *gocache.Cache
 }
 
 // NewTargetRepo creates a TargetRepo. It can optionally preload the repo with data
@@ -22,17 +36,22 @@ type TargetRepo struct {
 func NewTargetRepo(c cache.Cache, l log.Logger) TargetRepo {
 	l = l.With("component", "TargetRepo")
 	return TargetRepo{
-		cache: c,
-		log:   l,
+		cache:     c,
+		log:       l,
+		now:       time.Now,
+		firstSeen: gocache.New(firstSeenExpiration, firstSeenCleanup),
 	}
 }
 
 // Get gets all of the Targets for a given key
-func (t TargetRepo) Get(ctx context.Context, envID string) ([]domain.Target, error) {
+func (t TargetRepo) Get(ctx context.Context, envID string) (_ []domain.Target, err error) {
+	ctx, span := tracing.Start(ctx, "TargetRepo.Get", tracing.EnvironmentKey.String(envID))
+	defer func() { tracing.End(span, err) }()
+
 	var targets []domain.Target
 	key := domain.NewTargetsKey(envID)
 
-	err := t.cache.Get(ctx, string(key), &targets)
+	err = t.cache.Get(ctx, string(key), &targets)
 	if err != nil {
 		return []domain.Target{}, err
 	}
@@ -41,16 +60,92 @@ func (t TargetRepo) Get(ctx context.Context, envID string) ([]domain.Target, err
 }
 
 // GetByIdentifier gets a Target for a given key and identifer
-func (t TargetRepo) GetByIdentifier(ctx context.Context, envID string, identifier string) (domain.Target, error) {
+func (t TargetRepo) GetByIdentifier(ctx context.Context, envID string, identifier string) (_ domain.Target, err error) {
+	ctx, span := tracing.Start(ctx, "TargetRepo.GetByIdentifier", tracing.EnvironmentKey.String(envID))
+	defer func() { tracing.End(span, err) }()
+
 	target := domain.Target{}
 	key := domain.NewTargetKey(envID, identifier)
 
-	if err := t.cache.Get(ctx, string(key), &target); err != nil {
+	if err = t.cache.Get(ctx, string(key), &target); err != nil {
 		return domain.Target{}, err
 	}
 	return target, nil
 }
 
+// List gets every Target that the Proxy has seen in an environment, sorted by identifier
+func (t TargetRepo) List(ctx context.Context, envID string) ([]domain.Target, error) {
+	// Scan iterates over the keys in batches rather than blocking redis the
+	// way KEYS does while it looks at every key
+	keys, err := t.cache.Scan(ctx, domain.NewTargetKeysPattern(envID))
+	if err != nil {
+		return []domain.Target{}, err
+	}
+
+	targets := make([]domain.Target, 0, len(keys))
+	for key := range keys {
+		target := domain.Target{}
+		if err := t.cache.Get(ctx, key, &target); err != nil {
+			// The Target may have been pruned since we got the keys
+			if errors.Is(err, domain.ErrCacheNotFound) {
+				continue
+			}
+			return []domain.Target{}, err
+		}
+		targets = append(targets, target)
+	}
+
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].Identifier < targets[j].Identifier
+	})
+	return targets, nil
+}
+
+// Prune removes Targets in every environment that haven't been seen since the
+// cutoff and returns the number of Targets removed. Targets that were added
+// before we started tracking when they were last seen have their LastSeen set
+// to now so they're pruned once they've gone unseen for the full retention period.
+func (t TargetRepo) Prune(ctx context.Context, cutoff time.Time) (int, error) {
+	keys, err := t.cache.Scan(ctx, domain.NewTargetKeysPattern("*"))
+	if err != nil {
+		return 0, err
+	}
+
+	now := t.now().UnixMilli()
+
+	pruned := 0
+	for key := range keys {
+		target := domain.Target{}
+		if err := t.cache.Get(ctx, key, &target); err != nil {
+			if errors.Is(err, domain.ErrCacheNotFound) {
+				continue
+			}
+			return pruned, err
+		}
+
+		if target.LastSeen == 0 {
+			target.FirstSeen = now
+			target.LastSeen = now
+			if err := t.cache.Set(ctx, key, target); err != nil {
+				return pruned, err
+			}
+			continue
+		}
+
+		if target.LastSeen >= cutoff.UnixMilli() {
+			continue
+		}
+
+		if err := t.cache.Delete(ctx, key); err != nil {
+			return pruned, err
+		}
+		t.firstSeen.Delete(key)
+		pruned++
+	}
+
+	return pruned, nil
+}
+
 // DeltaAdd adds new Targets and updates existing Targets for the given key if they
 // exist in the cache. It will remove any existing Targets from the cache that are not
 // in the list of new Targets. If you pass it an empty list of Targets it will return
@@ -58,7 +153,10 @@ func (t TargetRepo) GetByIdentifier(ctx context.Context, envID string, identifie
 // all of the Targets for a given key then we should add an explicit Remove method
 // that calls cache.Remove.
 // nolint:cyclop
-func (t TargetRepo) DeltaAdd(ctx context.Context, envID string, targets ...domain.Target) error {
+func (t TargetRepo) DeltaAdd(ctx context.Context, envID string, targets ...domain.Target) (err error) {
+	ctx, span := tracing.Start(ctx, "TargetRepo.DeltaAdd", tracing.EnvironmentKey.String(envID))
+	defer func() { tracing.End(span, err) }()
+
 	if len(targets) == 0 {
 		return fmt.Errorf("can't perform DeltaAdd with zero targets for environment %s", envID)
 	}
@@ -66,7 +164,7 @@ func (t TargetRepo) DeltaAdd(ctx context.Context, envID string, targets ...domai
 	key := domain.NewTargetsKey(envID)
 	var results []domain.Target
 
-	err := t.cache.Get(ctx, string(key), &results)
+	err = t.cache.Get(ctx, string(key), &results)
 	if err != nil {
 		// If the key doesn't already exist in the cache we will want to add it
 		if !errors.Is(err, domain.ErrCacheNotFound) {
@@ -79,8 +177,13 @@ func (t TargetRepo) DeltaAdd(ctx context.Context, envID string, targets ...domai
 		existingTargets[t.Identifier] = t
 	}
 
+	now := t.now().UnixMilli()
+
+	touched := make([]domain.Target, 0, len(targets))
 	newTargets := make(map[string]domain.Target, len(results))
 	for _, target := range targets {
+		target = t.touch(ctx, envID, target, existingTargets, now)
+		touched = append(touched, target)
 		newTargets[target.Identifier] = target
 
 		if err := t.addTarget(ctx, envID, target); err != nil {
@@ -105,7 +208,42 @@ func (t TargetRepo) DeltaAdd(ctx context.Context, envID string, targets ...domai
 		}
 	}
 
-	return t.addTargets(ctx, envID, targets...)
+	return t.addTargets(ctx, envID, touched...)
+}
+
+// touch sets the Target's LastSeen to now and keeps its FirstSeen. FirstSeen
+// comes from the Targets list we've already loaded or is remembered in memory,
+// so the cached copy of the Target is only read if it hasn't been touched in
+// the last firstSeenExpiration rather than on every request.
+func (t TargetRepo) touch(ctx context.Context, envID string, target domain.Target, existingTargets map[string]domain.Target, now int64) domain.Target {
+	target.LastSeen = now
+
+	key := string(domain.NewTargetKey(envID, target.Identifier))
+	target.FirstSeen = t.firstSeenAt(ctx, envID, key, target.Identifier, existingTargets, now)
+	t.firstSeen.SetDefault(key, target.FirstSeen)
+
+	return target
+}
+
+// firstSeenAt looks up when the Target was first seen, falling back to now
+// if it's never been seen before
+func (t TargetRepo) firstSeenAt(ctx context.Context, envID string, key string, identifier string, existingTargets map[string]domain.Target, now int64) int64 {
+	if existing, ok := existingTargets[identifier]; ok && existing.FirstSeen != 0 {
+		return existing.FirstSeen
+	}
+
+	if firstSeen, ok := t.firstSeen.Get(key); ok {
+		if ts, ok := firstSeen.(int64); ok {
+			return ts
+		}
+	}
+
+	existing, err := t.GetByIdentifier(ctx, envID, identifier)
+	if err == nil && existing.FirstSeen != 0 {
+		return existing.FirstSeen
+	}
+
+	return now
 }
 
 // Add adds a target or multiple targets to the given key
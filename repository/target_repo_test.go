@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/harness/ff-proxy/v2/cache"
 	"github.com/harness/ff-proxy/v2/domain"
@@ -100,6 +101,8 @@ var (
 )
 
 func TestTargetRepo_GetByIdentifer(t *testing.T) {
+	const now = int64(1700000000000)
+
 	emptyConfig := []domain.Target{}
 	populatedConfig := []domain.Target{targetFoo, targetBar}
 
@@ -127,7 +130,7 @@ func TestTargetRepo_GetByIdentifer(t *testing.T) {
 			envID:       "123",
 			identifier:  "foo",
 			shouldErr:   false,
-			expected:    targetFoo,
+			expected:    seenAt(targetFoo, now),
 			expectedErr: nil,
 		},
 		"Given I have a populated cache and I try to get an identifier that isn't in the cache": {
@@ -147,6 +150,7 @@ func TestTargetRepo_GetByIdentifer(t *testing.T) {
 			ctx := context.Background()
 
 			repo := NewTargetRepo(tc.cache, log.NewNoOpLogger())
+			repo.now = func() time.Time { return time.UnixMilli(now) }
 			if len(tc.repoConfig) > 0 {
 				assert.Nil(t, repo.DeltaAdd(ctx, tc.envID, tc.repoConfig...))
 			}
@@ -163,7 +167,16 @@ func TestTargetRepo_GetByIdentifer(t *testing.T) {
 	}
 }
 
+// seenAt returns a copy of the Target with its FirstSeen and LastSeen set
+func seenAt(target domain.Target, ts int64) domain.Target {
+	target.FirstSeen = ts
+	target.LastSeen = ts
+	return target
+}
+
 func TestTargetRepo_DeltaAdd(t *testing.T) {
+	const now = int64(1700000000000)
+
 	target1 := domain.Target{
 		Target: clientgen.Target{
 			Identifier:  "target1",
@@ -222,7 +235,7 @@ func TestTargetRepo_DeltaAdd(t *testing.T) {
 			repoConfig: []domain.Target{},
 			env:        "123",
 			targets:    []domain.Target{target1, target2},
-			expected:   []domain.Target{target1, target2},
+			expected:   []domain.Target{seenAt(target1, now), seenAt(target2, now)},
 			shouldErr:  false,
 		},
 		"Given I have a TargetRepo with Target3 and I add Target1 and Target1": {
@@ -230,7 +243,7 @@ func TestTargetRepo_DeltaAdd(t *testing.T) {
 			repoConfig: []domain.Target{target3},
 			env:        "123",
 			targets:    []domain.Target{target1, target2},
-			expected:   []domain.Target{target1, target2},
+			expected:   []domain.Target{seenAt(target1, now), seenAt(target2, now)},
 			shouldErr:  false,
 		},
 		"Given I have a TargetRepo with two Targets and I add the same Targets with a different Project value ": {
@@ -238,7 +251,7 @@ func TestTargetRepo_DeltaAdd(t *testing.T) {
 			repoConfig: []domain.Target{target1, target2},
 			env:        "123",
 			targets:    []domain.Target{target1ProjectBar, target2ProjectBar},
-			expected:   []domain.Target{target1ProjectBar, target2ProjectBar},
+			expected:   []domain.Target{seenAt(target1ProjectBar, now), seenAt(target2ProjectBar, now)},
 			shouldErr:  false,
 		},
 		"Given I have a TargetRepo with two Targets and I try to add no Targets": {
@@ -246,7 +259,7 @@ func TestTargetRepo_DeltaAdd(t *testing.T) {
 			repoConfig: []domain.Target{target1, target2},
 			env:        "123",
 			targets:    []domain.Target{},
-			expected:   []domain.Target{target1, target2},
+			expected:   []domain.Target{seenAt(target1, now), seenAt(target2, now)},
 			shouldErr:  true,
 		},
 	}
@@ -258,6 +271,7 @@ func TestTargetRepo_DeltaAdd(t *testing.T) {
 			ctx := context.Background()
 
 			repo := NewTargetRepo(tc.cache, log.NewNoOpLogger())
+			repo.now = func() time.Time { return time.UnixMilli(now) }
 			if len(tc.repoConfig) > 0 {
 				assert.Nil(t, repo.DeltaAdd(ctx, tc.env, tc.repoConfig...))
 			}
@@ -277,3 +291,152 @@ func TestTargetRepo_DeltaAdd(t *testing.T) {
 		})
 	}
 }
+
+func TestTargetRepo_DeltaAdd_SeenTimestamps(t *testing.T) {
+	ctx := context.Background()
+
+	target1 := domain.Target{Target: clientgen.Target{Identifier: "target1", Name: "target1"}}
+	target2 := domain.Target{Target: clientgen.Target{Identifier: "target2", Name: "target2"}}
+
+	repo := NewTargetRepo(cache.NewMemCache(), log.NewNoOpLogger())
+
+	repo.now = func() time.Time { return time.UnixMilli(1000) }
+	assert.Nil(t, repo.DeltaAdd(ctx, "123", target1))
+
+	repo.now = func() time.Time { return time.UnixMilli(2000) }
+	assert.Nil(t, repo.DeltaAdd(ctx, "123", target1))
+	assert.Nil(t, repo.DeltaAdd(ctx, "123", target2))
+
+	actual, err := repo.GetByIdentifier(ctx, "123", "target1")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1000), actual.FirstSeen)
+	assert.Equal(t, int64(2000), actual.LastSeen)
+
+	actual, err = repo.GetByIdentifier(ctx, "123", "target2")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2000), actual.FirstSeen)
+	assert.Equal(t, int64(2000), actual.LastSeen)
+}
+
+// getCounter is a cache that counts how many times each key is read
+type getCounter struct {
+	cache.Cache
+	gets map[string]int
+}
+
+func (g *getCounter) Get(ctx context.Context, key string, v interface{}) error {
+	g.gets[key]++
+	return g.Cache.Get(ctx, key, v)
+}
+
+func TestTargetRepo_DeltaAdd_FirstSeenReads(t *testing.T) {
+	ctx := context.Background()
+
+	target1 := domain.Target{Target: clientgen.Target{Identifier: "target1", Name: "target1"}}
+	target2 := domain.Target{Target: clientgen.Target{Identifier: "target2", Name: "target2"}}
+
+	c := &getCounter{Cache: cache.NewMemCache(), gets: map[string]int{}}
+	repo := NewTargetRepo(c, log.NewNoOpLogger())
+
+	t.Log("Given I add target1 and then target2 so target1 is no longer in the targets list")
+	repo.now = func() time.Time { return time.UnixMilli(1000) }
+	assert.Nil(t, repo.DeltaAdd(ctx, "123", target1))
+	assert.Nil(t, repo.DeltaAdd(ctx, "123", target2))
+
+	t.Log("When I add target1 again a number of times")
+	repo.now = func() time.Time { return time.UnixMilli(2000) }
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, repo.DeltaAdd(ctx, "123", target1))
+		assert.Nil(t, repo.DeltaAdd(ctx, "123", target2))
+	}
+
+	t.Log("Then target1's FirstSeen is kept")
+	actual, err := repo.GetByIdentifier(ctx, "123", "target1")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1000), actual.FirstSeen)
+	assert.Equal(t, int64(2000), actual.LastSeen)
+
+	t.Log("And the individual Targets are only read once when they're first touched")
+	assert.Equal(t, 2, c.gets[string(domain.NewTargetKey("123", "target1"))])
+	assert.Equal(t, 1, c.gets[string(domain.NewTargetKey("123", "target2"))])
+}
+
+func TestTargetRepo_List(t *testing.T) {
+	ctx := context.Background()
+
+	target1 := domain.Target{Target: clientgen.Target{Identifier: "target1", Name: "target1"}}
+	target2 := domain.Target{Target: clientgen.Target{Identifier: "target2", Name: "target2"}}
+	target3 := domain.Target{Target: clientgen.Target{Identifier: "target3", Name: "target3"}}
+
+	repo := NewTargetRepo(cache.NewMemCache(), log.NewNoOpLogger())
+	repo.now = func() time.Time { return time.UnixMilli(1000) }
+
+	// DeltaAdd is called with a single Target during auth so add them one at
+	// a time to make sure List doesn't just return the last Targets added
+	assert.Nil(t, repo.DeltaAdd(ctx, "123", target2))
+	assert.Nil(t, repo.DeltaAdd(ctx, "123", target1))
+	assert.Nil(t, repo.DeltaAdd(ctx, "456", target3))
+
+	testCases := map[string]struct {
+		envID    string
+		expected []domain.Target
+	}{
+		"Given I list Targets for an environment with two Targets": {
+			envID:    "123",
+			expected: []domain.Target{seenAt(target1, 1000), seenAt(target2, 1000)},
+		},
+		"Given I list Targets for an environment with one Target": {
+			envID:    "456",
+			expected: []domain.Target{seenAt(target3, 1000)},
+		},
+		"Given I list Targets for an environment with no Targets": {
+			envID:    "789",
+			expected: []domain.Target{},
+		},
+	}
+
+	for desc, tc := range testCases {
+		tc := tc
+
+		t.Run(desc, func(t *testing.T) {
+			actual, err := repo.List(ctx, tc.envID)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestTargetRepo_Prune(t *testing.T) {
+	ctx := context.Background()
+
+	oldTarget := domain.Target{Target: clientgen.Target{Identifier: "old", Name: "old"}}
+	newTarget := domain.Target{Target: clientgen.Target{Identifier: "new", Name: "new"}}
+	legacyTarget := domain.Target{Target: clientgen.Target{Identifier: "legacy", Name: "legacy"}}
+
+	c := cache.NewMemCache()
+	repo := NewTargetRepo(c, log.NewNoOpLogger())
+
+	repo.now = func() time.Time { return time.UnixMilli(1000) }
+	assert.Nil(t, repo.DeltaAdd(ctx, "123", oldTarget))
+
+	repo.now = func() time.Time { return time.UnixMilli(5000) }
+	assert.Nil(t, repo.DeltaAdd(ctx, "456", newTarget))
+
+	// Simulate a Target that was cached before we tracked when Targets were seen
+	assert.Nil(t, c.Set(ctx, string(domain.NewTargetKey("123", "legacy")), legacyTarget))
+
+	pruned, err := repo.Prune(ctx, time.UnixMilli(3000))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, pruned)
+
+	_, err = repo.GetByIdentifier(ctx, "123", "old")
+	assert.ErrorIs(t, err, domain.ErrCacheNotFound)
+
+	actual, err := repo.GetByIdentifier(ctx, "456", "new")
+	assert.Nil(t, err)
+	assert.Equal(t, seenAt(newTarget, 5000), actual)
+
+	actual, err = repo.GetByIdentifier(ctx, "123", "legacy")
+	assert.Nil(t, err)
+	assert.Equal(t, seenAt(legacyTarget, 5000), actual)
+}
@@ -0,0 +1,153 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/harness/ff-proxy/v2/log"
+)
+
+var (
+	// ErrNotFound is the error returned by a Provider when a secret doesn't exist
+	ErrNotFound = errors.New("secret not found")
+
+	// ErrEmpty is the error returned when a secret exists but has no value
+	ErrEmpty = errors.New("secret is empty")
+)
+
+// Provider is a store that secrets can be read from
+type Provider interface {
+	// Get returns the current value of the secret with the given name
+	Get(ctx context.Context, name string) (string, error)
+}
+
+// FileProvider is a Provider that reads secrets from files, e.g. Kubernetes or
+// Docker secrets that are mounted into the container. The name of a secret is
+// the path to its file.
+type FileProvider struct{}
+
+// NewFileProvider creates a FileProvider
+func NewFileProvider() FileProvider {
+	return FileProvider{}
+}
+
+// Get reads the secret from the file at path, trailing newlines and whitespace are trimmed
+func (f FileProvider) Get(_ context.Context, path string) (string, error) {
+	// #nosec G304
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("%w: %s", ErrNotFound, path)
+		}
+		return "", err
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
+
+// Secret is a secret that's read from a Provider and can be kept up to date
+// with it so that rotated secrets are picked up without a restart
+type Secret struct {
+	log      log.Logger
+	name     string
+	provider Provider
+
+	mtx      *sync.RWMutex
+	value    string
+	onChange []func(value string)
+}
+
+// NewSecret reads the secret from the Provider, it errors if the secret
+// doesn't exist or is empty
+func NewSecret(ctx context.Context, l log.Logger, p Provider, name string) (*Secret, error) {
+	s := &Secret{
+		log:      l.With("component", "Secret", "secret", name),
+		name:     name,
+		provider: p,
+		mtx:      &sync.RWMutex{},
+	}
+
+	value, err := s.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.value = value
+
+	return s, nil
+}
+
+// Value returns the current value of the secret
+func (s *Secret) Value() string {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return s.value
+}
+
+// OnChange registers a function that's called with the new value whenever the
+// secret changes
+func (s *Secret) OnChange(fn func(value string)) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.onChange = append(s.onChange, fn)
+}
+
+// Watch kicks off a goroutine that re-reads the secret at the interval and
+// calls the OnChange functions if it's changed. If the secret can't be read
+// the last value is kept.
+func (s *Secret) Watch(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.Refresh(ctx); err != nil {
+					s.log.Error("failed to refresh secret, the previous value will continue to be used", "err", err)
+				}
+			}
+		}
+	}()
+}
+
+// Refresh re-reads the secret and calls the OnChange functions if it's changed
+func (s *Secret) Refresh(ctx context.Context) error {
+	value, err := s.get(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mtx.Lock()
+	if value == s.value {
+		s.mtx.Unlock()
+		return nil
+	}
+	s.value = value
+	onChange := append([]func(string){}, s.onChange...)
+	s.mtx.Unlock()
+
+	s.log.Info("secret changed")
+	for _, fn := range onChange {
+		fn(value)
+	}
+	return nil
+}
+
+func (s *Secret) get(ctx context.Context) (string, error) {
+	value, err := s.provider.Get(ctx, s.name)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret %s: %w", s.name, err)
+	}
+
+	if value == "" {
+		return "", fmt.Errorf("%w: %s", ErrEmpty, s.name)
+	}
+	return value, nil
+}
@@ -0,0 +1,94 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/harness/ff-proxy/v2/log"
+)
+
+func TestFileProvider_Get(t *testing.T) {
+	dir := t.TempDir()
+
+	secretPath := filepath.Join(dir, "auth-secret")
+	assert.Nil(t, os.WriteFile(secretPath, []byte("foobar\n"), 0o600))
+
+	testCases := map[string]struct {
+		path        string
+		expected    string
+		expectedErr error
+	}{
+		"Given I read a file that exists": {
+			path:     secretPath,
+			expected: "foobar",
+		},
+		"Given I read a file that doesn't exist": {
+			path:        filepath.Join(dir, "missing"),
+			expectedErr: ErrNotFound,
+		},
+	}
+
+	for desc, tc := range testCases {
+		desc := desc
+		tc := tc
+
+		t.Run(desc, func(t *testing.T) {
+			actual, err := NewFileProvider().Get(context.Background(), tc.path)
+			assert.True(t, errors.Is(err, tc.expectedErr), desc)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	dir := t.TempDir()
+
+	emptyPath := filepath.Join(dir, "empty")
+	assert.Nil(t, os.WriteFile(emptyPath, []byte("\n"), 0o600))
+
+	_, err := NewSecret(context.Background(), log.NoOpLogger{}, NewFileProvider(), emptyPath)
+	assert.True(t, errors.Is(err, ErrEmpty))
+
+	_, err = NewSecret(context.Background(), log.NoOpLogger{}, NewFileProvider(), filepath.Join(dir, "missing"))
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestSecret_Watch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	path := filepath.Join(t.TempDir(), "proxy-key")
+	assert.Nil(t, os.WriteFile(path, []byte("key-1"), 0o600))
+
+	secret, err := NewSecret(ctx, log.NoOpLogger{}, NewFileProvider(), path)
+	assert.Nil(t, err)
+	assert.Equal(t, "key-1", secret.Value())
+
+	changed := make(chan string, 1)
+	secret.OnChange(func(value string) {
+		changed <- value
+	})
+	secret.Watch(ctx, 10*time.Millisecond)
+
+	t.Log("When the file is updated the new value is picked up")
+	assert.Nil(t, os.WriteFile(path, []byte("key-2\n"), 0o600))
+
+	select {
+	case value := <-changed:
+		assert.Equal(t, "key-2", value)
+		assert.Equal(t, "key-2", secret.Value())
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the secret to change")
+	}
+
+	t.Log("When the file is removed the last value is kept")
+	assert.Nil(t, os.Remove(path))
+	assert.NotNil(t, secret.Refresh(ctx))
+	assert.Equal(t, "key-2", secret.Value())
+}
@@ -0,0 +1,144 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// VaultProvider is a Provider that reads secrets from a HashiCorp Vault KV
+// secrets engine. The name of a secret is its path and the field to read in
+// the format '<path>#<field>', e.g. 'ff-proxy#auth_secret'.
+type VaultProvider struct {
+	addr      string
+	token     string
+	mount     string
+	kvVersion int
+	client    *http.Client
+}
+
+// WithVaultMount sets the path the KV secrets engine is mounted at, it defaults to 'secret'
+func WithVaultMount(mount string) func(v *VaultProvider) {
+	return func(v *VaultProvider) {
+		v.mount = strings.Trim(mount, "/")
+	}
+}
+
+// WithVaultKVVersion sets the version of the KV secrets engine, it defaults to 2
+func WithVaultKVVersion(version int) func(v *VaultProvider) {
+	return func(v *VaultProvider) {
+		v.kvVersion = version
+	}
+}
+
+// WithVaultHTTPClient sets the http client used to make requests to Vault
+func WithVaultHTTPClient(c *http.Client) func(v *VaultProvider) {
+	return func(v *VaultProvider) {
+		v.client = c
+	}
+}
+
+// NewVaultProvider creates a VaultProvider that authenticates with the token
+func NewVaultProvider(addr string, token string, opts ...func(v *VaultProvider)) (VaultProvider, error) {
+	v := VaultProvider{
+		addr:      strings.TrimRight(addr, "/"),
+		token:     token,
+		mount:     "secret",
+		kvVersion: 2,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+
+	for _, opt := range opts {
+		opt(&v)
+	}
+
+	if v.addr == "" {
+		return VaultProvider{}, fmt.Errorf("vault address is required")
+	}
+	if v.token == "" {
+		return VaultProvider{}, fmt.Errorf("vault token is required")
+	}
+	if v.kvVersion != 1 && v.kvVersion != 2 {
+		return VaultProvider{}, fmt.Errorf("unsupported vault kv version %d, valid options are 1 & 2", v.kvVersion)
+	}
+
+	return v, nil
+}
+
+// Get reads a field from a Vault secret
+func (v VaultProvider) Get(ctx context.Context, name string) (string, error) {
+	path, field, ok := strings.Cut(name, "#")
+	if !ok || path == "" || field == "" {
+		return "", fmt.Errorf("invalid vault secret %q, expected <path>#<field>", name)
+	}
+
+	url := fmt.Sprintf("%s/v1/%s/%s", v.addr, v.mount, strings.Trim(path, "/"))
+	if v.kvVersion == 2 {
+		url = fmt.Sprintf("%s/v1/%s/data/%s", v.addr, v.mount, strings.Trim(path, "/"))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret from vault: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("failed to read secret from vault: status code %d: %s", resp.StatusCode, b)
+	}
+
+	data, err := v.decode(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode vault response: %s", err)
+	}
+
+	value, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("vault secret %q isn't a string", name)
+	}
+	return s, nil
+}
+
+// decode returns the secret's key value pairs, with v2 of the KV engine they're
+// nested inside a second data field alongside the secret's metadata
+func (v VaultProvider) decode(r io.Reader) (map[string]interface{}, error) {
+	if v.kvVersion == 1 {
+		var body struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		if err := json.NewDecoder(r).Decode(&body); err != nil {
+			return nil, err
+		}
+		return body.Data, nil
+	}
+
+	var body struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(r).Decode(&body); err != nil {
+		return nil, err
+	}
+	return body.Data.Data, nil
+}
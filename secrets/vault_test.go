@@ -0,0 +1,207 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/harness/ff-proxy/v2/log"
+)
+
+const devRootToken = "root"
+
+// vaultDevServer is a stand-in for a Vault server running in dev mode, which
+// has a KV v2 secrets engine mounted at 'secret' and a fixed root token
+type vaultDevServer struct {
+	*httptest.Server
+
+	mtx     *sync.Mutex
+	secrets map[string]map[string]interface{}
+}
+
+func newVaultDevServer(t *testing.T) *vaultDevServer {
+	v := &vaultDevServer{
+		mtx:     &sync.Mutex{},
+		secrets: map[string]map[string]interface{}{},
+	}
+
+	v.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != devRootToken {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+
+		path, ok := strings.CutPrefix(r.URL.Path, "/v1/secret/data/")
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		v.mtx.Lock()
+		data, ok := v.secrets[path]
+		v.mtx.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[]}`))
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{
+				"data":     data,
+				"metadata": map[string]interface{}{"version": 1},
+			},
+		})
+	}))
+	t.Cleanup(v.Close)
+
+	return v
+}
+
+func (v *vaultDevServer) put(path string, data map[string]interface{}) {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	v.secrets[path] = data
+}
+
+func TestVaultProvider_Get(t *testing.T) {
+	vault := newVaultDevServer(t)
+	vault.put("ff-proxy", map[string]interface{}{
+		"auth_secret": "foobar",
+		"port":        7000,
+	})
+
+	testCases := map[string]struct {
+		token       string
+		name        string
+		expected    string
+		shouldErr   bool
+		expectedErr error
+	}{
+		"Given I get a field that exists": {
+			token:    devRootToken,
+			name:     "ff-proxy#auth_secret",
+			expected: "foobar",
+		},
+		"Given I get a field that doesn't exist": {
+			token:       devRootToken,
+			name:        "ff-proxy#proxy_key",
+			shouldErr:   true,
+			expectedErr: ErrNotFound,
+		},
+		"Given I get a secret that doesn't exist": {
+			token:       devRootToken,
+			name:        "other#auth_secret",
+			shouldErr:   true,
+			expectedErr: ErrNotFound,
+		},
+		"Given I get a field that isn't a string": {
+			token:     devRootToken,
+			name:      "ff-proxy#port",
+			shouldErr: true,
+		},
+		"Given I get a secret without a field": {
+			token:     devRootToken,
+			name:      "ff-proxy",
+			shouldErr: true,
+		},
+		"Given I use an invalid token": {
+			token:     "foo",
+			name:      "ff-proxy#auth_secret",
+			shouldErr: true,
+		},
+	}
+
+	for desc, tc := range testCases {
+		desc := desc
+		tc := tc
+
+		t.Run(desc, func(t *testing.T) {
+			provider, err := NewVaultProvider(vault.URL, tc.token)
+			assert.Nil(t, err)
+
+			actual, err := provider.Get(context.Background(), tc.name)
+			if (err != nil) != tc.shouldErr {
+				t.Errorf("(%s): error = %v, shouldErr = %v", desc, err, tc.shouldErr)
+			}
+
+			if tc.expectedErr != nil {
+				assert.True(t, errors.Is(err, tc.expectedErr))
+			}
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestVaultProvider_SecretRotation(t *testing.T) {
+	ctx := context.Background()
+
+	vault := newVaultDevServer(t)
+	vault.put("ff-proxy", map[string]interface{}{"auth_secret": "foobar"})
+
+	provider, err := NewVaultProvider(vault.URL, devRootToken)
+	assert.Nil(t, err)
+
+	secret, err := NewSecret(ctx, log.NoOpLogger{}, provider, "ff-proxy#auth_secret")
+	assert.Nil(t, err)
+	assert.Equal(t, "foobar", secret.Value())
+
+	changes := []string{}
+	secret.OnChange(func(value string) {
+		changes = append(changes, value)
+	})
+
+	t.Log("When the secret is rotated in vault the new value is picked up")
+	vault.put("ff-proxy", map[string]interface{}{"auth_secret": "helloworld"})
+	assert.Nil(t, secret.Refresh(ctx))
+	assert.Equal(t, "helloworld", secret.Value())
+	assert.Equal(t, []string{"helloworld"}, changes)
+}
+
+func TestNewVaultProvider(t *testing.T) {
+	testCases := map[string]struct {
+		addr      string
+		token     string
+		opts      []func(v *VaultProvider)
+		shouldErr bool
+	}{
+		"Given I have an address and a token": {
+			addr:  "http://localhost:8200",
+			token: devRootToken,
+		},
+		"Given I don't have an address": {
+			token:     devRootToken,
+			shouldErr: true,
+		},
+		"Given I don't have a token": {
+			addr:      "http://localhost:8200",
+			shouldErr: true,
+		},
+		"Given I use an unsupported kv version": {
+			addr:      "http://localhost:8200",
+			token:     devRootToken,
+			opts:      []func(v *VaultProvider){WithVaultKVVersion(3)},
+			shouldErr: true,
+		},
+	}
+
+	for desc, tc := range testCases {
+		desc := desc
+		tc := tc
+
+		t.Run(desc, func(t *testing.T) {
+			_, err := NewVaultProvider(tc.addr, tc.token, tc.opts...)
+			if (err != nil) != tc.shouldErr {
+				t.Errorf("(%s): error = %v, shouldErr = %v", desc, err, tc.shouldErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,522 @@
+package settings
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+
+	"github.com/harness/ff-proxy/v2/token"
+)
+
+const (
+	// FormatYAML is the format for YAML config files
+	FormatYAML = "yaml"
+	// FormatTOML is the format for TOML config files
+	FormatTOML = "toml"
+)
+
+// Config is the Proxy's config file. Every option maps to one of the Proxy's
+// flags using the flag tag and options that are nil haven't been set.
+type Config struct {
+	Proxy   Proxy   `yaml:"proxy,omitempty" toml:"proxy,omitempty"`
+	Server  Server  `yaml:"server,omitempty" toml:"server,omitempty"`
+	Cache   Cache   `yaml:"cache,omitempty" toml:"cache,omitempty"`
+	Stream  Stream  `yaml:"stream,omitempty" toml:"stream,omitempty"`
+	Metrics Metrics `yaml:"metrics,omitempty" toml:"metrics,omitempty"`
+	Auth    Auth    `yaml:"auth,omitempty" toml:"auth,omitempty"`
+	Audit   Audit   `yaml:"audit,omitempty" toml:"audit,omitempty"`
+	Tracing Tracing `yaml:"tracing,omitempty" toml:"tracing,omitempty"`
+}
+
+// Proxy contains the options for how the Proxy runs and connects to Harness SaaS
+type Proxy struct {
+	ProxyKey                *string               `yaml:"proxyKey,omitempty" toml:"proxyKey,omitempty" flag:"proxy-key" secret:"true"`
+	ProxyKeyFile            *string               `yaml:"proxyKeyFile,omitempty" toml:"proxyKeyFile,omitempty" flag:"proxy-key-file"`
+	ClientService           *string               `yaml:"clientService,omitempty" toml:"clientService,omitempty" flag:"client-service"`
+	MetricService           *string               `yaml:"metricService,omitempty" toml:"metricService,omitempty" flag:"metric-service"`
+	ReadReplica             *bool                 `yaml:"readReplica,omitempty" toml:"readReplica,omitempty" flag:"readReplica"`
+	Offline                 *bool                 `yaml:"offline,omitempty" toml:"offline,omitempty" flag:"offline"`
+	ConfigDir               *string               `yaml:"configDir,omitempty" toml:"configDir,omitempty" flag:"config-dir"`
+	GenerateOfflineConfig   *bool                 `yaml:"generateOfflineConfig,omitempty" toml:"generateOfflineConfig,omitempty" flag:"generate-offline-config"`
+	ForwardTargets          *bool                 `yaml:"forwardTargets,omitempty" toml:"forwardTargets,omitempty" flag:"forward-targets"`
+	TargetRetentionDays     *int                  `yaml:"targetRetentionDays,omitempty" toml:"targetRetentionDays,omitempty" flag:"target-retention-days"`
+	HeartbeatInterval       *int                  `yaml:"heartbeatInterval,omitempty" toml:"heartbeatInterval,omitempty" flag:"heartbeat-interval"`
+	LogLevel                *string               `yaml:"logLevel,omitempty" toml:"logLevel,omitempty" flag:"log-level"`
+	Pprof                   *bool                 `yaml:"pprof,omitempty" toml:"pprof,omitempty" flag:"pprof"`
+	GCPProfilerEnabled      *bool                 `yaml:"gcpProfilerEnabled,omitempty" toml:"gcpProfilerEnabled,omitempty" flag:"gcp-profiler-enabled"`
+	AndRules                *bool                 `yaml:"andRules,omitempty" toml:"andRules,omitempty" flag:"and-rules"`
+	ConfigGenerations       *bool                 `yaml:"configGenerations,omitempty" toml:"configGenerations,omitempty" flag:"config-generations"`
+	ConfigGenerationHistory *int                  `yaml:"configGenerationHistory,omitempty" toml:"configGenerationHistory,omitempty" flag:"config-generation-history"`
+	Secrets                 Secrets               `yaml:"secrets,omitempty" toml:"secrets,omitempty"`
+	Outbound                Outbound              `yaml:"outbound,omitempty" toml:"outbound,omitempty"`
+	ClientServiceRequests   ClientServiceRequests `yaml:"clientServiceRequests,omitempty" toml:"clientServiceRequests,omitempty"`
+	ReplicaLocalCache       ReplicaLocalCache     `yaml:"replicaLocalCache,omitempty" toml:"replicaLocalCache,omitempty"`
+}
+
+// ReplicaLocalCache contains the options for the in memory copy of the config
+// that read replicas serve requests from
+type ReplicaLocalCache struct {
+	Size             *int `yaml:"size,omitempty" toml:"size,omitempty" flag:"replica-local-cache-size"`
+	ChecksumInterval *int `yaml:"checksumInterval,omitempty" toml:"checksumInterval,omitempty" flag:"replica-local-cache-checksum-interval"`
+}
+
+// ClientServiceRequests contains the options for retrying requests to the
+// Harness SaaS client service and for the circuit breaker in front of it
+type ClientServiceRequests struct {
+	MaxAttempts      *int `yaml:"maxAttempts,omitempty" toml:"maxAttempts,omitempty" flag:"client-service-max-attempts"`
+	BackoffInitial   *int `yaml:"backoffInitial,omitempty" toml:"backoffInitial,omitempty" flag:"client-service-backoff-initial"`
+	BackoffMax       *int `yaml:"backoffMax,omitempty" toml:"backoffMax,omitempty" flag:"client-service-backoff-max"`
+	BreakerThreshold *int `yaml:"breakerThreshold,omitempty" toml:"breakerThreshold,omitempty" flag:"client-service-breaker-threshold"`
+	BreakerCooldown  *int `yaml:"breakerCooldown,omitempty" toml:"breakerCooldown,omitempty" flag:"client-service-breaker-cooldown"`
+	RequestTimeout   *int `yaml:"requestTimeout,omitempty" toml:"requestTimeout,omitempty" flag:"client-service-request-timeout"`
+}
+
+// Outbound contains the options for the HTTP client the Proxy uses to connect
+// to Harness SaaS
+type Outbound struct {
+	ProxyURL       *string `yaml:"proxyURL,omitempty" toml:"proxyURL,omitempty" flag:"outbound-proxy-url" secret:"true"`
+	NoProxy        *string `yaml:"noProxy,omitempty" toml:"noProxy,omitempty" flag:"outbound-no-proxy"`
+	CAFile         *string `yaml:"caFile,omitempty" toml:"caFile,omitempty" flag:"outbound-ca-file"`
+	ClientCert     *string `yaml:"clientCert,omitempty" toml:"clientCert,omitempty" flag:"outbound-client-cert"`
+	ClientKey      *string `yaml:"clientKey,omitempty" toml:"clientKey,omitempty" flag:"outbound-client-key"`
+	ConnectTimeout *int    `yaml:"connectTimeout,omitempty" toml:"connectTimeout,omitempty" flag:"outbound-connect-timeout"`
+	ReadTimeout    *int    `yaml:"readTimeout,omitempty" toml:"readTimeout,omitempty" flag:"outbound-read-timeout"`
+}
+
+// Secrets contains the options for reading secrets from an external store
+type Secrets struct {
+	Provider        *string `yaml:"provider,omitempty" toml:"provider,omitempty" flag:"secret-provider"`
+	RefreshInterval *int    `yaml:"refreshInterval,omitempty" toml:"refreshInterval,omitempty" flag:"secrets-refresh-interval"`
+	Vault           Vault   `yaml:"vault,omitempty" toml:"vault,omitempty"`
+}
+
+// Vault contains the options for reading secrets from HashiCorp Vault
+type Vault struct {
+	Address    *string `yaml:"address,omitempty" toml:"address,omitempty" flag:"vault-addr"`
+	Token      *string `yaml:"token,omitempty" toml:"token,omitempty" flag:"vault-token" secret:"true"`
+	Mount      *string `yaml:"mount,omitempty" toml:"mount,omitempty" flag:"vault-mount"`
+	KVVersion  *int    `yaml:"kvVersion,omitempty" toml:"kvVersion,omitempty" flag:"vault-kv-version"`
+	SecretPath *string `yaml:"secretPath,omitempty" toml:"secretPath,omitempty" flag:"vault-secret-path"`
+}
+
+// Server contains the options for the Proxy's HTTP servers
+type Server struct {
+	Port                        *int       `yaml:"port,omitempty" toml:"port,omitempty" flag:"port"`
+	PrometheusPort              *int       `yaml:"prometheusPort,omitempty" toml:"prometheusPort,omitempty" flag:"prometheus-port"`
+	DrainPeriod                 *int       `yaml:"drainPeriod,omitempty" toml:"drainPeriod,omitempty" flag:"drain-period"`
+	PrometheusEnvironmentLabels *bool      `yaml:"prometheusEnvironmentLabels,omitempty" toml:"prometheusEnvironmentLabels,omitempty" flag:"prometheus-environment-labels"`
+	PrometheusSDKLabels         *bool      `yaml:"prometheusSDKLabels,omitempty" toml:"prometheusSDKLabels,omitempty" flag:"prometheus-sdk-labels"`
+	PrometheusMaxLabelValues    *int       `yaml:"prometheusMaxLabelValues,omitempty" toml:"prometheusMaxLabelValues,omitempty" flag:"prometheus-max-label-values"`
+	TrustedProxies              *string    `yaml:"trustedProxies,omitempty" toml:"trustedProxies,omitempty" flag:"trusted-proxies"`
+	TLS                         TLS        `yaml:"tls,omitempty" toml:"tls,omitempty"`
+	CORS                        CORS       `yaml:"cors,omitempty" toml:"cors,omitempty"`
+	RateLimits                  RateLimits `yaml:"rateLimits,omitempty" toml:"rateLimits,omitempty"`
+}
+
+// TLS contains the options for serving the Proxy over https
+type TLS struct {
+	Enabled *bool   `yaml:"enabled,omitempty" toml:"enabled,omitempty" flag:"tls-enabled"`
+	Cert    *string `yaml:"cert,omitempty" toml:"cert,omitempty" flag:"tls-cert"`
+	Key     *string `yaml:"key,omitempty" toml:"key,omitempty" flag:"tls-key"`
+}
+
+// CORS contains the options for the Proxy's CORS policy
+type CORS struct {
+	AllowedOrigins     *string `yaml:"allowedOrigins,omitempty" toml:"allowedOrigins,omitempty" flag:"cors-allowed-origins"`
+	AllowedHeaders     *string `yaml:"allowedHeaders,omitempty" toml:"allowedHeaders,omitempty" flag:"cors-allowed-headers"`
+	ExposedHeaders     *string `yaml:"exposedHeaders,omitempty" toml:"exposedHeaders,omitempty" flag:"cors-exposed-headers"`
+	AllowCredentials   *bool   `yaml:"allowCredentials,omitempty" toml:"allowCredentials,omitempty" flag:"cors-allow-credentials"`
+	MaxAge             *int    `yaml:"maxAge,omitempty" toml:"maxAge,omitempty" flag:"cors-max-age"`
+	EnvironmentOrigins *string `yaml:"environmentOrigins,omitempty" toml:"environmentOrigins,omitempty" flag:"cors-environment-origins"`
+}
+
+// RateLimits contains the rate limits for each group of SDK endpoints
+type RateLimits struct {
+	Auth       *string `yaml:"auth,omitempty" toml:"auth,omitempty" flag:"rate-limit-auth"`
+	Config     *string `yaml:"config,omitempty" toml:"config,omitempty" flag:"rate-limit-config"`
+	Evaluation *string `yaml:"evaluation,omitempty" toml:"evaluation,omitempty" flag:"rate-limit-evaluation"`
+	Stream     *string `yaml:"stream,omitempty" toml:"stream,omitempty" flag:"rate-limit-stream"`
+	Metrics    *string `yaml:"metrics,omitempty" toml:"metrics,omitempty" flag:"rate-limit-metrics"`
+}
+
+// Cache contains the options for the cache the Proxy stores config in
+type Cache struct {
+	Redis Redis `yaml:"redis,omitempty" toml:"redis,omitempty"`
+}
+
+// Redis contains the options for connecting to Redis
+type Redis struct {
+	Address      *string `yaml:"address,omitempty" toml:"address,omitempty" flag:"redis-address"`
+	Username     *string `yaml:"username,omitempty" toml:"username,omitempty" flag:"redis-username"`
+	Password     *string `yaml:"password,omitempty" toml:"password,omitempty" flag:"redis-password" secret:"true"`
+	PasswordFile *string `yaml:"passwordFile,omitempty" toml:"passwordFile,omitempty" flag:"redis-password-file"`
+	DB           *int    `yaml:"db,omitempty" toml:"db,omitempty" flag:"redis-db"`
+	PoolSize     *int    `yaml:"poolSize,omitempty" toml:"poolSize,omitempty" flag:"redis-pool-size"`
+}
+
+// Stream contains the options for the SaaS stream and the streams between the
+// Primary and read replicas
+type Stream struct {
+	MetricsMaxLen            *int64 `yaml:"metricsMaxLen,omitempty" toml:"metricsMaxLen,omitempty" flag:"metrics-stream-max-len"`
+	MetricsReadConcurrency   *int   `yaml:"metricsReadConcurrency,omitempty" toml:"metricsReadConcurrency,omitempty" flag:"metrics-stream-read-concurrency"`
+	PollIntervalDisconnected *int   `yaml:"pollIntervalDisconnected,omitempty" toml:"pollIntervalDisconnected,omitempty" flag:"poll-interval-disconnected"`
+	PollIntervalConnected    *int   `yaml:"pollIntervalConnected,omitempty" toml:"pollIntervalConnected,omitempty" flag:"poll-interval-connected"`
+	BackoffInitial           *int   `yaml:"backoffInitial,omitempty" toml:"backoffInitial,omitempty" flag:"sse-backoff-initial"`
+	BackoffMax               *int   `yaml:"backoffMax,omitempty" toml:"backoffMax,omitempty" flag:"sse-backoff-max"`
+	IdleTimeout              *int   `yaml:"idleTimeout,omitempty" toml:"idleTimeout,omitempty" flag:"sse-idle-timeout"`
+}
+
+// Metrics contains the options for how the Proxy handles SDK metrics
+type Metrics struct {
+	PostDuration *int          `yaml:"postDuration,omitempty" toml:"postDuration,omitempty" flag:"metric-post-duration"`
+	Evaluations  *bool         `yaml:"evaluations,omitempty" toml:"evaluations,omitempty" flag:"evaluation-metrics"`
+	Buffer       MetricsBuffer `yaml:"buffer,omitempty" toml:"buffer,omitempty"`
+	Sinks        MetricsSinks  `yaml:"sinks,omitempty" toml:"sinks,omitempty"`
+}
+
+// MetricsBuffer contains the options for buffering metrics that fail to send
+type MetricsBuffer struct {
+	Type       *string `yaml:"type,omitempty" toml:"type,omitempty" flag:"metrics-buffer"`
+	Dir        *string `yaml:"dir,omitempty" toml:"dir,omitempty" flag:"metrics-buffer-dir"`
+	MaxEntries *int    `yaml:"maxEntries,omitempty" toml:"maxEntries,omitempty" flag:"metrics-buffer-max-entries"`
+}
+
+// MetricsSinks contains the options for exporting SDK evaluation metrics
+type MetricsSinks struct {
+	Prometheus   *bool   `yaml:"prometheus,omitempty" toml:"prometheus,omitempty" flag:"metrics-sink-prometheus"`
+	OTLPEndpoint *string `yaml:"otlpEndpoint,omitempty" toml:"otlpEndpoint,omitempty" flag:"metrics-sink-otlp-endpoint"`
+	File         *string `yaml:"file,omitempty" toml:"file,omitempty" flag:"metrics-sink-file"`
+	MaxSeries    *int    `yaml:"maxSeries,omitempty" toml:"maxSeries,omitempty" flag:"metrics-sink-max-series"`
+}
+
+// Auth contains the options for authenticating SDKs and admin requests
+type Auth struct {
+	Secret                  *string `yaml:"secret,omitempty" toml:"secret,omitempty" flag:"auth-secret" secret:"true"`
+	SecretFile              *string `yaml:"secretFile,omitempty" toml:"secretFile,omitempty" flag:"auth-secret-file"`
+	BypassAuth              *bool   `yaml:"bypassAuth,omitempty" toml:"bypassAuth,omitempty" flag:"bypass-auth"`
+	InsecureDevMode         *bool   `yaml:"insecureDevMode,omitempty" toml:"insecureDevMode,omitempty" flag:"insecure-dev-mode"`
+	TokenTTL                *int    `yaml:"tokenTTL,omitempty" toml:"tokenTTL,omitempty" flag:"auth-token-ttl"`
+	KeyID                   *string `yaml:"keyID,omitempty" toml:"keyID,omitempty" flag:"auth-key-id"`
+	Algorithm               *string `yaml:"algorithm,omitempty" toml:"algorithm,omitempty" flag:"auth-algorithm"`
+	PrivateKeyFile          *string `yaml:"privateKeyFile,omitempty" toml:"privateKeyFile,omitempty" flag:"auth-private-key-file"`
+	PreviousKeys            *string `yaml:"previousKeys,omitempty" toml:"previousKeys,omitempty" flag:"auth-previous-keys"`
+	PreviousKeysRetireAt    *string `yaml:"previousKeysRetireAt,omitempty" toml:"previousKeysRetireAt,omitempty" flag:"auth-previous-keys-retire-at"`
+	PreviousKeysGracePeriod *int    `yaml:"previousKeysGracePeriod,omitempty" toml:"previousKeysGracePeriod,omitempty" flag:"auth-previous-keys-grace-period"`
+	AdminToken              *string `yaml:"adminToken,omitempty" toml:"adminToken,omitempty" flag:"admin-token" secret:"true"`
+	APIKeyHashPepper        *string `yaml:"apiKeyHashPepper,omitempty" toml:"apiKeyHashPepper,omitempty" flag:"api-key-hash-pepper" secret:"true"`
+	APIKeyHashPepperFile    *string `yaml:"apiKeyHashPepperFile,omitempty" toml:"apiKeyHashPepperFile,omitempty" flag:"api-key-hash-pepper-file"`
+}
+
+// Audit contains the options for recording the config changes the Proxy applies
+type Audit struct {
+	Log         *bool   `yaml:"log,omitempty" toml:"log,omitempty" flag:"audit-log"`
+	File        *string `yaml:"file,omitempty" toml:"file,omitempty" flag:"audit-log-file"`
+	HistorySize *int    `yaml:"historySize,omitempty" toml:"historySize,omitempty" flag:"audit-history-size"`
+}
+
+// Tracing contains the options for exporting OpenTelemetry traces
+type Tracing struct {
+	OTLPEndpoint  *string `yaml:"otlpEndpoint,omitempty" toml:"otlpEndpoint,omitempty" flag:"tracing-otlp-endpoint"`
+	SamplePercent *int    `yaml:"samplePercent,omitempty" toml:"samplePercent,omitempty" flag:"tracing-sample-percent"`
+}
+
+// FormatFromPath returns the format of a config file based on its extension
+func FormatFromPath(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	case ".toml":
+		return FormatTOML, nil
+	default:
+		return "", fmt.Errorf("unsupported config file %q, the file must have a .yaml, .yml or .toml extension", path)
+	}
+}
+
+// Load reads a YAML or TOML config file, the format is picked from the file's
+// extension. It errors if the file has any options that don't exist.
+func Load(path string) (Config, error) {
+	format, err := FormatFromPath(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	// #nosec G304
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read config file: %s", err)
+	}
+
+	return Decode(b, format)
+}
+
+// Decode decodes a YAML or TOML config
+func Decode(b []byte, format string) (Config, error) {
+	c := Config{}
+
+	switch format {
+	case FormatYAML:
+		if err := yaml.UnmarshalStrict(b, &c); err != nil {
+			return Config{}, fmt.Errorf("invalid config file: %s", err)
+		}
+	case FormatTOML:
+		md, err := toml.Decode(string(b), &c)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid config file: %s", err)
+		}
+
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			keys := make([]string, 0, len(undecoded))
+			for _, k := range undecoded {
+				keys = append(keys, k.String())
+			}
+			return Config{}, fmt.Errorf("invalid config file: unknown options %s", strings.Join(keys, ", "))
+		}
+	default:
+		return Config{}, fmt.Errorf("unsupported config format %q", format)
+	}
+
+	return c, nil
+}
+
+// Encode writes the Config in the given format
+func Encode(w io.Writer, c Config, format string) error {
+	switch format {
+	case FormatYAML:
+		return yaml.NewEncoder(w).Encode(c)
+	case FormatTOML:
+		buf := &bytes.Buffer{}
+		if err := toml.NewEncoder(buf).Encode(c); err != nil {
+			return err
+		}
+		_, err := w.Write(buf.Bytes())
+		return err
+	default:
+		return fmt.Errorf("unsupported config format %q", format)
+	}
+}
+
+// Apply sets the flag for every option in the Config, apart from the flags in
+// skip which have already been set with a higher precedence
+func (c Config) Apply(fs *flag.FlagSet, skip map[string]bool) error {
+	return walk(reflect.ValueOf(&c).Elem(), "", func(path string, name string, field reflect.Value, _ reflect.StructField) error {
+		if field.IsNil() || skip[name] {
+			return nil
+		}
+
+		if err := fs.Set(name, fmt.Sprint(field.Elem().Interface())); err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+		return nil
+	})
+}
+
+// FromFlags creates the effective Config from the current value of every flag
+func FromFlags(fs *flag.FlagSet) (Config, error) {
+	c := Config{}
+
+	err := walk(reflect.ValueOf(&c).Elem(), "", func(path string, name string, field reflect.Value, _ reflect.StructField) error {
+		f := fs.Lookup(name)
+		if f == nil {
+			return fmt.Errorf("%s: flag %q doesn't exist", path, name)
+		}
+
+		getter, ok := f.Value.(flag.Getter)
+		if !ok {
+			return fmt.Errorf("%s: can't get the value of flag %q", path, name)
+		}
+
+		value := reflect.ValueOf(getter.Get())
+		if value.Type() != field.Type().Elem() {
+			return fmt.Errorf("%s: flag %q is a %s not a %s", path, name, value.Type(), field.Type().Elem())
+		}
+
+		ptr := reflect.New(field.Type().Elem())
+		ptr.Elem().Set(value)
+		field.Set(ptr)
+		return nil
+	})
+
+	return c, err
+}
+
+// Masked returns a copy of the Config with the secrets masked so it can be printed
+func (c Config) Masked() Config {
+	masked := c
+	_ = walk(reflect.ValueOf(&masked).Elem(), "", func(_ string, _ string, field reflect.Value, sf reflect.StructField) error {
+		if sf.Tag.Get("secret") != "true" || field.IsNil() {
+			return nil
+		}
+
+		s := token.MaskRight(field.Elem().String())
+		field.Set(reflect.ValueOf(&s))
+		return nil
+	})
+	return masked
+}
+
+// Validate checks the options in the Config have valid values. It returns
+// every invalid option rather than stopping at the first one.
+func (c Config) Validate() error {
+	errs := []error{}
+	check := func(ok bool, path string, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...)))
+		}
+	}
+
+	if c.Server.Port != nil {
+		check(validPort(*c.Server.Port), "server.port", "must be between 1 and 65535, got %d", *c.Server.Port)
+	}
+	if c.Server.PrometheusPort != nil {
+		check(validPort(*c.Server.PrometheusPort), "server.prometheusPort", "must be between 1 and 65535, got %d", *c.Server.PrometheusPort)
+	}
+	if isTrue(c.Server.TLS.Enabled) {
+		check(isSet(c.Server.TLS.Cert), "server.tls.cert", "is required when tls is enabled")
+		check(isSet(c.Server.TLS.Key), "server.tls.key", "is required when tls is enabled")
+	}
+	if c.Proxy.LogLevel != nil {
+		check(oneOf(strings.ToUpper(*c.Proxy.LogLevel), "INFO", "DEBUG", "ERROR"), "proxy.logLevel", "must be one of INFO, DEBUG or ERROR, got %q", *c.Proxy.LogLevel)
+	}
+	if c.Proxy.Secrets.Provider != nil {
+		check(oneOf(*c.Proxy.Secrets.Provider, "", "vault"), "proxy.secrets.provider", "must be vault or empty, got %q", *c.Proxy.Secrets.Provider)
+	}
+	if c.Cache.Redis.DB != nil {
+		check(*c.Cache.Redis.DB >= 0, "cache.redis.db", "can't be negative, got %d", *c.Cache.Redis.DB)
+	}
+	if c.Cache.Redis.PoolSize != nil {
+		check(*c.Cache.Redis.PoolSize > 0, "cache.redis.poolSize", "must be greater than 0, got %d", *c.Cache.Redis.PoolSize)
+	}
+	if c.Metrics.Buffer.Type != nil {
+		check(oneOf(*c.Metrics.Buffer.Type, "", "redis", "disk"), "metrics.buffer.type", "must be redis, disk or empty, got %q", *c.Metrics.Buffer.Type)
+	}
+	if c.Auth.Algorithm != nil {
+		check(oneOf(*c.Auth.Algorithm, token.AlgorithmHS256, token.AlgorithmRS256, token.AlgorithmEdDSA), "auth.algorithm", "must be one of %s, %s or %s, got %q", token.AlgorithmHS256, token.AlgorithmRS256, token.AlgorithmEdDSA, *c.Auth.Algorithm)
+	}
+	if c.Auth.TokenTTL != nil {
+		check(*c.Auth.TokenTTL >= 0, "auth.tokenTTL", "can't be negative, got %d", *c.Auth.TokenTTL)
+	}
+	if c.Stream.PollIntervalDisconnected != nil {
+		check(*c.Stream.PollIntervalDisconnected >= 0, "stream.pollIntervalDisconnected", "can't be negative, got %d", *c.Stream.PollIntervalDisconnected)
+	}
+	if c.Stream.PollIntervalConnected != nil {
+		check(*c.Stream.PollIntervalConnected >= 0, "stream.pollIntervalConnected", "can't be negative, got %d", *c.Stream.PollIntervalConnected)
+	}
+	if c.Stream.BackoffInitial != nil {
+		check(*c.Stream.BackoffInitial > 0, "stream.backoffInitial", "must be greater than 0, got %d", *c.Stream.BackoffInitial)
+	}
+	if c.Stream.BackoffInitial != nil && c.Stream.BackoffMax != nil {
+		check(*c.Stream.BackoffMax >= *c.Stream.BackoffInitial, "stream.backoffMax", "must be at least stream.backoffInitial (%d), got %d", *c.Stream.BackoffInitial, *c.Stream.BackoffMax)
+	}
+	if c.Stream.IdleTimeout != nil {
+		check(*c.Stream.IdleTimeout >= 0, "stream.idleTimeout", "can't be negative, got %d", *c.Stream.IdleTimeout)
+	}
+	if isSet(c.Proxy.Outbound.ClientCert) || isSet(c.Proxy.Outbound.ClientKey) {
+		check(isSet(c.Proxy.Outbound.ClientCert), "proxy.outbound.clientCert", "is required when proxy.outbound.clientKey is set")
+		check(isSet(c.Proxy.Outbound.ClientKey), "proxy.outbound.clientKey", "is required when proxy.outbound.clientCert is set")
+	}
+	if c.Proxy.Outbound.ConnectTimeout != nil {
+		check(*c.Proxy.Outbound.ConnectTimeout >= 0, "proxy.outbound.connectTimeout", "can't be negative, got %d", *c.Proxy.Outbound.ConnectTimeout)
+	}
+	if c.Proxy.Outbound.ReadTimeout != nil {
+		check(*c.Proxy.Outbound.ReadTimeout >= 0, "proxy.outbound.readTimeout", "can't be negative, got %d", *c.Proxy.Outbound.ReadTimeout)
+	}
+	cs := c.Proxy.ClientServiceRequests
+	if cs.MaxAttempts != nil {
+		check(*cs.MaxAttempts > 0, "proxy.clientServiceRequests.maxAttempts", "must be greater than 0, got %d", *cs.MaxAttempts)
+	}
+	if cs.BackoffInitial != nil {
+		check(*cs.BackoffInitial > 0, "proxy.clientServiceRequests.backoffInitial", "must be greater than 0, got %d", *cs.BackoffInitial)
+	}
+	if cs.BackoffInitial != nil && cs.BackoffMax != nil {
+		check(*cs.BackoffMax >= *cs.BackoffInitial, "proxy.clientServiceRequests.backoffMax", "must be at least proxy.clientServiceRequests.backoffInitial (%d), got %d", *cs.BackoffInitial, *cs.BackoffMax)
+	}
+	if cs.BreakerThreshold != nil {
+		check(*cs.BreakerThreshold >= 0, "proxy.clientServiceRequests.breakerThreshold", "can't be negative, got %d", *cs.BreakerThreshold)
+	}
+	if cs.BreakerCooldown != nil {
+		check(*cs.BreakerCooldown > 0, "proxy.clientServiceRequests.breakerCooldown", "must be greater than 0, got %d", *cs.BreakerCooldown)
+	}
+	if cs.RequestTimeout != nil {
+		check(*cs.RequestTimeout >= 0, "proxy.clientServiceRequests.requestTimeout", "can't be negative, got %d", *cs.RequestTimeout)
+	}
+	if c.Proxy.ConfigGenerationHistory != nil {
+		check(*c.Proxy.ConfigGenerationHistory >= 2, "proxy.configGenerationHistory", "must be at least 2, got %d", *c.Proxy.ConfigGenerationHistory)
+	}
+
+	lc := c.Proxy.ReplicaLocalCache
+	if lc.Size != nil {
+		check(*lc.Size >= 0, "proxy.replicaLocalCache.size", "can't be negative, got %d", *lc.Size)
+	}
+	if lc.ChecksumInterval != nil {
+		check(*lc.ChecksumInterval >= 0, "proxy.replicaLocalCache.checksumInterval", "can't be negative, got %d", *lc.ChecksumInterval)
+	}
+
+	if c.Audit.HistorySize != nil {
+		check(*c.Audit.HistorySize > 0, "audit.historySize", "must be greater than 0, got %d", *c.Audit.HistorySize)
+	}
+
+	return errors.Join(errs...)
+}
+
+// walk calls fn for every option in the struct v, path is the option's
+// position in the config file e.g. server.tls.enabled and name is its flag
+func walk(v reflect.Value, prefix string, fn func(path string, name string, field reflect.Value, sf reflect.StructField) error) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		field := v.Field(i)
+
+		key, _, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+
+		if field.Kind() == reflect.Struct {
+			if err := walk(field, path, fn); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := sf.Tag.Get("flag")
+		if name == "" {
+			continue
+		}
+
+		if err := fn(path, name, field, sf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validPort(p int) bool {
+	return p > 0 && p <= 65535
+}
+
+func isTrue(b *bool) bool {
+	return b != nil && *b
+}
+
+func isSet(s *string) bool {
+	return s != nil && *s != ""
+}
+
+func oneOf(s string, options ...string) bool {
+	for _, o := range options {
+		if s == o {
+			return true
+		}
+	}
+	return false
+}
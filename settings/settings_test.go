@@ -0,0 +1,310 @@
+package settings
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const yamlConfig = `
+server:
+  port: 7000
+  tls:
+    enabled: true
+    cert: /certs/cert.crt
+    key: /certs/cert.key
+cache:
+  redis:
+    address: redis:6379
+    password: supersecret
+stream:
+  metricsMaxLen: 5000
+auth:
+  secret: foobarfoobar
+  bypassAuth: false
+`
+
+const tomlConfig = `
+[server]
+port = 7000
+
+[server.tls]
+enabled = true
+cert = "/certs/cert.crt"
+key = "/certs/cert.key"
+
+[cache.redis]
+address = "redis:6379"
+password = "supersecret"
+
+[stream]
+metricsMaxLen = 5000
+
+[auth]
+secret = "foobarfoobar"
+bypassAuth = false
+`
+
+func strPtr(s string) *string { return &s }
+func intPtr(i int) *int       { return &i }
+func boolPtr(b bool) *bool    { return &b }
+
+func newFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Int("port", 8000, "")
+	fs.Bool("tls-enabled", false, "")
+	fs.String("tls-cert", "", "")
+	fs.String("tls-key", "", "")
+	fs.String("redis-address", "", "")
+	fs.String("redis-password", "", "")
+	fs.Int64("metrics-stream-max-len", 1000, "")
+	fs.String("auth-secret", "secret", "")
+	fs.Bool("bypass-auth", false, "")
+	return fs
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(name string, content string) string {
+		path := filepath.Join(dir, name)
+		assert.Nil(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	metricsMaxLen := int64(5000)
+	expected := Config{
+		Server: Server{
+			Port: intPtr(7000),
+			TLS:  TLS{Enabled: boolPtr(true), Cert: strPtr("/certs/cert.crt"), Key: strPtr("/certs/cert.key")},
+		},
+		Cache:  Cache{Redis: Redis{Address: strPtr("redis:6379"), Password: strPtr("supersecret")}},
+		Stream: Stream{MetricsMaxLen: &metricsMaxLen},
+		Auth:   Auth{Secret: strPtr("foobarfoobar"), BypassAuth: boolPtr(false)},
+	}
+
+	testCases := map[string]struct {
+		path      string
+		expected  Config
+		shouldErr bool
+	}{
+		"Given I have a YAML config file": {
+			path:     write("config.yaml", yamlConfig),
+			expected: expected,
+		},
+		"Given I have a TOML config file": {
+			path:     write("config.toml", tomlConfig),
+			expected: expected,
+		},
+		"Given I have a YAML config file with an unknown option": {
+			path:      write("unknown.yml", "server:\n  prot: 7000\n"),
+			shouldErr: true,
+		},
+		"Given I have a TOML config file with an unknown option": {
+			path:      write("unknown.toml", "[server]\nprot = 7000\n"),
+			shouldErr: true,
+		},
+		"Given I have a config file with the wrong type": {
+			path:      write("type.yaml", "server:\n  port: abc\n"),
+			shouldErr: true,
+		},
+		"Given I have a config file with an unsupported extension": {
+			path:      write("config.json", "{}"),
+			shouldErr: true,
+		},
+		"Given I have a config file that doesn't exist": {
+			path:      filepath.Join(dir, "missing.yaml"),
+			shouldErr: true,
+		},
+	}
+
+	for desc, tc := range testCases {
+		desc := desc
+		tc := tc
+
+		t.Run(desc, func(t *testing.T) {
+			actual, err := Load(tc.path)
+			if (err != nil) != tc.shouldErr {
+				t.Errorf("(%s): error = %v, shouldErr = %v", desc, err, tc.shouldErr)
+			}
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestConfig_Apply(t *testing.T) {
+	c, err := Decode([]byte(yamlConfig), FormatYAML)
+	assert.Nil(t, err)
+
+	fs := newFlagSet()
+
+	t.Log("Given the port was set by a flag and the auth secret by an env var")
+	assert.Nil(t, fs.Set("port", "9000"))
+	assert.Nil(t, fs.Set("auth-secret", "fromenv"))
+
+	assert.Nil(t, c.Apply(fs, map[string]bool{"port": true, "auth-secret": true}))
+
+	t.Log("Then they take precedence over the config file")
+	assert.Equal(t, "9000", fs.Lookup("port").Value.String())
+	assert.Equal(t, "fromenv", fs.Lookup("auth-secret").Value.String())
+
+	t.Log("And the other flags are set from the config file")
+	assert.Equal(t, "true", fs.Lookup("tls-enabled").Value.String())
+	assert.Equal(t, "redis:6379", fs.Lookup("redis-address").Value.String())
+	assert.Equal(t, "5000", fs.Lookup("metrics-stream-max-len").Value.String())
+
+	t.Log("And flags that aren't in the config file keep their defaults")
+	assert.Equal(t, "false", fs.Lookup("bypass-auth").Value.String())
+}
+
+// newFullFlagSet registers a flag for every option in the Config
+func newFullFlagSet(t *testing.T) *flag.FlagSet {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+
+	err := walk(reflect.ValueOf(&Config{}).Elem(), "", func(_ string, name string, field reflect.Value, _ reflect.StructField) error {
+		switch field.Type().Elem().Kind() {
+		case reflect.String:
+			fs.String(name, "", "")
+		case reflect.Int:
+			fs.Int(name, 0, "")
+		case reflect.Int64:
+			fs.Int64(name, 0, "")
+		case reflect.Bool:
+			fs.Bool(name, false, "")
+		default:
+			t.Fatalf("unexpected type for flag %q: %s", name, field.Type())
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+
+	return fs
+}
+
+func TestFromFlags(t *testing.T) {
+	t.Log("Given I have a FlagSet with a flag for every option")
+	fs := newFullFlagSet(t)
+	assert.Nil(t, fs.Set("port", "9000"))
+	assert.Nil(t, fs.Set("auth-secret", "foobar"))
+
+	c, err := FromFlags(fs)
+	assert.Nil(t, err)
+
+	t.Log("Then the Config has the value of every flag")
+	assert.Equal(t, 9000, *c.Server.Port)
+	assert.Equal(t, "foobar", *c.Auth.Secret)
+	assert.Equal(t, false, *c.Auth.BypassAuth)
+
+	t.Log("Given I have a FlagSet that's missing some options")
+	_, err = FromFlags(newFlagSet())
+	assert.NotNil(t, err)
+}
+
+func TestConfig_Masked(t *testing.T) {
+	c, err := Decode([]byte(yamlConfig), FormatYAML)
+	assert.Nil(t, err)
+
+	masked := c.Masked()
+	assert.Equal(t, "supe*******", *masked.Cache.Redis.Password)
+	assert.Equal(t, "foob********", *masked.Auth.Secret)
+	assert.Equal(t, "redis:6379", *masked.Cache.Redis.Address)
+
+	t.Log("And the original Config isn't modified")
+	assert.Equal(t, "supersecret", *c.Cache.Redis.Password)
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, Encode(buf, masked, FormatYAML))
+	assert.NotContains(t, buf.String(), "supersecret")
+
+	buf.Reset()
+	assert.Nil(t, Encode(buf, masked, FormatTOML))
+	assert.NotContains(t, buf.String(), "supersecret")
+}
+
+func TestConfig_Validate(t *testing.T) {
+	testCases := map[string]struct {
+		config    Config
+		shouldErr bool
+	}{
+		"Given I have an empty config": {
+			config:    Config{},
+			shouldErr: false,
+		},
+		"Given I have a valid config": {
+			config: Config{
+				Server: Server{Port: intPtr(7000)},
+				Proxy:  Proxy{LogLevel: strPtr("DEBUG")},
+				Auth:   Auth{Algorithm: strPtr("RS256")},
+			},
+			shouldErr: false,
+		},
+		"Given I have an invalid port": {
+			config:    Config{Server: Server{Port: intPtr(70000)}},
+			shouldErr: true,
+		},
+		"Given I enable tls without a cert": {
+			config:    Config{Server: Server{TLS: TLS{Enabled: boolPtr(true), Key: strPtr("/certs/cert.key")}}},
+			shouldErr: true,
+		},
+		"Given I have an invalid log level": {
+			config:    Config{Proxy: Proxy{LogLevel: strPtr("TRACE")}},
+			shouldErr: true,
+		},
+		"Given I have an invalid auth algorithm": {
+			config:    Config{Auth: Auth{Algorithm: strPtr("HS512")}},
+			shouldErr: true,
+		},
+		"Given I have an invalid metrics buffer": {
+			config:    Config{Metrics: Metrics{Buffer: MetricsBuffer{Type: strPtr("s3")}}},
+			shouldErr: true,
+		},
+		"Given I have an audit history size of zero": {
+			config:    Config{Audit: Audit{HistorySize: intPtr(0)}},
+			shouldErr: true,
+		},
+		"Given I have an outbound client cert without a key": {
+			config:    Config{Proxy: Proxy{Outbound: Outbound{ClientCert: strPtr("client.crt")}}},
+			shouldErr: true,
+		},
+		"Given I keep fewer than two config generations": {
+			config:    Config{Proxy: Proxy{ConfigGenerationHistory: intPtr(1)}},
+			shouldErr: true,
+		},
+		"Given I have a negative replica local cache size": {
+			config:    Config{Proxy: Proxy{ReplicaLocalCache: ReplicaLocalCache{Size: intPtr(-1)}}},
+			shouldErr: true,
+		},
+		"Given I have a client service max attempts of zero": {
+			config:    Config{Proxy: Proxy{ClientServiceRequests: ClientServiceRequests{MaxAttempts: intPtr(0)}}},
+			shouldErr: true,
+		},
+	}
+
+	for desc, tc := range testCases {
+		desc := desc
+		tc := tc
+
+		t.Run(desc, func(t *testing.T) {
+			err := tc.config.Validate()
+			if (err != nil) != tc.shouldErr {
+				t.Errorf("(%s): error = %v, shouldErr = %v", desc, err, tc.shouldErr)
+			}
+		})
+	}
+}
+
+func TestConfig_Validate_ReportsEveryError(t *testing.T) {
+	c := Config{
+		Server: Server{Port: intPtr(0)},
+		Auth:   Auth{Algorithm: strPtr("HS512")},
+	}
+
+	err := c.Validate()
+	assert.ErrorContains(t, err, "server.port")
+	assert.ErrorContains(t, err, "auth.algorithm")
+}
@@ -47,11 +47,7 @@ func SaasStreamOnDisconnect(l log.Logger, streamHealth Health, pp Pushpin, redis
 		// Close any open stream between this Proxy and SDKs. This is to force SDKs to poll the Proxy for
 		// changes until we've a healthy SaaS -> Proxy stream to make sure they don't miss out on changes
 		// the Proxy may have pulled down while the Proxy -> Saas stream was down.
-		for streamID := range streams() {
-			if err := pp.Close(streamID); err != nil {
-				l.Error("failed to close Proxy->SDK stream", "streamID", streamID, "err", err)
-			}
-		}
+		CloseSDKStreams(l, pp, streams)
 
 		// Reset context timeout for publishing to the redis stream
 		ctx, cancel = context.WithTimeout(context.Background(), 15*time.Second)
@@ -69,6 +65,16 @@ func SaasStreamOnDisconnect(l log.Logger, streamHealth Health, pp Pushpin, redis
 	}
 }
 
+// CloseSDKStreams closes every open stream between this Proxy and SDKs. SDKs
+// will fall back to polling and reconnect once they're able to.
+func CloseSDKStreams(l log.Logger, pp Pushpin, streams getConnectedStreamsFn) {
+	for streamID := range streams() {
+		if err := pp.Close(streamID); err != nil {
+			l.Error("failed to close Proxy->SDK stream", "streamID", streamID, "err", err)
+		}
+	}
+}
+
 // SaasStreamOnConnect sets the status of the SaaS stream to healthy in the cache
 func SaasStreamOnConnect(l log.Logger, streamHealth Health, reloadConfig func() error, redisSSEStream Stream, pollingStatus pollingStatus) func() {
 	return func() {
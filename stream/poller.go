@@ -0,0 +1,204 @@
+package stream
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/harness/ff-proxy/v2/domain"
+	"github.com/harness/ff-proxy/v2/log"
+)
+
+// The reasons the Primary Proxy polls Harness SaaS for config changes
+const (
+	// PollReasonDisconnect is the poll made as soon as the SaaS stream disconnects
+	PollReasonDisconnect = "disconnect"
+
+	// PollReasonReconnect is the poll made when the SaaS stream reconnects
+	PollReasonReconnect = "reconnect"
+
+	// PollReasonInterval is the poll made on an interval while the SaaS stream is down
+	PollReasonInterval = "interval"
+
+	// PollReasonSafetyNet is the poll made on an interval while the SaaS stream is healthy
+	PollReasonSafetyNet = "safety_net"
+)
+
+// WithDisconnectedInterval sets how often the Poller polls while the SaaS stream is down
+func WithDisconnectedInterval(d time.Duration) func(p *Poller) {
+	return func(p *Poller) {
+		p.disconnectedInterval = d
+	}
+}
+
+// WithConnectedInterval sets how often the Poller polls while the SaaS stream
+// is healthy. This is a safety net in case the stream silently stops sending
+// events.
+func WithConnectedInterval(d time.Duration) func(p *Poller) {
+	return func(p *Poller) {
+		p.connectedInterval = d
+	}
+}
+
+// Poller polls Harness SaaS for config changes. It polls on an interval
+// while the SaaS stream is down and, optionally, on a longer interval while
+// it's healthy. It also records metrics for the polls made by the stream's
+// connect and disconnect handlers.
+type Poller struct {
+	log                  log.Logger
+	health               Health
+	reloadConfig         func() error
+	version              func() string
+	disconnectedInterval time.Duration
+	connectedInterval    time.Duration
+
+	mtx      *sync.Mutex
+	lastPoll time.Time
+
+	polls    *prometheus.CounterVec
+	changes  *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// NewPoller creates a Poller. The version func should return something that
+// changes whenever the config does e.g. a checksum, it's used to record
+// whether a poll found any changes.
+func NewPoller(l log.Logger, health Health, reloadConfig func() error, version func() string, reg prometheus.Registerer, opts ...func(p *Poller)) *Poller {
+	l = l.With("component", "Poller")
+	p := &Poller{
+		log:          l,
+		health:       health,
+		reloadConfig: reloadConfig,
+		version:      version,
+		mtx:          &sync.Mutex{},
+		polls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ff_proxy_config_polls_total",
+			Help: "Records the number of times the Primary Proxy has polled Harness SaaS for config changes",
+		},
+			[]string{"reason", "error"},
+		),
+		changes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ff_proxy_config_poll_changes_total",
+			Help: "Records the number of polls to Harness SaaS that found config changes",
+		},
+			[]string{"reason"},
+		),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "ff_proxy_config_poll_duration_seconds",
+			Help:    "Records how long it takes the Primary Proxy to poll Harness SaaS for config changes",
+			Buckets: prometheus.DefBuckets,
+		},
+			[]string{"reason"},
+		),
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	reg.MustRegister(p.polls, p.changes, p.duration)
+	return p
+}
+
+// PollFn returns a func that polls for the given reason. It's used to hook the
+// Poller up to the SaaS stream's connect and disconnect handlers.
+func (p *Poller) PollFn(reason string) func() error {
+	return func() error {
+		return p.Poll(reason)
+	}
+}
+
+// Poll reloads the config from Harness SaaS and records the outcome
+func (p *Poller) Poll(reason string) error {
+	before := p.version()
+	start := time.Now()
+
+	err := p.reloadConfig()
+
+	p.mtx.Lock()
+	p.lastPoll = time.Now()
+	p.mtx.Unlock()
+
+	p.duration.WithLabelValues(reason).Observe(time.Since(start).Seconds())
+	errLabel := "false"
+	if err != nil {
+		errLabel = "true"
+	}
+	p.polls.WithLabelValues(reason, errLabel).Inc()
+	if err == nil && p.version() != before {
+		p.changes.WithLabelValues(reason).Inc()
+	}
+	return err
+}
+
+// Start polls on an interval until the context is cancelled. It does nothing
+// if neither interval is set.
+func (p *Poller) Start(ctx context.Context) {
+	tick := p.tickInterval()
+	if tick <= 0 {
+		return
+	}
+
+	p.log.Info("starting config poller", "disconnected_interval", p.disconnectedInterval, "connected_interval", p.connectedInterval)
+	go func() {
+		ticker := time.NewTicker(tick)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				p.log.Info("stopping config poller")
+				return
+			case <-ticker.C:
+				p.pollIfDue(ctx, tick)
+			}
+		}
+	}()
+}
+
+// pollIfDue polls if it's been longer than the interval for the stream's
+// current state since the last poll. Polls made by the stream's connect and
+// disconnect handlers count so we don't poll twice in quick succession.
+func (p *Poller) pollIfDue(ctx context.Context, tick time.Duration) {
+	status, err := p.health.Status(ctx)
+	if err != nil {
+		p.log.Error("failed to get stream status", "err", err)
+		return
+	}
+
+	interval, reason := p.disconnectedInterval, PollReasonInterval
+	if status.State == domain.StreamStateConnected {
+		interval, reason = p.connectedInterval, PollReasonSafetyNet
+	}
+
+	// Allow for the ticker firing slightly before the interval has elapsed
+	p.mtx.Lock()
+	since := time.Since(p.lastPoll)
+	p.mtx.Unlock()
+	if interval <= 0 || since < interval-tick/2 {
+		return
+	}
+
+	p.log.Info("polling Harness SaaS for changes", "reason", reason)
+	if err := p.Poll(reason); err != nil {
+		p.log.Error("failed to poll for new config", "reason", reason, "err", err)
+		return
+	}
+	p.log.Info("successfully polled Harness SaaS for changes", "reason", reason)
+}
+
+// tickInterval returns the shortest interval that's been set
+func (p *Poller) tickInterval() time.Duration {
+	switch {
+	case p.disconnectedInterval <= 0:
+		return p.connectedInterval
+	case p.connectedInterval <= 0:
+		return p.disconnectedInterval
+	case p.connectedInterval < p.disconnectedInterval:
+		return p.connectedInterval
+	default:
+		return p.disconnectedInterval
+	}
+}
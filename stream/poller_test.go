@@ -0,0 +1,164 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/harness/ff-proxy/v2/domain"
+	"github.com/harness/ff-proxy/v2/log"
+)
+
+type mockStatusHealth struct {
+	*mockHealth
+	state domain.StreamState
+}
+
+func (m mockStatusHealth) Status(_ context.Context) (domain.StreamStatus, error) {
+	return domain.StreamStatus{State: m.state}, nil
+}
+
+func TestPoller_Poll(t *testing.T) {
+	version := "v1"
+	reloadErr := errors.New("reload error")
+
+	testCases := map[string]struct {
+		reloadConfig    func() error
+		shouldErr       bool
+		expectedErrors  float64
+		expectedChanges float64
+	}{
+		"Given I poll and the config hasn't changed": {
+			reloadConfig:    func() error { return nil },
+			expectedChanges: 0,
+		},
+		"Given I poll and the config has changed": {
+			reloadConfig: func() error {
+				version = "v2"
+				return nil
+			},
+			expectedChanges: 1,
+		},
+		"Given I poll and reloading the config fails": {
+			reloadConfig:   func() error { return reloadErr },
+			shouldErr:      true,
+			expectedErrors: 1,
+		},
+	}
+
+	for desc, tc := range testCases {
+		desc := desc
+		tc := tc
+
+		t.Run(desc, func(t *testing.T) {
+			p := NewPoller(log.NoOpLogger{}, mockStatusHealth{}, tc.reloadConfig, func() string { return version }, prometheus.NewRegistry())
+
+			err := p.Poll(PollReasonDisconnect)
+			if (err != nil) != tc.shouldErr {
+				t.Errorf("(%s): error = %v, shouldErr = %v", desc, err, tc.shouldErr)
+			}
+
+			assert.Equal(t, tc.expectedErrors, testutil.ToFloat64(p.polls.WithLabelValues(PollReasonDisconnect, "true")))
+			assert.Equal(t, tc.expectedChanges, testutil.ToFloat64(p.changes.WithLabelValues(PollReasonDisconnect)))
+		})
+	}
+}
+
+func TestPoller_pollIfDue(t *testing.T) {
+	testCases := map[string]struct {
+		state                domain.StreamState
+		disconnectedInterval time.Duration
+		connectedInterval    time.Duration
+		lastPoll             time.Duration
+		expectedReason       string
+	}{
+		"Given the stream is disconnected and it's time to poll": {
+			state:                domain.StreamStateDisconnected,
+			disconnectedInterval: time.Minute,
+			lastPoll:             2 * time.Minute,
+			expectedReason:       PollReasonInterval,
+		},
+		"Given the stream is disconnected and we've polled recently": {
+			state:                domain.StreamStateDisconnected,
+			disconnectedInterval: time.Minute,
+			lastPoll:             10 * time.Second,
+			expectedReason:       "",
+		},
+		"Given the stream is connected and the safety net poll is disabled": {
+			state:                domain.StreamStateConnected,
+			disconnectedInterval: time.Minute,
+			lastPoll:             time.Hour,
+			expectedReason:       "",
+		},
+		"Given the stream is connected and it's time for a safety net poll": {
+			state:                domain.StreamStateConnected,
+			disconnectedInterval: time.Minute,
+			connectedInterval:    10 * time.Minute,
+			lastPoll:             time.Hour,
+			expectedReason:       PollReasonSafetyNet,
+		},
+		"Given the stream is connected and it's not time for a safety net poll": {
+			state:                domain.StreamStateConnected,
+			disconnectedInterval: time.Minute,
+			connectedInterval:    10 * time.Minute,
+			lastPoll:             2 * time.Minute,
+			expectedReason:       "",
+		},
+	}
+
+	for desc, tc := range testCases {
+		desc := desc
+		tc := tc
+
+		t.Run(desc, func(t *testing.T) {
+			polls := 0
+			p := NewPoller(
+				log.NoOpLogger{},
+				mockStatusHealth{state: tc.state},
+				func() error {
+					polls++
+					return nil
+				},
+				func() string { return "" },
+				prometheus.NewRegistry(),
+				WithDisconnectedInterval(tc.disconnectedInterval),
+				WithConnectedInterval(tc.connectedInterval),
+			)
+			p.lastPoll = time.Now().Add(-tc.lastPoll)
+
+			p.pollIfDue(context.Background(), p.tickInterval())
+
+			if tc.expectedReason == "" {
+				assert.Equal(t, 0, polls)
+				return
+			}
+			assert.Equal(t, 1, polls)
+			assert.Equal(t, float64(1), testutil.ToFloat64(p.polls.WithLabelValues(tc.expectedReason, "false")))
+		})
+	}
+}
+
+func TestNewJitteredBackoff(t *testing.T) {
+	b := NewJitteredBackoff(1*time.Second, 8*time.Second)
+
+	t.Log("When I backoff repeatedly")
+	expected := []time.Duration{1 * time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 8 * time.Second}
+	for _, interval := range expected {
+		actual := b.NextBackOff()
+
+		t.Log("Then each backoff is within 50% of the expected interval")
+		assert.GreaterOrEqual(t, actual, interval/2)
+		assert.LessOrEqual(t, actual, interval+interval/2)
+	}
+
+	t.Log("When I reset the backoff")
+	b.Reset()
+
+	t.Log("Then it starts from the initial interval again")
+	assert.LessOrEqual(t, b.NextBackOff(), 1500*time.Millisecond)
+}
@@ -2,7 +2,12 @@ package stream
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
 
 	"github.com/harness-community/sse/v3"
 	"github.com/harness/ff-proxy/v2/build"
@@ -12,6 +17,27 @@ import (
 	"github.com/harness/ff-proxy/v2/log"
 )
 
+// ErrStreamIdle is the error returned when the stream is closed because it
+// hasn't received any events or heartbeats within the idle timeout
+var ErrStreamIdle = errors.New("stream idle timeout")
+
+// WithIdleTimeout sets how long the SSEClient waits for an event or heartbeat
+// before it treats the connection as dead, calls the disconnect handler and
+// closes the subscription so that it's reconnected. Setting it to zero
+// disables the watchdog.
+func WithIdleTimeout(d time.Duration) func(s *SSEClient) {
+	return func(s *SSEClient) {
+		s.idleTimeout = d
+	}
+}
+
+// WithHTTPClient sets the http.Client used to connect to the stream
+func WithHTTPClient(h *http.Client) func(s *SSEClient) {
+	return func(s *SSEClient) {
+		s.httpClient = h
+	}
+}
+
 // SSE is the interface for the underlying SSE client we're using
 type SSE interface {
 	SubscribeWithContext(ctx context.Context, stream string, handler func(msg *sse.Event)) error
@@ -19,13 +45,41 @@ type SSE interface {
 
 // SSEClient is an implementation of the Subscriber interface for interacting with SSE Streams
 type SSEClient struct {
-	log log.Logger
-	sse SSE
+	log         log.Logger
+	sse         SSE
+	idleTimeout time.Duration
+	httpClient  *http.Client
+
+	// lastActivity is the unix nano time we last read anything from the
+	// stream, including heartbeats that never make it to the message handler
+	lastActivity *atomic.Int64
+
+	// connected tracks whether we've called the connect or disconnect handler
+	// last so that the disconnect handler is only called once per connection
+	// when it's called by both the watchdog and the underlying client
+	connected    *atomic.Bool
+	onDisconnect func()
 }
 
 // NewSSEClient creates an SSEClient
-func NewSSEClient(l log.Logger, url string, key string, token string, accountID string, onConn func(), onDisconn func()) *SSEClient {
+func NewSSEClient(l log.Logger, url string, key string, token string, accountID string, onConn func(), onDisconn func(), opts ...func(s *SSEClient)) *SSEClient {
+	s := &SSEClient{
+		log:          l,
+		lastActivity: &atomic.Int64{},
+		connected:    &atomic.Bool{},
+		onDisconnect: onDisconn,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
 	c := sse.NewClient(url)
+	if s.httpClient != nil {
+		// Copy the client so we don't wrap the transport of a client that's
+		// shared with other services
+		h := *s.httpClient
+		c.Connection = &h
+	}
 	c.Headers = map[string]string{
 		"Authorization":     fmt.Sprintf("Bearer %s", token),
 		"API-Key":           key,
@@ -34,26 +88,44 @@ func NewSSEClient(l log.Logger, url string, key string, token string, accountID
 	}
 
 	c.OnConnect(func(c *sse.Client) {
+		s.connected.Store(true)
 		onConn()
 	})
 
 	c.OnDisconnect(func(c *sse.Client) {
-		onDisconn()
+		s.disconnected()
 	})
 
+	// Track any reads from the response body so that heartbeats, which the
+	// client doesn't pass on to us, still count as activity
+	next := c.Connection.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	c.Connection.Transport = activityTransport{next: next, lastActivity: s.lastActivity}
+
 	// don't use the default exponentialBackoff strategy - we'll have our own disconnect logic
 	// that we'll implement
 	c.ReconnectStrategy = &backoff.StopBackOff{}
 
-	return &SSEClient{
-		log: l,
-		sse: c,
-	}
+	s.sse = c
+	return s
 }
 
 // Sub makes SSEClient implement the Stream & Subscriber interfaces
 func (s *SSEClient) Sub(ctx context.Context, channel string, _ string, fn domain.HandleMessageFn) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	idle := &atomic.Bool{}
+	if s.idleTimeout > 0 {
+		s.markActivity()
+		go s.watchIdle(ctx, cancel, idle)
+	}
+
 	err := s.sse.SubscribeWithContext(ctx, channel, func(msg *sse.Event) {
+		s.markActivity()
+
 		// If we get a message with no data we just want to carry on and receive the next message
 		if len(msg.Data) <= 0 {
 			return
@@ -65,12 +137,93 @@ func (s *SSEClient) Sub(ctx context.Context, channel string, _ string, fn domain
 			s.log.Warn("failed to handle message", "err", err)
 		}
 	})
+	if idle.Load() {
+		return fmt.Errorf("%w: %w", ErrSubscribing, ErrStreamIdle)
+	}
 	if err != nil {
 		return fmt.Errorf("%w: %s", ErrSubscribing, err)
 	}
 	return nil
 }
 
+// watchIdle closes the subscription if it goes longer than the idle timeout
+// without any activity. A half open connection can stop delivering events
+// without ever erroring so without this we'd think we were still connected.
+func (s *SSEClient) watchIdle(ctx context.Context, cancel context.CancelFunc, idle *atomic.Bool) {
+	ticker := time.NewTicker(s.idleTimeout / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			since := time.Since(time.Unix(0, s.lastActivity.Load()))
+			if since < s.idleTimeout {
+				continue
+			}
+
+			s.log.Warn("no events or heartbeats received on the SaaS stream, reconnecting", "idle_timeout", s.idleTimeout, "last_activity", since)
+			idle.Store(true)
+
+			// Call the disconnect handler before we close the subscription so
+			// the stream is marked as unhealthy and we poll for changes
+			// while we reconnect
+			s.disconnected()
+			cancel()
+			return
+		}
+	}
+}
+
+// disconnected calls the disconnect handler if it hasn't already been called
+// for the current connection
+func (s *SSEClient) disconnected() {
+	if s.connected != nil && !s.connected.CompareAndSwap(true, false) {
+		return
+	}
+	if s.onDisconnect != nil {
+		s.onDisconnect()
+	}
+}
+
+func (s *SSEClient) markActivity() {
+	if s.lastActivity != nil {
+		s.lastActivity.Store(time.Now().UnixNano())
+	}
+}
+
+// activityTransport is a http.RoundTripper that records the time of every
+// read from a response body
+type activityTransport struct {
+	next         http.RoundTripper
+	lastActivity *atomic.Int64
+}
+
+// RoundTrip makes the request and wraps the response body
+func (a activityTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := a.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	resp.Body = activityReader{ReadCloser: resp.Body, lastActivity: a.lastActivity}
+	return resp, nil
+}
+
+type activityReader struct {
+	io.ReadCloser
+	lastActivity *atomic.Int64
+}
+
+func (a activityReader) Read(p []byte) (int, error) {
+	n, err := a.ReadCloser.Read(p)
+	if n > 0 {
+		a.lastActivity.Store(time.Now().UnixNano())
+	}
+	return n, err
+}
+
 // Pub ...
 // TODO: Temporarily adding this to make this type implement the Stream interface. There's some
 // cleaner refactoring I can do around this and the pushpin type but I don't want to make this
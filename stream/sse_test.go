@@ -3,10 +3,14 @@ package stream
 import (
 	"context"
 	"errors"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/harness-community/sse/v3"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/harness/ff-proxy/v2/log"
 )
 
 type mockSSEClient struct {
@@ -65,3 +69,80 @@ func TestSSEClient_Sub(t *testing.T) {
 		})
 	}
 }
+
+// blockingSSEClient blocks until the context is cancelled, sending heartbeats
+// on the interval if it's set
+type blockingSSEClient struct {
+	heartbeat time.Duration
+}
+
+func (b blockingSSEClient) SubscribeWithContext(ctx context.Context, stream string, fn func(msg *sse.Event)) error {
+	if b.heartbeat <= 0 {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	ticker := time.NewTicker(b.heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			fn(&sse.Event{})
+		}
+	}
+}
+
+func TestSSEClient_Sub_IdleTimeout(t *testing.T) {
+	testCases := map[string]struct {
+		sseClient          blockingSSEClient
+		expectedDisconnect int32
+		expectIdle         bool
+	}{
+		"Given the stream stops receiving events": {
+			sseClient:          blockingSSEClient{},
+			expectedDisconnect: 1,
+			expectIdle:         true,
+		},
+		"Given the stream keeps receiving heartbeats": {
+			sseClient:          blockingSSEClient{heartbeat: 5 * time.Millisecond},
+			expectedDisconnect: 0,
+			expectIdle:         false,
+		},
+	}
+
+	for desc, tc := range testCases {
+		desc := desc
+		tc := tc
+
+		t.Run(desc, func(t *testing.T) {
+			disconnects := &atomic.Int32{}
+			connected := &atomic.Bool{}
+			connected.Store(true)
+
+			sc := SSEClient{
+				log:          log.NoOpLogger{},
+				sse:          tc.sseClient,
+				idleTimeout:  50 * time.Millisecond,
+				lastActivity: &atomic.Int64{},
+				connected:    connected,
+				onDisconnect: func() { disconnects.Add(1) },
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+
+			err := sc.Sub(ctx, "", "", func(id string, v interface{}) error { return nil })
+
+			t.Log("Then the subscription is only closed for being idle if no heartbeats were received")
+			assert.Equal(t, tc.expectIdle, errors.Is(err, ErrStreamIdle))
+			assert.Equal(t, tc.expectedDisconnect, disconnects.Load())
+
+			t.Log("And the disconnect handler is only ever called once per connection")
+			sc.disconnected()
+			assert.Equal(t, int32(1), disconnects.Load())
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/harness-community/sse/v3"
@@ -23,6 +24,10 @@ var (
 	errParsingMessage = errors.New("errParsingMessage")
 )
 
+// healthySubscriptionDuration is how long a subscription has to stay up for
+// before we reset the backoff when it disconnects
+const healthySubscriptionDuration = 1 * time.Minute
+
 func WithOnConnect(fn func()) func(s *Stream) {
 	return func(s *Stream) {
 		s.onConnect = fn
@@ -36,13 +41,30 @@ func WithOnDisconnect(fn func()) func(s *Stream) {
 	}
 }
 
-// WithBackoff is an optional func for seeting the backoff duration
+// WithBackoff is an optional func for seeting the backoff duration. The backoff
+// is reset whenever a subscription was healthy before it disconnected.
 func WithBackoff(b backoff.BackOff) func(s *Stream) {
 	return func(s *Stream) {
 		s.backoff = b
 	}
 }
 
+// NewJitteredBackoff returns an exponential backoff that starts at initial and
+// doubles up to max. Each interval is randomised by +/- 50% so that lots of
+// Proxies that disconnect at the same time don't all reconnect together.
+func NewJitteredBackoff(initial time.Duration, max time.Duration) backoff.BackOff {
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = initial
+	b.MaxInterval = max
+	b.Multiplier = 2
+	b.RandomizationFactor = 0.5
+
+	// Never give up trying to reconnect
+	b.MaxElapsedTime = 0
+	b.Reset()
+	return b
+}
+
 // Stream defines a type that can subscribe to a stream and handle events that come off it
 type Stream struct {
 	log            log.Logger
@@ -100,6 +122,9 @@ func (s Stream) subscribe(ctx context.Context) {
 	}
 
 	msgID := ""
+	start := time.Now()
+	received := &atomic.Bool{}
+
 	err := s.stream.Sub(ctx, s.topic, msgID, func(id string, v interface{}) (err error) {
 		msg, err := parseMessage(v)
 		if err != nil {
@@ -107,6 +132,7 @@ func (s Stream) subscribe(ctx context.Context) {
 		}
 
 		msgID = id
+		received.Store(true)
 
 		return s.messageHandler.HandleMessage(ctx, msg)
 	})
@@ -120,6 +146,13 @@ func (s Stream) subscribe(ctx context.Context) {
 		s.onDisconnect()
 	}
 
+	// If the subscription was healthy before it disconnected we want to start
+	// backing off from the beginning again rather than carrying on from where
+	// the last run of failed subscriptions left off
+	if received.Load() || time.Since(start) >= healthySubscriptionDuration {
+		s.backoff.Reset()
+	}
+
 	backoffDuration := s.backoff.NextBackOff()
 	s.log.Warn("disconnected from stream, backing off and retrying", "backoff_duration", backoffDuration, "err", err, "msgID", msgID)
 	time.Sleep(backoffDuration)
@@ -0,0 +1,65 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/harness/ff-proxy/v2/domain"
+	"github.com/harness/ff-proxy/v2/tracing"
+)
+
+// TracingStream is a Stream decorator that creates spans for the messages
+// published and received on a stream
+type TracingStream struct {
+	name string
+	next domain.Stream
+}
+
+// NewTracingStream creates a TracingStream. The name is used to tell spans
+// from different streams apart e.g. redis & pushpin.
+func NewTracingStream(name string, next domain.Stream) TracingStream {
+	return TracingStream{name: name, next: next}
+}
+
+// Pub creates a span and calls the decorated streams Pub method
+func (t TracingStream) Pub(ctx context.Context, channel string, msg interface{}) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, fmt.Sprintf("%s publish", t.name),
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String(t.name),
+			semconv.MessagingDestinationName(channel),
+			semconv.MessagingOperationTypePublish,
+		),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.Pub(ctx, channel, msg)
+}
+
+// Sub calls the decorated streams Sub method and creates a span for each
+// message that's received. Subscriptions last for as long as the Proxy is
+// running so each message starts a new trace.
+func (t TracingStream) Sub(ctx context.Context, channel string, id string, msgFn domain.HandleMessageFn) error {
+	return t.next.Sub(ctx, channel, id, func(id string, v interface{}) (err error) {
+		_, span := tracing.Tracer().Start(ctx, fmt.Sprintf("%s receive", t.name),
+			trace.WithNewRoot(),
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(
+				semconv.MessagingSystemKey.String(t.name),
+				semconv.MessagingDestinationName(channel),
+				semconv.MessagingOperationTypeReceive,
+			),
+		)
+		defer func() { tracing.End(span, err) }()
+
+		return msgFn(id, v)
+	})
+}
+
+// Close calls the decorated streams Close method
+func (t TracingStream) Close(channel string) error {
+	return t.next.Close(channel)
+}
@@ -66,6 +66,7 @@ var onlineProxyRedisTemplate = `ACCOUNT_IDENTIFIER=%s
 ORG_IDENTIFIER=%s
 SECONDARY_ORG_IDENTIFIER=%s
 AUTH_SECRET=my_secret
+INSECURE_DEV_MODE=true
 REDIS_ADDRESS=redis:6379
 PORT=9000
 TARGET_POLL_DURATION=0
@@ -0,0 +1,275 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	// AlgorithmHS256 signs tokens with a shared secret
+	AlgorithmHS256 = "HS256"
+	// AlgorithmRS256 signs tokens with an RSA private key
+	AlgorithmRS256 = "RS256"
+	// AlgorithmEdDSA signs tokens with an Ed25519 private key
+	AlgorithmEdDSA = "EdDSA"
+)
+
+var (
+	// ErrUnknownKeyID is the error returned when a token was signed by a key
+	// that isn't in the KeySet
+	ErrUnknownKeyID = errors.New("unknown key id")
+
+	// ErrKeyExpired is the error returned when a token was signed by a key
+	// whose grace period has ended
+	ErrKeyExpired = errors.New("signing key has expired")
+)
+
+// Key is a key that can verify and optionally sign auth tokens
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+
+	signKey   interface{}
+	verifyKey interface{}
+
+	// expires is when the key stops being accepted for verifying tokens, a
+	// zero value means it never expires
+	expires time.Time
+}
+
+// CanSign returns true if the Key has the private key or secret needed to sign tokens
+func (k Key) CanSign() bool {
+	return k.signKey != nil
+}
+
+// NewHMACKey creates an HS256 Key from a shared secret
+func NewHMACKey(id string, secret []byte) Key {
+	return Key{ID: id, Method: jwt.SigningMethodHS256, signKey: secret, verifyKey: secret}
+}
+
+// SecretKeyID returns a key id derived from an HS256 secret, prefixed with
+// prefix if it's set. Every Proxy that loads the same secret derives the same
+// id whether it loaded the secret at startup or when it was rotated, so they
+// can verify each other's tokens and a rotated secret never has the same id
+// as the one it replaced.
+func SecretKeyID(prefix string, secret []byte) string {
+	sum := sha256.Sum256(secret)
+	id := hex.EncodeToString(sum[:4])
+	if prefix == "" {
+		return id
+	}
+	return prefix + "-" + id
+}
+
+// NewRSAKey creates an RS256 Key from a PEM encoded RSA private or public key.
+// Keys created from a public key can only verify tokens.
+func NewRSAKey(id string, pemBytes []byte) (Key, error) {
+	if private, err := jwt.ParseRSAPrivateKeyFromPEM(pemBytes); err == nil {
+		return Key{ID: id, Method: jwt.SigningMethodRS256, signKey: private, verifyKey: private.Public().(*rsa.PublicKey)}, nil
+	}
+
+	public, err := jwt.ParseRSAPublicKeyFromPEM(pemBytes)
+	if err != nil {
+		return Key{}, fmt.Errorf("failed to parse RSA key %q: %s", id, err)
+	}
+	return Key{ID: id, Method: jwt.SigningMethodRS256, verifyKey: public}, nil
+}
+
+// NewEdDSAKey creates an EdDSA Key from a PEM encoded Ed25519 private or public
+// key. Keys created from a public key can only verify tokens.
+func NewEdDSAKey(id string, pemBytes []byte) (Key, error) {
+	if private, err := jwt.ParseEdPrivateKeyFromPEM(pemBytes); err == nil {
+		signer, ok := private.(ed25519.PrivateKey)
+		if !ok {
+			return Key{}, fmt.Errorf("failed to parse EdDSA key %q: not an Ed25519 key", id)
+		}
+		return Key{ID: id, Method: jwt.SigningMethodEdDSA, signKey: signer, verifyKey: signer.Public()}, nil
+	}
+
+	public, err := jwt.ParseEdPublicKeyFromPEM(pemBytes)
+	if err != nil {
+		return Key{}, fmt.Errorf("failed to parse EdDSA key %q: %s", id, err)
+	}
+	if _, ok := public.(ed25519.PublicKey); !ok {
+		return Key{}, fmt.Errorf("failed to parse EdDSA key %q: not an Ed25519 key", id)
+	}
+	return Key{ID: id, Method: jwt.SigningMethodEdDSA, verifyKey: crypto.PublicKey(public)}, nil
+}
+
+// LoadKey creates a Key using the algorithm from the file at path. For HS256
+// the file contains the secret, otherwise it contains a PEM encoded key.
+func LoadKey(id string, algorithm string, path string) (Key, error) {
+	// #nosec G304
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Key{}, fmt.Errorf("failed to read key %q: %s", id, err)
+	}
+
+	switch algorithm {
+	case AlgorithmHS256:
+		secret := strings.TrimSpace(string(b))
+		if secret == "" {
+			return Key{}, fmt.Errorf("key %q is empty", id)
+		}
+		return NewHMACKey(id, []byte(secret)), nil
+	case AlgorithmRS256:
+		return NewRSAKey(id, b)
+	case AlgorithmEdDSA:
+		return NewEdDSAKey(id, b)
+	default:
+		return Key{}, fmt.Errorf("unsupported algorithm %q for key %q, valid options are %s, %s & %s", algorithm, id, AlgorithmHS256, AlgorithmRS256, AlgorithmEdDSA)
+	}
+}
+
+// ParseKeySpecs loads a comma separated list of keys in the format
+// '<kid>:<algorithm>:<path>'
+func ParseKeySpecs(s string) ([]Key, error) {
+	keys := []Key{}
+	if s == "" {
+		return keys, nil
+	}
+
+	for _, spec := range strings.Split(s, ",") {
+		parts := strings.SplitN(strings.TrimSpace(spec), ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid key %q, expected <kid>:<algorithm>:<path>", spec)
+		}
+
+		k, err := LoadKey(parts[0], parts[1], parts[2])
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+// KeySet is the key that new tokens are signed with and any previous keys that
+// tokens can still be verified with. Tokens carry the ID of the key that signed
+// them in their 'kid' header. Copies of a KeySet share the same keys so changes
+// to the signing key are seen everywhere it's used.
+type KeySet struct {
+	mtx     *sync.RWMutex
+	signing *Key
+	keys    map[string]Key
+	now     func() time.Time
+}
+
+// NewKeySet creates a KeySet that signs tokens with the signing key. Tokens
+// signed by any of the previous keys are accepted until they're retired, a zero
+// retiredAt means they're accepted indefinitely. The retirement time is absolute
+// so that restarting the Proxy doesn't extend it.
+func NewKeySet(signing Key, previous []Key, retiredAt time.Time) (KeySet, error) {
+	if !signing.CanSign() {
+		return KeySet{}, fmt.Errorf("key %q can't be used to sign tokens because it only has a public key", signing.ID)
+	}
+
+	ks := KeySet{
+		mtx:     &sync.RWMutex{},
+		signing: &signing,
+		keys:    map[string]Key{signing.ID: signing},
+		now:     time.Now,
+	}
+
+	for _, k := range previous {
+		if _, ok := ks.keys[k.ID]; ok {
+			return KeySet{}, fmt.Errorf("duplicate key id %q", k.ID)
+		}
+
+		k.expires = retiredAt
+		ks.keys[k.ID] = k
+	}
+
+	return ks, nil
+}
+
+// NewHMACKeySet creates a KeySet that signs and verifies tokens with a single
+// shared secret and no key ID, which is how tokens were signed before the
+// Proxy supported multiple keys.
+func NewHMACKeySet(secret []byte) KeySet {
+	ks, _ := NewKeySet(NewHMACKey("", secret), nil, time.Time{})
+	return ks
+}
+
+// SetSigningKey replaces the key that new tokens are signed with. If the new
+// key has a different ID the old one is accepted until the grace period has
+// passed, otherwise tokens signed by the old key are rejected straight away.
+func (k KeySet) SetSigningKey(signing Key, gracePeriod time.Duration) error {
+	if !signing.CanSign() {
+		return fmt.Errorf("key %q can't be used to sign tokens because it only has a public key", signing.ID)
+	}
+
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+
+	if old := *k.signing; old.ID != signing.ID {
+		old.expires = k.expiry(gracePeriod)
+		k.keys[old.ID] = old
+	}
+
+	*k.signing = signing
+	k.keys[signing.ID] = signing
+	return nil
+}
+
+// Sign creates a signed token from the claims using the signing key
+func (k KeySet) Sign(claims jwt.Claims) (string, error) {
+	k.mtx.RLock()
+	signing := *k.signing
+	k.mtx.RUnlock()
+
+	token := jwt.NewWithClaims(signing.Method, claims)
+
+	// Tokens signed with the legacy key don't have a kid so that Proxies that
+	// haven't been upgraded can still verify them
+	if signing.ID != "" {
+		token.Header["kid"] = signing.ID
+	}
+
+	return token.SignedString(signing.signKey)
+}
+
+// Keyfunc returns the key to verify a token with based on its kid header. It
+// errors if the key is unknown, its grace period has ended or the token's
+// algorithm doesn't match the key's so a token can't pick how it's verified.
+func (k KeySet) Keyfunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+
+	k.mtx.RLock()
+	key, ok := k.keys[kid]
+	k.mtx.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKeyID, kid)
+	}
+
+	if !key.expires.IsZero() && k.now().After(key.expires) {
+		return nil, fmt.Errorf("%w: %q", ErrKeyExpired, kid)
+	}
+
+	if t.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %q for key %q", t.Method.Alg(), kid)
+	}
+
+	return key.verifyKey, nil
+}
+
+// expiry returns when a key with the grace period stops being accepted
+func (k KeySet) expiry(gracePeriod time.Duration) time.Time {
+	if gracePeriod <= 0 {
+		return time.Time{}
+	}
+	return k.now().Add(gracePeriod)
+}
@@ -0,0 +1,378 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/harness/ff-proxy/v2/domain"
+)
+
+func mustRSAKeyPEM(t *testing.T) (private []byte, public []byte) {
+	t.Helper()
+
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pub, err := x509.MarshalPKIXPublicKey(&k.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k)}),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub})
+}
+
+func mustEdDSAKeyPEM(t *testing.T) (private []byte, public []byte) {
+	t.Helper()
+
+	pubKey, privKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	priv, err := x509.MarshalPKCS8PrivateKey(privKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pub, err := x509.MarshalPKIXPublicKey(pubKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: priv}),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub})
+}
+
+func parseToken(tokenString string, ks KeySet) (*domain.Claims, error) {
+	claims := &domain.Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, ks.Keyfunc)
+	return claims, err
+}
+
+func TestKeySet_SignAndVerify(t *testing.T) {
+	rsaPrivate, rsaPublic := mustRSAKeyPEM(t)
+	edPrivate, edPublic := mustEdDSAKeyPEM(t)
+
+	rsaKey, err := NewRSAKey("rsa-1", rsaPrivate)
+	assert.Nil(t, err)
+	rsaPublicKey, err := NewRSAKey("rsa-1", rsaPublic)
+	assert.Nil(t, err)
+
+	edKey, err := NewEdDSAKey("ed-1", edPrivate)
+	assert.Nil(t, err)
+	edPublicKey, err := NewEdDSAKey("ed-1", edPublic)
+	assert.Nil(t, err)
+
+	testCases := map[string]struct {
+		signer      KeySet
+		verifier    KeySet
+		expectedKID interface{}
+		shouldErr   bool
+	}{
+		"Given I sign and verify with the legacy HMAC key": {
+			signer:      NewHMACKeySet([]byte("secret")),
+			verifier:    NewHMACKeySet([]byte("secret")),
+			expectedKID: nil,
+		},
+		"Given I sign and verify with an HMAC key that has a kid": {
+			signer:      mustKeySet(t, NewHMACKey("hmac-1", []byte("secret"))),
+			verifier:    mustKeySet(t, NewHMACKey("hmac-1", []byte("secret"))),
+			expectedKID: "hmac-1",
+		},
+		"Given I sign with an RSA key and verify with its public key": {
+			signer:      mustKeySet(t, rsaKey),
+			verifier:    mustKeySet(t, NewHMACKey("", []byte("secret")), rsaPublicKey),
+			expectedKID: "rsa-1",
+		},
+		"Given I sign with an EdDSA key and verify with its public key": {
+			signer:      mustKeySet(t, edKey),
+			verifier:    mustKeySet(t, NewHMACKey("", []byte("secret")), edPublicKey),
+			expectedKID: "ed-1",
+		},
+		"Given I verify with a different HMAC secret": {
+			signer:    NewHMACKeySet([]byte("secret")),
+			verifier:  NewHMACKeySet([]byte("other")),
+			shouldErr: true,
+		},
+		"Given I verify a token signed by an unknown kid": {
+			signer:      mustKeySet(t, NewHMACKey("hmac-2", []byte("secret"))),
+			verifier:    mustKeySet(t, NewHMACKey("hmac-1", []byte("secret"))),
+			expectedKID: "hmac-2",
+			shouldErr:   true,
+		},
+	}
+
+	for desc, tc := range testCases {
+		desc := desc
+		tc := tc
+
+		t.Run(desc, func(t *testing.T) {
+			tokenString, err := tc.signer.Sign(domain.Claims{Environment: "env-123"})
+			assert.Nil(t, err)
+
+			parsed, _ := jwt.Parse(tokenString, nil)
+			assert.Equal(t, tc.expectedKID, parsed.Header["kid"])
+
+			claims, err := parseToken(tokenString, tc.verifier)
+			if (err != nil) != tc.shouldErr {
+				t.Errorf("(%s): error = %v, shouldErr = %v", desc, err, tc.shouldErr)
+			}
+
+			if !tc.shouldErr {
+				assert.Equal(t, "env-123", claims.Environment)
+			}
+		})
+	}
+}
+
+// mustKeySet creates a KeySet that signs with the first key and accepts the rest indefinitely
+func mustKeySet(t *testing.T, signing Key, previous ...Key) KeySet {
+	t.Helper()
+
+	ks, err := NewKeySet(signing, previous, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ks
+}
+
+func TestKeySet_Rotation(t *testing.T) {
+	oldKeys := NewHMACKeySet([]byte("old-secret"))
+	retiredAt := time.Now().Add(time.Hour)
+	newKeys, err := NewKeySet(NewHMACKey("v2", []byte("new-secret")), []Key{NewHMACKey("", []byte("old-secret"))}, retiredAt)
+	assert.Nil(t, err)
+
+	oldToken, err := oldKeys.Sign(domain.Claims{Environment: "env-123"})
+	assert.Nil(t, err)
+
+	newToken, err := newKeys.Sign(domain.Claims{Environment: "env-123"})
+	assert.Nil(t, err)
+
+	t.Log("When I verify tokens before the old key is retired both keys are accepted")
+	_, err = parseToken(oldToken, newKeys)
+	assert.Nil(t, err)
+	_, err = parseToken(newToken, newKeys)
+	assert.Nil(t, err)
+
+	t.Log("When the old key has been retired tokens signed with it are rejected")
+	newKeys.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+
+	_, err = parseToken(oldToken, newKeys)
+	assert.True(t, errors.Is(err, ErrKeyExpired))
+	_, err = parseToken(newToken, newKeys)
+	assert.Nil(t, err)
+
+	t.Log("And recreating the KeySet, e.g. after a restart, doesn't extend the old key's retirement")
+	restarted, err := NewKeySet(NewHMACKey("v2", []byte("new-secret")), []Key{NewHMACKey("", []byte("old-secret"))}, retiredAt)
+	assert.Nil(t, err)
+	restarted.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+
+	_, err = parseToken(oldToken, restarted)
+	assert.True(t, errors.Is(err, ErrKeyExpired))
+}
+
+func TestKeySet_SetSigningKey(t *testing.T) {
+	keys := NewHMACKeySet([]byte("old-secret"))
+
+	// Copies of the KeySet, e.g. the ones held by the token Source and the auth
+	// middleware, should see the new signing key
+	verifier := keys
+
+	oldToken, err := keys.Sign(domain.Claims{Environment: "env-123"})
+	assert.Nil(t, err)
+
+	t.Log("When I replace the signing key with one that has the same id")
+	assert.Nil(t, keys.SetSigningKey(NewHMACKey("", []byte("new-secret")), time.Hour))
+
+	newToken, err := keys.Sign(domain.Claims{Environment: "env-123"})
+	assert.Nil(t, err)
+
+	t.Log("Then tokens signed by the old key are rejected straight away")
+	_, err = parseToken(oldToken, verifier)
+	assert.NotNil(t, err)
+	_, err = parseToken(newToken, verifier)
+	assert.Nil(t, err)
+
+	t.Log("When I replace the signing key with one that has a different id")
+	assert.Nil(t, keys.SetSigningKey(NewHMACKey("v2", []byte("newer-secret")), time.Hour))
+
+	newerToken, err := keys.Sign(domain.Claims{Environment: "env-123"})
+	assert.Nil(t, err)
+
+	t.Log("Then tokens signed by the old key are accepted during the grace period")
+	_, err = parseToken(newToken, verifier)
+	assert.Nil(t, err)
+	_, err = parseToken(newerToken, verifier)
+	assert.Nil(t, err)
+
+	verifier.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	_, err = parseToken(newToken, verifier)
+	assert.True(t, errors.Is(err, ErrKeyExpired))
+}
+
+func TestSecretKeyID_Rotation(t *testing.T) {
+	oldSecret := []byte("old-secret")
+	newSecret := []byte("new-secret")
+
+	t.Log("Given I have a Proxy that's been running since before the secret was rotated")
+	running := mustKeySet(t, NewHMACKey(SecretKeyID("v1", oldSecret), oldSecret))
+	oldToken, err := running.Sign(domain.Claims{Environment: "env-123"})
+	assert.Nil(t, err)
+
+	assert.Nil(t, running.SetSigningKey(NewHMACKey(SecretKeyID("v1", newSecret), newSecret), time.Hour))
+
+	t.Log("And a Proxy that was started after the secret was rotated")
+	started := mustKeySet(t, NewHMACKey(SecretKeyID("v1", newSecret), newSecret))
+
+	t.Log("Then tokens signed by the freshly started Proxy are accepted by the running Proxy")
+	startedToken, err := started.Sign(domain.Claims{Environment: "env-123"})
+	assert.Nil(t, err)
+	_, err = parseToken(startedToken, running)
+	assert.Nil(t, err)
+
+	t.Log("And tokens signed by the running Proxy are accepted by the freshly started Proxy")
+	runningToken, err := running.Sign(domain.Claims{Environment: "env-123"})
+	assert.Nil(t, err)
+	_, err = parseToken(runningToken, started)
+	assert.Nil(t, err)
+
+	t.Log("And the running Proxy still accepts tokens signed with the old secret during the grace period")
+	_, err = parseToken(oldToken, running)
+	assert.Nil(t, err)
+}
+
+func TestKeySet_Keyfunc_AlgorithmMismatch(t *testing.T) {
+	_, rsaPublic := mustRSAKeyPEM(t)
+
+	rsaPublicKey, err := NewRSAKey("rsa-1", rsaPublic)
+	assert.Nil(t, err)
+
+	verifier := mustKeySet(t, NewHMACKey("", []byte("secret")), rsaPublicKey)
+
+	// An HS256 token signed with the public key as the secret must not be
+	// verified against the RSA key
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, domain.Claims{})
+	token.Header["kid"] = "rsa-1"
+	tokenString, err := token.SignedString(rsaPublic)
+	assert.Nil(t, err)
+
+	_, err = parseToken(tokenString, verifier)
+	assert.NotNil(t, err)
+}
+
+func TestNewKeySet(t *testing.T) {
+	_, rsaPublic := mustRSAKeyPEM(t)
+
+	rsaPublicKey, err := NewRSAKey("rsa-1", rsaPublic)
+	assert.Nil(t, err)
+
+	testCases := map[string]struct {
+		signing   Key
+		previous  []Key
+		shouldErr bool
+	}{
+		"Given I have a signing key and a previous key": {
+			signing:  NewHMACKey("v2", []byte("secret")),
+			previous: []Key{NewHMACKey("v1", []byte("secret"))},
+		},
+		"Given my signing key only has a public key": {
+			signing:   rsaPublicKey,
+			shouldErr: true,
+		},
+		"Given I have duplicate key ids": {
+			signing:   NewHMACKey("v1", []byte("secret")),
+			previous:  []Key{NewHMACKey("v1", []byte("other"))},
+			shouldErr: true,
+		},
+	}
+
+	for desc, tc := range testCases {
+		desc := desc
+		tc := tc
+
+		t.Run(desc, func(t *testing.T) {
+			_, err := NewKeySet(tc.signing, tc.previous, time.Time{})
+			if (err != nil) != tc.shouldErr {
+				t.Errorf("(%s): error = %v, shouldErr = %v", desc, err, tc.shouldErr)
+			}
+		})
+	}
+}
+
+func TestParseKeySpecs(t *testing.T) {
+	dir := t.TempDir()
+
+	_, rsaPublic := mustRSAKeyPEM(t)
+	rsaPath := filepath.Join(dir, "rsa.pub")
+	assert.Nil(t, os.WriteFile(rsaPath, rsaPublic, 0o600))
+
+	secretPath := filepath.Join(dir, "secret")
+	assert.Nil(t, os.WriteFile(secretPath, []byte("old-secret\n"), 0o600))
+
+	testCases := map[string]struct {
+		value       string
+		expectedIDs []string
+		shouldErr   bool
+	}{
+		"Given I have an empty string": {
+			value:       "",
+			expectedIDs: []string{},
+		},
+		"Given I have an HMAC and an RSA key": {
+			value:       "v1:HS256:" + secretPath + ", rsa-1:RS256:" + rsaPath,
+			expectedIDs: []string{"v1", "rsa-1"},
+		},
+		"Given I have a key with an unsupported algorithm": {
+			value:     "v1:HS512:" + secretPath,
+			shouldErr: true,
+		},
+		"Given I have a key that doesn't match its algorithm": {
+			value:     "v1:EdDSA:" + rsaPath,
+			shouldErr: true,
+		},
+		"Given I have a key file that doesn't exist": {
+			value:     "v1:HS256:" + filepath.Join(dir, "missing"),
+			shouldErr: true,
+		},
+		"Given I have a key without an algorithm": {
+			value:     "v1",
+			shouldErr: true,
+		},
+	}
+
+	for desc, tc := range testCases {
+		desc := desc
+		tc := tc
+
+		t.Run(desc, func(t *testing.T) {
+			keys, err := ParseKeySpecs(tc.value)
+			if (err != nil) != tc.shouldErr {
+				t.Errorf("(%s): error = %v, shouldErr = %v", desc, err, tc.shouldErr)
+			}
+
+			if tc.shouldErr {
+				return
+			}
+
+			ids := []string{}
+			for _, k := range keys {
+				ids = append(ids, k.ID)
+			}
+			assert.Equal(t, tc.expectedIDs, ids)
+		})
+	}
+}
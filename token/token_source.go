@@ -9,6 +9,7 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 
 	"github.com/harness/ff-proxy/v2/domain"
+	"github.com/harness/ff-proxy/v2/hash"
 	"github.com/harness/ff-proxy/v2/log"
 )
 
@@ -16,22 +17,32 @@ type authRepo interface {
 	Get(context context.Context, key domain.AuthAPIKey) (string, bool, error)
 }
 
-type hasher interface {
-	Hash(s string) string
-}
-
 // Source is a type that can create and validate tokens
 type Source struct {
 	repo   authRepo
-	hasher hasher
-	secret []byte
+	hasher hash.Hasher
+	keys   KeySet
+	ttl    time.Duration
 	log    log.Logger
 }
 
-// NewSource creates a new Source
-func NewSource(l log.Logger, repo authRepo, hasher hasher, secret []byte) Source {
+// WithTTL sets how long the tokens generated by the Source are valid for. A
+// ttl of zero means tokens don't expire.
+func WithTTL(ttl time.Duration) func(s *Source) {
+	return func(s *Source) {
+		s.ttl = ttl
+	}
+}
+
+// NewSource creates a new Source that signs tokens using the KeySet
+func NewSource(l log.Logger, repo authRepo, hasher hash.Hasher, keys KeySet, opts ...func(s *Source)) Source {
 	l = l.With("component", "Source")
-	return Source{log: l, repo: repo, hasher: hasher, secret: secret}
+	s := Source{log: l, repo: repo, hasher: hasher, keys: keys}
+
+	for _, opt := range opts {
+		opt(&s)
+	}
+	return s
 }
 
 // GenerateToken creates a token from a key
@@ -61,8 +72,11 @@ func (a Source) GenerateToken(key string) (domain.Token, error) {
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, c)
-	authToken, err := token.SignedString(a.secret)
+	if a.ttl > 0 {
+		c.ExpiresAt = jwt.NewNumericDate(t.Add(a.ttl))
+	}
+
+	authToken, err := a.keys.Sign(c)
 	if err != nil {
 		return domain.Token{}, err
 	}
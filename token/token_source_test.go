@@ -2,6 +2,7 @@ package token
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -32,7 +33,7 @@ func TestTokenSource_GenerateToken(t *testing.T) {
 	authRepo := repository.NewAuthRepo(cache.NewMemCache())
 	assert.Nil(t, authRepo.Add(context.Background(), authConfig))
 
-	tokenSource := NewSource(log.NoOpLogger{}, authRepo, hash.NewSha256(), secret)
+	tokenSource := NewSource(log.NoOpLogger{}, authRepo, hash.NewSha256(), NewHMACKeySet(secret))
 
 	testCases := map[string]struct {
 		key         string
@@ -85,6 +86,106 @@ func TestTokenSource_GenerateToken(t *testing.T) {
 	}
 }
 
+func TestTokenSource_GenerateToken_TTL(t *testing.T) {
+	const (
+		unhashedKey = "21ee6c7a-f78d-4afd-86a1-5c108aad41e8"
+		hashedKey   = "bc1ca6b8271bfef0485c9f2978cbb2e1536801f312dc069a344c85146ad7cdb3"
+		envID       = "aba48e5a-3161-4622-b4c4-a3fcc2f22ed7"
+	)
+	keys := NewHMACKeySet([]byte(`secret`))
+
+	authRepo := repository.NewAuthRepo(cache.NewMemCache())
+	assert.Nil(t, authRepo.Add(context.Background(), domain.AuthConfig{
+		APIKey:        domain.NewAuthAPIKey(hashedKey),
+		EnvironmentID: envID,
+	}))
+
+	testCases := map[string]struct {
+		ttl              time.Duration
+		expectsExpiresAt bool
+	}{
+		"Given I don't set a TTL": {
+			ttl:              0,
+			expectsExpiresAt: false,
+		},
+		"Given I set a TTL": {
+			ttl:              time.Hour,
+			expectsExpiresAt: true,
+		},
+	}
+
+	for desc, tc := range testCases {
+		tc := tc
+		t.Run(desc, func(t *testing.T) {
+			tokenSource := NewSource(log.NoOpLogger{}, authRepo, hash.NewSha256(), keys, WithTTL(tc.ttl))
+
+			actual, err := tokenSource.GenerateToken(unhashedKey)
+			assert.Nil(t, err)
+
+			claims := &domain.Claims{}
+			_, err = jwt.ParseWithClaims(actual.TokenString(), claims, keys.Keyfunc)
+			assert.Nil(t, err)
+
+			if !tc.expectsExpiresAt {
+				assert.Nil(t, claims.ExpiresAt)
+				return
+			}
+
+			assert.NotNil(t, claims.ExpiresAt)
+			assert.WithinDuration(t, claims.IssuedAt.Add(tc.ttl), claims.ExpiresAt.Time, time.Second)
+		})
+	}
+
+	t.Log("When a token has expired it should fail to verify")
+	expired, err := keys.Sign(domain.Claims{
+		Environment: envID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+		},
+	})
+	assert.Nil(t, err)
+
+	_, err = jwt.ParseWithClaims(expired, &domain.Claims{}, keys.Keyfunc)
+	assert.True(t, errors.Is(err, jwt.ErrTokenExpired))
+}
+
+func TestTokenSource_GenerateToken_HashMigration(t *testing.T) {
+	const (
+		unhashedKey = "21ee6c7a-f78d-4afd-86a1-5c108aad41e8"
+		hashedKey   = "bc1ca6b8271bfef0485c9f2978cbb2e1536801f312dc069a344c85146ad7cdb3"
+		envID       = "aba48e5a-3161-4622-b4c4-a3fcc2f22ed7"
+	)
+	keys := NewHMACKeySet([]byte(`secret`))
+	hasher := hash.NewHMACSha256("pepper")
+
+	testCases := map[string]struct {
+		repo repository.AuthRepo
+	}{
+		"Given the key is stored as a v1 hash": {
+			repo: repository.NewAuthRepo(cache.NewMemCache()),
+		},
+		"Given the key is stored as a v2 hash": {
+			repo: repository.NewAuthRepo(cache.NewMemCache(), repository.WithKeyUpgrader(hasher)),
+		},
+	}
+
+	for desc, tc := range testCases {
+		tc := tc
+		t.Run(desc, func(t *testing.T) {
+			assert.Nil(t, tc.repo.Add(context.Background(), domain.AuthConfig{
+				APIKey:        domain.NewAuthAPIKey(hashedKey),
+				EnvironmentID: envID,
+			}))
+
+			tokenSource := NewSource(log.NoOpLogger{}, tc.repo, hash.NewSha256(), keys)
+
+			actual, err := tokenSource.GenerateToken(unhashedKey)
+			assert.Nil(t, err)
+			assert.Equal(t, envID, actual.Claims().Environment)
+		})
+	}
+}
+
 func mustGenerateFakeToken(t *testing.T, secret []byte) string {
 	type fakeClaims struct {
 		Foobar string
@@ -0,0 +1,83 @@
+// Package tracing sets up OpenTelemetry tracing for the Proxy and contains
+// helpers for creating spans. Spans are created using the global tracer
+// provider so they're no-ops unless NewProvider has been called.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/harness/ff-proxy/v2/build"
+	"github.com/harness/ff-proxy/v2/domain"
+)
+
+const (
+	instrumentationName = "github.com/harness/ff-proxy/v2"
+	serviceName         = "ff-proxy"
+
+	// EnvironmentKey is the attribute key for the environment a span relates to
+	EnvironmentKey = attribute.Key("ff.environment.id")
+)
+
+// NewProvider creates a TracerProvider that exports spans to the OTLP
+// collector at the endpoint over HTTP and registers it as the global provider.
+// It also registers the W3C trace context and baggage propagators so traces
+// are continued from incoming requests and into outgoing ones. sampleRatio is
+// the fraction of new traces that are sampled, requests that are part of a
+// trace that's already been sampled are always sampled.
+func NewProvider(ctx context.Context, endpoint string, sampleRatio float64) (*sdktrace.TracerProvider, error) {
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create otlp trace exporter: %s", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+		semconv.ServiceVersion(build.Version),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %s", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider, nil
+}
+
+// Tracer returns the tracer the Proxy creates spans with
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start starts an internal span with the given attributes
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records the error on the span, if there is one, and ends it. Keys not
+// being found in the cache are expected so they aren't recorded as errors.
+func End(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, domain.ErrCacheNotFound) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
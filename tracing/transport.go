@@ -0,0 +1,64 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Transport is an http.RoundTripper that creates a client span for each
+// request and propagates the trace context to the server in its headers
+type Transport struct {
+	next http.RoundTripper
+}
+
+// NewTransport creates a Transport that makes requests using next. If next is
+// nil http.DefaultTransport is used.
+func NewTransport(next http.RoundTripper) Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return Transport{next: next}
+}
+
+// NewHTTPClient returns a copy of the client that traces its requests
+func NewHTTPClient(c *http.Client) *http.Client {
+	traced := *c
+	traced.Transport = NewTransport(c.Transport)
+	return &traced
+}
+
+// RoundTrip makes Transport implement the http.RoundTripper interface
+func (t Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := Tracer().Start(req.Context(), fmt.Sprintf("HTTP %s", req.Method),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.ServerAddress(req.URL.Hostname()),
+			semconv.URLPath(req.URL.Path),
+		),
+	)
+	defer span.End()
+
+	// RoundTrippers shouldn't modify the request they're given
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return resp, err
+	}
+
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+	}
+	return resp, nil
+}
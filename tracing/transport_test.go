@@ -0,0 +1,94 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newRecorder registers a tracer provider that records spans in memory for
+// the duration of the test
+func newRecorder(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+
+	prevProvider := otel.GetTracerProvider()
+	prevPropagator := otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+	return recorder
+}
+
+func TestTransport_RoundTrip(t *testing.T) {
+	testCases := map[string]struct {
+		status         int
+		expectedStatus codes.Code
+	}{
+		"Given the server responds with a 200": {
+			status:         http.StatusOK,
+			expectedStatus: codes.Unset,
+		},
+		"Given the server responds with a 404": {
+			status:         http.StatusNotFound,
+			expectedStatus: codes.Unset,
+		},
+		"Given the server responds with a 500": {
+			status:         http.StatusInternalServerError,
+			expectedStatus: codes.Error,
+		},
+	}
+
+	for desc, tc := range testCases {
+		tc := tc
+		t.Run(desc, func(t *testing.T) {
+			recorder := newRecorder(t)
+
+			var traceparent string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				traceparent = r.Header.Get("traceparent")
+				w.WriteHeader(tc.status)
+			}))
+			defer server.Close()
+
+			ctx, parent := Tracer().Start(context.Background(), "parent")
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/client/env/123/feature-configs", nil)
+			assert.Nil(t, err)
+
+			t.Log("When I make a request using a traced client")
+			resp, err := NewHTTPClient(server.Client()).Do(req)
+			assert.Nil(t, err)
+			resp.Body.Close()
+			parent.End()
+
+			t.Log("Then a client span is created as a child of the span in the request context")
+			spans := recorder.Ended()
+			assert.Len(t, spans, 2)
+
+			span := spans[0]
+			assert.Equal(t, "HTTP GET", span.Name())
+			assert.Equal(t, trace.SpanKindClient, span.SpanKind())
+			assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+			assert.Equal(t, tc.expectedStatus, span.Status().Code)
+
+			t.Log("And the trace context is sent to the server")
+			assert.Contains(t, traceparent, span.SpanContext().TraceID().String())
+			assert.Contains(t, traceparent, span.SpanContext().SpanID().String())
+
+			t.Log("And the request the client was given isn't modified")
+			assert.Equal(t, "", req.Header.Get("traceparent"))
+		})
+	}
+}
@@ -3,12 +3,14 @@ package transport
 import (
 	"context"
 	"encoding/base64"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"regexp"
+	"strconv"
 
 	"github.com/harness/ff-proxy/v2/domain"
 	"github.com/harness/ff-proxy/v2/log"
@@ -37,6 +39,15 @@ func encodeResponse(_ context.Context, w http.ResponseWriter, response interface
 	return encoder.Encode(response)
 }
 
+// encodeBootstrapResponse encodes bootstrap responses. They contain an auth
+// token and are often injected into HTML pages by a server so we stop them
+// from being cached. The json encoder escapes HTML characters so the response
+// can be embedded in a script tag.
+func encodeBootstrapResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Cache-Control", "no-store")
+	return encodeResponse(ctx, w, response)
+}
+
 func encodeStreamResponse(_ context.Context, w http.ResponseWriter, response interface{}) error {
 	r, ok := response.(domain.StreamResponse)
 	if !ok {
@@ -50,6 +61,58 @@ func encodeStreamResponse(_ context.Context, w http.ResponseWriter, response int
 	return nil
 }
 
+// encodeExportTargetsResponse writes the exported Targets as either JSON or CSV
+// depending on the format that was requested
+func encodeExportTargetsResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	r, ok := response.(domain.ExportTargetsResponse)
+	if !ok {
+		return fmt.Errorf("internal error encoding export targets response")
+	}
+
+	if r.Format != domain.TargetsExportFormatCSV {
+		return encodeResponse(ctx, w, r.Targets)
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="targets.csv"`)
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"identifier", "name", "anonymous", "firstSeen", "lastSeen", "attributes"}); err != nil {
+		return err
+	}
+
+	for _, t := range r.Targets {
+		anonymous := false
+		if t.Anonymous != nil {
+			anonymous = *t.Anonymous
+		}
+
+		attributes := ""
+		if t.Attributes != nil {
+			b, err := jsoniter.Marshal(t.Attributes)
+			if err != nil {
+				return err
+			}
+			attributes = string(b)
+		}
+
+		record := []string{
+			t.Identifier,
+			t.Name,
+			strconv.FormatBool(anonymous),
+			strconv.FormatInt(t.FirstSeen, 10),
+			strconv.FormatInt(t.LastSeen, 10),
+			attributes,
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
 func encodeEchoError(c echo.Context, err error) error {
 	code := codeFrom(err)
 	return c.JSON(code, map[string]interface{}{
@@ -71,6 +134,10 @@ func codeFrom(err error) int {
 		return http.StatusUnauthorized
 	}
 
+	if errors.Is(err, proxyservice.ErrForbidden) {
+		return http.StatusForbidden
+	}
+
 	if errors.Is(err, proxyservice.ErrNotImplemented) {
 		return http.StatusNotImplemented
 	}
@@ -79,6 +146,10 @@ func codeFrom(err error) int {
 		return http.StatusServiceUnavailable
 	}
 
+	if errors.Is(err, proxyservice.ErrUnavailable) {
+		return http.StatusServiceUnavailable
+	}
+
 	return http.StatusInternalServerError
 }
 
@@ -130,6 +201,19 @@ func decodeAuthRequest(c echo.Context, l log.Logger) (interface{}, error) {
 	return req, nil
 }
 
+// decodeBootstrapRequest decodes POST /client/bootstrap requests into a
+// domain.BootstrapRequest. They have the same body as auth requests so are
+// validated in the same way.
+func decodeBootstrapRequest(c echo.Context, l log.Logger) (interface{}, error) {
+	req, err := decodeAuthRequest(c, l)
+	if err != nil {
+		return nil, err
+	}
+
+	authReq := req.(domain.AuthRequest)
+	return domain.BootstrapRequest{APIKey: authReq.APIKey, Target: authReq.Target, Origin: c.Request().Header.Get(echo.HeaderOrigin)}, nil
+}
+
 // decodeHealthRequest returns an empty interface
 func decodeHealthRequest(_ echo.Context, _ log.Logger) (interface{}, error) {
 	return nil, nil
@@ -302,6 +386,94 @@ func decodeMetricsRequest(c echo.Context, l log.Logger) (interface{}, error) {
 	return req, nil
 }
 
+// decodeExportTargetsRequest decodes GET /admin/environments/{environment}/targets
+// requests into a domain.ExportTargetsRequest. The format defaults to JSON if the
+// format query param isn't set.
+func decodeExportTargetsRequest(c echo.Context, l log.Logger) (interface{}, error) {
+	req := domain.ExportTargetsRequest{
+		EnvironmentID: c.Param("environment_uuid"),
+		Format:        c.QueryParam("format"),
+	}
+
+	if req.EnvironmentID == "" {
+		l.Info("invalid ExportTargets request, environmentID cannot be empty", "envID", req.EnvironmentID)
+		return nil, errBadRouting
+	}
+
+	switch req.Format {
+	case "":
+		req.Format = domain.TargetsExportFormatJSON
+	case domain.TargetsExportFormatJSON, domain.TargetsExportFormatCSV:
+	default:
+		l.Info("invalid ExportTargets request, unsupported format", "format", req.Format)
+		return nil, fmt.Errorf("%w: format must be one of %s or %s", errBadRequest, domain.TargetsExportFormatJSON, domain.TargetsExportFormatCSV)
+	}
+
+	return req, nil
+}
+
+// decodeAuditHistoryRequest decodes GET /admin/audit requests into a
+// domain.AuditHistoryRequest. The environment and identifier query params
+// filter the records and the limit defaults to 100.
+func decodeAuditHistoryRequest(c echo.Context, l log.Logger) (interface{}, error) {
+	req := domain.AuditHistoryRequest{
+		Environment: c.QueryParam("environment"),
+		Identifier:  c.QueryParam("identifier"),
+		Limit:       100,
+	}
+
+	if limit := c.QueryParam("limit"); limit != "" {
+		i, err := strconv.Atoi(limit)
+		if err != nil || i < 1 {
+			l.Info("invalid AuditHistory request, limit must be a positive integer", "limit", limit)
+			return nil, fmt.Errorf("%w: limit must be a positive integer", errBadRequest)
+		}
+		req.Limit = i
+	}
+
+	return req, nil
+}
+
+// decodeGenerationRequest decodes requests to get or release the generation
+// of an environment's config into a domain.GenerationRequest
+func decodeGenerationRequest(c echo.Context, l log.Logger) (interface{}, error) {
+	req := domain.GenerationRequest{
+		EnvironmentID: c.Param("environment_uuid"),
+	}
+
+	if req.EnvironmentID == "" {
+		l.Info("invalid Generation request, environmentID cannot be empty", "envID", req.EnvironmentID)
+		return nil, errBadRouting
+	}
+
+	return req, nil
+}
+
+// decodePinGenerationRequest decodes POST /admin/environments/{environment}/generations/pin
+// requests into a domain.PinGenerationRequest. If the generation query param
+// isn't set the environment is pinned to the generation before the current one.
+func decodePinGenerationRequest(c echo.Context, l log.Logger) (interface{}, error) {
+	req := domain.PinGenerationRequest{
+		EnvironmentID: c.Param("environment_uuid"),
+	}
+
+	if req.EnvironmentID == "" {
+		l.Info("invalid PinGeneration request, environmentID cannot be empty", "envID", req.EnvironmentID)
+		return nil, errBadRouting
+	}
+
+	if generation := c.QueryParam("generation"); generation != "" {
+		i, err := strconv.ParseInt(generation, 10, 64)
+		if err != nil || i < 1 {
+			l.Info("invalid PinGeneration request, generation must be a positive integer", "generation", generation)
+			return nil, fmt.Errorf("%w: generation must be a positive integer", errBadRequest)
+		}
+		req.Generation = i
+	}
+
+	return req, nil
+}
+
 var (
 	identifierRegex = regexp.MustCompile("^[A-Za-z0-9.@_-]*$")
 	nameRegex       = regexp.MustCompile(`^[\p{L}\d .@_-]*$`)
@@ -11,6 +11,7 @@ import (
 // Endpoints collects all of the endpoints that make up a ProxyService
 type Endpoints struct {
 	PostAuthenticate              endpoint.Endpoint
+	PostBootstrap                 endpoint.Endpoint
 	GetFeatureConfigs             endpoint.Endpoint
 	GetFeatureConfigsByIdentifier endpoint.Endpoint
 	GetTargetSegments             endpoint.Endpoint
@@ -20,6 +21,14 @@ type Endpoints struct {
 	GetStream                     endpoint.Endpoint
 	PostMetrics                   endpoint.Endpoint
 	Health                        endpoint.Endpoint
+	Liveness                      endpoint.Endpoint
+	Readiness                     endpoint.Endpoint
+	Startup                       endpoint.Endpoint
+	GetExportTargets              endpoint.Endpoint
+	GetAuditHistory               endpoint.Endpoint
+	GetConfigGeneration           endpoint.Endpoint
+	PostPinGeneration             endpoint.Endpoint
+	DeletePinGeneration           endpoint.Endpoint
 }
 
 // NewEndpoints returns an initialised Endpoints where each endpoint invokes the
@@ -27,6 +36,7 @@ type Endpoints struct {
 func NewEndpoints(p proxyservice.ProxyService) *Endpoints {
 	return &Endpoints{
 		PostAuthenticate:              makePostAuthenticateEndpoint(p),
+		PostBootstrap:                 makePostBootstrapEndpoint(p),
 		GetFeatureConfigs:             makeGetFeatureConfigsEndpoint(p),
 		GetFeatureConfigsByIdentifier: makeGetFeatureConfigsByIdentifierEndpoint(p),
 		GetTargetSegments:             makeGetTargetSegmentsEndpoint(p),
@@ -36,6 +46,14 @@ func NewEndpoints(p proxyservice.ProxyService) *Endpoints {
 		GetStream:                     makeGetStreamEndpoint(p),
 		PostMetrics:                   makePostMetricsEndpoint(p),
 		Health:                        makeHealthEndpoint(p),
+		Liveness:                      makeLivenessEndpoint(p),
+		Readiness:                     makeReadinessEndpoint(p),
+		Startup:                       makeStartupEndpoint(p),
+		GetExportTargets:              makeGetExportTargetsEndpoint(p),
+		GetAuditHistory:               makeGetAuditHistoryEndpoint(p),
+		GetConfigGeneration:           makeGetConfigGenerationEndpoint(p),
+		PostPinGeneration:             makePostPinGenerationEndpoint(p),
+		DeletePinGeneration:           makeDeletePinGenerationEndpoint(p),
 	}
 }
 
@@ -52,6 +70,19 @@ func makePostAuthenticateEndpoint(s proxyservice.ProxyService) endpoint.Endpoint
 	}
 }
 
+// makePostBootstrapEndpoint is a function to convert a clients Bootstrap
+// method to an endpoint
+func makePostBootstrapEndpoint(s proxyservice.ProxyService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(domain.BootstrapRequest)
+		resp, err := s.Bootstrap(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+}
+
 // makeGetFeatureConfigsEndpoint is a function to convert a clients GetFeatureConfig
 // method to an endpoint
 func makeGetFeatureConfigsEndpoint(s proxyservice.ProxyService) endpoint.Endpoint {
@@ -166,3 +197,104 @@ func makeHealthEndpoint(s proxyservice.ProxyService) endpoint.Endpoint {
 		return res, nil
 	}
 }
+
+// makeLivenessEndpoint is a function to convert a clients Liveness method
+// to an endpoint
+func makeLivenessEndpoint(s proxyservice.ProxyService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		res, err := s.Liveness(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return res, nil
+	}
+}
+
+// makeReadinessEndpoint is a function to convert a clients Readiness method
+// to an endpoint
+func makeReadinessEndpoint(s proxyservice.ProxyService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		res, err := s.Readiness(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return res, nil
+	}
+}
+
+// makeStartupEndpoint is a function to convert a clients Startup method
+// to an endpoint
+func makeStartupEndpoint(s proxyservice.ProxyService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		res, err := s.Startup(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return res, nil
+	}
+}
+
+// makeGetExportTargetsEndpoint is a function to convert a services ExportTargets
+// method to an endpoint
+func makeGetExportTargetsEndpoint(s proxyservice.ProxyService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(domain.ExportTargetsRequest)
+		resp, err := s.ExportTargets(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+}
+
+// makeGetAuditHistoryEndpoint is a function to convert a services AuditHistory
+// method to an endpoint
+func makeGetAuditHistoryEndpoint(s proxyservice.ProxyService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(domain.AuditHistoryRequest)
+		resp, err := s.AuditHistory(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+}
+
+// makeGetConfigGenerationEndpoint is a function to convert a services
+// ConfigGeneration method to an endpoint
+func makeGetConfigGenerationEndpoint(s proxyservice.ProxyService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(domain.GenerationRequest)
+		resp, err := s.ConfigGeneration(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+}
+
+// makePostPinGenerationEndpoint is a function to convert a services
+// PinGeneration method to an endpoint
+func makePostPinGenerationEndpoint(s proxyservice.ProxyService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(domain.PinGenerationRequest)
+		resp, err := s.PinGeneration(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+}
+
+// makeDeletePinGenerationEndpoint is a function to convert a services
+// ReleaseGeneration method to an endpoint
+func makeDeletePinGenerationEndpoint(s proxyservice.ProxyService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(domain.GenerationRequest)
+		resp, err := s.ReleaseGeneration(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+}
@@ -14,7 +14,11 @@ import (
 
 var proxyRoutes = domain.NewImmutableSet(map[string]struct{}{
 	domain.AuthRoute:                     {},
+	domain.BootstrapRoute:                {},
 	domain.HealthRoute:                   {},
+	domain.LivenessRoute:                 {},
+	domain.ReadinessRoute:                {},
+	domain.StartupRoute:                  {},
 	domain.FeatureConfigsRoute:           {},
 	domain.FeatureConfigsIdentifierRoute: {},
 	domain.SegmentsRoute:                 {},
@@ -23,6 +27,10 @@ var proxyRoutes = domain.NewImmutableSet(map[string]struct{}{
 	domain.EvaluationsFlagRoute:          {},
 	domain.StreamRoute:                   {},
 	domain.MetricsRoute:                  {},
+	domain.AdminTargetsRoute:             {},
+	domain.AdminAuditRoute:               {},
+	domain.AdminGenerationsRoute:         {},
+	domain.AdminGenerationPinRoute:       {},
 })
 
 type prometheusRegister interface {
@@ -42,10 +50,15 @@ type HTTPServer struct {
 
 // NewHTTPServer registers the passed endpoints against routes and returns an
 // HTTPServer that's ready to use
-func NewHTTPServer(port int, e *Endpoints, l log.Logger, tlsEnabled bool, tlsCert string, tlsKey string) *HTTPServer {
+func NewHTTPServer(port int, e *Endpoints, l log.Logger, tlsEnabled bool, tlsCert string, tlsKey string, opts ...func(h *HTTPServer)) *HTTPServer {
 	l = l.With("component", "HTTPServer")
 
 	router := echo.New()
+
+	// By default echo takes the client IP from headers the client can set
+	// themselves, we only want to do that for proxies we trust
+	router.IPExtractor = echo.ExtractIPDirect()
+
 	server := &http.Server{
 		Addr:              fmt.Sprintf(":%d", port),
 		Handler:           router,
@@ -63,6 +76,11 @@ func NewHTTPServer(port int, e *Endpoints, l log.Logger, tlsEnabled bool, tlsCer
 		tlsCert:    tlsCert,
 		tlsKey:     tlsKey,
 	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
 	h.registerEndpoints(e)
 	return h
 }
@@ -104,6 +122,14 @@ func (h *HTTPServer) registerEndpoints(e *Endpoints) {
 		h.log,
 	))
 
+	h.router.POST(domain.BootstrapRoute, NewUnaryHandler(
+		e.PostBootstrap,
+		decodeBootstrapRequest,
+		encodeBootstrapResponse,
+		encodeEchoError,
+		h.log,
+	))
+
 	h.router.GET(domain.HealthRoute, NewUnaryHandler(
 		e.Health,
 		decodeHealthRequest,
@@ -112,6 +138,30 @@ func (h *HTTPServer) registerEndpoints(e *Endpoints) {
 		h.log,
 	))
 
+	h.router.GET(domain.LivenessRoute, NewUnaryHandler(
+		e.Liveness,
+		decodeHealthRequest,
+		encodeResponse,
+		encodeEchoError,
+		h.log,
+	))
+
+	h.router.GET(domain.ReadinessRoute, NewUnaryHandler(
+		e.Readiness,
+		decodeHealthRequest,
+		encodeResponse,
+		encodeEchoError,
+		h.log,
+	))
+
+	h.router.GET(domain.StartupRoute, NewUnaryHandler(
+		e.Startup,
+		decodeHealthRequest,
+		encodeResponse,
+		encodeEchoError,
+		h.log,
+	))
+
 	h.router.GET(domain.FeatureConfigsRoute, NewUnaryHandler(
 		e.GetFeatureConfigs,
 		decodeGetFeatureConfigsRequest,
@@ -175,6 +225,46 @@ func (h *HTTPServer) registerEndpoints(e *Endpoints) {
 		encodeEchoError,
 		h.log,
 	))
+
+	h.router.GET(domain.AdminTargetsRoute, NewUnaryHandler(
+		e.GetExportTargets,
+		decodeExportTargetsRequest,
+		encodeExportTargetsResponse,
+		encodeEchoError,
+		h.log,
+	))
+
+	h.router.GET(domain.AdminAuditRoute, NewUnaryHandler(
+		e.GetAuditHistory,
+		decodeAuditHistoryRequest,
+		encodeResponse,
+		encodeEchoError,
+		h.log,
+	))
+
+	h.router.GET(domain.AdminGenerationsRoute, NewUnaryHandler(
+		e.GetConfigGeneration,
+		decodeGenerationRequest,
+		encodeResponse,
+		encodeEchoError,
+		h.log,
+	))
+
+	h.router.POST(domain.AdminGenerationPinRoute, NewUnaryHandler(
+		e.PostPinGeneration,
+		decodePinGenerationRequest,
+		encodeResponse,
+		encodeEchoError,
+		h.log,
+	))
+
+	h.router.DELETE(domain.AdminGenerationPinRoute, NewUnaryHandler(
+		e.DeletePinGeneration,
+		decodeGenerationRequest,
+		encodeResponse,
+		encodeEchoError,
+		h.log,
+	))
 }
 
 // WithCustomHandler lets you register a custom handler with the HTTPServer
@@ -24,6 +24,7 @@ import (
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/harness/ff-proxy/v2/audit"
 	"github.com/harness/ff-proxy/v2/cache"
 	"github.com/harness/ff-proxy/v2/config/local"
 	"github.com/harness/ff-proxy/v2/domain"
@@ -105,9 +106,16 @@ type setupConfig struct {
 	promReg           prometheusRegister
 	sdkClients        *mockSDKClient
 	healthFn          func(ctx context.Context) domain.HealthResponse
+	readinessFn       func(ctx context.Context) domain.ProbeResponse
+	startupFn         func(ctx context.Context) domain.ProbeResponse
 	healthySaasStream func() bool
 	andRulesEnabled   bool
 	port              int
+	adminToken        string
+	auditHistory      func(req domain.AuditHistoryRequest) []domain.AuditRecord
+	generations       proxyservice.GenerationPinner
+	configVersion     func(ctx context.Context, envID string) string
+	originAllowed     func(envID string, origin string) bool
 }
 
 type setupOpts func(s *setupConfig)
@@ -154,6 +162,18 @@ func setupHealthFn(fn func(ctx context.Context) domain.HealthResponse) setupOpts
 	}
 }
 
+func setupReadinessFn(fn func(ctx context.Context) domain.ProbeResponse) setupOpts {
+	return func(s *setupConfig) {
+		s.readinessFn = fn
+	}
+}
+
+func setupStartupFn(fn func(ctx context.Context) domain.ProbeResponse) setupOpts {
+	return func(s *setupConfig) {
+		s.startupFn = fn
+	}
+}
+
 func setupWithCache(c cache.Cache) setupOpts {
 	return func(s *setupConfig) {
 		s.cache = c
@@ -172,6 +192,36 @@ func setupWithAndRulesEnabled(enabled bool) setupOpts {
 	}
 }
 
+func setupWithAdminToken(token string) setupOpts {
+	return func(s *setupConfig) {
+		s.adminToken = token
+	}
+}
+
+func setupWithAuditHistory(fn func(req domain.AuditHistoryRequest) []domain.AuditRecord) setupOpts {
+	return func(s *setupConfig) {
+		s.auditHistory = fn
+	}
+}
+
+func setupWithGenerations(g proxyservice.GenerationPinner) setupOpts {
+	return func(s *setupConfig) {
+		s.generations = g
+	}
+}
+
+func setupWithConfigVersion(fn func(ctx context.Context, envID string) string) setupOpts {
+	return func(s *setupConfig) {
+		s.configVersion = fn
+	}
+}
+
+func setupWithOriginAllowed(fn func(envID string, origin string) bool) setupOpts {
+	return func(s *setupConfig) {
+		s.originAllowed = fn
+	}
+}
+
 func setupWithPort(port int) setupOpts {
 	return func(s *setupConfig) {
 		s.port = port
@@ -252,10 +302,25 @@ func setupHTTPServer(t *testing.T, bypassAuth bool, opts ...setupOpts) *HTTPServ
 					Since: 1699877509155,
 				},
 				CacheStatus: "healthy",
+				SecurityStatus: domain.SecurityStatus{
+					Status: domain.SecurityStatusSecure,
+				},
 			}
 		}
 	}
 
+	if setupConfig.readinessFn == nil {
+		setupConfig.readinessFn = func(ctx context.Context) domain.ProbeResponse {
+			return domain.ProbeResponse{Status: domain.ProbeStatusHealthy}
+		}
+	}
+
+	if setupConfig.startupFn == nil {
+		setupConfig.startupFn = func(ctx context.Context) domain.ProbeResponse {
+			return domain.ProbeResponse{Status: domain.ProbeStatusHealthy}
+		}
+	}
+
 	if setupConfig.sdkClients == nil {
 		setupConfig.sdkClients = &mockSDKClient{data: make(map[string]bool)}
 	}
@@ -270,7 +335,7 @@ func setupHTTPServer(t *testing.T, bypassAuth bool, opts ...setupOpts) *HTTPServ
 
 	logger := log.NoOpLogger{}
 
-	tokenSource := token.NewSource(logger, setupConfig.authRepo, hash.NewSha256(), []byte(`secret`))
+	tokenSource := token.NewSource(logger, setupConfig.authRepo, hash.NewSha256(), token.NewHMACKeySet([]byte(`secret`)))
 
 	err = config.Populate(context.Background(), setupConfig.authRepo, setupConfig.featureRepo, setupConfig.segmentRepo)
 	assert.Nil(t, err)
@@ -283,6 +348,12 @@ func setupHTTPServer(t *testing.T, bypassAuth bool, opts ...setupOpts) *HTTPServ
 		SegmentRepo:        *setupConfig.segmentRepo,
 		AuthRepo:           *setupConfig.authRepo,
 		Health:             setupConfig.healthFn,
+		Readiness:          setupConfig.readinessFn,
+		Startup:            setupConfig.startupFn,
+		AuditHistory:       setupConfig.auditHistory,
+		Generations:        setupConfig.generations,
+		ConfigVersion:      setupConfig.configVersion,
+		OriginAllowed:      setupConfig.originAllowed,
 		AuthFn:             tokenSource.GenerateToken,
 		ClientService:      setupConfig.clientService,
 		MetricStore:        setupConfig.metricService,
@@ -304,11 +375,12 @@ func setupHTTPServer(t *testing.T, bypassAuth bool, opts ...setupOpts) *HTTPServ
 	server := NewHTTPServer(setupConfig.port, endpoints, logger, false, "", "")
 	server.Use(
 		middleware.NewPrometheusMiddleware(prometheus.NewRegistry()),
-		middleware.NewCorsMiddleware(),
+		middleware.NewCorsMiddleware(middleware.DefaultCorsConfig()),
 		middleware.AllowQuerySemicolons(),
 		middleware.NewEchoRequestIDMiddleware(),
 		middleware.NewEchoLoggingMiddleware(logger),
-		middleware.NewEchoAuthMiddleware(logger, repo, []byte(`secret`), bypassAuth),
+		middleware.NewEchoAdminAuthMiddleware(setupConfig.adminToken),
+		middleware.NewEchoAuthMiddleware(logger, repo, token.NewHMACKeySet([]byte(`secret`)), bypassAuth),
 		middleware.ValidateEnvironment(bypassAuth),
 	)
 	return server
@@ -1215,6 +1287,16 @@ func TestHTTPServer_PostAuthentication(t *testing.T) {
 				cacheTarget, err := targetRepo.GetByIdentifier(context.Background(), envID123, tc.expectedCacheTargets[0].Identifier)
 				assert.Nil(t, err)
 
+				// The TargetRepo records when it saw the Target so make sure
+				// they're set before we clear them to compare the Targets
+				assert.NotZero(t, cacheTarget.FirstSeen)
+				assert.NotZero(t, cacheTarget.LastSeen)
+				cacheTarget.FirstSeen, cacheTarget.LastSeen = 0, 0
+
+				for i := range cacheTargets {
+					cacheTargets[i].FirstSeen, cacheTargets[i].LastSeen = 0, 0
+				}
+
 				assert.Equal(t, tc.expectedCacheTargets[0], cacheTarget)
 
 				t.Log("Then the Targets in the ClientService should match the expected Targets")
@@ -1227,6 +1309,108 @@ func TestHTTPServer_PostAuthentication(t *testing.T) {
 	}
 }
 
+func TestHTTPServer_PostBootstrap(t *testing.T) {
+	configVersion := func(_ context.Context, envID string) string {
+		return envID + "-v1"
+	}
+
+	// envID123 only allows requests from https://app.example.com
+	originAllowed := func(envID string, origin string) bool {
+		return middleware.EnvironmentOriginAllowed(map[string][]string{envID123: {"https://app.example.com"}}, envID, origin)
+	}
+
+	testCases := map[string]struct {
+		body               []byte
+		origin             string
+		healthySaasStream  func() bool
+		expectedStatusCode int
+		expectedChannel    string
+	}{
+		"Given I make a bootstrap request with an APIKey that doesn't exist": {
+			body:               []byte(`{"apiKey": "hello", "target": {"identifier": "foo"}}`),
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		"Given I make a bootstrap request without an APIKey": {
+			body:               []byte(`{"target": {"identifier": "foo"}}`),
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		"Given I make a bootstrap request with an invalid Target Identifier": {
+			body:               []byte(fmt.Sprintf(`{"apiKey": "%s", "target": {"identifier": "hello world"}}`, apiKey1)),
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		"Given I make a bootstrap request with a valid APIKey and Target": {
+			body:               []byte(fmt.Sprintf(`{"apiKey": "%s", "target": {"identifier": "foo"}}`, apiKey1)),
+			expectedStatusCode: http.StatusOK,
+			expectedChannel:    envID123,
+		},
+		"Given I make a bootstrap request from an origin that's allowed for the environment": {
+			body:               []byte(fmt.Sprintf(`{"apiKey": "%s", "target": {"identifier": "foo"}}`, apiKey1)),
+			origin:             "https://app.example.com",
+			expectedStatusCode: http.StatusOK,
+			expectedChannel:    envID123,
+		},
+		"Given I make a bootstrap request from an origin that isn't allowed for the environment": {
+			body:               []byte(fmt.Sprintf(`{"apiKey": "%s", "target": {"identifier": "foo"}}`, apiKey1)),
+			origin:             "https://evil.example.com",
+			expectedStatusCode: http.StatusForbidden,
+		},
+		"Given I make a bootstrap request while the Proxy isn't serving streams": {
+			body:               []byte(fmt.Sprintf(`{"apiKey": "%s", "target": {"identifier": "foo"}}`, apiKey1)),
+			healthySaasStream:  func() bool { return false },
+			expectedStatusCode: http.StatusOK,
+			expectedChannel:    "",
+		},
+	}
+
+	for desc, tc := range testCases {
+		tc := tc
+		t.Run(desc, func(t *testing.T) {
+			// setup HTTPServer & service with auth enabled to make sure
+			// bootstrap requests don't need a token
+			server := setupHTTPServer(t, false,
+				setupWithHealthySaasStream(tc.healthySaasStream),
+				setupWithConfigVersion(configVersion),
+				setupWithOriginAllowed(originAllowed),
+			)
+			testServer := httptest.NewServer(server)
+			defer testServer.Close()
+
+			req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/client/bootstrap", testServer.URL), bytes.NewBuffer(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.origin != "" {
+				req.Header.Set(echo.HeaderOrigin, tc.origin)
+			}
+
+			resp, err := testServer.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.expectedStatusCode, resp.StatusCode)
+			if tc.expectedStatusCode != http.StatusOK {
+				return
+			}
+
+			actual := domain.BootstrapResponse{}
+			if err := json.NewDecoder(resp.Body).Decode(&actual); err != nil {
+				t.Fatalf("(%s): failed to unmarshal response body: %s", desc, err)
+			}
+
+			t.Log("Then the response has an auth token, evaluations, the stream channel and the config version")
+			assert.NotEmpty(t, actual.AuthToken)
+			assert.NotEmpty(t, actual.Evaluations)
+			assert.Equal(t, tc.expectedChannel, actual.StreamChannel)
+			assert.Equal(t, envID123+"-v1", actual.ConfigVersion)
+
+			t.Log("And the response isn't cached")
+			assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+		})
+	}
+}
+
 // TestAuthentication tests the endpoints when the auth middleware is enabled
 func TestAuthentication(t *testing.T) {
 	// setup HTTPServer & service with auth enabled
@@ -1297,7 +1481,7 @@ func TestAuthentication(t *testing.T) {
 // TestHTTPServer_Health sets up a service with health check functions
 // injects it into the HTTPServer and makes HTTP requests to the /health endpoint
 func TestHTTPServer_Health(t *testing.T) {
-	healthyResponse := []byte(`{"configStatus":{"state":"SYNCED","since":1699877509155},"streamStatus":{"state":"CONNECTED","since":1699877509155},"cacheStatus":"healthy"}
+	healthyResponse := []byte(`{"configStatus":{"state":"SYNCED","since":1699877509155},"streamStatus":{"state":"CONNECTED","since":1699877509155},"cacheStatus":"healthy","securityStatus":{"status":"secure","insecureDevMode":false}}
 `)
 
 	unhealthyResponse := []byte(`{"error":"internal error"}
@@ -1389,6 +1573,414 @@ func TestHTTPServer_Health(t *testing.T) {
 	}
 }
 
+func TestHTTPServer_Probes(t *testing.T) {
+	unhealthy := func(ctx context.Context) domain.ProbeResponse {
+		return domain.ProbeResponse{
+			Status: domain.ProbeStatusUnhealthy,
+			Checks: map[string]string{"config": domain.ProbeStatusUnhealthy},
+		}
+	}
+
+	testCases := map[string]struct {
+		route                string
+		readinessFn          func(ctx context.Context) domain.ProbeResponse
+		startupFn            func(ctx context.Context) domain.ProbeResponse
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		"Given I make a GET request to the liveness probe": {
+			route:                domain.LivenessRoute,
+			readinessFn:          unhealthy,
+			startupFn:            unhealthy,
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{"status":"healthy"}` + "\n",
+		},
+		"Given I make a GET request to the readiness probe and the Proxy is ready": {
+			route:                domain.ReadinessRoute,
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{"status":"healthy"}` + "\n",
+		},
+		"Given I make a GET request to the readiness probe and the Proxy isn't ready": {
+			route:                domain.ReadinessRoute,
+			readinessFn:          unhealthy,
+			expectedStatusCode:   http.StatusServiceUnavailable,
+			expectedResponseBody: `{"error":"service unavailable: config=unhealthy"}` + "\n",
+		},
+		"Given I make a GET request to the startup probe and the Proxy has started": {
+			route:                domain.StartupRoute,
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{"status":"healthy"}` + "\n",
+		},
+		"Given I make a GET request to the startup probe and the Proxy hasn't started": {
+			route:                domain.StartupRoute,
+			startupFn:            unhealthy,
+			expectedStatusCode:   http.StatusServiceUnavailable,
+			expectedResponseBody: `{"error":"service unavailable: config=unhealthy"}` + "\n",
+		},
+	}
+
+	for desc, tc := range testCases {
+		tc := tc
+
+		// setup HTTPServer & service with auth enabled to make sure the probes skip it
+		server := setupHTTPServer(t, false,
+			setupReadinessFn(tc.readinessFn),
+			setupStartupFn(tc.startupFn),
+		)
+		testServer := httptest.NewServer(server)
+
+		t.Run(desc, func(t *testing.T) {
+			defer testServer.Close()
+
+			resp, err := testServer.Client().Get(fmt.Sprintf("%s%s", testServer.URL, tc.route))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.expectedStatusCode, resp.StatusCode)
+
+			actual, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("(%s): failed to read response body: %s", desc, err)
+			}
+			assert.Equal(t, tc.expectedResponseBody, string(actual))
+		})
+	}
+}
+
+func TestHTTPServer_ExportTargets(t *testing.T) {
+	const adminToken = "admin-token"
+
+	anonymous := true
+	attributes := map[string]interface{}{"email": "foo@bar.com"}
+
+	targets := []domain.Target{
+		{
+			Target:    clientgen.Target{Identifier: "foo", Name: "Foo", Attributes: &attributes},
+			FirstSeen: 1700000000000,
+			LastSeen:  1700000005000,
+		},
+		{
+			Target:    clientgen.Target{Identifier: "bar", Name: "Bar", Anonymous: &anonymous},
+			FirstSeen: 1700000001000,
+			LastSeen:  1700000002000,
+		},
+	}
+
+	c := cache.NewMemCache()
+	for _, target := range targets {
+		assert.Nil(t, c.Set(context.Background(), string(domain.NewTargetKey(envID123, target.Identifier)), target))
+	}
+
+	testCases := map[string]struct {
+		adminToken           string
+		authHeader           string
+		query                string
+		expectedStatusCode   int
+		expectedContentType  string
+		expectedResponseBody string
+	}{
+		"Given I make a request and the admin endpoints are disabled": {
+			adminToken:           "",
+			authHeader:           "Bearer " + adminToken,
+			expectedStatusCode:   http.StatusForbidden,
+			expectedContentType:  "application/json; charset=UTF-8",
+			expectedResponseBody: `{"message":"admin endpoints are disabled, set ADMIN_TOKEN to enable them"}` + "\n",
+		},
+		"Given I make a request without the admin token": {
+			adminToken:           adminToken,
+			authHeader:           "Bearer " + apiKey123Token,
+			expectedStatusCode:   http.StatusUnauthorized,
+			expectedContentType:  "application/json; charset=UTF-8",
+			expectedResponseBody: `{"message":"invalid admin token"}` + "\n",
+		},
+		"Given I make a request with an unsupported format": {
+			adminToken:           adminToken,
+			authHeader:           "Bearer " + adminToken,
+			query:                "?format=xml",
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedContentType:  "application/json; charset=UTF-8",
+			expectedResponseBody: `{"error":"bad request: format must be one of json or csv"}` + "\n",
+		},
+		"Given I make a request with no format": {
+			adminToken:           adminToken,
+			authHeader:           "Bearer " + adminToken,
+			expectedStatusCode:   http.StatusOK,
+			expectedContentType:  "application/json; charset=utf-8",
+			expectedResponseBody: `[{"account":"","anonymous":true,"environment":"","identifier":"bar","name":"Bar","org":"","project":"","firstSeen":1700000001000,"lastSeen":1700000002000},{"account":"","attributes":{"email":"foo@bar.com"},"environment":"","identifier":"foo","name":"Foo","org":"","project":"","firstSeen":1700000000000,"lastSeen":1700000005000}]` + "\n",
+		},
+		"Given I make a request for a CSV export": {
+			adminToken:          adminToken,
+			authHeader:          "Bearer " + adminToken,
+			query:               "?format=csv",
+			expectedStatusCode:  http.StatusOK,
+			expectedContentType: "text/csv; charset=utf-8",
+			expectedResponseBody: `identifier,name,anonymous,firstSeen,lastSeen,attributes
+bar,Bar,true,1700000001000,1700000002000,
+foo,Foo,false,1700000000000,1700000005000,"{""email"":""foo@bar.com""}"
+`,
+		},
+	}
+
+	for desc, tc := range testCases {
+		tc := tc
+
+		// setup HTTPServer & service with auth enabled to make sure the admin
+		// endpoints use the admin token instead of an SDK token
+		server := setupHTTPServer(t, false,
+			setupWithCache(c),
+			setupWithAdminToken(tc.adminToken),
+		)
+		testServer := httptest.NewServer(server)
+
+		t.Run(desc, func(t *testing.T) {
+			defer testServer.Close()
+
+			url := fmt.Sprintf("%s/admin/environments/%s/targets%s", testServer.URL, envID123, tc.query)
+			req, err := http.NewRequest(http.MethodGet, url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", tc.authHeader)
+
+			resp, err := testServer.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.expectedStatusCode, resp.StatusCode)
+			assert.Equal(t, tc.expectedContentType, resp.Header.Get("Content-Type"))
+
+			actual, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("(%s): failed to read response body: %s", desc, err)
+			}
+			assert.Equal(t, tc.expectedResponseBody, string(actual))
+		})
+	}
+}
+
+func TestHTTPServer_AuditHistory(t *testing.T) {
+	const adminToken = "admin-token"
+
+	history := audit.NewHistory(10)
+	oldVersion, newVersion := int64(1), int64(2)
+	assert.Nil(t, history.Write(domain.AuditRecord{
+		Time:        time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Source:      domain.AuditSourceSSE,
+		Event:       domain.EventPatch,
+		Action:      domain.AuditActionPatch,
+		Kind:        domain.AuditKindFlag,
+		Environment: envID123,
+		Identifier:  "dark-mode",
+		OldVersion:  &oldVersion,
+		NewVersion:  &newVersion,
+		Diff:        "state changed from off to on",
+	}))
+	assert.Nil(t, history.Write(domain.AuditRecord{
+		Time:        time.Date(2024, 1, 1, 0, 0, 1, 0, time.UTC),
+		Source:      domain.AuditSourcePoll,
+		Action:      domain.AuditActionDelete,
+		Kind:        domain.AuditKindSegment,
+		Environment: "other-env",
+		Identifier:  "beta-users",
+	}))
+
+	testCases := map[string]struct {
+		auditHistory         func(req domain.AuditHistoryRequest) []domain.AuditRecord
+		query                string
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		"Given auditing is disabled": {
+			auditHistory:         nil,
+			expectedStatusCode:   http.StatusNotImplemented,
+			expectedResponseBody: `{"error":"endpoint not implemented: audit history is disabled"}` + "\n",
+		},
+		"Given I make a request with an invalid limit": {
+			auditHistory:         history.Recent,
+			query:                "?limit=abc",
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"bad request: limit must be a positive integer"}` + "\n",
+		},
+		"Given I make a request with no filters": {
+			auditHistory:         history.Recent,
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{"records":[{"time":"2024-01-01T00:00:01Z","source":"poll","action":"delete","kind":"segment","environment":"other-env","identifier":"beta-users"},{"time":"2024-01-01T00:00:00Z","source":"sse","event":"patch","action":"patch","kind":"flag","environment":"` + envID123 + `","identifier":"dark-mode","oldVersion":1,"newVersion":2,"diff":"state changed from off to on"}]}` + "\n",
+		},
+		"Given I make a request for an environment": {
+			auditHistory:         history.Recent,
+			query:                "?environment=other-env",
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{"records":[{"time":"2024-01-01T00:00:01Z","source":"poll","action":"delete","kind":"segment","environment":"other-env","identifier":"beta-users"}]}` + "\n",
+		},
+	}
+
+	for desc, tc := range testCases {
+		tc := tc
+
+		server := setupHTTPServer(t, false,
+			setupWithAdminToken(adminToken),
+			setupWithAuditHistory(tc.auditHistory),
+		)
+		testServer := httptest.NewServer(server)
+
+		t.Run(desc, func(t *testing.T) {
+			defer testServer.Close()
+
+			req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/admin/audit%s", testServer.URL, tc.query), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+adminToken)
+
+			resp, err := testServer.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.expectedStatusCode, resp.StatusCode)
+
+			actual, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("(%s): failed to read response body: %s", desc, err)
+			}
+			assert.Equal(t, tc.expectedResponseBody, string(actual))
+		})
+	}
+}
+
+// mockGenerationPinner pins environments between generations 1 and 2
+type mockGenerationPinner struct {
+	gen *domain.ConfigGeneration
+}
+
+func (m mockGenerationPinner) ConfigGeneration(_ context.Context, envID string) (domain.ConfigGeneration, error) {
+	if envID != envID123 {
+		return domain.ConfigGeneration{}, domain.ErrCacheNotFound
+	}
+	return *m.gen, nil
+}
+
+func (m mockGenerationPinner) Pin(_ context.Context, _ string, generation int64) (domain.ConfigGeneration, error) {
+	if generation == 0 {
+		generation = 1
+	}
+	if !m.gen.Kept(generation) {
+		return domain.ConfigGeneration{}, fmt.Errorf("%w: %d", domain.ErrGenerationNotFound, generation)
+	}
+	m.gen.Current, m.gen.Pinned = generation, true
+	return *m.gen, nil
+}
+
+func (m mockGenerationPinner) Release(_ context.Context, _ string) (domain.ConfigGeneration, error) {
+	if !m.gen.Pinned {
+		return domain.ConfigGeneration{}, domain.ErrEnvironmentNotPinned
+	}
+	m.gen.Current, m.gen.Pinned = m.gen.Latest(), false
+	return *m.gen, nil
+}
+
+func TestHTTPServer_Generations(t *testing.T) {
+	const adminToken = "admin-token"
+
+	testCases := map[string]struct {
+		generations          proxyservice.GenerationPinner
+		method               string
+		path                 string
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		"Given generations aren't enabled": {
+			generations:          nil,
+			method:               http.MethodGet,
+			path:                 "/generations",
+			expectedStatusCode:   http.StatusNotImplemented,
+			expectedResponseBody: `{"error":"endpoint not implemented: config generations can only be managed by a Primary Proxy with them enabled"}` + "\n",
+		},
+		"Given I get the generations for an environment": {
+			generations:          mockGenerationPinner{gen: &domain.ConfigGeneration{Current: 2, History: []int64{2, 1}, UpdatedAt: 100}},
+			method:               http.MethodGet,
+			path:                 "/generations",
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{"current":2,"history":[2,1],"updatedAt":100}` + "\n",
+		},
+		"Given I pin an environment to its previous generation": {
+			generations:          mockGenerationPinner{gen: &domain.ConfigGeneration{Current: 2, History: []int64{2, 1}, UpdatedAt: 100}},
+			method:               http.MethodPost,
+			path:                 "/generations/pin",
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{"current":1,"history":[2,1],"pinned":true,"updatedAt":100}` + "\n",
+		},
+		"Given I pin an environment to a generation that isn't kept": {
+			generations:          mockGenerationPinner{gen: &domain.ConfigGeneration{Current: 2, History: []int64{2, 1}, UpdatedAt: 100}},
+			method:               http.MethodPost,
+			path:                 "/generations/pin?generation=7",
+			expectedStatusCode:   http.StatusNotFound,
+			expectedResponseBody: `{"error":"not found: generation not found: 7"}` + "\n",
+		},
+		"Given I pin an environment to an invalid generation": {
+			generations:          mockGenerationPinner{gen: &domain.ConfigGeneration{Current: 2, History: []int64{2, 1}, UpdatedAt: 100}},
+			method:               http.MethodPost,
+			path:                 "/generations/pin?generation=abc",
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"bad request: generation must be a positive integer"}` + "\n",
+		},
+		"Given I release a pinned environment": {
+			generations:          mockGenerationPinner{gen: &domain.ConfigGeneration{Current: 1, History: []int64{2, 1}, Pinned: true, UpdatedAt: 100}},
+			method:               http.MethodDelete,
+			path:                 "/generations/pin",
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{"current":2,"history":[2,1],"updatedAt":100}` + "\n",
+		},
+		"Given I release an environment that isn't pinned": {
+			generations:          mockGenerationPinner{gen: &domain.ConfigGeneration{Current: 2, History: []int64{2, 1}, UpdatedAt: 100}},
+			method:               http.MethodDelete,
+			path:                 "/generations/pin",
+			expectedStatusCode:   http.StatusNotFound,
+			expectedResponseBody: `{"error":"not found: environment isn't pinned"}` + "\n",
+		},
+	}
+
+	for desc, tc := range testCases {
+		tc := tc
+
+		server := setupHTTPServer(t, false,
+			setupWithAdminToken(adminToken),
+			setupWithGenerations(tc.generations),
+		)
+		testServer := httptest.NewServer(server)
+
+		t.Run(desc, func(t *testing.T) {
+			defer testServer.Close()
+
+			url := fmt.Sprintf("%s/admin/environments/%s%s", testServer.URL, envID123, tc.path)
+			req, err := http.NewRequest(tc.method, url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+adminToken)
+
+			resp, err := testServer.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.expectedStatusCode, resp.StatusCode)
+
+			actual, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("(%s): failed to read response body: %s", desc, err)
+			}
+			assert.Equal(t, tc.expectedResponseBody, string(actual))
+		})
+	}
+}
+
 func TestHTTPServer_Stream(t *testing.T) {
 	const (
 		apiKey       = "apikey1"
@@ -1568,6 +2160,30 @@ func TestHTTPServer_WithCustomHandler(t *testing.T) {
 			},
 			shouldErr: true,
 		},
+		"Given I try to register a custom handler on /health/live": {
+			args: args{
+				method:  http.MethodGet,
+				route:   domain.LivenessRoute,
+				handler: nil,
+			},
+			shouldErr: true,
+		},
+		"Given I try to register a custom handler on /health/ready": {
+			args: args{
+				method:  http.MethodGet,
+				route:   domain.ReadinessRoute,
+				handler: nil,
+			},
+			shouldErr: true,
+		},
+		"Given I try to register a custom handler on /health/startup": {
+			args: args{
+				method:  http.MethodGet,
+				route:   domain.StartupRoute,
+				handler: nil,
+			},
+			shouldErr: true,
+		},
 		"Given I try to register a custom handler on /feature-configs": {
 			args: args{
 				method:  http.MethodGet,
@@ -0,0 +1,65 @@
+package transport
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// WithTrustedProxies makes the HTTPServer take the client IP from the
+// X-Forwarded-For header when a request comes from one of the trusted ranges.
+// Without any trusted proxies the client IP is always the address that made
+// the request, otherwise any client could pick its own IP by setting the header.
+func WithTrustedProxies(ranges []*net.IPNet) func(h *HTTPServer) {
+	return func(h *HTTPServer) {
+		if len(ranges) == 0 {
+			return
+		}
+
+		// echo trusts loopback, link local and private addresses by default so
+		// we turn those off and only trust the ranges we've been given
+		opts := []echo.TrustOption{
+			echo.TrustLoopback(false),
+			echo.TrustLinkLocal(false),
+			echo.TrustPrivateNet(false),
+		}
+		for _, r := range ranges {
+			opts = append(opts, echo.TrustIPRange(r))
+		}
+		h.router.IPExtractor = echo.ExtractIPFromXFFHeader(opts...)
+	}
+}
+
+// ParseTrustedProxies parses a comma separated list of IPs and CIDR ranges
+func ParseTrustedProxies(s string) ([]*net.IPNet, error) {
+	ranges := []*net.IPNet{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		if !strings.Contains(part, "/") {
+			ip := net.ParseIP(part)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q, expected an IP or CIDR range", part)
+			}
+
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			ranges = append(ranges, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q, expected an IP or CIDR range", part)
+		}
+		ranges = append(ranges, ipNet)
+	}
+	return ranges, nil
+}
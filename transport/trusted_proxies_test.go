@@ -0,0 +1,97 @@
+package transport
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/harness/ff-proxy/v2/log"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	testCases := map[string]struct {
+		input       string
+		expected    []string
+		shouldError bool
+	}{
+		"Given I have an empty string": {
+			input:    "",
+			expected: []string{},
+		},
+		"Given I have IPs and CIDR ranges": {
+			input:    "10.0.0.0/8, 192.0.2.1,2001:db8::1",
+			expected: []string{"10.0.0.0/8", "192.0.2.1/32", "2001:db8::1/128"},
+		},
+		"Given I have an invalid IP": {
+			input:       "10.0.0.300",
+			shouldError: true,
+		},
+		"Given I have an invalid CIDR range": {
+			input:       "10.0.0.0/33",
+			shouldError: true,
+		},
+	}
+
+	for desc, tc := range testCases {
+		tc := tc
+		t.Run(desc, func(t *testing.T) {
+			ranges, err := ParseTrustedProxies(tc.input)
+			if tc.shouldError {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+
+			actual := make([]string, 0, len(ranges))
+			for _, r := range ranges {
+				actual = append(actual, r.String())
+			}
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestHTTPServer_ClientIP(t *testing.T) {
+	_, trusted, _ := net.ParseCIDR("10.0.0.0/8")
+
+	testCases := map[string]struct {
+		opts       []func(h *HTTPServer)
+		remoteAddr string
+		expected   string
+	}{
+		"Given I haven't configured any trusted proxies": {
+			remoteAddr: "10.1.2.3:1234",
+			expected:   "10.1.2.3",
+		},
+		"Given the request comes from a trusted proxy": {
+			opts:       []func(h *HTTPServer){WithTrustedProxies([]*net.IPNet{trusted})},
+			remoteAddr: "10.1.2.3:1234",
+			expected:   "203.0.113.7",
+		},
+		"Given the request doesn't come from a trusted proxy": {
+			opts:       []func(h *HTTPServer){WithTrustedProxies([]*net.IPNet{trusted})},
+			remoteAddr: "192.168.1.1:1234",
+			expected:   "192.168.1.1",
+		},
+	}
+
+	for desc, tc := range testCases {
+		tc := tc
+		t.Run(desc, func(t *testing.T) {
+			h := NewHTTPServer(0, &Endpoints{}, log.NoOpLogger{}, false, "", "", tc.opts...)
+
+			req := httptest.NewRequest(http.MethodGet, "/client/auth", nil)
+			req.RemoteAddr = tc.remoteAddr
+			req.Header.Set(echo.HeaderXForwardedFor, "203.0.113.7")
+			req.Header.Set(echo.HeaderXRealIP, "203.0.113.8")
+
+			t.Log("Then the client IP is only taken from the X-Forwarded-For header for trusted proxies")
+			c := h.router.NewContext(req, httptest.NewRecorder())
+			assert.Equal(t, tc.expected, c.RealIP())
+		})
+	}
+}